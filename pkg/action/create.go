@@ -146,7 +146,7 @@ func (o *CreateOptions) Complete() error {
 
 // Run execute command. the options of parameter contain the command flags and args.
 func (o *CreateOptions) Run() error {
-	resObj, err := o.buildResourceObj()
+	resObj, err := o.BuildResourceObj()
 	if err != nil {
 		return err
 	}
@@ -228,7 +228,8 @@ func (o *CreateOptions) CleanUp() error {
 	return nil
 }
 
-func (o *CreateOptions) buildResourceObj() (*unstructured.Unstructured, error) {
+// BuildResourceObj renders the cue template with the options to the resource object to be created.
+func (o *CreateOptions) BuildResourceObj() (*unstructured.Unstructured, error) {
 	var (
 		cueValue    cue.Value
 		err         error
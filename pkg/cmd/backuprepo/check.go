@@ -0,0 +1,522 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package backuprepo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/yaml"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	storagev1alpha1 "github.com/apecloud/kubeblocks/apis/storage/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	dptypes "github.com/apecloud/kubeblocks/pkg/dataprotection/types"
+	dputils "github.com/apecloud/kubeblocks/pkg/dataprotection/utils"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+const (
+	checkContainerName  = "check"
+	checkRepoMountPath  = "/backupdata"
+	checkStepLinePrefix = "STEP "
+)
+
+// checkSteps are the steps of the probe in order, a step is skipped if the object is not written.
+var checkSteps = []string{"write", "read", "list", "delete"}
+
+// checkScript probes the backup repo by datasafed, the directory and the object size in bytes are
+// passed as the arguments. Each step is reported in a line: STEP <name> <pass|fail> <millis> <bytes> <message>.
+var checkScript = fmt.Sprintf(`export PATH="$PATH:$%s"
+dir="$1"; size="$2"
+now() { awk '{printf "%%d\n", $1 * 1000}' /proc/uptime; }
+step() {
+  name="$1"; bytes="$2"; shift 2
+  start=$(now)
+  if out=$("$@" 2>&1); then result=pass; else result=fail; fi
+  echo "STEP $name $result $(( $(now) - start )) $bytes $(echo "$out" | tr '\n' ' ')"
+  [ "$result" = pass ]
+}
+write_object() { datasafed push - "$dir/probe" < /tmp/probe; }
+read_object() {
+  [ "$(datasafed pull "$dir/probe" - | sha256sum | cut -d' ' -f1)" = "$sum" ] || { echo "the read object does not match the written object"; return 1; }
+}
+list_object() { datasafed list "$dir" | grep -q probe || { echo "the written object is not listed"; return 1; }; }
+delete_object() { datasafed rm -r "$dir"; }
+head -c "$size" /dev/urandom > /tmp/probe
+sum=$(sha256sum /tmp/probe | cut -d' ' -f1)
+if step write "$size" write_object; then
+  step read "$size" read_object
+  step list 0 list_object
+  step delete 0 delete_object
+fi
+exit 0`, dptypes.DPDatasafedBinPath)
+
+var checkExample = templates.Examples(`
+	# Check the connectivity and performance of a backup repo
+	kbcli backuprepo check my-backuprepo
+
+	# Check the backup repo with a 64MiB test object
+	kbcli backuprepo check my-backuprepo --object-size 64Mi
+	`)
+
+// checkStepResult is the result of a probe step.
+type checkStepResult struct {
+	Name    string
+	Result  string
+	Latency time.Duration
+	Bytes   int64
+	Message string
+}
+
+type checkOptions struct {
+	genericiooptions.IOStreams
+	factory   cmdutil.Factory
+	client    kubernetes.Interface
+	dynamic   dynamic.Interface
+	namespace string
+
+	name       string
+	image      string
+	objectSize string
+	timeout    time.Duration
+
+	repo     *dpv1alpha1.BackupRepo
+	provider *storagev1alpha1.StorageProvider
+	size     int64
+
+	// runProbeJob runs the probe job and returns the logs of its pod
+	runProbeJob func(job *batchv1.Job) (string, error)
+}
+
+func newCheckOptions(f cmdutil.Factory, streams genericiooptions.IOStreams) *checkOptions {
+	o := &checkOptions{
+		IOStreams:  streams,
+		factory:    f,
+		objectSize: "16Mi",
+		timeout:    5 * time.Minute,
+	}
+	o.runProbeJob = o.runJob
+	return o
+}
+
+func newCheckCommand(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := newCheckOptions(f, streams)
+	cmd := &cobra.Command{
+		Use:               "check NAME",
+		Short:             "Check the connectivity and performance of a backup repo.",
+		Example:           checkExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.BackupRepoGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.complete(args))
+			cmdutil.CheckErr(o.validate())
+			cmdutil.CheckErr(o.run())
+		},
+	}
+	addCheckFlags(cmd, o, "")
+	return cmd
+}
+
+// addCheckFlags adds the flags of the probe, the prefix distinguishes them from the flags of other commands.
+func addCheckFlags(cmd *cobra.Command, o *checkOptions, prefix string) {
+	cmd.Flags().StringVar(&o.image, prefix+"image", o.image, "The image of the probe job, which contains the sh, awk and sha256sum commands, defaults to the tools image of the installed KubeBlocks")
+	cmd.Flags().StringVar(&o.objectSize, prefix+"object-size", o.objectSize, "The size of the test object written to the backup repo")
+	cmd.Flags().DurationVar(&o.timeout, prefix+"timeout", o.timeout, "The time to wait for the probe job to complete")
+}
+
+func (o *checkOptions) complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return fmt.Errorf("must specify a backuprepo name")
+	}
+	o.name = args[0]
+	if o.client, err = o.factory.KubernetesClientSet(); err != nil {
+		return err
+	}
+	if o.dynamic, err = o.factory.DynamicClient(); err != nil {
+		return err
+	}
+	if o.namespace, _, err = o.factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *checkOptions) validate() error {
+	size, err := resource.ParseQuantity(o.objectSize)
+	if err != nil {
+		return fmt.Errorf("invalid object size %q: %v", o.objectSize, err)
+	}
+	if o.size = size.Value(); o.size <= 0 {
+		return fmt.Errorf("the object size must be positive")
+	}
+	return nil
+}
+
+func (o *checkOptions) run() error {
+	if err := o.waitForRepo(); err != nil {
+		return err
+	}
+	if o.image == "" {
+		image, err := util.GetKubeBlocksToolsImage(o.client)
+		if err != nil {
+			return fmt.Errorf("%v, please specify the image of the probe job", err)
+		}
+		o.image = image
+	}
+	o.provider = &storagev1alpha1.StorageProvider{}
+	if err := util.GetK8SClientObject(o.dynamic, o.provider, types.StorageProviderGVR(), "", o.repo.Spec.StorageProviderRef); err != nil {
+		return fmt.Errorf("failed to get the storage provider %s: %v", o.repo.Spec.StorageProviderRef, err)
+	}
+	fmt.Fprintf(o.Out, "Checking backup repo %s (provider: %s, access method: %s, phase: %s) in namespace %s\n",
+		o.repo.Name, o.repo.Spec.StorageProviderRef, accessMethodOf(o.repo), o.repo.Status.Phase, o.namespace)
+
+	name := "kbcli-check-" + rand.String(5)
+	job := o.buildProbeJob(name)
+	cleanup, err := o.prepareRepoAccess(name, &job.Spec.Template.Spec)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+	logs, err := o.runProbeJob(job)
+	if err != nil {
+		return err
+	}
+	steps := parseCheckSteps(logs)
+	if len(steps) == 0 {
+		return fmt.Errorf("no step of the probe is reported, logs of the probe job:\n%s", logs)
+	}
+	return o.printCheckSteps(steps)
+}
+
+// waitForRepo waits for KubeBlocks to finish its own pre-check of the repo, the generated storage
+// class of the mount access method is available after that.
+func (o *checkOptions) waitForRepo() error {
+	o.repo = &dpv1alpha1.BackupRepo{}
+	err := wait.PollUntilContextTimeout(context.Background(), time.Second, o.timeout, true, func(_ context.Context) (bool, error) {
+		if err := util.GetK8SClientObject(o.dynamic, o.repo, types.BackupRepoGVR(), "", o.name); err != nil {
+			return false, err
+		}
+		return o.repo.Status.Phase != "" && o.repo.Status.Phase != dpv1alpha1.BackupRepoPreChecking, nil
+	})
+	if wait.Interrupted(err) {
+		return fmt.Errorf("timed out waiting for the pre-check of backup repo %s to finish", o.name)
+	}
+	if err != nil {
+		return err
+	}
+	switch o.repo.Status.Phase {
+	case dpv1alpha1.BackupRepoDeleting:
+		return fmt.Errorf("backup repo %s is being deleted", o.name)
+	case dpv1alpha1.BackupRepoFailed:
+		return fmt.Errorf("backup repo %s failed the pre-check of KubeBlocks, %s", o.name, failedConditionMessages(o.repo))
+	}
+	return nil
+}
+
+// failedConditionMessages returns the messages of the failed conditions reported by KubeBlocks.
+func failedConditionMessages(repo *dpv1alpha1.BackupRepo) string {
+	var messages []string
+	for _, cond := range repo.Status.Conditions {
+		if cond.Status == metav1.ConditionFalse {
+			messages = append(messages, fmt.Sprintf("%s: %s", cond.Type, cond.Message))
+		}
+	}
+	if len(messages) == 0 {
+		return "no failed condition is reported"
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (o *checkOptions) buildProbeJob(name string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: o.namespace,
+			Labels: map[string]string{
+				constant.AppManagedByLabelKey: "kbcli",
+				associatedBackupRepoKey:       o.repo.Name,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          pointer.Int32(0),
+			ActiveDeadlineSeconds: pointer.Int64(int64(o.timeout.Seconds())),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    checkContainerName,
+						Image:   o.image,
+						Command: []string{"sh", "-c", checkScript, "datasafed", "/" + name, strconv.FormatInt(o.size, 10)},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// prepareRepoAccess creates the temporary tool config secret or PVC of the repo in the namespace
+// as the KubeBlocks pre-check does, and injects datasafed into the pod spec. The returned function
+// removes the created objects.
+func (o *checkOptions) prepareRepoAccess(name string, podSpec *corev1.PodSpec) (func(), error) {
+	cleanup := func() {}
+	renderCtx := checkRenderContext{GeneratedStorageClassName: o.repo.Status.GeneratedStorageClassName}
+	if o.repo.Status.GeneratedCSIDriverSecret != nil {
+		renderCtx.CSIDriverSecretRef = *o.repo.Status.GeneratedCSIDriverSecret
+	}
+	var err error
+	if renderCtx.Parameters, err = o.collectParameters(); err != nil {
+		return cleanup, err
+	}
+	labels := map[string]string{constant.AppManagedByLabelKey: "kbcli", associatedBackupRepoKey: o.repo.Name}
+
+	if o.repo.AccessByTool() {
+		if o.provider.Spec.DatasafedConfigTemplate == "" {
+			return cleanup, fmt.Errorf("storage provider %s doesn't support the Tool access method", o.provider.Name)
+		}
+		content, err := renderCheckTemplate("tool-config", o.provider.Spec.DatasafedConfigTemplate, renderCtx)
+		if err != nil {
+			return cleanup, fmt.Errorf("failed to render the tool config template: %v", err)
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: o.namespace, Labels: labels},
+			Data:       map[string][]byte{"datasafed.conf": []byte(content)},
+		}
+		if _, err = o.client.CoreV1().Secrets(o.namespace).Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+			return cleanup, fmt.Errorf("failed to create the tool config secret: %v", err)
+		}
+		dputils.InjectDatasafedWithConfig(podSpec, name, "")
+		return func() {
+			o.deleteObject("secret", name, o.client.CoreV1().Secrets(o.namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}))
+		}, nil
+	}
+
+	if o.repo.Status.GeneratedStorageClassName == "" {
+		return cleanup, fmt.Errorf("the storage class of backup repo %s is not generated, please check the repo by \"kbcli backuprepo describe %s\"", o.repo.Name, o.repo.Name)
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if o.provider.Spec.PersistentVolumeClaimTemplate != "" {
+		content, err := renderCheckTemplate("pvc", o.provider.Spec.PersistentVolumeClaimTemplate, renderCtx)
+		if err != nil {
+			return cleanup, fmt.Errorf("failed to render the PVC template: %v", err)
+		}
+		if err = yaml.Unmarshal([]byte(content), pvc); err != nil {
+			return cleanup, fmt.Errorf("failed to unmarshal the PVC object: %v", err)
+		}
+	} else {
+		pvc.Spec.StorageClassName = &o.repo.Status.GeneratedStorageClassName
+	}
+	pvc.Name = name
+	pvc.Namespace = o.namespace
+	pvc.Labels = labels
+	if len(pvc.Spec.AccessModes) == 0 {
+		pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	}
+	if pvc.Spec.Resources.Requests.Storage().IsZero() {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: o.repo.Spec.VolumeCapacity}
+	}
+	if _, err = o.client.CoreV1().PersistentVolumeClaims(o.namespace).Create(context.TODO(), pvc, metav1.CreateOptions{}); err != nil {
+		return cleanup, fmt.Errorf("failed to create the PVC: %v", err)
+	}
+	dputils.InjectDatasafedWithPVC(podSpec, name, checkRepoMountPath, "")
+	return func() {
+		o.deleteObject("PVC", name, o.client.CoreV1().PersistentVolumeClaims(o.namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}))
+	}, nil
+}
+
+// collectParameters collects the parameters of the repo from its config and credential.
+func (o *checkOptions) collectParameters() (map[string]string, error) {
+	values := map[string]string{}
+	for k, v := range o.repo.Spec.Config {
+		values[k] = v
+	}
+	if o.repo.Spec.Credential == nil {
+		return values, nil
+	}
+	secret, err := o.client.CoreV1().Secrets(o.repo.Spec.Credential.Namespace).Get(context.TODO(), o.repo.Spec.Credential.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the credential secret of backup repo %s: %v", o.repo.Name, err)
+	}
+	for k, v := range secret.Data {
+		values[k] = string(v)
+	}
+	return values, nil
+}
+
+func (o *checkOptions) deleteObject(kind, name string, err error) {
+	if err != nil && !apierrors.IsNotFound(err) {
+		fmt.Fprintf(o.ErrOut, "failed to delete %s %s: %v\n", kind, name, err)
+	}
+}
+
+// runJob creates the job, waits for it to finish, and returns the logs of its pod.
+func (o *checkOptions) runJob(job *batchv1.Job) (string, error) {
+	jobs := o.client.BatchV1().Jobs(o.namespace)
+	if _, err := jobs.Create(context.TODO(), job, metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to create the probe job: %v", err)
+	}
+	defer func() {
+		policy := metav1.DeletePropagationBackground
+		o.deleteObject("job", job.Name, jobs.Delete(context.TODO(), job.Name, metav1.DeleteOptions{PropagationPolicy: &policy}))
+	}()
+	fmt.Fprintf(o.Out, "Waiting for the probe job %s to complete, timeout: %s\n", job.Name, o.timeout)
+	err := wait.PollUntilContextTimeout(context.Background(), time.Second, o.timeout, true, func(_ context.Context) (bool, error) {
+		obj, err := jobs.Get(context.TODO(), job.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return obj.Status.Succeeded > 0 || obj.Status.Failed > 0, nil
+	})
+	if wait.Interrupted(err) {
+		return "", fmt.Errorf("timed out waiting for the probe job %s to complete, the backup repo may be inaccessible from namespace %s", job.Name, o.namespace)
+	}
+	if err != nil {
+		return "", err
+	}
+	pods, err := o.client.CoreV1().Pods(o.namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: "job-name=" + job.Name})
+	if err != nil {
+		return "", err
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("the pod of the probe job %s is not found", job.Name)
+	}
+	logs, err := o.client.CoreV1().Pods(o.namespace).GetLogs(pods.Items[0].Name, &corev1.PodLogOptions{Container: checkContainerName}).DoRaw(context.TODO())
+	if err != nil {
+		return "", fmt.Errorf("failed to get the logs of the probe job: %v", err)
+	}
+	return string(logs), nil
+}
+
+// parseCheckSteps parses the step lines in the logs of the probe, the steps which are not reported are skipped.
+func parseCheckSteps(logs string) []checkStepResult {
+	reported := map[string]checkStepResult{}
+	scanner := bufio.NewScanner(bytes.NewBufferString(logs))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, checkStepLinePrefix) {
+			continue
+		}
+		fields := strings.SplitN(strings.TrimPrefix(line, checkStepLinePrefix), " ", 5)
+		if len(fields) < 4 {
+			continue
+		}
+		millis, _ := strconv.ParseInt(fields[2], 10, 64)
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		step := checkStepResult{Name: fields[0], Result: fields[1], Latency: time.Duration(millis) * time.Millisecond, Bytes: size}
+		if len(fields) == 5 {
+			step.Message = strings.TrimSpace(fields[4])
+		}
+		reported[step.Name] = step
+	}
+	if len(reported) == 0 {
+		return nil
+	}
+	var steps []checkStepResult
+	for _, name := range checkSteps {
+		step, ok := reported[name]
+		if !ok {
+			step = checkStepResult{Name: name, Result: "skip"}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func (o *checkOptions) printCheckSteps(steps []checkStepResult) error {
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("STEP", "RESULT", "LATENCY", "THROUGHPUT", "ERROR")
+	passed := true
+	for _, step := range steps {
+		latency, throughput, message := "", "", ""
+		switch step.Result {
+		case "pass":
+			latency = step.Latency.String()
+			if step.Bytes > 0 {
+				millis := step.Latency.Milliseconds()
+				if millis == 0 {
+					millis = 1
+				}
+				throughput = humanize.IBytes(uint64(step.Bytes*1000/millis)) + "/s"
+			}
+		case "fail":
+			passed = false
+			latency = step.Latency.String()
+			message = step.Message
+		}
+		tbl.AddRow(step.Name, step.Result, latency, throughput, message)
+	}
+	tbl.Print()
+	if !passed {
+		return fmt.Errorf("backup repo %s failed the check", o.repo.Name)
+	}
+	fmt.Fprintf(o.Out, "Backup repo %s passed the check.\n", o.repo.Name)
+	return nil
+}
+
+// checkRenderContext is the context to render the templates of the storage provider,
+// it is the same as the one used by KubeBlocks.
+type checkRenderContext struct {
+	Parameters                map[string]string
+	CSIDriverSecretRef        corev1.SecretReference
+	GeneratedStorageClassName string
+}
+
+func renderCheckTemplate(name, tpl string, renderCtx checkRenderContext) (string, error) {
+	t, err := template.New(name).Funcs(sprig.TxtFuncMap()).Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	err = t.Execute(&b, renderCtx)
+	return b.String(), err
+}
+
+func accessMethodOf(repo *dpv1alpha1.BackupRepo) dpv1alpha1.AccessMethod {
+	if repo.AccessByTool() {
+		return dpv1alpha1.AccessMethodTool
+	}
+	return dpv1alpha1.AccessMethodMount
+}
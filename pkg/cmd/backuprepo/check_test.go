@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package backuprepo
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	storagev1alpha1 "github.com/apecloud/kubeblocks/apis/storage/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+)
+
+var _ = Describe("backuprepo check command", func() {
+	const (
+		repoName  = "test-backuprepo"
+		passedLog = "STEP write pass 200 16777216 \nSTEP read pass 100 16777216 \nSTEP list pass 10 0 \nSTEP delete pass 20 0 \n"
+	)
+
+	var (
+		out  *bytes.Buffer
+		tf   *cmdtesting.TestFactory
+		repo *dpv1alpha1.BackupRepo
+	)
+
+	BeforeEach(func() {
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		repo = testing.FakeBackupRepo(repoName, false)
+		repo.Spec.AccessMethod = dpv1alpha1.AccessMethodTool
+		repo.Spec.Config = map[string]string{"bucket": "test-bucket"}
+		repo.Spec.Credential = &corev1.SecretReference{Name: "test-credential", Namespace: testing.Namespace}
+		repo.Status.Phase = dpv1alpha1.BackupRepoReady
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	newOptions := func(logs string) *checkOptions {
+		provider := testing.FakeStorageProvider("fake-storage-provider", func(obj *storagev1alpha1.StorageProvider) {
+			obj.Spec.DatasafedConfigTemplate = `bucket = {{ index .Parameters "bucket" }}
+access_key_id = {{ index .Parameters "accessKeyId" | upper }}`
+		})
+		tf.FakeDynamicClient = testing.FakeDynamicClient(repo, provider)
+		var streams genericiooptions.IOStreams
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		o := newCheckOptions(tf, streams)
+		Expect(o.complete([]string{repoName})).Should(Succeed())
+		Expect(o.validate()).Should(Succeed())
+		kbDeploy := testing.FakeKBDeploy("0.8.0")
+		kbDeploy.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "manager",
+			Env:  []corev1.EnvVar{{Name: "KUBEBLOCKS_TOOLS_IMAGE", Value: "apecloud/kubeblocks-tools:0.8.0"}},
+		}}
+		o.client = testing.FakeClientSet(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-credential", Namespace: testing.Namespace},
+			Data:       map[string][]byte{"accessKeyId": []byte("key")},
+		}, kbDeploy)
+		o.runProbeJob = func(job *batchv1.Job) (string, error) {
+			Expect(job.Namespace).Should(Equal(testing.Namespace))
+			Expect(job.Spec.Template.Spec.Containers[0].Image).Should(Equal("apecloud/kubeblocks-tools:0.8.0"))
+			Expect(job.Spec.Template.Spec.Containers[0].Command).Should(ContainElement("16777216"))
+			volumes := job.Spec.Template.Spec.Volumes
+			switch {
+			case repo.AccessByTool():
+				Expect(volumes).Should(ContainElement(HaveField("VolumeSource.Secret.SecretName", job.Name)))
+				secret, err := o.client.CoreV1().Secrets(testing.Namespace).Get(context.TODO(), job.Name, metav1.GetOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(secret.Data["datasafed.conf"])).Should(Equal("bucket = test-bucket\naccess_key_id = KEY"))
+			default:
+				Expect(volumes).Should(ContainElement(HaveField("VolumeSource.PersistentVolumeClaim.ClaimName", job.Name)))
+				pvc, err := o.client.CoreV1().PersistentVolumeClaims(testing.Namespace).Get(context.TODO(), job.Name, metav1.GetOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*pvc.Spec.StorageClassName).Should(Equal(repo.Status.GeneratedStorageClassName))
+			}
+			return logs, nil
+		}
+		return o
+	}
+
+	It("parse the steps of the probe", func() {
+		Expect(parseCheckSteps("installing datasafed\n")).Should(BeEmpty())
+		steps := parseCheckSteps("STEP write fail 1500 16777216 AccessDenied: access denied \n")
+		Expect(steps).Should(HaveLen(4))
+		Expect(steps[0].Result).Should(Equal("fail"))
+		Expect(steps[0].Message).Should(Equal("AccessDenied: access denied"))
+		Expect(steps[0].Latency.Seconds()).Should(Equal(1.5))
+		Expect(steps[1].Result).Should(Equal("skip"))
+	})
+
+	It("check the backup repo accessed by tool", func() {
+		Expect(newCheckCommand(tf, genericiooptions.NewTestIOStreamsDiscard())).ShouldNot(BeNil())
+		o := newOptions(passedLog)
+		Expect(o.run()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`write\s+pass\s+200ms\s+80 MiB/s`))
+		Expect(out.String()).Should(MatchRegexp(`delete\s+pass\s+20ms`))
+		Expect(out.String()).Should(ContainSubstring("passed the check"))
+
+		By("the temporary tool config secret is removed")
+		secrets, err := o.client.CoreV1().Secrets(testing.Namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(secrets.Items).Should(HaveLen(1))
+
+		By("report the failed steps")
+		o = newOptions("STEP write pass 200 16777216 \nSTEP read fail 100 16777216 the read object does not match the written object \n")
+		Expect(o.run()).Should(MatchError(ContainSubstring("failed the check")))
+		Expect(out.String()).Should(MatchRegexp(`read\s+fail\s+100ms\s+the read object does not match`))
+		Expect(out.String()).Should(MatchRegexp(`list\s+skip`))
+
+		By("report the logs if no step is reported")
+		o = newOptions("sh: datasafed: not found\n")
+		Expect(o.run()).Should(MatchError(ContainSubstring("datasafed: not found")))
+	})
+
+	It("check the backup repo accessed by mount", func() {
+		repo.Spec.AccessMethod = dpv1alpha1.AccessMethodMount
+		Expect(newOptions(passedLog).run()).Should(MatchError(ContainSubstring("storage class of backup repo")))
+
+		repo.Status.GeneratedStorageClassName = "test-storage-class"
+		o := newOptions(passedLog)
+		Expect(o.run()).Should(Succeed())
+		pvcs, err := o.client.CoreV1().PersistentVolumeClaims(testing.Namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(pvcs.Items).Should(BeEmpty())
+	})
+
+	It("report the failed pre-check of KubeBlocks", func() {
+		repo.Status.Phase = dpv1alpha1.BackupRepoFailed
+		repo.Status.Conditions = []metav1.Condition{
+			{Type: "StorageProviderReady", Status: metav1.ConditionTrue},
+			{Type: "PreCheckPassed", Status: metav1.ConditionFalse, Message: "failed to write the object: AccessDenied"},
+		}
+		Expect(newOptions(passedLog).run()).Should(MatchError(ContainSubstring("PreCheckPassed: failed to write the object: AccessDenied")))
+	})
+})
@@ -86,6 +86,8 @@ type createOptions struct {
 	config          map[string]string
 	credential      map[string]string
 	allValues       map[string]interface{}
+	check           bool
+	checkOptions    *checkOptions
 }
 
 var backupRepoCreateExamples = templates.Examples(`
@@ -105,6 +107,15 @@ var backupRepoCreateExamples = templates.Examples(`
       --bucket test-kb-backup \
       --access-key-id <ACCESS KEY> \
       --secret-access-key <SECRET KEY>
+
+    # Create a backup repo and check its connectivity and performance before returning
+    kbcli backuprepo create my-backup-repo \
+      --provider s3 \
+      --region us-west-1 \
+      --bucket test-kb-backup \
+      --access-key-id <ACCESS KEY> \
+      --secret-access-key <SECRET KEY> \
+      --check
 `)
 
 func newCreateCommand(o *createOptions, f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
@@ -112,6 +123,7 @@ func newCreateCommand(o *createOptions, f cmdutil.Factory, streams genericioopti
 		o = &createOptions{}
 	}
 	o.IOStreams = streams
+	o.checkOptions = newCheckOptions(f, streams)
 	cmd := &cobra.Command{
 		Use:     "create [NAME]",
 		Short:   "Create a backup repo",
@@ -140,6 +152,8 @@ func newCreateCommand(o *createOptions, f cmdutil.Factory, streams genericioopti
 		`Specify the reclaim policy for PVs created by this backup repo, the value can be "Retain" or "Delete"`)
 	cmd.Flags().StringVar(&o.volumeCapacity, "volume-capacity", "100Gi",
 		`Specify the capacity of the new created PVC"`)
+	cmd.Flags().BoolVar(&o.check, "check", false, "Specify whether to check the connectivity and performance of the created backup repo before returning")
+	addCheckFlags(cmd, o.checkOptions, "check-")
 
 	// register flag completion func
 	registerFlagCompletionFunc(cmd, f)
@@ -293,6 +307,13 @@ func (o *createOptions) validate(cmd *cobra.Command) error {
 		return fmt.Errorf("invalid --volume-capacity \"%s\", err: %s", o.volumeCapacity, err)
 	}
 
+	// Validate the flags of the check
+	if o.check {
+		if err := o.checkOptions.validate(); err != nil {
+			return err
+		}
+	}
+
 	// Check if the repo already exists
 	if o.repoName != "" {
 		_, err := o.dynamic.Resource(types.BackupRepoGVR()).Get(
@@ -445,6 +466,12 @@ func (o *createOptions) run() error {
 	}
 
 	printer.PrintLine(fmt.Sprintf("Successfully create backup repo \"%s\".", createdBackupRepo.GetName()))
+	if o.check {
+		if err = o.checkOptions.complete([]string{createdBackupRepo.GetName()}); err != nil {
+			return err
+		}
+		return o.checkOptions.run()
+	}
 	return nil
 }
 
@@ -137,6 +137,17 @@ var _ = Describe("backuprepo create command", func() {
 			Expect(options.isDefault).Should(BeTrue())
 		})
 
+		It("should set the check fields", func() {
+			err := options.parseProviderFlags(cmd, []string{
+				"--provider", "fake-s3", "--access-key-id", "abc", "--secret-access-key", "def",
+				"--check", "--check-object-size", "1Mi",
+			}, tf)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(options.check).Should(BeTrue())
+			Expect(options.checkOptions.validate()).Should(Succeed())
+			Expect(options.checkOptions.size).Should(Equal(int64(1 << 20)))
+		})
+
 		It("should return ErrHelp if --help is specified", func() {
 			err := options.parseProviderFlags(cmd, []string{"--provider", "fake-s3", "--help"}, tf)
 			Expect(err).Should(MatchError(pflag.ErrHelp))
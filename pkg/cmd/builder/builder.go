@@ -24,6 +24,7 @@ import (
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 
+	"github.com/apecloud/kbcli/pkg/cmd/builder/config"
 	"github.com/apecloud/kbcli/pkg/cmd/builder/template"
 	"github.com/apecloud/kbcli/pkg/cmd/builder/tools"
 )
@@ -37,6 +38,7 @@ func NewBuilderCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 	cmd.AddCommand(
 		template.NewComponentTemplateRenderCmd(f, streams),
 		tools.NewMigrateHelmScriptsCmd(f, streams),
+		config.NewConfigValidateCmd(streams),
 	)
 	return cmd
 }
@@ -0,0 +1,212 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	cfgcore "github.com/apecloud/kubeblocks/pkg/configuration/core"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+// ExitCodeInvalidParameters is the exit code if any parameter is invalid, the other failures
+// such as an unreadable config constraint exit with 1, and the valid parameters exit with 0
+// even if there are warnings.
+const ExitCodeInvalidParameters = 2
+
+var configValidateExample = templates.Examples(`
+	# validate the parameters against the config constraint without connecting to a cluster
+	kbcli builder config-validate --constraint mysql-config-constraint.yaml --set max_connections=1000,innodb_autoinc_lock_mode=1
+
+	# validate the parameters and output the result in JSON format
+	kbcli builder config-validate --constraint mysql-config-constraint.yaml --set max_connections=1000 -o json`)
+
+type configValidateOptions struct {
+	genericiooptions.IOStreams
+
+	constraintFile string
+	configFile     string
+	parameters     []string
+	format         printer.Format
+}
+
+// validationResult is the result of the parameter validation
+type validationResult struct {
+	Valid  bool                  `json:"valid"`
+	Issues []util.ParameterIssue `json:"issues"`
+}
+
+func (o *configValidateOptions) run() error {
+	if o.constraintFile == "" {
+		return cfgcore.MakeError("the config constraint file is required, specify it by --constraint")
+	}
+	params, err := ParseParameters(o.parameters)
+	if err != nil {
+		return err
+	}
+	cc, err := ReadConfigConstraint(o.constraintFile)
+	if err != nil {
+		return err
+	}
+	issues, err := util.ValidateParameters(&cc.Spec, o.configFile, params)
+	if err != nil {
+		return err
+	}
+	if err = PrintParameterIssues(o.Out, issues, o.format); err != nil {
+		return err
+	}
+	return CheckParameterIssues(issues)
+}
+
+// ParseParameters parses the parameters in the format of key=value[,key=value].
+func ParseParameters(parameters []string) (map[string]*string, error) {
+	if len(parameters) == 0 {
+		return nil, cfgcore.MakeError("no parameter to validate, specify the parameters by --set")
+	}
+	params := map[string]*string{}
+	for _, param := range parameters {
+		for _, p := range strings.Split(param, ",") {
+			fields := strings.SplitN(p, "=", 2)
+			if len(fields) != 2 || fields[0] == "" {
+				return nil, cfgcore.MakeError("updated parameter format: key=value")
+			}
+			params[fields[0]] = &fields[1]
+		}
+	}
+	return params, nil
+}
+
+// ReadConfigConstraint reads the ConfigConstraint from a local YAML file.
+func ReadConfigConstraint(file string) (*appsv1alpha1.ConfigConstraint, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cc := &appsv1alpha1.ConfigConstraint{}
+	if err = yaml.Unmarshal(data, cc); err != nil {
+		return nil, cfgcore.WrapError(err, "failed to parse the config constraint file %s", file)
+	}
+	if cc.Kind != "" && cc.Kind != "ConfigConstraint" {
+		return nil, cfgcore.MakeError("the kind of %s is %s, expect ConfigConstraint", file, cc.Kind)
+	}
+	return cc, nil
+}
+
+// PrintParameterIssues prints the issues of the parameters in the specified format.
+func PrintParameterIssues(out io.Writer, issues []util.ParameterIssue, format printer.Format) error {
+	result := validationResult{Valid: true, Issues: issues}
+	for _, issue := range issues {
+		if !issue.IsWarning() {
+			result.Valid = false
+		}
+	}
+	if result.Issues == nil {
+		result.Issues = []util.ParameterIssue{}
+	}
+	switch format {
+	case printer.JSON:
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case printer.YAML:
+		data, err := yaml.Marshal(result)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, string(data))
+	default:
+		if len(issues) > 0 {
+			tbl := printer.NewTablePrinter(out)
+			tbl.SetHeader("PARAMETER", "VALUE", "LEVEL", "TYPE", "MESSAGE")
+			for _, issue := range issues {
+				level := "error"
+				if issue.IsWarning() {
+					level = "warning"
+				}
+				tbl.AddRow(issue.Parameter, issue.Value, level, issue.Type, issue.Message)
+			}
+			tbl.Print()
+		}
+		if result.Valid {
+			fmt.Fprintln(out, "All parameters are valid.")
+		}
+	}
+	return nil
+}
+
+// CheckParameterIssues returns an error exiting with ExitCodeInvalidParameters if any parameter is invalid.
+func CheckParameterIssues(issues []util.ParameterIssue) error {
+	var invalid []string
+	for _, issue := range issues {
+		if !issue.IsWarning() {
+			invalid = append(invalid, issue.Parameter)
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	return exec.CodeExitError{
+		Err:  fmt.Errorf("invalid parameters: %s", strings.Join(invalid, ",")),
+		Code: ExitCodeInvalidParameters,
+	}
+}
+
+// NewConfigValidateCmd creates the command to validate the parameters against a local ConfigConstraint file.
+func NewConfigValidateCmd(streams genericiooptions.IOStreams) *cobra.Command {
+	o := &configValidateOptions{IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:   "config-validate --constraint FILE --set key=value[,key=value]",
+		Short: "Validate the config parameters against a ConfigConstraint file without connecting to a cluster.",
+		Long: templates.LongDesc(`
+			Validate the config parameters against a ConfigConstraint file without connecting to a cluster.
+			The unknown and immutable parameters, the values mismatching the type or out of the range, and the values
+			refused by the CUE validation are reported as errors, the static parameters are reported as warnings.
+
+			Exit codes: 0 if the parameters are valid, 1 if the validation fails to run,
+			2 if any parameter is invalid.`),
+		Example: configValidateExample,
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.run())
+		},
+	}
+	cmd.Flags().StringVar(&o.constraintFile, "constraint", "", "Specify the ConfigConstraint YAML file")
+	cmd.Flags().StringSliceVar(&o.parameters, "set", nil, "Specify the parameters to validate, e.g. --set max_connections=1000,general_log=OFF")
+	cmd.Flags().StringVar(&o.configFile, "config-file", "", "Specify the name of the config file the parameters belong to (e.g. my.cnf)")
+	printer.AddOutputFlag(cmd, &o.format)
+	return cmd
+}
@@ -0,0 +1,116 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	testapps "github.com/apecloud/kubeblocks/pkg/testutil/apps"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var _ = Describe("config validate", func() {
+	var (
+		out *bytes.Buffer
+		o   *configValidateOptions
+		dir string
+	)
+
+	BeforeEach(func() {
+		var streams genericiooptions.IOStreams
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		dir, _ = os.MkdirTemp(os.TempDir(), "test-")
+		cc := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		cc.Spec.ImmutableParameters = []string{"datadir"}
+		data, err := yaml.Marshal(cc)
+		Expect(err).ShouldNot(HaveOccurred())
+		constraintFile := filepath.Join(dir, "cc.yaml")
+		Expect(os.WriteFile(constraintFile, data, 0644)).Should(Succeed())
+		o = &configValidateOptions{IOStreams: streams, constraintFile: constraintFile}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("validate the valid parameters", func() {
+		Expect(NewConfigValidateCmd(genericiooptions.NewTestIOStreamsDiscard())).ShouldNot(BeNil())
+		o.parameters = []string{"innodb_autoinc_lock_mode=1,auto_increment_increment=10", "automatic_sp_privileges=OFF"}
+		Expect(o.run()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`automatic_sp_privileges\s+OFF\s+warning\s+static`))
+		Expect(out.String()).Should(ContainSubstring("All parameters are valid."))
+	})
+
+	It("report the invalid parameters", func() {
+		o.parameters = []string{"innodb_autoinc_lock_mode=5,auto_increment_increment=abc,binlog_stmt_cache_size=1024,automatic_sp_privileges=X,datadir=/data,unknown_param=1"}
+		err := o.run()
+		var exitErr exec.CodeExitError
+		Expect(errors.As(err, &exitErr)).Should(BeTrue())
+		Expect(exitErr.ExitStatus()).Should(Equal(ExitCodeInvalidParameters))
+		Expect(out.String()).Should(MatchRegexp(`auto_increment_increment\s+abc\s+error\s+type`))
+		Expect(out.String()).Should(MatchRegexp(`automatic_sp_privileges\s+X\s+error\s+range\s+value "X" is not one of \[OFF ON\]`))
+		Expect(out.String()).Should(MatchRegexp(`binlog_stmt_cache_size\s+1024\s+error\s+range\s+value 1024 is out of range, the minimum is 4096`))
+		Expect(out.String()).Should(MatchRegexp(`datadir\s+/data\s+error\s+immutable`))
+		Expect(out.String()).Should(MatchRegexp(`innodb_autoinc_lock_mode\s+5\s+error\s+range`))
+		Expect(out.String()).Should(MatchRegexp(`unknown_param\s+1\s+error\s+unknown`))
+
+		By("output the result in JSON format")
+		out.Reset()
+		o.format = printer.JSON
+		o.parameters = []string{"unknown_param=1"}
+		Expect(o.run()).Should(HaveOccurred())
+		Expect(out.String()).Should(ContainSubstring(`"valid": false`))
+		Expect(out.String()).Should(ContainSubstring(`"type": "unknown"`))
+	})
+
+	It("fail to validate without the constraint or the parameters", func() {
+		o.parameters = []string{"max_connections"}
+		Expect(o.run()).Should(MatchError(ContainSubstring("key=value")))
+		o.parameters = nil
+		Expect(o.run()).Should(MatchError(ContainSubstring("no parameter to validate")))
+		o.constraintFile = ""
+		Expect(o.run()).Should(MatchError(ContainSubstring("--constraint")))
+	})
+
+	It("classify the parameters", func() {
+		issues, err := util.ValidateParameters(&appsv1alpha1.ConfigConstraintSpec{
+			FormatterConfig:   &appsv1alpha1.FormatterConfig{Format: appsv1alpha1.Ini},
+			ReloadOptions:     &appsv1alpha1.ReloadOptions{UnixSignalTrigger: &appsv1alpha1.UnixSignalTrigger{Signal: appsv1alpha1.SIGHUP, ProcessName: "mysqld"}},
+			StaticParameters:  []string{"innodb_buffer_pool_size"},
+			DynamicParameters: []string{"max_connections"},
+		}, "my.cnf", map[string]*string{"max_connections": nil, "innodb_buffer_pool_size": nil})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(issues).Should(HaveLen(1))
+		Expect(issues[0].Parameter).Should(Equal("innodb_buffer_pool_size"))
+		Expect(issues[0].IsWarning()).Should(BeTrue())
+	})
+})
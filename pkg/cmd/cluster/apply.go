@@ -0,0 +1,688 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+	"github.com/apecloud/kbcli/pkg/util/prompt"
+)
+
+var applyExample = templates.Examples(`
+	# preview the three-way diff and the OpsRequests to be created without applying them
+	kbcli cluster apply -f mycluster.yaml --dry-run
+
+	# apply the cluster manifest, the spec changes are converted into OpsRequests
+	kbcli cluster apply -f mycluster.yaml
+
+	# apply the cluster manifest from stdin without confirmation
+	cat mycluster.yaml | kbcli cluster apply -f - --auto-approve
+
+	# the config parameters of the components can be declared by the annotation in the manifest
+	# metadata:
+	#   annotations:
+	#     kubeblocks.io/config-parameters: '{"mysql":{"max_connections":"2000"}}'
+`)
+
+// ApplyOptions is the options of the cluster apply command.
+type ApplyOptions struct {
+	Factory   cmdutil.Factory
+	Namespace string
+	Dynamic   dynamic.Interface
+	Client    kubernetes.Interface
+
+	// Filename is the cluster manifest, it can be a local file, a URL or "-" for stdin
+	Filename    string
+	DryRun      string
+	AutoApprove bool
+
+	// desiredObj is the cluster manifest as read from the file
+	desiredObj  *unstructured.Unstructured
+	desired     *appsv1alpha1.Cluster
+	live        *appsv1alpha1.Cluster
+	lastApplied *appsv1alpha1.Cluster
+
+	genericiooptions.IOStreams
+}
+
+// applyChange is a delta between the live and the desired cluster spec, each change
+// is converted into an OpsRequest.
+type applyChange struct {
+	opsType     appsv1alpha1.OpsType
+	component   string
+	description string
+
+	// VerticalScaling
+	cpu    string
+	memory string
+	class  string
+	// HorizontalScaling
+	replicas int32
+	// VolumeExpansion
+	vctName string
+	storage string
+	// Reconfiguring
+	parameters map[string]string
+}
+
+func NewApplyCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ApplyOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:   "apply -f FILENAME",
+		Short: "Apply a cluster manifest, the changes of the cluster spec are converted into OpsRequests.",
+		Long: templates.LongDesc(`
+			Apply a cluster manifest. If the cluster does not exist, it will be created. Otherwise, a three-way diff
+			of the last-applied, live and desired cluster spec is displayed, and the changes of the replicas,
+			resources, class and storage of the components, and the config parameters declared by the annotation
+			"kubeblocks.io/config-parameters" are converted into HorizontalScaling, VerticalScaling, VolumeExpansion
+			and Reconfiguring OpsRequests.`),
+		Example: applyExample,
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete())
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVarP(&o.Filename, "filename", "f", "", "The cluster manifest to apply, it can be a local file, a URL or '-' for stdin")
+	cmd.Flags().StringVar(&o.DryRun, "dry-run", "none", `Must be "none", "client", or "server". If with client strategy, only print the diff and the objects that would be sent, and no data is actually sent. If with server strategy, submit the server-side request, but no data is persistent.`)
+	cmd.Flags().Lookup("dry-run").NoOptDefVal = "unchanged"
+	cmd.Flags().BoolVar(&o.AutoApprove, "auto-approve", false, "Skip interactive approval before applying the changes")
+	util.CheckErr(cmd.MarkFlagRequired("filename"))
+	return cmd
+}
+
+func (o *ApplyOptions) Complete() error {
+	var err error
+	if o.Filename == "" {
+		return fmt.Errorf("missing cluster manifest, please specify it by the \"-f\" flag")
+	}
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	if o.Client, err = o.Factory.KubernetesClientSet(); err != nil {
+		return err
+	}
+
+	data, err := MultipleSourceComponents(o.Filename, o.In)
+	if err != nil {
+		return err
+	}
+	if o.desiredObj, o.desired, err = parseClusterManifest(data); err != nil {
+		return err
+	}
+	if o.desired.Namespace == "" {
+		o.desired.Namespace = o.Namespace
+		o.desiredObj.SetNamespace(o.Namespace)
+	}
+	o.Namespace = o.desired.Namespace
+	return o.completeLiveCluster()
+}
+
+// completeLiveCluster gets the live cluster and the last-applied cluster recorded in its annotation.
+func (o *ApplyOptions) completeLiveCluster() error {
+	live, err := cluster.GetClusterByName(o.Dynamic, o.desired.Name, o.Namespace)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	o.live = live
+	if lastApplied, ok := live.Annotations[corev1.LastAppliedConfigAnnotation]; ok && lastApplied != "" {
+		if _, o.lastApplied, err = parseClusterManifest([]byte(lastApplied)); err != nil {
+			return fmt.Errorf("failed to parse the last-applied configuration of cluster %s: %v", live.Name, err)
+		}
+	}
+	return nil
+}
+
+func (o *ApplyOptions) Validate() error {
+	if o.desired.Name == "" {
+		return fmt.Errorf("missing the name of the cluster in the manifest")
+	}
+	if _, err := getConfigParameters(o.desired); err != nil {
+		return err
+	}
+	dryRun := &action.CreateOptions{DryRun: o.DryRun}
+	_, err := dryRun.GetDryRunStrategy()
+	return err
+}
+
+func (o *ApplyOptions) Run() error {
+	if o.live == nil {
+		return o.createCluster()
+	}
+
+	if err := o.printDiff(); err != nil {
+		return err
+	}
+	changes, err := o.buildChanges()
+	if err != nil {
+		return err
+	}
+	unmanaged, err := o.hasUnmanagedChanges()
+	if err != nil {
+		return err
+	}
+	if unmanaged {
+		printer.Warning(o.Out, "only the changes of replicas, resources, class, storage and config parameters of the existing components are applied by OpsRequests, "+
+			"the other changes are ignored, please use 'kbcli cluster update' instead.\n")
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(o.Out, "cluster %s unchanged\n", o.desired.Name)
+		return o.updateLastApplied()
+	}
+
+	o.printChanges(changes)
+	if !o.AutoApprove && o.DryRun == "none" {
+		if err = prompt.Confirm([]string{o.desired.Name}, o.In, "", ""); err != nil {
+			return err
+		}
+	}
+	for i := range changes {
+		if err = o.createOps(changes[i]); err != nil {
+			return fmt.Errorf("failed to create %s OpsRequest for component %s: %v", changes[i].opsType, changes[i].component, err)
+		}
+	}
+	return o.updateLastApplied()
+}
+
+// createCluster creates the cluster and records the manifest as the last-applied configuration.
+func (o *ApplyOptions) createCluster() error {
+	obj := o.desiredObj.DeepCopy()
+	lastApplied, err := o.lastAppliedConfiguration()
+	if err != nil {
+		return err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[corev1.LastAppliedConfigAnnotation] = lastApplied
+	obj.SetAnnotations(annotations)
+
+	switch o.DryRun {
+	case "none":
+		if _, err = o.Dynamic.Resource(types.ClusterGVR()).Namespace(o.Namespace).Create(context.TODO(), obj, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.Out, "cluster %s created\n", obj.GetName())
+		return nil
+	case "server":
+		if obj, err = o.Dynamic.Resource(types.ClusterGVR()).Namespace(o.Namespace).Create(context.TODO(), obj,
+			metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}); err != nil {
+			return err
+		}
+	}
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(o.Out, string(data))
+	return nil
+}
+
+// printDiff prints the three-way diff of the cluster spec, the fields which are not declared in
+// the compared manifest are pruned from the live spec to hide the fields filled by the server.
+func (o *ApplyOptions) printDiff() error {
+	live, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&o.live.Spec)
+	if err != nil {
+		return err
+	}
+	printSpecDiff := func(title string, original, edited interface{}, from, to string) error {
+		originalBytes, err := yaml.Marshal(original)
+		if err != nil {
+			return err
+		}
+		editedBytes, err := yaml.Marshal(edited)
+		if err != nil {
+			return err
+		}
+		diff, err := util.GetUnifiedDiffString(string(originalBytes), string(editedBytes), from, to, 3)
+		if err != nil || diff == "" {
+			return err
+		}
+		fmt.Fprintf(o.Out, "\n%s:\n", title)
+		util.DisplayDiffWithColor(o.Out, diff)
+		return nil
+	}
+
+	if o.lastApplied != nil {
+		lastApplied, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&o.lastApplied.Spec)
+		if err != nil {
+			return err
+		}
+		if err = printSpecDiff("Changes made out of apply since the last apply", lastApplied, pruneToShape(live, lastApplied),
+			"last-applied", "live"); err != nil {
+			return err
+		}
+	}
+	desired, _, _ := unstructured.NestedMap(o.desiredObj.Object, "spec")
+	return printSpecDiff("Changes to apply", pruneToShape(live, desired), desired, "live", "desired")
+}
+
+// buildChanges converts the deltas of the live and desired cluster spec into the changes.
+func (o *ApplyOptions) buildChanges() ([]applyChange, error) {
+	desiredParams, err := getConfigParameters(o.desired)
+	if err != nil {
+		return nil, err
+	}
+	lastParams, err := getConfigParameters(o.lastApplied)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []applyChange
+	for _, desiredComp := range o.desired.Spec.ComponentSpecs {
+		liveComp := getComponentSpec(o.live, desiredComp.Name)
+		if liveComp == nil {
+			continue
+		}
+		if change := buildVScaleChange(liveComp, &desiredComp); change != nil {
+			changes = append(changes, *change)
+		}
+		if desiredComp.Replicas != liveComp.Replicas {
+			changes = append(changes, applyChange{
+				opsType:     appsv1alpha1.HorizontalScalingType,
+				component:   desiredComp.Name,
+				replicas:    desiredComp.Replicas,
+				description: fmt.Sprintf("replicas: %d -> %d", liveComp.Replicas, desiredComp.Replicas),
+			})
+		}
+		volumeChanges, err := buildVolumeExpansionChanges(liveComp, &desiredComp)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, volumeChanges...)
+		if change := buildReconfigureChange(desiredComp.Name, lastParams[desiredComp.Name], desiredParams[desiredComp.Name]); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return changes, nil
+}
+
+func buildVScaleChange(liveComp, desiredComp *appsv1alpha1.ClusterComponentSpec) *applyChange {
+	if desiredComp.ClassDefRef != nil && desiredComp.ClassDefRef.Class != "" {
+		if liveComp.ClassDefRef != nil && *liveComp.ClassDefRef == *desiredComp.ClassDefRef {
+			return nil
+		}
+		class := desiredComp.ClassDefRef.Class
+		if desiredComp.ClassDefRef.Name != "" {
+			class = fmt.Sprintf("%s:%s", desiredComp.ClassDefRef.Name, class)
+		}
+		liveClass := printer.NoneString
+		if liveComp.ClassDefRef != nil && liveComp.ClassDefRef.Class != "" {
+			liveClass = liveComp.ClassDefRef.Class
+		}
+		return &applyChange{
+			opsType:     appsv1alpha1.VerticalScalingType,
+			component:   desiredComp.Name,
+			class:       class,
+			description: fmt.Sprintf("class: %s -> %s", liveClass, class),
+		}
+	}
+
+	change := &applyChange{
+		opsType:   appsv1alpha1.VerticalScalingType,
+		component: desiredComp.Name,
+	}
+	var descriptions []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		desiredQuantity, ok := getResourceQuantity(desiredComp.Resources, name)
+		if !ok {
+			continue
+		}
+		liveQuantity, ok := getResourceQuantity(liveComp.Resources, name)
+		if ok && liveQuantity.Cmp(desiredQuantity) == 0 {
+			continue
+		}
+		liveValue := printer.NoneString
+		if ok {
+			liveValue = liveQuantity.String()
+		}
+		if name == corev1.ResourceCPU {
+			change.cpu = desiredQuantity.String()
+		} else {
+			change.memory = desiredQuantity.String()
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s: %s -> %s", name, liveValue, desiredQuantity.String()))
+	}
+	if len(descriptions) == 0 {
+		return nil
+	}
+	change.description = strings.Join(descriptions, ", ")
+	return change
+}
+
+func buildVolumeExpansionChanges(liveComp, desiredComp *appsv1alpha1.ClusterComponentSpec) ([]applyChange, error) {
+	var changes []applyChange
+	for _, desiredVct := range desiredComp.VolumeClaimTemplates {
+		desiredStorage, ok := desiredVct.Spec.Resources.Requests[corev1.ResourceStorage]
+		if !ok {
+			continue
+		}
+		for _, liveVct := range liveComp.VolumeClaimTemplates {
+			if liveVct.Name != desiredVct.Name {
+				continue
+			}
+			liveStorage := liveVct.Spec.Resources.Requests[corev1.ResourceStorage]
+			switch desiredStorage.Cmp(liveStorage) {
+			case -1:
+				return nil, fmt.Errorf("the storage of volume claim template %s in component %s can not be shrunk from %s to %s",
+					desiredVct.Name, desiredComp.Name, liveStorage.String(), desiredStorage.String())
+			case 1:
+				changes = append(changes, applyChange{
+					opsType:     appsv1alpha1.VolumeExpansionType,
+					component:   desiredComp.Name,
+					vctName:     desiredVct.Name,
+					storage:     desiredStorage.String(),
+					description: fmt.Sprintf("%s: %s -> %s", desiredVct.Name, liveStorage.String(), desiredStorage.String()),
+				})
+			}
+		}
+	}
+	return changes, nil
+}
+
+func buildReconfigureChange(compName string, lastParams, desiredParams map[string]string) *applyChange {
+	changed := map[string]string{}
+	for k, v := range desiredParams {
+		if lastValue, ok := lastParams[k]; !ok || lastValue != v {
+			changed[k] = v
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	return &applyChange{
+		opsType:     appsv1alpha1.ReconfiguringType,
+		component:   compName,
+		parameters:  changed,
+		description: strings.Join(formatParameters(changed), ","),
+	}
+}
+
+// hasUnmanagedChanges checks if there are changes which can not be converted into OpsRequests.
+func (o *ApplyOptions) hasUnmanagedChanges() (bool, error) {
+	live, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&o.live.Spec)
+	if err != nil {
+		return false, err
+	}
+	desired, _, _ := unstructured.NestedMap(o.desiredObj.Object, "spec")
+	desired = runtime.DeepCopyJSON(desired)
+	comps, _, _ := unstructured.NestedSlice(desired, "componentSpecs")
+	for i := range comps {
+		comp, ok := comps[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(comp, "name"); getComponentSpec(o.live, name) == nil {
+			continue
+		}
+		// the managed fields will be applied by OpsRequests
+		for _, field := range []string{"replicas", "resources", "classDefRef"} {
+			delete(comp, field)
+		}
+		vcts, _, _ := unstructured.NestedSlice(comp, "volumeClaimTemplates")
+		for j := range vcts {
+			if vct, ok := vcts[j].(map[string]interface{}); ok {
+				unstructured.RemoveNestedField(vct, "spec", "resources", "requests", "storage")
+			}
+		}
+		if len(vcts) > 0 {
+			comp["volumeClaimTemplates"] = vcts
+		}
+		comps[i] = comp
+	}
+	if len(comps) > 0 {
+		desired["componentSpecs"] = comps
+	}
+
+	liveBytes, err := yaml.Marshal(pruneToShape(live, desired))
+	if err != nil {
+		return false, err
+	}
+	desiredBytes, err := yaml.Marshal(desired)
+	if err != nil {
+		return false, err
+	}
+	return string(liveBytes) != string(desiredBytes), nil
+}
+
+func (o *ApplyOptions) printChanges(changes []applyChange) {
+	fmt.Fprintf(o.Out, "\nThe following OpsRequests will be created for cluster %s:\n", o.desired.Name)
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("TYPE", "COMPONENT", "CHANGES")
+	for _, c := range changes {
+		tbl.AddRow(c.opsType, c.component, c.description)
+	}
+	tbl.Print()
+}
+
+// createOps creates the OpsRequest of the change.
+func (o *ApplyOptions) createOps(change applyChange) error {
+	ops := newBaseOperationsOptions(o.Factory, o.IOStreams, change.opsType, true)
+	if err := ops.CreateOptions.Complete(); err != nil {
+		return err
+	}
+	ops.Dynamic = o.Dynamic
+	ops.Client = o.Client
+	ops.Namespace = o.Namespace
+	ops.Name = o.desired.Name
+	ops.DryRun = o.DryRun
+	ops.Format = printer.YAML
+	ops.ComponentNames = []string{change.component}
+	// all changes have been confirmed
+	ops.autoApprove = true
+
+	switch change.opsType {
+	case appsv1alpha1.VerticalScalingType:
+		ops.CPU = change.cpu
+		ops.Memory = change.memory
+		ops.Class = change.class
+	case appsv1alpha1.HorizontalScalingType:
+		ops.Replicas = int(change.replicas)
+	case appsv1alpha1.VolumeExpansionType:
+		ops.VCTNames = []string{change.vctName}
+		ops.Storage = change.storage
+	case appsv1alpha1.ReconfiguringType:
+		// reconfiguring may restart the component, so double check it unless auto-approve is set
+		ops.autoApprove = o.AutoApprove
+		configOps := &configOpsOptions{
+			OperationsOptions: ops,
+			Parameters:        formatParameters(change.parameters),
+		}
+		if err := configOps.Complete(); err != nil {
+			return err
+		}
+		if err := configOps.Validate(); err != nil {
+			return err
+		}
+		return configOps.Run()
+	}
+	if err := ops.Validate(); err != nil {
+		return err
+	}
+	return ops.Run()
+}
+
+// updateLastApplied records the manifest as the last-applied configuration of the cluster.
+func (o *ApplyOptions) updateLastApplied() error {
+	if o.DryRun != "none" {
+		return nil
+	}
+	lastApplied, err := o.lastAppliedConfiguration()
+	if err != nil {
+		return err
+	}
+	if o.live.Annotations[corev1.LastAppliedConfigAnnotation] == lastApplied {
+		return nil
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				corev1.LastAppliedConfigAnnotation: lastApplied,
+			},
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = o.Dynamic.Resource(types.ClusterGVR()).Namespace(o.Namespace).Patch(context.TODO(), o.desired.Name,
+		apitypes.MergePatchType, patchBytes, metav1.PatchOptions{})
+	return err
+}
+
+func (o *ApplyOptions) lastAppliedConfiguration() (string, error) {
+	obj := o.desiredObj.DeepCopy()
+	annotations := obj.GetAnnotations()
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	obj.SetAnnotations(annotations)
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func parseClusterManifest(data []byte) (*unstructured.Unstructured, *appsv1alpha1.Cluster, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj := &unstructured.Unstructured{}
+	if err = obj.UnmarshalJSON(jsonData); err != nil {
+		return nil, nil, err
+	}
+	if obj.GetKind() != types.KindCluster {
+		return nil, nil, fmt.Errorf("the kind of the manifest must be %s, but got \"%s\"", types.KindCluster, obj.GetKind())
+	}
+	cls := &appsv1alpha1.Cluster{}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, cls); err != nil {
+		return nil, nil, err
+	}
+	return obj, cls, nil
+}
+
+// getConfigParameters gets the config parameters of the components declared by the annotation.
+func getConfigParameters(cls *appsv1alpha1.Cluster) (map[string]map[string]string, error) {
+	if cls == nil || cls.Annotations[types.ConfigParametersAnnotationKey] == "" {
+		return nil, nil
+	}
+	params := map[string]map[string]string{}
+	if err := yaml.Unmarshal([]byte(cls.Annotations[types.ConfigParametersAnnotationKey]), &params); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s, the value should be like {\"component\":{\"key\":\"value\"}}: %v",
+			types.ConfigParametersAnnotationKey, err)
+	}
+	return params, nil
+}
+
+func getComponentSpec(cls *appsv1alpha1.Cluster, compName string) *appsv1alpha1.ClusterComponentSpec {
+	for i := range cls.Spec.ComponentSpecs {
+		if cls.Spec.ComponentSpecs[i].Name == compName {
+			return &cls.Spec.ComponentSpecs[i]
+		}
+	}
+	return nil
+}
+
+// getResourceQuantity gets the quantity of the resource from requests, fall back to limits.
+func getResourceQuantity(resources corev1.ResourceRequirements, name corev1.ResourceName) (resource.Quantity, bool) {
+	if q, ok := resources.Requests[name]; ok {
+		return q, true
+	}
+	q, ok := resources.Limits[name]
+	return q, ok
+}
+
+func formatParameters(params map[string]string) []string {
+	res := make([]string, 0, len(params))
+	for k, v := range params {
+		res = append(res, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(res)
+	return res
+}
+
+// pruneToShape keeps the fields of obj which also exist in shape, the items of lists are matched by index.
+func pruneToShape(obj, shape interface{}) interface{} {
+	switch s := shape.(type) {
+	case map[string]interface{}:
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return obj
+		}
+		res := map[string]interface{}{}
+		for k, v := range s {
+			if ov, ok := m[k]; ok {
+				res[k] = pruneToShape(ov, v)
+			}
+		}
+		return res
+	case []interface{}:
+		l, ok := obj.([]interface{})
+		if !ok {
+			return obj
+		}
+		res := make([]interface{}, len(l))
+		for i := range l {
+			if i < len(s) {
+				res[i] = pruneToShape(l[i], s[i])
+			} else {
+				res[i] = l[i]
+			}
+		}
+		return res
+	default:
+		return obj
+	}
+}
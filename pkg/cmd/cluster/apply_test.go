@@ -0,0 +1,176 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("cluster apply", func() {
+	const clusterName = "test-apply"
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+		tmpDir  string
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(clusterName, testing.Namespace),
+			testing.FakeClusterDef(), testing.FakeClusterVersion())
+		tmpDir = GinkgoT().TempDir()
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	writeManifest := func(cls *appsv1alpha1.Cluster) string {
+		data, err := yaml.Marshal(cls)
+		Expect(err).ShouldNot(HaveOccurred())
+		file := filepath.Join(tmpDir, cls.Name+".yaml")
+		Expect(os.WriteFile(file, data, 0644)).Should(Succeed())
+		return file
+	}
+
+	newApplyOptions := func(cls *appsv1alpha1.Cluster) *ApplyOptions {
+		o := &ApplyOptions{
+			Factory:   tf,
+			IOStreams: streams,
+			Filename:  writeManifest(cls),
+			DryRun:    "client",
+		}
+		Expect(o.Complete()).Should(Succeed())
+		Expect(o.Validate()).Should(Succeed())
+		return o
+	}
+
+	It("apply command", func() {
+		cmd := NewApplyCmd(tf, streams)
+		Expect(cmd).ShouldNot(BeNil())
+	})
+
+	It("build changes", func() {
+		desired := testing.FakeCluster(clusterName, testing.Namespace)
+		desired.Annotations = map[string]string{
+			types.ConfigParametersAnnotationKey: `{"` + testing.ComponentName + `":{"max_connections":"2000"}}`,
+		}
+		desired.Spec.ComponentSpecs[0].Replicas = 3
+		desired.Spec.ComponentSpecs[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("1")
+		desired.Spec.ComponentSpecs[1].VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("10Gi")
+
+		o := newApplyOptions(desired)
+		changes, err := o.buildChanges()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(changes).Should(HaveLen(4))
+		Expect(changes[0].opsType).Should(Equal(appsv1alpha1.VerticalScalingType))
+		Expect(changes[0].cpu).Should(Equal("1"))
+		Expect(changes[0].memory).Should(BeEmpty())
+		Expect(changes[1].opsType).Should(Equal(appsv1alpha1.HorizontalScalingType))
+		Expect(changes[1].replicas).Should(Equal(int32(3)))
+		Expect(changes[2].opsType).Should(Equal(appsv1alpha1.ReconfiguringType))
+		Expect(changes[2].parameters).Should(HaveKeyWithValue("max_connections", "2000"))
+		Expect(changes[3].opsType).Should(Equal(appsv1alpha1.VolumeExpansionType))
+		Expect(changes[3].component).Should(Equal(testing.ComponentName + "-1"))
+		Expect(changes[3].storage).Should(Equal("10Gi"))
+
+		unmanaged, err := o.hasUnmanagedChanges()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(unmanaged).Should(BeFalse())
+
+		By("expect an error when shrinking the volume")
+		desired.Spec.ComponentSpecs[1].VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("500Mi")
+		o = newApplyOptions(desired)
+		_, err = o.buildChanges()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("can not be shrunk"))
+
+		By("expect unmanaged changes")
+		desired = testing.FakeCluster(clusterName, testing.Namespace)
+		desired.Spec.TerminationPolicy = appsv1alpha1.Delete
+		o = newApplyOptions(desired)
+		changes, err = o.buildChanges()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(changes).Should(BeEmpty())
+		unmanaged, err = o.hasUnmanagedChanges()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(unmanaged).Should(BeTrue())
+	})
+
+	It("apply the changes with dry-run", func() {
+		desired := testing.FakeCluster(clusterName, testing.Namespace)
+		desired.Spec.ComponentSpecs[0].Replicas = 3
+		o := newApplyOptions(desired)
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("+++ desired"))
+		Expect(out.String()).Should(ContainSubstring("replicas: 1 -> 3"))
+		Expect(out.String()).Should(ContainSubstring("kind: OpsRequest"))
+	})
+
+	It("create the cluster if it does not exist", func() {
+		desired := testing.FakeCluster(clusterName+"-new", testing.Namespace)
+		o := newApplyOptions(desired)
+		o.DryRun = "none"
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("created"))
+
+		obj, err := tf.FakeDynamicClient.Resource(types.ClusterGVR()).Namespace(testing.Namespace).
+			Get(context.TODO(), desired.Name, metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(obj.GetAnnotations()).Should(HaveKey(corev1.LastAppliedConfigAnnotation))
+	})
+
+	It("prune to shape", func() {
+		live := map[string]interface{}{
+			"a": "1",
+			"b": map[string]interface{}{"c": "2", "d": "3"},
+			"e": []interface{}{map[string]interface{}{"f": "4", "g": "5"}},
+		}
+		shape := map[string]interface{}{
+			"b": map[string]interface{}{"c": "x"},
+			"e": []interface{}{map[string]interface{}{"f": "x"}},
+		}
+		Expect(pruneToShape(live, shape)).Should(Equal(map[string]interface{}{
+			"b": map[string]interface{}{"c": "2"},
+			"e": []interface{}{map[string]interface{}{"f": "4"}},
+		}))
+	})
+})
@@ -0,0 +1,439 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	dptypes "github.com/apecloud/kubeblocks/pkg/dataprotection/types"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+// backupScheduleNextRuns is the number of the next run times shown by describing a backup schedule
+const backupScheduleNextRuns = 3
+
+// PrintBackupScheduleList prints a row for each backup method of the backup schedules.
+func PrintBackupScheduleList(o action.ListOptions) error {
+	// if format is JSON or YAML, use default printer to output the result.
+	if o.Format == printer.JSON || o.Format == printer.YAML {
+		_, err := o.Run()
+		return err
+	}
+	dynamic, err := o.Factory.DynamicClient()
+	if err != nil {
+		return err
+	}
+	if o.AllNamespaces {
+		o.Namespace = ""
+	}
+	schedules, err := listBackupSchedules(dynamic, o.Namespace, o.LabelSelector, o.Names)
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		o.PrintNotFoundResources()
+		return nil
+	}
+
+	backups, err := listScheduledBackups(dynamic, o.Namespace, "")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("NAME", "NAMESPACE", "CLUSTER", "METHOD", "ENABLED", "CRON", "RETENTION", "NEXT-RUN", "LAST-BACKUP", "STATUS")
+	for _, schedule := range schedules {
+		for _, policy := range schedule.Spec.Schedules {
+			lastBackup, status := lastScheduledBackup(backups, schedule, policy.BackupMethod)
+			tbl.AddRow(schedule.Name, schedule.Namespace, schedule.Labels[constant.AppInstanceLabelKey], policy.BackupMethod,
+				strconv.FormatBool(isSchedulePolicyEnabled(policy)), policy.CronExpression, policy.RetentionPeriod.String(),
+				nextSchedulePolicyRun(policy, now), lastBackup, status)
+		}
+	}
+	tbl.Print()
+	return nil
+}
+
+func listBackupSchedules(dynamic dynamic.Interface, namespace, labelSelector string, names []string) ([]*dpv1alpha1.BackupSchedule, error) {
+	objs, err := dynamic.Resource(types.BackupScheduleGVR()).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	nameMap := map[string]bool{}
+	for _, name := range names {
+		nameMap[name] = true
+	}
+	var schedules []*dpv1alpha1.BackupSchedule
+	for _, obj := range objs.Items {
+		if len(names) > 0 && !nameMap[obj.GetName()] {
+			continue
+		}
+		schedule := &dpv1alpha1.BackupSchedule{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, schedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+func isSchedulePolicyEnabled(policy dpv1alpha1.SchedulePolicy) bool {
+	return policy.Enabled != nil && *policy.Enabled
+}
+
+// nextScheduleRuns returns the next run times of the cron expression after the time, the cron
+// expression of the backup schedule is in UTC.
+func nextScheduleRuns(cronExpression string, after time.Time, n int) ([]time.Time, error) {
+	schedule, err := cron.ParseStandard(cronExpression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression: %s, please see https://en.wikipedia.org/wiki/Cron", cronExpression)
+	}
+	runs := make([]time.Time, 0, n)
+	next := after.UTC()
+	for i := 0; i < n; i++ {
+		next = schedule.Next(next)
+		runs = append(runs, next)
+	}
+	return runs, nil
+}
+
+func nextSchedulePolicyRun(policy dpv1alpha1.SchedulePolicy, now time.Time) string {
+	if !isSchedulePolicyEnabled(policy) {
+		return printer.NoneString
+	}
+	runs, err := nextScheduleRuns(policy.CronExpression, now, 1)
+	if err != nil {
+		return printer.NoneString
+	}
+	return util.TimeTimeFormat(runs[0].Local())
+}
+
+// listScheduledBackups lists the backups produced by the backup schedules, the latest first. All the
+// scheduled backups in the namespace are listed if the schedule name is empty.
+func listScheduledBackups(dynamic dynamic.Interface, namespace, schedule string) ([]*dpv1alpha1.Backup, error) {
+	selector := dptypes.BackupScheduleLabelKey
+	if schedule != "" {
+		selector = fmt.Sprintf("%s=%s", dptypes.BackupScheduleLabelKey, schedule)
+	}
+	objs, err := dynamic.Resource(types.BackupGVR()).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var backups []*dpv1alpha1.Backup
+	for _, obj := range objs.Items {
+		backup := &dpv1alpha1.Backup{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, backup); err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[j].CreationTimestamp.Before(&backups[i].CreationTimestamp)
+	})
+	return backups, nil
+}
+
+// lastScheduledBackup returns the latest backup produced by the backup method of the schedule and
+// its status, the status of the schedule is used if there is no such backup.
+func lastScheduledBackup(backups []*dpv1alpha1.Backup, schedule *dpv1alpha1.BackupSchedule, method string) (string, string) {
+	for _, backup := range backups {
+		if backup.Namespace == schedule.Namespace && backup.Labels[dptypes.BackupScheduleLabelKey] == schedule.Name &&
+			backup.Spec.BackupMethod == method {
+			return fmt.Sprintf("%s (%s)", backup.Name, util.TimeFormat(&backup.CreationTimestamp)), string(backup.Status.Phase)
+		}
+	}
+	status := schedule.Status.Schedules[method]
+	if status.FailureReason != "" {
+		return printer.NoneString, fmt.Sprintf("%s: %s", status.Phase, status.FailureReason)
+	}
+	return printer.NoneString, string(status.Phase)
+}
+
+type DescribeBackupScheduleOptions struct {
+	Factory   cmdutil.Factory
+	Dynamic   dynamic.Interface
+	Namespace string
+	Names     []string
+
+	genericiooptions.IOStreams
+}
+
+func (o *DescribeBackupScheduleOptions) Complete(args []string) error {
+	var err error
+	if len(args) == 0 {
+		return fmt.Errorf("missing backup schedule name")
+	}
+	o.Names = args
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	o.Dynamic, err = o.Factory.DynamicClient()
+	return err
+}
+
+func (o *DescribeBackupScheduleOptions) Run() error {
+	schedules, err := listBackupSchedules(o.Dynamic, o.Namespace, "", o.Names)
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		return fmt.Errorf("backup schedule %s not found in namespace %s", strings.Join(o.Names, ","), o.Namespace)
+	}
+	for _, schedule := range schedules {
+		if err = o.printBackupSchedule(schedule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *DescribeBackupScheduleOptions) printBackupSchedule(schedule *dpv1alpha1.BackupSchedule) error {
+	printer.PrintLine("Summary:")
+	realPrintPairStringToLine("Name", schedule.Name)
+	realPrintPairStringToLine("Cluster", schedule.Labels[constant.AppInstanceLabelKey])
+	realPrintPairStringToLine("Namespace", schedule.Namespace)
+	realPrintPairStringToLine("Backup Policy", schedule.Spec.BackupPolicyName)
+	if schedule.Spec.StartingDeadlineMinutes != nil {
+		realPrintPairStringToLine("Starting Deadline", fmt.Sprintf("%dm", *schedule.Spec.StartingDeadlineMinutes))
+	}
+	realPrintPairStringToLine("Phase", string(schedule.Status.Phase))
+	realPrintPairStringToLine("Failure Reason", schedule.Status.FailureReason)
+
+	backups, err := listScheduledBackups(o.Dynamic, schedule.Namespace, schedule.Name)
+	if err != nil {
+		return err
+	}
+	printer.PrintLine("\nSchedules:")
+	printSchedulePolicies(o.Out, schedule, backups, time.Now())
+
+	printer.PrintLine("\nBackups:")
+	if len(backups) == 0 {
+		printer.PrintLine("  No backup is produced by the schedule")
+		return nil
+	}
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("NAME", "METHOD", "STATUS", "TOTAL-SIZE", "CREATE-TIME", "COMPLETION-TIME", "EXPIRATION")
+	for _, backup := range backups {
+		tbl.AddRow(backup.Name, backup.Spec.BackupMethod, backup.Status.Phase, backup.Status.TotalSize,
+			util.TimeFormat(&backup.CreationTimestamp), util.TimeFormat(backup.Status.CompletionTimestamp), util.TimeFormat(backup.Status.Expiration))
+	}
+	tbl.Print()
+	return nil
+}
+
+// printSchedulePolicies prints the backup methods of the schedule with their next run times and the last run status.
+func printSchedulePolicies(out io.Writer, schedule *dpv1alpha1.BackupSchedule, backups []*dpv1alpha1.Backup, now time.Time) {
+	tbl := printer.NewTablePrinter(out)
+	tbl.SetHeader("METHOD", "ENABLED", "CRON", "RETENTION", "NEXT-RUNS", "LAST-BACKUP", "STATUS")
+	for _, policy := range schedule.Spec.Schedules {
+		lastBackup, status := lastScheduledBackup(backups, schedule, policy.BackupMethod)
+		nextRuns := printer.NoneString
+		if isSchedulePolicyEnabled(policy) {
+			runs, err := nextScheduleRuns(policy.CronExpression, now, backupScheduleNextRuns)
+			if err != nil {
+				nextRuns = err.Error()
+			} else {
+				var formatted []string
+				for _, run := range runs {
+					formatted = append(formatted, util.TimeTimeFormat(run.Local()))
+				}
+				nextRuns = strings.Join(formatted, ", ")
+			}
+		}
+		tbl.AddRow(policy.BackupMethod, strconv.FormatBool(isSchedulePolicyEnabled(policy)), policy.CronExpression, policy.RetentionPeriod.String(),
+			nextRuns, lastBackup, status)
+	}
+	tbl.Print()
+}
+
+// UpdateBackupScheduleOptions updates the backup methods of a backup schedule, the backup
+// method is required if the schedule has multiple methods, except enabling or disabling all of them.
+type UpdateBackupScheduleOptions struct {
+	Factory   cmdutil.Factory
+	Dynamic   dynamic.Interface
+	Namespace string
+	Name      string
+
+	Method                  string
+	Enabled                 *bool
+	CronExpression          string
+	RetentionPeriod         string
+	StartingDeadlineMinutes *int64
+	// Force updates the backup method even if it is overridden by the backup settings of the cluster
+	Force bool
+
+	schedule *dpv1alpha1.BackupSchedule
+	genericiooptions.IOStreams
+}
+
+func (o *UpdateBackupScheduleOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return fmt.Errorf("the backup schedule name is required")
+	}
+	o.Name = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	o.Dynamic, err = o.Factory.DynamicClient()
+	return err
+}
+
+func (o *UpdateBackupScheduleOptions) Validate() error {
+	if o.Enabled == nil && o.CronExpression == "" && o.RetentionPeriod == "" && o.StartingDeadlineMinutes == nil {
+		return fmt.Errorf("nothing to update, please specify at least one of --cron, --retention-period and --starting-deadline-minutes")
+	}
+	if o.CronExpression != "" {
+		if _, err := cron.ParseStandard(o.CronExpression); err != nil {
+			return fmt.Errorf("invalid cron expression: %s, please see https://en.wikipedia.org/wiki/Cron", o.CronExpression)
+		}
+	}
+	if o.RetentionPeriod != "" {
+		if _, err := dpv1alpha1.RetentionPeriod(o.RetentionPeriod).ToDuration(); err != nil {
+			return fmt.Errorf("invalid retention period: %s, supported values: [1y, 1mo, 1d, 1h, 1m] or combine them [1y1mo1d1h1m]", o.RetentionPeriod)
+		}
+	}
+	if o.StartingDeadlineMinutes != nil && *o.StartingDeadlineMinutes < 0 {
+		return fmt.Errorf("the starting deadline minutes can not be negative")
+	}
+	o.schedule = &dpv1alpha1.BackupSchedule{}
+	if err := util.GetK8SClientObject(o.Dynamic, o.schedule, types.BackupScheduleGVR(), o.Namespace, o.Name); err != nil {
+		return err
+	}
+	if o.Method != "" {
+		if !o.hasMethod(o.Method) {
+			return fmt.Errorf("backup method %s is not found in backup schedule %s", o.Method, o.Name)
+		}
+	} else if len(o.schedule.Spec.Schedules) > 1 && (o.CronExpression != "" || o.RetentionPeriod != "") {
+		return fmt.Errorf("backup schedule %s has multiple backup methods, please specify one by --method", o.Name)
+	}
+	return o.validateClusterBackup()
+}
+
+func (o *UpdateBackupScheduleOptions) hasMethod(method string) bool {
+	for _, policy := range o.schedule.Spec.Schedules {
+		if policy.BackupMethod == method {
+			return true
+		}
+	}
+	return false
+}
+
+// validateClusterBackup refuses to update the backup method which is set in the backup settings of the
+// cluster, since they override the enabled, cron expression and retention period of the backup method
+// when the cluster is reconciled, and the update would be reverted silently.
+func (o *UpdateBackupScheduleOptions) validateClusterBackup() error {
+	if o.Force || (o.Enabled == nil && o.CronExpression == "" && o.RetentionPeriod == "") {
+		return nil
+	}
+	name := o.schedule.Labels[constant.AppInstanceLabelKey]
+	if name == "" {
+		return nil
+	}
+	cls, err := cluster.GetClusterByName(o.Dynamic, name, o.Namespace)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if cls.Spec.Backup == nil || cls.Spec.Backup.Method == "" {
+		return nil
+	}
+	method := cls.Spec.Backup.Method
+	if (o.Method != "" && o.Method != method) || !o.hasMethod(method) {
+		return nil
+	}
+	return fmt.Errorf("backup method %s of backup schedule %s is overridden by the backup settings of cluster %s, "+
+		"please update them by \"kbcli cluster update %s --backup-method %s\" instead, or specify --force to update the schedule anyway",
+		method, o.Name, name, name, method)
+}
+
+func (o *UpdateBackupScheduleOptions) Run() error {
+	schedules := o.schedule.Spec.Schedules
+	for i := range schedules {
+		if o.Method != "" && schedules[i].BackupMethod != o.Method {
+			continue
+		}
+		if o.Enabled != nil {
+			schedules[i].Enabled = o.Enabled
+		}
+		if o.CronExpression != "" {
+			schedules[i].CronExpression = o.CronExpression
+		}
+		if o.RetentionPeriod != "" {
+			schedules[i].RetentionPeriod = dpv1alpha1.RetentionPeriod(o.RetentionPeriod)
+		}
+	}
+	spec := map[string]interface{}{"schedules": schedules}
+	if o.StartingDeadlineMinutes != nil {
+		// zero removes the starting deadline
+		if *o.StartingDeadlineMinutes == 0 {
+			spec["startingDeadlineMinutes"] = nil
+		} else {
+			spec["startingDeadlineMinutes"] = *o.StartingDeadlineMinutes
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{"spec": spec})
+	if err != nil {
+		return err
+	}
+	obj, err := o.Dynamic.Resource(types.BackupScheduleGVR()).Namespace(o.Namespace).Patch(context.TODO(), o.Name, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, o.schedule); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Backup schedule %s is updated\n", o.Name)
+	backups, err := listScheduledBackups(o.Dynamic, o.Namespace, o.Name)
+	if err != nil {
+		return err
+	}
+	printSchedulePolicies(o.Out, o.schedule, backups, time.Now())
+	return nil
+}
@@ -0,0 +1,202 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	dptypes "github.com/apecloud/kubeblocks/pkg/dataprotection/types"
+	"github.com/apecloud/kubeblocks/pkg/dataprotection/utils/boolptr"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var _ = Describe("backup schedule", func() {
+	const (
+		scheduleName   = "fake-backup-schedule"
+		snapshotMethod = "volume-snapshot"
+	)
+
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		schedule := testing.FakeBackupSchedule(scheduleName, "fake-backup-policy")
+		schedule.Spec.Schedules = append(schedule.Spec.Schedules, dpv1alpha1.SchedulePolicy{
+			Enabled:        boolptr.False(),
+			BackupMethod:   snapshotMethod,
+			CronExpression: "0 */6 * * *",
+		})
+		older, latest := testing.FakeBackup("backup-older"), testing.FakeBackup("backup-latest")
+		for i, backup := range []*dpv1alpha1.Backup{older, latest} {
+			backup.Labels = map[string]string{dptypes.BackupScheduleLabelKey: scheduleName}
+			backup.Spec.BackupMethod = testing.BackupMethodName
+			backup.CreationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(i-2) * time.Hour))
+		}
+		older.Status.Phase = dpv1alpha1.BackupPhaseCompleted
+		latest.Status.Phase = dpv1alpha1.BackupPhaseFailed
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(schedule, older, latest, testing.FakeBackup("manual-backup"))
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	getSchedule := func() *dpv1alpha1.BackupSchedule {
+		schedule := &dpv1alpha1.BackupSchedule{}
+		Expect(util.GetK8SClientObject(tf.FakeDynamicClient, schedule, types.BackupScheduleGVR(), testing.Namespace, scheduleName)).Should(Succeed())
+		return schedule
+	}
+
+	newUpdateOptions := func() *UpdateBackupScheduleOptions {
+		o := &UpdateBackupScheduleOptions{Factory: tf, IOStreams: streams}
+		Expect(o.Complete([]string{scheduleName})).Should(Succeed())
+		return o
+	}
+
+	It("compute the next run times", func() {
+		runs, err := nextScheduleRuns("0 18 * * *", time.Date(2023, 12, 1, 20, 0, 0, 0, time.UTC), 3)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(runs).Should(Equal([]time.Time{
+			time.Date(2023, 12, 2, 18, 0, 0, 0, time.UTC),
+			time.Date(2023, 12, 3, 18, 0, 0, 0, time.UTC),
+			time.Date(2023, 12, 4, 18, 0, 0, 0, time.UTC),
+		}))
+		_, err = nextScheduleRuns("invalid", time.Now(), 1)
+		Expect(err).Should(MatchError(ContainSubstring("invalid cron expression")))
+	})
+
+	It("list and describe the backup schedules", func() {
+		o := action.NewListOptions(tf, streams, types.BackupScheduleGVR())
+		Expect(o.Complete()).Should(Succeed())
+		Expect(PrintBackupScheduleList(*o)).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`fake-backup-schedule\s+fake-namespace\s+fake-cluster-name\s+fake-backup-method\s+true\s+0 0 \* \* \*\s+1d\s+.+\s+backup-latest \(.+\)\s+Failed`))
+		Expect(out.String()).Should(MatchRegexp(`fake-backup-schedule\s+fake-namespace\s+fake-cluster-name\s+volume-snapshot\s+false\s+0 \*/6 \* \* \*\s+<none>\s+<none>`))
+
+		out.Reset()
+		describe := &DescribeBackupScheduleOptions{Factory: tf, IOStreams: streams}
+		Expect(describe.Complete([]string{scheduleName})).Should(Succeed())
+		Expect(describe.Run()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`backup-latest\s+fake-backup-method\s+Failed[\s\S]+backup-older\s+fake-backup-method\s+Completed`))
+		Expect(out.String()).ShouldNot(ContainSubstring("manual-backup"))
+
+		describe.Names = []string{"not-exist"}
+		Expect(describe.Run()).Should(MatchError(ContainSubstring("not found")))
+	})
+
+	It("update the backup schedule", func() {
+		By("enable all the backup methods")
+		enabled := true
+		o := newUpdateOptions()
+		o.Enabled = &enabled
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		for _, policy := range getSchedule().Spec.Schedules {
+			Expect(*policy.Enabled).Should(BeTrue())
+		}
+
+		By("the backup method is required to update the cron expression")
+		o = newUpdateOptions()
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("nothing to update")))
+		o.CronExpression = "0 18 * * *"
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("please specify one by --method")))
+		o.Method = "not-exist"
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("backup method not-exist is not found")))
+		o.Method = snapshotMethod
+		o.RetentionPeriod = "7days"
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("invalid retention period")))
+		o.RetentionPeriod = "7d"
+		deadline := int64(30)
+		o.StartingDeadlineMinutes = &deadline
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		schedule := getSchedule()
+		Expect(*schedule.Spec.StartingDeadlineMinutes).Should(Equal(int64(30)))
+		Expect(schedule.Spec.Schedules[0].CronExpression).Should(Equal("0 0 * * *"))
+		Expect(schedule.Spec.Schedules[1].CronExpression).Should(Equal("0 18 * * *"))
+		Expect(schedule.Spec.Schedules[1].RetentionPeriod.String()).Should(Equal("7d"))
+
+		By("disable the backup method and remove the starting deadline")
+		o = newUpdateOptions()
+		o.Enabled = boolptr.False()
+		o.Method = testing.BackupMethodName
+		deadline = 0
+		o.StartingDeadlineMinutes = &deadline
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		schedule = getSchedule()
+		Expect(schedule.Spec.StartingDeadlineMinutes).Should(BeNil())
+		Expect(*schedule.Spec.Schedules[0].Enabled).Should(BeFalse())
+		Expect(*schedule.Spec.Schedules[1].Enabled).Should(BeTrue())
+	})
+
+	It("refuse to update the backup method overridden by the cluster", func() {
+		cls := testing.FakeCluster(testing.ClusterName, testing.Namespace)
+		cls.Spec.Backup = &appsv1alpha1.ClusterBackup{Method: testing.BackupMethodName}
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cls)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(tf.FakeDynamicClient.Tracker().Add(&unstructured.Unstructured{Object: obj})).Should(Succeed())
+
+		By("the backup method of the cluster is refused")
+		o := newUpdateOptions()
+		o.Enabled = boolptr.False()
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("kbcli cluster update " + testing.ClusterName + " --backup-method " + testing.BackupMethodName)))
+		o.Method = testing.BackupMethodName
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("specify --force")))
+
+		By("the other backup method and the starting deadline are updated")
+		o.Method = snapshotMethod
+		Expect(o.Validate()).Should(Succeed())
+		deadline := int64(30)
+		o = newUpdateOptions()
+		o.StartingDeadlineMinutes = &deadline
+		Expect(o.Validate()).Should(Succeed())
+
+		By("the backup method of the cluster is updated with --force")
+		o = newUpdateOptions()
+		o.Method = testing.BackupMethodName
+		o.CronExpression = "0 18 * * *"
+		o.Force = true
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		Expect(getSchedule().Spec.Schedules[0].CronExpression).Should(Equal("0 18 * * *"))
+	})
+})
@@ -0,0 +1,695 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	dptypes "github.com/apecloud/kubeblocks/pkg/dataprotection/types"
+	dputils "github.com/apecloud/kubeblocks/pkg/dataprotection/utils"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+const (
+	backupTransferContainer = "transfer"
+	backupTransferMountPath = "/backupdata"
+	// backupTransferChecksumFile saves the SHA256 checksum of the file transferred last in the transfer pod
+	backupTransferChecksumFile = "/tmp/kbcli-backup-transfer.sha256"
+
+	// backupManifestFile describes the downloaded backup, and the backup files are saved in the backupDataDir
+	backupManifestFile = "backup.json"
+	backupDataDir      = "data"
+
+	// uploadedBackupName is the name of the action set, the backup policy and its backup method of the
+	// uploaded backups. The backup type of the action set is not Full, so the backup controller waits for
+	// an external handler, which is kbcli, to handle the uploaded backups instead of running a backup for
+	// them, and never updates their status. The uploaded backup is restored by the backup method in its
+	// status, which is the one of the downloaded backup.
+	uploadedBackupName = "kbcli-uploaded-backup"
+	uploadedBackupType = dpv1alpha1.BackupTypeDifferential
+)
+
+// the scripts run in the transfer pod, the path is passed as the first argument, and datasafed
+// accesses the backup repo by the mounted volume or the tool config injected into the pod.
+// Listing a path which does not exist outputs nothing. The pulled and pushed files are streamed
+// through tee, so their checksums are computed in the pod without reading the files again, and
+// saved in the file passed as the second argument.
+var (
+	listBackupFilesScript = fmt.Sprintf(`set -e; export PATH="$PATH:$%s"; datasafed list -r -f "$1"`, dptypes.DPDatasafedBinPath)
+	pullBackupFileScript  = fmt.Sprintf(`set -e; export PATH="$PATH:$%s"; rm -f "$2" "$2.fifo" "$2.failed"; mkfifo "$2.fifo"
+sha256sum < "$2.fifo" | cut -d' ' -f1 > "$2" &
+{ datasafed pull "$1" - || touch "$2.failed"; } | tee "$2.fifo"; wait $!; rm -f "$2.fifo"; test ! -e "$2.failed"`, dptypes.DPDatasafedBinPath)
+	pushBackupFileScript = fmt.Sprintf(`set -e; export PATH="$PATH:$%s"; rm -f "$2" "$2.fifo"; mkfifo "$2.fifo"
+sha256sum < "$2.fifo" | cut -d' ' -f1 > "$2" &
+tee "$2.fifo" | datasafed push - "$1"; wait $!; rm -f "$2.fifo"; cat "$2"`, dptypes.DPDatasafedBinPath)
+	readChecksumScript = `cat "$1"`
+)
+
+// backupManifest is the manifest of the downloaded backup, it is used to create the backup on upload.
+type backupManifest struct {
+	Name        string                  `json:"name"`
+	Namespace   string                  `json:"namespace"`
+	BackupRepo  string                  `json:"backupRepo"`
+	Path        string                  `json:"path"`
+	Labels      map[string]string       `json:"labels,omitempty"`
+	Annotations map[string]string       `json:"annotations,omitempty"`
+	Spec        dpv1alpha1.BackupSpec   `json:"spec"`
+	Status      dpv1alpha1.BackupStatus `json:"status"`
+	Files       []backupFile            `json:"files"`
+}
+
+// backupFile is a file of the backup, the path is relative to the backup path in the backup repo.
+type backupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// backupTransferOptions transfers the backup files between the backup repo and the local disk by
+// a short-lived pod which accesses the backup repo, the files are streamed by the exec channel.
+type backupTransferOptions struct {
+	Factory   cmdutil.Factory
+	Dynamic   dynamic.Interface
+	Client    kubernetes.Interface
+	Namespace string
+
+	Image   string
+	Timeout time.Duration
+
+	// execInPod runs the command in the container of the pod, streaming the stdin and stdout
+	execInPod func(pod *corev1.Pod, container string, command []string, in io.Reader, out io.Writer) error
+	genericiooptions.IOStreams
+}
+
+type DownloadBackupOptions struct {
+	backupTransferOptions
+
+	BackupName string
+	Dir        string
+
+	backup *dpv1alpha1.Backup
+	repo   *dpv1alpha1.BackupRepo
+}
+
+type UploadBackupOptions struct {
+	backupTransferOptions
+
+	Dir  string
+	Repo string
+	// Name is the name of the uploaded backup, it defaults to the name of the downloaded backup
+	Name string
+
+	manifest *backupManifest
+	repo     *dpv1alpha1.BackupRepo
+}
+
+func newBackupTransferOptions(f cmdutil.Factory, streams genericiooptions.IOStreams) backupTransferOptions {
+	return backupTransferOptions{
+		Factory:   f,
+		Timeout:   5 * time.Minute,
+		execInPod: newStreamCommandExecutor(f),
+		IOStreams: streams,
+	}
+}
+
+func NewDownloadBackupOptions(f cmdutil.Factory, streams genericiooptions.IOStreams) *DownloadBackupOptions {
+	return &DownloadBackupOptions{backupTransferOptions: newBackupTransferOptions(f, streams)}
+}
+
+func NewUploadBackupOptions(f cmdutil.Factory, streams genericiooptions.IOStreams) *UploadBackupOptions {
+	return &UploadBackupOptions{backupTransferOptions: newBackupTransferOptions(f, streams)}
+}
+
+func (o *backupTransferOptions) complete() error {
+	var err error
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	o.Client, err = o.Factory.KubernetesClientSet()
+	return err
+}
+
+// startTransferPod starts the pod which accesses the backup repo, and waits for it to be running.
+func (o *backupTransferOptions) startTransferPod(repo *dpv1alpha1.BackupRepo, backupPath string) (*corev1.Pod, error) {
+	if o.Image == "" {
+		image, err := util.GetKubeBlocksToolsImage(o.Client)
+		if err != nil {
+			return nil, fmt.Errorf("%v, please specify the image by --image", err)
+		}
+		o.Image = image
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kbcli-backup-transfer-" + rand.String(5),
+			Namespace: o.Namespace,
+			Labels:    map[string]string{constant.AppManagedByLabelKey: "kbcli"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:    backupTransferContainer,
+				Image:   o.Image,
+				Command: []string{"sh", "-c", "sleep 86400"},
+			}},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	dputils.InjectDatasafed(&pod.Spec, repo, backupTransferMountPath, backupPath)
+	pod, err := o.Client.CoreV1().Pods(o.Namespace).Create(context.TODO(), pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(o.Out, "Waiting for pod %s to access the backup repo %s, timeout: %s\n", pod.Name, repo.Name, o.Timeout)
+	err = wait.PollUntilContextTimeout(context.Background(), time.Second, o.Timeout, true, func(_ context.Context) (bool, error) {
+		if pod, err = o.Client.CoreV1().Pods(o.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{}); err != nil {
+			return false, err
+		}
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			return false, fmt.Errorf("pod %s is %s", pod.Name, pod.Status.Phase)
+		}
+		return pod.Status.Phase == corev1.PodRunning, nil
+	})
+	if err != nil {
+		o.deleteTransferPod(pod)
+		if wait.Interrupted(err) {
+			return nil, fmt.Errorf("timed out waiting for pod %s to be running", pod.Name)
+		}
+		return nil, err
+	}
+	return pod, nil
+}
+
+func (o *backupTransferOptions) deleteTransferPod(pod *corev1.Pod) {
+	err := o.Client.CoreV1().Pods(o.Namespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		fmt.Fprintf(o.ErrOut, "failed to delete pod %s: %v\n", pod.Name, err)
+	}
+}
+
+// listRemoteFiles lists the files in the backup path of the repo, the paths are relative to the backup path.
+func (o *backupTransferOptions) listRemoteFiles(pod *corev1.Pod, backupPath string) ([]string, error) {
+	out := &bytes.Buffer{}
+	if err := o.execInPod(pod, backupTransferContainer, []string{"sh", "-c", listBackupFilesScript, "datasafed", backupPath}, nil, out); err != nil {
+		return nil, fmt.Errorf("failed to list the backup files in %s: %v", backupPath, err)
+	}
+	var files []string
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		file := strings.TrimSpace(scanner.Text())
+		if file == "" {
+			continue
+		}
+		rel, err := relativeBackupFilePath(backupPath, file)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files, scanner.Err()
+}
+
+// readChecksum reads the checksum of the file transferred last, which is computed in the transfer pod.
+func (o *backupTransferOptions) readChecksum(pod *corev1.Pod) (string, error) {
+	out := &bytes.Buffer{}
+	if err := o.execInPod(pod, backupTransferContainer, []string{"sh", "-c", readChecksumScript, "datasafed", backupTransferChecksumFile}, nil, out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// relativeBackupFilePath returns the path of the file relative to the backup path, the files outside
+// the backup path are refused as they would be written outside the local directory.
+func relativeBackupFilePath(backupPath, file string) (string, error) {
+	base := path.Clean("/" + backupPath)
+	if !strings.HasPrefix(file, "/") {
+		file = path.Join(base, file)
+	}
+	file = path.Clean(file)
+	if !strings.HasPrefix(file, base+"/") {
+		return "", fmt.Errorf("file %s is not in the backup path %s", file, backupPath)
+	}
+	return strings.TrimPrefix(file, base+"/"), nil
+}
+
+// getBackupRepo gets the backup repo, it returns the default backup repo if the name is empty.
+func (o *backupTransferOptions) getBackupRepo(name string) (*dpv1alpha1.BackupRepo, error) {
+	if name != "" {
+		repo := &dpv1alpha1.BackupRepo{}
+		if err := util.GetK8SClientObject(o.Dynamic, repo, types.BackupRepoGVR(), "", name); err != nil {
+			return nil, err
+		}
+		return repo, nil
+	}
+	objs, err := o.Dynamic.Resource(types.BackupRepoGVR()).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs.Items {
+		repo := &dpv1alpha1.BackupRepo{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, repo); err != nil {
+			return nil, err
+		}
+		if repo.Status.IsDefault {
+			return repo, nil
+		}
+	}
+	return nil, fmt.Errorf("no default backup repo is found, please specify it by --repo")
+}
+
+func (o *DownloadBackupOptions) Complete(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("the backup name is required")
+	}
+	o.BackupName = args[0]
+	if o.Dir == "" {
+		o.Dir = o.BackupName
+	}
+	return o.complete()
+}
+
+func (o *DownloadBackupOptions) Validate() error {
+	o.backup = &dpv1alpha1.Backup{}
+	if err := util.GetK8SClientObject(o.Dynamic, o.backup, types.BackupGVR(), o.Namespace, o.BackupName); err != nil {
+		return err
+	}
+	if o.backup.Status.Phase != dpv1alpha1.BackupPhaseCompleted {
+		return fmt.Errorf("backup %s is %s, only the completed backup can be downloaded", o.BackupName, o.backup.Status.Phase)
+	}
+	if o.backup.Status.BackupRepoName == "" || o.backup.Status.Path == "" {
+		return fmt.Errorf("backup %s has no files in a backup repo, the volume snapshot backup can not be downloaded", o.BackupName)
+	}
+	var err error
+	if o.repo, err = o.getBackupRepo(o.backup.Status.BackupRepoName); err != nil {
+		return err
+	}
+	if _, err = os.Stat(filepath.Join(o.Dir, backupManifestFile)); err == nil {
+		return fmt.Errorf("a backup has been downloaded to %s", o.Dir)
+	}
+	return nil
+}
+
+// Run downloads the backup files into the data directory and verifies their checksums, the manifest
+// is written at last, so a directory without the manifest is an incomplete download.
+func (o *DownloadBackupOptions) Run() error {
+	pod, err := o.startTransferPod(o.repo, o.backup.Status.Path)
+	if err != nil {
+		return err
+	}
+	defer o.deleteTransferPod(pod)
+
+	files, err := o.listRemoteFiles(pod, o.backup.Status.Path)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no file of backup %s is found in %s of the backup repo %s", o.BackupName, o.backup.Status.Path, o.repo.Name)
+	}
+	manifest := &backupManifest{
+		Name:        o.backup.Name,
+		Namespace:   o.backup.Namespace,
+		BackupRepo:  o.repo.Name,
+		Path:        o.backup.Status.Path,
+		Labels:      o.backup.Labels,
+		Annotations: o.backup.Annotations,
+		Spec:        o.backup.Spec,
+		Status:      o.backup.Status,
+	}
+	for _, rel := range files {
+		file, err := o.downloadFile(pod, rel)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, *file)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(o.Dir, backupManifestFile), data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Backup %s is downloaded to %s\n", o.BackupName, o.Dir)
+	return nil
+}
+
+// downloadFile downloads the file and computes its checksum while streaming it, the checksum is verified
+// with the one computed in the transfer pod.
+func (o *DownloadBackupOptions) downloadFile(pod *corev1.Pod, rel string) (*backupFile, error) {
+	local := filepath.Join(o.Dir, backupDataDir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(local)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	counter := &countingWriter{}
+	command := []string{"sh", "-c", pullBackupFileScript, "datasafed", path.Join(o.backup.Status.Path, rel), backupTransferChecksumFile}
+	if err = o.execInPod(pod, backupTransferContainer, command, nil, io.MultiWriter(f, hash, counter)); err != nil {
+		return nil, fmt.Errorf("failed to download file %s: %v", rel, err)
+	}
+	sum, err := o.readChecksum(pod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the checksum of file %s: %v", rel, err)
+	}
+	file := &backupFile{Path: rel, Size: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	if file.SHA256 != sum {
+		return nil, fmt.Errorf("the checksum of the downloaded file %s is %s, but %s is expected", rel, file.SHA256, sum)
+	}
+	fmt.Fprintf(o.Out, "Downloaded %s (%d bytes)\n", rel, file.Size)
+	return file, nil
+}
+
+func (o *UploadBackupOptions) Complete(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("the directory of the downloaded backup is required")
+	}
+	o.Dir = args[0]
+	data, err := os.ReadFile(filepath.Join(o.Dir, backupManifestFile))
+	if err != nil {
+		return fmt.Errorf("failed to read the manifest of the downloaded backup: %v", err)
+	}
+	o.manifest = &backupManifest{}
+	if err = json.Unmarshal(data, o.manifest); err != nil {
+		return fmt.Errorf("failed to parse the manifest of the downloaded backup: %v", err)
+	}
+	if o.Name == "" {
+		o.Name = o.manifest.Name
+	}
+	return o.complete()
+}
+
+func (o *UploadBackupOptions) Validate() error {
+	var err error
+	if o.repo, err = o.getBackupRepo(o.Repo); err != nil {
+		return err
+	}
+	if o.repo.Status.Phase != dpv1alpha1.BackupRepoReady {
+		return fmt.Errorf("backup repo %s is not ready, current phase: %s", o.repo.Name, o.repo.Status.Phase)
+	}
+	// the PVC or the tool config of the backup repo is prepared in the namespace when it is used by a backup
+	if o.repo.AccessByMount() {
+		_, err = o.Client.CoreV1().PersistentVolumeClaims(o.Namespace).Get(context.TODO(), o.repo.Status.BackupPVCName, metav1.GetOptions{})
+	} else {
+		_, err = o.Client.CoreV1().Secrets(o.Namespace).Get(context.TODO(), o.repo.Status.ToolConfigSecretName, metav1.GetOptions{})
+	}
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("backup repo %s is not accessible in namespace %s yet, please create a backup in the namespace with it first", o.repo.Name, o.Namespace)
+	} else if err != nil {
+		return err
+	}
+	_, err = o.Dynamic.Resource(types.BackupGVR()).Namespace(o.Namespace).Get(context.TODO(), o.Name, metav1.GetOptions{})
+	if err == nil {
+		return fmt.Errorf("backup %s already exists in namespace %s, please specify another name by --name", o.Name, o.Namespace)
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+	// the action set and the backup policy of the uploaded backups are created by kbcli if they do not exist
+	for _, r := range []struct {
+		gvr       schema.GroupVersionResource
+		namespace string
+	}{{types.ActionSetGVR(), ""}, {types.BackupPolicyGVR(), o.Namespace}} {
+		obj, err := o.Dynamic.Resource(r.gvr).Namespace(r.namespace).Get(context.TODO(), uploadedBackupName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if obj.GetLabels()[constant.AppManagedByLabelKey] != "kbcli" {
+			return fmt.Errorf("%s %s is reserved for the uploaded backups, but it is not created by kbcli", obj.GetKind(), uploadedBackupName)
+		}
+	}
+	return o.verifyLocalFiles()
+}
+
+// verifyLocalFiles verifies the checksums of the downloaded files before uploading them.
+func (o *UploadBackupOptions) verifyLocalFiles() error {
+	if len(o.manifest.Files) == 0 {
+		return fmt.Errorf("no backup file is found in the manifest of %s", o.Dir)
+	}
+	for _, file := range o.manifest.Files {
+		f, err := os.Open(o.localPath(file))
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if sum := hex.EncodeToString(hash.Sum(nil)); sum != file.SHA256 {
+			return fmt.Errorf("the checksum of the local file %s is %s, but %s is expected, the file may be corrupted", file.Path, sum, file.SHA256)
+		}
+	}
+	return nil
+}
+
+func (o *UploadBackupOptions) localPath(file backupFile) string {
+	return filepath.Join(o.Dir, backupDataDir, filepath.FromSlash(file.Path))
+}
+
+// backupPath returns the path of the uploaded backup, the path prefix between the namespace and
+// the name of the downloaded backup is kept.
+func (o *UploadBackupOptions) backupPath() string {
+	prefix := strings.TrimPrefix(path.Clean("/"+o.manifest.Path), "/"+o.manifest.Namespace)
+	prefix = strings.TrimSuffix(prefix, "/"+o.manifest.Name)
+	return path.Join("/", o.Namespace, prefix, o.Name)
+}
+
+// Run uploads the files to the backup repo and verifies their checksums, then creates the backup
+// which refers to the uploaded files, so it can be restored like the source backup.
+func (o *UploadBackupOptions) Run() error {
+	// the backup policy is created before uploading the files, so it has been observed by the backup
+	// controller when the backup is created, otherwise the backup controller fails the backup
+	if err := o.createUploadedBackupPolicy(); err != nil {
+		return err
+	}
+	backupPath := o.backupPath()
+	pod, err := o.startTransferPod(o.repo, backupPath)
+	if err != nil {
+		return err
+	}
+	defer o.deleteTransferPod(pod)
+
+	files, err := o.listRemoteFiles(pod, backupPath)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("path %s of the backup repo %s is not empty", backupPath, o.repo.Name)
+	}
+	for _, file := range o.manifest.Files {
+		if err = o.uploadFile(pod, backupPath, file); err != nil {
+			return err
+		}
+	}
+	if err = o.createBackup(backupPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Backup %s is uploaded to %s of the backup repo %s\n", o.Name, backupPath, o.repo.Name)
+	return nil
+}
+
+// uploadFile uploads the file and verifies the checksum computed in the transfer pod while pushing it.
+func (o *UploadBackupOptions) uploadFile(pod *corev1.Pod, backupPath string, file backupFile) error {
+	f, err := os.Open(o.localPath(file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	out := &bytes.Buffer{}
+	command := []string{"sh", "-c", pushBackupFileScript, "datasafed", path.Join(backupPath, file.Path), backupTransferChecksumFile}
+	if err = o.execInPod(pod, backupTransferContainer, command, f, out); err != nil {
+		return fmt.Errorf("failed to upload file %s: %v", file.Path, err)
+	}
+	if sum := strings.TrimSpace(out.String()); sum != file.SHA256 {
+		return fmt.Errorf("the checksum of the uploaded file %s is %q, but %s is expected", file.Path, sum, file.SHA256)
+	}
+	fmt.Fprintf(o.Out, "Uploaded %s (%d bytes)\n", file.Path, file.Size)
+	return nil
+}
+
+// createUploadedBackupPolicy creates the action set and the backup policy of the uploaded backups if they
+// do not exist. The backup policy targets no pod, as the uploaded backups are never run.
+func (o *UploadBackupOptions) createUploadedBackupPolicy() error {
+	labels := map[string]string{constant.AppManagedByLabelKey: "kbcli"}
+	actionSet := &dpv1alpha1.ActionSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: fmt.Sprintf("%s/%s", types.DPAPIGroup, types.DPAPIVersion),
+			Kind:       types.KindActionSet,
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: uploadedBackupName, Labels: labels},
+		Spec:       dpv1alpha1.ActionSetSpec{BackupType: uploadedBackupType},
+	}
+	policy := &dpv1alpha1.BackupPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: fmt.Sprintf("%s/%s", types.DPAPIGroup, types.DPAPIVersion),
+			Kind:       types.KindBackupPolicy,
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: uploadedBackupName, Namespace: o.Namespace, Labels: labels},
+		Spec: dpv1alpha1.BackupPolicySpec{
+			BackupRepoName: &o.repo.Name,
+			Target: &dpv1alpha1.BackupTarget{
+				PodSelector: &dpv1alpha1.PodSelector{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{constant.AppInstanceLabelKey: uploadedBackupName}},
+				},
+				ServiceAccountName: "default",
+			},
+			BackupMethods: []dpv1alpha1.BackupMethod{{Name: uploadedBackupName, ActionSetName: uploadedBackupName}},
+		},
+	}
+	for _, r := range []struct {
+		gvr       schema.GroupVersionResource
+		namespace string
+		obj       runtime.Object
+	}{{types.ActionSetGVR(), "", actionSet}, {types.BackupPolicyGVR(), o.Namespace, policy}} {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.obj)
+		if err != nil {
+			return err
+		}
+		_, err = o.Dynamic.Resource(r.gvr).Namespace(r.namespace).Create(context.TODO(), &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// createBackup creates the backup of the uploaded backup policy and marks it completed with the status of
+// the downloaded backup. The labels of the downloaded backup are not kept, as the uploaded backup does
+// not belong to the source cluster, and it is not cleaned up by the backup schedule of the source cluster.
+func (o *UploadBackupOptions) createBackup(backupPath string) error {
+	backup := &dpv1alpha1.Backup{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: fmt.Sprintf("%s/%s", types.DPAPIGroup, types.DPAPIVersion),
+			Kind:       types.KindBackup,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        o.Name,
+			Namespace:   o.Namespace,
+			Labels:      map[string]string{constant.AppManagedByLabelKey: "kbcli"},
+			Annotations: o.manifest.Annotations,
+		},
+		Spec: o.manifest.Spec,
+	}
+	if backupType, ok := o.manifest.Labels[dptypes.BackupTypeLabelKey]; ok {
+		backup.Labels[dptypes.BackupTypeLabelKey] = backupType
+	}
+	backup.Spec.BackupPolicyName = uploadedBackupName
+	backup.Spec.BackupMethod = uploadedBackupName
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(backup)
+	if err != nil {
+		return err
+	}
+	if _, err = o.Dynamic.Resource(types.BackupGVR()).Namespace(o.Namespace).Create(context.TODO(), &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+
+	status := o.manifest.Status
+	status.Phase = dpv1alpha1.BackupPhaseCompleted
+	status.BackupRepoName = o.repo.Name
+	status.Path = backupPath
+	status.PersistentVolumeClaimName = ""
+	if o.repo.AccessByMount() {
+		status.PersistentVolumeClaimName = o.repo.Status.BackupPVCName
+	}
+	statusObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return err
+	}
+	// the expiration of the downloaded backup may have passed, which deletes the uploaded backup at once
+	statusObj["expiration"] = nil
+	patch, err := json.Marshal(map[string]interface{}{"status": statusObj})
+	if err != nil {
+		return err
+	}
+	_, err = o.Dynamic.Resource(types.BackupGVR()).Namespace(o.Namespace).Patch(context.TODO(), o.Name, k8stypes.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	return err
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// newStreamCommandExecutor returns a function to run the command in the container of the pod,
+// the stdin and stdout are streamed instead of being buffered.
+func newStreamCommandExecutor(f cmdutil.Factory) func(pod *corev1.Pod, container string, command []string, in io.Reader, out io.Writer) error {
+	return func(pod *corev1.Pod, container string, command []string, in io.Reader, out io.Writer) error {
+		stderr := &bytes.Buffer{}
+		execOptions := action.NewExecOptions(f, genericiooptions.IOStreams{In: in, Out: out, ErrOut: stderr})
+		execOptions.Stdin = in != nil
+		execOptions.TTY = false
+		execOptions.Quiet = true
+		if err := execOptions.Complete(); err != nil {
+			return err
+		}
+		execOptions.Pod = pod
+		execOptions.ContainerName = container
+		execOptions.Command = command
+		if err := execOptions.Run(); err != nil {
+			return fmt.Errorf("%s, %s", err.Error(), strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+}
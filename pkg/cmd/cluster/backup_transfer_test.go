@@ -0,0 +1,272 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	kubefakeclient "k8s.io/client-go/kubernetes/fake"
+	clientfake "k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	dptypes "github.com/apecloud/kubeblocks/pkg/dataprotection/types"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var _ = Describe("backup transfer", func() {
+	const (
+		backupName = "mybackup"
+		backupPath = "/" + testing.Namespace + "/mycluster-uid/mysql/mybackup"
+		repoName   = "myrepo"
+	)
+
+	var (
+		streams genericiooptions.IOStreams
+		tf      *cmdtesting.TestFactory
+		client  *kubefakeclient.Clientset
+		dir     string
+		// storage is the files in the backup repo, keyed by the path
+		storage map[string]string
+	)
+
+	checksum := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+
+	execInPod := func(pod *corev1.Pod, container string, command []string, in io.Reader, out io.Writer) error {
+		Expect(container).Should(Equal(backupTransferContainer))
+		script, file := command[2], command[4]
+		switch script {
+		case listBackupFilesScript:
+			for p := range storage {
+				if strings.HasPrefix(p, file+"/") {
+					fmt.Fprintln(out, p)
+				}
+			}
+		case pullBackupFileScript:
+			Expect(command[5]).Should(Equal(backupTransferChecksumFile))
+			storage[command[5]] = checksum(storage[file])
+			_, err := io.WriteString(out, storage[file])
+			return err
+		case pushBackupFileScript:
+			data, err := io.ReadAll(in)
+			storage[file] = string(data)
+			_, _ = fmt.Fprintln(out, checksum(string(data)))
+			return err
+		case readChecksumScript:
+			_, err := io.WriteString(out, storage[file]+"\n")
+			return err
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		streams, _, _, _ = genericiooptions.NewTestIOStreams()
+		backup := testing.FakeBackup(backupName)
+		backup.Status.Phase = dpv1alpha1.BackupPhaseCompleted
+		backup.Status.BackupRepoName = repoName
+		backup.Status.Path = backupPath
+		backup.Labels = map[string]string{
+			constant.AppInstanceLabelKey:   testing.ClusterName,
+			dptypes.BackupScheduleLabelKey: "mycluster-mysql-backup-schedule",
+			dptypes.BackupTypeLabelKey:     string(dpv1alpha1.BackupTypeFull),
+		}
+		expiration := metav1.NewTime(time.Now().Add(-time.Hour))
+		backup.Status.Expiration = &expiration
+		repo := &dpv1alpha1.BackupRepo{}
+		repo.Name = repoName
+		repo.Spec.AccessMethod = dpv1alpha1.AccessMethodTool
+		repo.Status.Phase = dpv1alpha1.BackupRepoReady
+		repo.Status.ToolConfigSecretName = "tool-config-myrepo"
+		repo.Status.IsDefault = true
+
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(backup, repo)
+		secret := &corev1.Secret{}
+		secret.Name = repo.Status.ToolConfigSecretName
+		secret.Namespace = testing.Namespace
+		kbDeploy := testing.FakeKBDeploy("0.8.0")
+		kbDeploy.Namespace = testing.Namespace
+		kbDeploy.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "manager",
+			Env:  []corev1.EnvVar{{Name: "KUBEBLOCKS_TOOLS_IMAGE", Value: "apecloud/kubeblocks-tools:0.8.0"}},
+		}}
+		client = testing.FakeClientSet(secret, kbDeploy)
+		// the transfer pod is running once it is created
+		client.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			pod := action.(clienttesting.CreateAction).GetObject().(*corev1.Pod)
+			Expect(pod.Spec.Containers[0].Image).Should(Equal("apecloud/kubeblocks-tools:0.8.0"))
+			pod.Status.Phase = corev1.PodRunning
+			return false, nil, nil
+		})
+		dir, _ = os.MkdirTemp(os.TempDir(), "test-")
+		storage = map[string]string{
+			backupPath + "/mysql.xbstream":            "full backup data",
+			backupPath + "/binlog/0001":               "binlog data",
+			"/" + testing.Namespace + "/another/file": "another backup",
+		}
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+		os.RemoveAll(dir)
+	})
+
+	download := func() error {
+		o := NewDownloadBackupOptions(tf, streams)
+		o.Dir = dir
+		Expect(o.Complete([]string{backupName})).Should(Succeed())
+		o.Client = client
+		o.execInPod = execInPod
+		if err := o.Validate(); err != nil {
+			return err
+		}
+		return o.Run()
+	}
+
+	newUploadOptions := func() *UploadBackupOptions {
+		o := NewUploadBackupOptions(tf, streams)
+		o.Name = "mybackup-copy"
+		Expect(o.Complete([]string{dir})).Should(Succeed())
+		o.Client = client
+		o.execInPod = execInPod
+		return o
+	}
+
+	It("get the relative path of the backup file", func() {
+		Expect(relativeBackupFilePath(backupPath, backupPath+"/binlog/0001")).Should(Equal("binlog/0001"))
+		Expect(relativeBackupFilePath(backupPath, "mysql.xbstream")).Should(Equal("mysql.xbstream"))
+		_, err := relativeBackupFilePath(backupPath, backupPath+"/../another/file")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("download and upload the backup", func() {
+		Expect(download()).Should(Succeed())
+		data, err := os.ReadFile(filepath.Join(dir, backupDataDir, "binlog", "0001"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal("binlog data"))
+		Expect(filepath.Join(dir, backupDataDir, "file")).ShouldNot(BeAnExistingFile())
+		Expect(download()).Should(MatchError(ContainSubstring("has been downloaded")))
+		pods, err := client.CoreV1().Pods(testing.Namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(pods.Items).Should(BeEmpty())
+
+		By("upload the backup with another name")
+		o := newUploadOptions()
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		Expect(storage).Should(HaveKeyWithValue("/"+testing.Namespace+"/mycluster-uid/mysql/mybackup-copy/mysql.xbstream", "full backup data"))
+		Expect(storage).Should(HaveKeyWithValue("/"+testing.Namespace+"/mycluster-uid/mysql/mybackup-copy/binlog/0001", "binlog data"))
+		backup := &dpv1alpha1.Backup{}
+		Expect(util.GetK8SClientObject(tf.FakeDynamicClient, backup, types.BackupGVR(), testing.Namespace, "mybackup-copy")).Should(Succeed())
+		Expect(backup.Status.Phase).Should(Equal(dpv1alpha1.BackupPhaseCompleted))
+		Expect(backup.Status.BackupRepoName).Should(Equal(repoName))
+		Expect(backup.Status.Path).Should(Equal("/" + testing.Namespace + "/mycluster-uid/mysql/mybackup-copy"))
+		Expect(backup.Status.Expiration).Should(BeNil())
+		Expect(backup.Spec.BackupPolicyName).Should(Equal(uploadedBackupName))
+		Expect(backup.Labels).Should(Equal(map[string]string{
+			constant.AppManagedByLabelKey: "kbcli",
+			dptypes.BackupTypeLabelKey:    string(dpv1alpha1.BackupTypeFull),
+		}))
+		actionSet := &dpv1alpha1.ActionSet{}
+		Expect(util.GetK8SClientObject(tf.FakeDynamicClient, actionSet, types.ActionSetGVR(), "", uploadedBackupName)).Should(Succeed())
+		Expect(actionSet.Spec.BackupType).ShouldNot(Equal(dpv1alpha1.BackupTypeFull))
+		policy := &dpv1alpha1.BackupPolicy{}
+		Expect(util.GetK8SClientObject(tf.FakeDynamicClient, policy, types.BackupPolicyGVR(), testing.Namespace, uploadedBackupName)).Should(Succeed())
+		Expect(policy.Spec.BackupMethods).Should(Equal([]dpv1alpha1.BackupMethod{{Name: uploadedBackupName, ActionSetName: uploadedBackupName}}))
+
+		By("upload the backup again with the existing backup policy")
+		o = newUploadOptions()
+		o.Name = "mybackup-copy-2"
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+
+		By("the existing backup can not be overwritten")
+		Expect(newUploadOptions().Validate()).Should(MatchError(ContainSubstring("already exists")))
+
+		By("the corrupted local files are refused")
+		Expect(os.WriteFile(filepath.Join(dir, backupDataDir, "mysql.xbstream"), []byte("corrupted"), 0644)).Should(Succeed())
+		o = newUploadOptions()
+		o.Name = "mybackup-corrupted"
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("may be corrupted")))
+	})
+
+	It("verify the checksums of the downloaded files", func() {
+		exec := execInPod
+		execInPod = func(pod *corev1.Pod, container string, command []string, in io.Reader, out io.Writer) error {
+			if command[2] == pullBackupFileScript {
+				storage[command[5]] = checksum(storage[command[4]])
+				_, err := io.WriteString(out, "truncated")
+				return err
+			}
+			return exec(pod, container, command, in, out)
+		}
+		DeferCleanup(func() { execInPod = exec })
+		Expect(download()).Should(MatchError(ContainSubstring("but " + checksum("binlog data") + " is expected")))
+	})
+
+	It("refuse the backup policy of the uploaded backups not created by kbcli", func() {
+		Expect(download()).Should(Succeed())
+		policy, err := runtime.DefaultUnstructuredConverter.ToUnstructured(testing.FakeBackupPolicy(uploadedBackupName, testing.ClusterName))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(tf.FakeDynamicClient.Tracker().Add(&unstructured.Unstructured{Object: policy})).Should(Succeed())
+		Expect(newUploadOptions().Validate()).Should(MatchError(ContainSubstring("not created by kbcli")))
+	})
+
+	It("verify the checksums of the uploaded files", func() {
+		Expect(download()).Should(Succeed())
+		exec := execInPod
+		execInPod = func(pod *corev1.Pod, container string, command []string, in io.Reader, out io.Writer) error {
+			if command[2] == pushBackupFileScript {
+				_, _ = io.ReadAll(io.LimitReader(in, 3))
+				_, err := fmt.Fprintln(out, checksum("tru"))
+				return err
+			}
+			return exec(pod, container, command, in, out)
+		}
+		DeferCleanup(func() { execInPod = exec })
+		o := newUploadOptions()
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(MatchError(ContainSubstring("but " + checksum("binlog data") + " is expected")))
+	})
+})
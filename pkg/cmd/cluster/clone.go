@@ -0,0 +1,380 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	"github.com/apecloud/kubeblocks/pkg/dataprotection/utils/boolptr"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var cloneExample = templates.Examples(`
+	# clone the cluster mycluster to a new cluster mycluster-debug
+	kbcli cluster clone mycluster mycluster-debug
+
+	# clone the cluster to the namespace debug with the specified backup method
+	kbcli cluster clone mycluster mycluster-debug --target-namespace debug --backup-method xtrabackup
+
+	# reuse the backup completed in the last 6 hours if there is one
+	kbcli cluster clone mycluster mycluster-debug --max-age 6h
+
+	# clone the cluster with less resources, and delete the backup after the cluster is restored
+	kbcli cluster clone mycluster mycluster-debug --set cpu=1,memory=1Gi --cleanup-backup`)
+
+type CloneOptions struct {
+	Factory   cmdutil.Factory
+	Dynamic   dynamic.Interface
+	Client    kubernetes.Interface
+	Namespace string
+
+	Source          string
+	Target          string
+	TargetNamespace string
+	BackupMethod    string
+	BackupPolicy    string
+	MaxAge          time.Duration
+	Values          []string
+	CleanupBackup   bool
+	Timeout         time.Duration
+
+	sourceCluster *appsv1alpha1.Cluster
+	// backupName is the name of the backup to restore, reused is true if it is an existing backup
+	backupName string
+	reused     bool
+	// interval is the interval to poll the backup phase
+	interval time.Duration
+
+	genericiooptions.IOStreams
+}
+
+func NewCloneCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &CloneOptions{Factory: f, IOStreams: streams, interval: 5 * time.Second}
+	cmd := &cobra.Command{
+		Use:   "clone SOURCE TARGET",
+		Short: "Clone a cluster to a new cluster by backup and restore.",
+		Long: templates.LongDesc(`
+			Clone a cluster to a new cluster. A backup of the source cluster is created, or a recent completed
+			backup is reused if --max-age is specified. After the backup is completed, the target cluster is
+			restored from the backup, and the backup can be deleted after the restore of the target cluster
+			is completed.`),
+		Example:           cloneExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVar(&o.TargetNamespace, "target-namespace", "", "The namespace of the target cluster, use the namespace of the source cluster if not specified")
+	cmd.Flags().StringVar(&o.BackupMethod, "backup-method", "", "The backup method, if not specified, use the only backup method of the backup policy or the method which snapshots the volumes")
+	cmd.Flags().StringVar(&o.BackupPolicy, "backup-policy", "", "The backup policy, use the default backup policy of the source cluster if not specified")
+	cmd.Flags().DurationVar(&o.MaxAge, "max-age", 0, "Reuse the latest completed backup of the source cluster if it is completed within the max age, 0 means always creating a new backup")
+	cmd.Flags().StringArrayVar(&o.Values, "set", []string{}, "Override the resources of the target cluster including cpu, memory, replicas and storage, each set corresponds to a component (e.g. --set cpu=1,memory=1Gi,replicas=1)")
+	cmd.Flags().BoolVar(&o.CleanupBackup, "cleanup-backup", false, "Delete the backup created by the clone after the restore of the target cluster is completed")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", defaultOpsWaitTimeout, "Time to wait for each phase of the clone")
+	return cmd
+}
+
+func (o *CloneOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 2 {
+		return fmt.Errorf("the source and target cluster names are required")
+	}
+	o.Source, o.Target = args[0], args[1]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.TargetNamespace == "" {
+		o.TargetNamespace = o.Namespace
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	o.Client, err = o.Factory.KubernetesClientSet()
+	return err
+}
+
+func (o *CloneOptions) Validate() error {
+	var err error
+	if o.Source == o.Target && o.Namespace == o.TargetNamespace {
+		return fmt.Errorf("the target cluster can not be the same as the source cluster")
+	}
+	if o.sourceCluster, err = cluster.GetClusterByName(o.Dynamic, o.Source, o.Namespace); err != nil {
+		return err
+	}
+	if _, err = cluster.GetClusterByName(o.Dynamic, o.Target, o.TargetNamespace); err == nil {
+		return fmt.Errorf("cluster %s already exists in namespace %s", o.Target, o.TargetNamespace)
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+	if o.MaxAge < 0 {
+		return fmt.Errorf("max age can not be negative")
+	}
+	return nil
+}
+
+func (o *CloneOptions) Run() error {
+	backup, err := o.findRecentBackup()
+	if err != nil {
+		return err
+	}
+	if backup != nil {
+		o.backupName = backup.Name
+		o.reused = true
+		fmt.Fprintf(o.Out, "Reuse backup %s completed at %s\n", backup.Name, util.TimeFormat(backup.Status.CompletionTimestamp))
+	} else {
+		if err = o.createBackup(); err != nil {
+			return err
+		}
+		if err = o.waitForBackupCompleted(); err != nil {
+			return err
+		}
+	}
+
+	if err = o.createCluster(); err != nil {
+		return err
+	}
+	if err = waitForClusterRunning(o.Dynamic, o.TargetNamespace, o.Target, o.Timeout, o.Out); err != nil {
+		return err
+	}
+	if err = waitForClusterRestored(o.Dynamic, o.TargetNamespace, o.Target, o.Timeout, o.Out); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Cluster %s is cloned to %s in namespace %s\n", o.Source, o.Target, o.TargetNamespace)
+
+	if !o.CleanupBackup {
+		return nil
+	}
+	if o.reused {
+		fmt.Fprintf(o.Out, "Backup %s is reused, skip deleting it\n", o.backupName)
+		return nil
+	}
+	fmt.Fprintf(o.Out, "Deleting backup %s\n", o.backupName)
+	return o.Dynamic.Resource(types.BackupGVR()).Namespace(o.Namespace).Delete(context.TODO(), o.backupName, metav1.DeleteOptions{})
+}
+
+// findRecentBackup finds the latest completed backup of the source cluster within the max age,
+// the backup policy and method should match the specified ones.
+func (o *CloneOptions) findRecentBackup() (*dpv1alpha1.Backup, error) {
+	if o.MaxAge == 0 {
+		return nil, nil
+	}
+	objs, err := o.Dynamic.Resource(types.BackupGVR()).Namespace(o.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", constant.AppInstanceLabelKey, o.Source),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var latest *dpv1alpha1.Backup
+	for _, obj := range objs.Items {
+		backup := &dpv1alpha1.Backup{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, backup); err != nil {
+			return nil, err
+		}
+		completed := backup.Status.CompletionTimestamp
+		if backup.Status.Phase != dpv1alpha1.BackupPhaseCompleted || completed == nil ||
+			time.Since(completed.Time) > o.MaxAge {
+			continue
+		}
+		if o.BackupPolicy != "" && backup.Spec.BackupPolicyName != o.BackupPolicy {
+			continue
+		}
+		if o.BackupMethod != "" && backup.Spec.BackupMethod != o.BackupMethod {
+			continue
+		}
+		if latest == nil || completed.After(latest.Status.CompletionTimestamp.Time) {
+			latest = backup
+		}
+	}
+	return latest, nil
+}
+
+// createBackup creates a backup of the source cluster by the backup OpsRequest.
+func (o *CloneOptions) createBackup() error {
+	backupOpts := &CreateBackupOptions{
+		CreateOptions: action.CreateOptions{
+			Factory:         o.Factory,
+			IOStreams:       o.IOStreams,
+			GVR:             types.OpsGVR(),
+			CueTemplateName: "opsrequest_template.cue",
+			Args:            []string{o.Source},
+			Quiet:           true,
+		},
+	}
+	backupOpts.CreateOptions.Options = backupOpts
+	backupOpts.BackupSpec.BackupPolicyName = o.BackupPolicy
+	backupOpts.BackupSpec.BackupMethod = o.BackupMethod
+	if err := backupOpts.CompleteBackup(); err != nil {
+		return err
+	}
+	backupOpts.Dynamic = o.Dynamic
+	backupOpts.Client = o.Client
+	if backupOpts.BackupSpec.BackupPolicyName == "" {
+		if err := backupOpts.completeDefaultBackupPolicy(); err != nil {
+			return err
+		}
+	}
+	if backupOpts.BackupSpec.BackupMethod == "" {
+		policy := &dpv1alpha1.BackupPolicy{}
+		if err := util.GetK8SClientObject(o.Dynamic, policy, types.BackupPolicyGVR(), o.Namespace, backupOpts.BackupSpec.BackupPolicyName); err != nil {
+			return err
+		}
+		method, err := getDefaultBackupMethod(policy)
+		if err != nil {
+			return err
+		}
+		backupOpts.BackupSpec.BackupMethod = method
+	}
+	if err := backupOpts.Validate(); err != nil {
+		return err
+	}
+
+	o.backupName = backupOpts.BackupSpec.BackupName
+	fmt.Fprintf(o.Out, "Creating backup %s of cluster %s with method %s\n", o.backupName, o.Source, backupOpts.BackupSpec.BackupMethod)
+	return backupOpts.Run()
+}
+
+// waitForBackupCompleted waits for the backup to be completed and prints the changes of its phase.
+func (o *CloneOptions) waitForBackupCompleted() error {
+	fmt.Fprintf(o.Out, "Waiting for backup %s to be completed, timeout: %s\n", o.backupName, o.Timeout)
+	var phase dpv1alpha1.BackupPhase
+	backup := &dpv1alpha1.Backup{}
+	err := wait.PollUntilContextTimeout(context.Background(), o.interval, o.Timeout, true, func(_ context.Context) (bool, error) {
+		if err := util.GetK8SClientObject(o.Dynamic, backup, types.BackupGVR(), o.Namespace, o.backupName); err != nil {
+			// the backup is created by the OpsRequest asynchronously
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		if backup.Status.Phase != phase {
+			phase = backup.Status.Phase
+			fmt.Fprintf(o.Out, "Backup %s is %s\n", o.backupName, phase)
+		}
+		return phase == dpv1alpha1.BackupPhaseCompleted || phase == dpv1alpha1.BackupPhaseFailed, nil
+	})
+	if err != nil {
+		if wait.Interrupted(err) {
+			return fmt.Errorf("timed out waiting for backup %s to be completed, current phase: %s", o.backupName, phase)
+		}
+		return err
+	}
+	if phase == dpv1alpha1.BackupPhaseFailed {
+		return fmt.Errorf("backup %s failed: %s, run \"kbcli cluster describe-backup %s -n %s\" for more details",
+			o.backupName, backup.Status.FailureReason, o.backupName, o.Namespace)
+	}
+	return nil
+}
+
+// createCluster creates the target cluster from the backup in the same way as "kbcli cluster create --backup".
+func (o *CloneOptions) createCluster() error {
+	createOpts, err := newCreateOptionsFromBackup(o.Factory, o.IOStreams, o.sourceCluster, o.Target, o.TargetNamespace, o.backupName, o.Namespace)
+	if err != nil {
+		return err
+	}
+	createOpts.Dynamic = o.Dynamic
+	createOpts.Client = o.Client
+	createOpts.Values = o.Values
+	createOpts.TerminationPolicy = string(o.sourceCluster.Spec.TerminationPolicy)
+	if err = createOpts.Complete(); err != nil {
+		return err
+	}
+	if err = createOpts.Validate(); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Creating cluster %s in namespace %s from backup %s\n", o.Target, o.TargetNamespace, o.backupName)
+	return createOpts.Run()
+}
+
+// newCreateOptionsFromBackup builds the options to create the cluster from the backup, the cluster level
+// settings are inherited from the source cluster.
+func newCreateOptionsFromBackup(f cmdutil.Factory, streams genericiooptions.IOStreams, src *appsv1alpha1.Cluster,
+	name, namespace, backupName, backupNamespace string) (*CreateOptions, error) {
+	createOpts := NewCreateOptions(f, streams)
+	createOpts.Args = []string{name}
+	if err := createOpts.CreateOptions.Complete(); err != nil {
+		return nil, err
+	}
+	createOpts.Namespace = namespace
+	createOpts.Backup = backupName
+	createOpts.BackupNamespace = backupNamespace
+	createOpts.VolumeRestorePolicy = "Parallel"
+	createOpts.Format = printer.YAML
+	createOpts.DryRun = "none"
+	createOpts.Quiet = true
+
+	createOpts.PodAntiAffinity = string(appsv1alpha1.Preferred)
+	createOpts.Tenancy = string(appsv1alpha1.SharedNode)
+	if src.Spec.Affinity != nil {
+		if src.Spec.Affinity.PodAntiAffinity != "" {
+			createOpts.PodAntiAffinity = string(src.Spec.Affinity.PodAntiAffinity)
+		}
+		if src.Spec.Affinity.Tenancy != "" {
+			createOpts.Tenancy = string(src.Spec.Affinity.Tenancy)
+		}
+		createOpts.TopologyKeys = src.Spec.Affinity.TopologyKeys
+		createOpts.NodeLabels = src.Spec.Affinity.NodeLabels
+	}
+	for i := range src.Spec.Tolerations {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&src.Spec.Tolerations[i])
+		if err != nil {
+			return nil, err
+		}
+		createOpts.Tolerations = append(createOpts.Tolerations, obj)
+	}
+	return createOpts, nil
+}
+
+// getDefaultBackupMethod returns the only backup method of the backup policy, or the method which snapshots the volumes.
+func getDefaultBackupMethod(policy *dpv1alpha1.BackupPolicy) (string, error) {
+	methods := policy.Spec.BackupMethods
+	if len(methods) == 1 {
+		return methods[0].Name, nil
+	}
+	for _, method := range methods {
+		if boolptr.IsSetToTrue(method.SnapshotVolumes) {
+			return method.Name, nil
+		}
+	}
+	return "", fmt.Errorf("failed to find the default backup method of backup policy %s, please specify it by --backup-method", policy.Name)
+}
@@ -0,0 +1,164 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	"github.com/apecloud/kubeblocks/pkg/dataprotection/utils/boolptr"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+)
+
+var _ = Describe("cluster clone", func() {
+	const targetName = "test-clone"
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	fakeBackup := func(name string, phase dpv1alpha1.BackupPhase, completedBefore time.Duration) *dpv1alpha1.Backup {
+		backup := testing.FakeBackupWithCluster(testing.FakeCluster(testing.ClusterName, testing.Namespace), name)
+		backup.Spec.BackupMethod = testing.BackupMethodName
+		backup.Status.Phase = phase
+		backup.Status.FailureReason = "fake failure"
+		completed := metav1.NewTime(time.Now().Add(-completedBefore))
+		backup.Status.CompletionTimestamp = &completed
+		return backup
+	}
+
+	newCloneOptions := func(objs ...runtime.Object) *CloneOptions {
+		tf.FakeDynamicClient = testing.FakeDynamicClient(objs...)
+		o := &CloneOptions{Factory: tf, IOStreams: streams, interval: 100 * time.Millisecond, Timeout: time.Second}
+		Expect(o.Complete([]string{testing.ClusterName, targetName})).Should(Succeed())
+		return o
+	}
+
+	It("clone command", func() {
+		Expect(NewCloneCmd(tf, streams)).ShouldNot(BeNil())
+	})
+
+	It("validate", func() {
+		o := newCloneOptions(testing.FakeCluster(testing.ClusterName, testing.Namespace))
+		Expect(o.Validate()).Should(Succeed())
+
+		By("target cluster exists")
+		o = newCloneOptions(testing.FakeCluster(testing.ClusterName, testing.Namespace), testing.FakeCluster(targetName, testing.Namespace))
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("already exists")))
+
+		By("source cluster does not exist")
+		o = newCloneOptions()
+		Expect(o.Validate()).Should(HaveOccurred())
+	})
+
+	It("find the recent backup", func() {
+		o := newCloneOptions(fakeBackup("backup-old", dpv1alpha1.BackupPhaseCompleted, 2*time.Hour),
+			fakeBackup("backup-recent", dpv1alpha1.BackupPhaseCompleted, 10*time.Minute),
+			fakeBackup("backup-failed", dpv1alpha1.BackupPhaseFailed, time.Minute))
+		backup, err := o.findRecentBackup()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(backup).Should(BeNil())
+
+		o.MaxAge = time.Hour
+		backup, err = o.findRecentBackup()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(backup.Name).Should(Equal("backup-recent"))
+
+		o.MaxAge = 3 * time.Hour
+		o.BackupMethod = "other-method"
+		backup, err = o.findRecentBackup()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(backup).Should(BeNil())
+	})
+
+	It("wait for the backup", func() {
+		o := newCloneOptions(fakeBackup("backup-completed", dpv1alpha1.BackupPhaseCompleted, 0),
+			fakeBackup("backup-failed", dpv1alpha1.BackupPhaseFailed, 0))
+		o.backupName = "backup-completed"
+		Expect(o.waitForBackupCompleted()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("Backup backup-completed is Completed"))
+
+		o.backupName = "backup-failed"
+		Expect(o.waitForBackupCompleted()).Should(MatchError(ContainSubstring("fake failure")))
+
+		o.backupName = "backup-not-found"
+		Expect(o.waitForBackupCompleted()).Should(MatchError(ContainSubstring("timed out")))
+	})
+
+	It("wait for the restore of the target cluster", func() {
+		restored := testing.FakeCluster(targetName, testing.Namespace)
+		o := newCloneOptions(restored)
+		Expect(waitForClusterRestored(o.Dynamic, testing.Namespace, targetName, o.Timeout, o.Out)).Should(Succeed())
+
+		By("the PostReady restore is running")
+		restoring := testing.FakeCluster(targetName, testing.Namespace)
+		restoring.Annotations = map[string]string{constant.RestoreFromBackupAnnotationKey: `{"mysql":{"name":"backup-1"}}`}
+		o = newCloneOptions(restoring)
+		Expect(waitForClusterRestored(o.Dynamic, testing.Namespace, targetName, o.Timeout, o.Out)).Should(MatchError(ContainSubstring("timed out")))
+
+		By("the restore failed")
+		restore := &dpv1alpha1.Restore{}
+		restore.Name = targetName + "-mysql-postready"
+		restore.Namespace = testing.Namespace
+		restore.Labels = map[string]string{constant.AppInstanceLabelKey: targetName}
+		restore.Status.Phase = dpv1alpha1.RestorePhaseFailed
+		o = newCloneOptions(restoring, restore)
+		Expect(waitForClusterRestored(o.Dynamic, testing.Namespace, targetName, o.Timeout, o.Out)).Should(MatchError(ContainSubstring("restore " + restore.Name + " of cluster " + targetName + " failed")))
+	})
+
+	It("get the default backup method", func() {
+		policy := testing.FakeBackupPolicy("test-policy", testing.ClusterName)
+		method, err := getDefaultBackupMethod(policy)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(method).Should(Equal(testing.BackupMethodName))
+
+		policy.Spec.BackupMethods = append(policy.Spec.BackupMethods, dpv1alpha1.BackupMethod{Name: "snapshot", SnapshotVolumes: boolptr.True()})
+		method, err = getDefaultBackupMethod(policy)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(method).Should(Equal("snapshot"))
+
+		policy.Spec.BackupMethods = policy.Spec.BackupMethods[:1]
+		policy.Spec.BackupMethods = append(policy.Spec.BackupMethods, dpv1alpha1.BackupMethod{Name: "other"})
+		_, err = getDefaultBackupMethod(policy)
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -48,6 +48,10 @@ func NewClusterCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 			Message: "Basic Cluster Commands:",
 			Commands: []*cobra.Command{
 				NewCreateCmd(f, streams),
+				NewApplyCmd(f, streams),
+				NewExportCmd(f, streams),
+				NewImportCmd(f, streams),
+				NewCloneCmd(f, streams),
 				NewConnectCmd(f, streams),
 				NewDescribeCmd(f, streams),
 				NewListCmd(f, streams),
@@ -74,9 +78,13 @@ func NewClusterCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 				NewDescribeOpsCmd(f, streams),
 				NewListOpsCmd(f, streams),
 				NewDeleteOpsCmd(f, streams),
+				NewListScheduledOpsCmd(f, streams),
+				NewDeleteScheduledOpsCmd(f, streams),
 				NewExposeCmd(f, streams),
 				NewCancelCmd(f, streams),
+				NewRevertOpsCmd(f, streams),
 				NewCustomOpsCmd(f, streams),
+				NewOpsCmd(f, streams),
 			},
 		},
 		{
@@ -87,6 +95,12 @@ func NewClusterCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 				NewDescribeReconfigureCmd(f, streams),
 				NewExplainReconfigureCmd(f, streams),
 				NewDiffConfigureCmd(f, streams),
+				NewConfigHistoryCmd(f, streams),
+				NewRollbackConfigCmd(f, streams),
+				NewTuneCmd(f, streams),
+				NewConfigDriftCmd(f, streams),
+				NewExportConfigCmd(f, streams),
+				NewImportConfigCmd(f, streams),
 			},
 		},
 		{
@@ -100,6 +114,8 @@ func NewClusterCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 				NewDeleteBackupCmd(f, streams),
 				NewCreateRestoreCmd(f, streams),
 				NewDescribeBackupCmd(f, streams),
+				NewListRestorePointsCmd(f, streams),
+				NewVerifyBackupCmd(f, streams),
 			},
 		},
 		{
@@ -107,6 +123,8 @@ func NewClusterCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 			Commands: []*cobra.Command{
 				NewLogsCmd(f, streams),
 				NewListLogsCmd(f, streams),
+				NewWatchCmd(f, streams),
+				NewDiagnoseCmd(f, streams),
 			},
 		},
 
@@ -0,0 +1,571 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/configuration/core"
+	"github.com/apecloud/kubeblocks/pkg/lorry/engines/models"
+	"github.com/apecloud/kubeblocks/pkg/lorry/engines/register"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+	"github.com/apecloud/kbcli/pkg/util/flags"
+)
+
+var configDriftExample = templates.Examples(`
+		# check whether the config files and the runtime parameters of the instances drift from the ConfigMaps
+		kbcli cluster config-drift mycluster
+
+		# check the config file my.cnf of the component mysql and output the drifts in JSON format
+		kbcli cluster config-drift mycluster --components=mysql --config-file=my.cnf -o json
+
+		# make the drifted parameters of the instance mycluster-mysql-0 permanent by a Reconfiguring OpsRequest
+		kbcli cluster config-drift mycluster --instance=mycluster-mysql-0 --persist`)
+
+const (
+	configDriftSourceFile    = "file"
+	configDriftSourceRuntime = "runtime"
+)
+
+// configDrift is a parameter of an instance whose effective value differs from the rendered ConfigMap.
+type configDrift struct {
+	Instance   string `json:"instance"`
+	Component  string `json:"component"`
+	ConfigSpec string `json:"configSpec"`
+	ConfigFile string `json:"configFile"`
+	Parameter  string `json:"parameter"`
+	// Source is where the effective value is read from, the config file in the instance or the runtime of the engine
+	Source string `json:"source"`
+	// Expected is the value in the ConfigMap, nil means the parameter is absent from the ConfigMap
+	Expected *string `json:"expected"`
+	// Actual is the effective value in the instance, nil means the parameter is absent from the instance
+	Actual *string `json:"actual"`
+}
+
+// configDriftReport is the structured output of the config-drift command.
+type configDriftReport struct {
+	Cluster   string        `json:"cluster"`
+	Namespace string        `json:"namespace"`
+	Drifts    []configDrift `json:"drifts"`
+	// Warnings are the instances or checks which are skipped
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// runtimeConfigProbe gets the runtime parameters of an instance by appending the arguments
+// to the connect command of the engine.
+type runtimeConfigProbe struct {
+	args  string
+	parse func(output string) map[string]string
+}
+
+var (
+	mysqlRuntimeConfigProbe = runtimeConfigProbe{
+		args:  `-N -s -e "SHOW GLOBAL VARIABLES"`,
+		parse: parseSeparatedRuntimeConfig("\t"),
+	}
+	postgresRuntimeConfigProbe = runtimeConfigProbe{
+		args:  `-A -t -F "|" -c "SELECT name, current_setting(name) FROM pg_settings"`,
+		parse: parseSeparatedRuntimeConfig("|"),
+	}
+	redisRuntimeConfigProbe = runtimeConfigProbe{
+		args:  `CONFIG GET '*'`,
+		parse: parseRedisRuntimeConfig,
+	}
+
+	// runtimeConfigProbes are the probes of the engines, keyed by the character type
+	runtimeConfigProbes = map[string]runtimeConfigProbe{
+		string(models.MySQL):              mysqlRuntimeConfigProbe,
+		string(models.WeSQL):              mysqlRuntimeConfigProbe,
+		string(models.PolarDBX):           mysqlRuntimeConfigProbe,
+		string(models.PostgreSQL):         postgresRuntimeConfigProbe,
+		string(models.OfficialPostgreSQL): postgresRuntimeConfigProbe,
+		string(models.ApecloudPostgreSQL): postgresRuntimeConfigProbe,
+		string(models.Redis):              redisRuntimeConfigProbe,
+	}
+)
+
+type ConfigDriftOptions struct {
+	Factory   cmdutil.Factory
+	Client    clientset.Interface
+	Dynamic   dynamic.Interface
+	Namespace string
+	Name      string
+
+	Components   []string
+	ConfigSpec   string
+	ConfigFile   string
+	Instances    []string
+	CheckRuntime bool
+	Persist      bool
+	AutoApprove  bool
+	Format       printer.Format
+
+	// execInPod runs the command in the container of the pod and returns the stdout
+	execInPod func(pod *corev1.Pod, container string, command []string) (string, error)
+	genericiooptions.IOStreams
+}
+
+func NewConfigDriftCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ConfigDriftOptions{
+		Factory:   f,
+		IOStreams: streams,
+		execInPod: newInstanceCommandExecutor(f),
+	}
+	cmd := &cobra.Command{
+		Use:   "config-drift NAME",
+		Short: "Detect the configuration drift between the ConfigMaps and the running instances.",
+		Long: templates.LongDesc(`
+			Detect the configuration drift between the ConfigMaps and the running instances.
+			The effective config files are read from the instances, and the runtime parameters are
+			read from the engines which support it (MySQL, PostgreSQL and Redis), then they are compared
+			with the rendered ConfigMaps. With --persist, the drifted values are made permanent by
+			Reconfiguring OpsRequests, except the parameters absent from the instances, which are
+			kept in the ConfigMaps.`),
+		Example:           configDriftExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	printer.AddOutputFlag(cmd, &o.Format)
+	flags.AddComponentsFlag(f, cmd, &o.Components, "Specify the name of the components to check. If not specified, all the components are checked.")
+	cmd.Flags().StringVar(&o.ConfigSpec, "config-spec", "", "Specify the name of the configuration template to check. If not specified, all the configuration templates are checked.")
+	cmd.Flags().StringVar(&o.ConfigFile, "config-file", "", "Specify the name of the configuration file to check. If not specified, all the reconfigurable files are checked.")
+	cmd.Flags().StringSliceVar(&o.Instances, "instance", nil, "Specify the name of the instances to check. If not specified, all the instances are checked.")
+	cmd.Flags().BoolVar(&o.CheckRuntime, "check-runtime", true, "Check the runtime parameters of the engines which support it")
+	cmd.Flags().BoolVar(&o.Persist, "persist", false, "Create Reconfiguring OpsRequests to make the drifted values permanent")
+	cmd.Flags().BoolVar(&o.AutoApprove, "auto-approve", false, "Skip interactive approval before persisting the drifted values")
+	return cmd
+}
+
+func (o *ConfigDriftOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return fmt.Errorf("only one cluster name should be specified")
+	}
+	o.Name = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	o.Client, err = o.Factory.KubernetesClientSet()
+	return err
+}
+
+func (o *ConfigDriftOptions) Run() error {
+	report, err := o.detect()
+	if err != nil {
+		return err
+	}
+	if err = o.printReport(report); err != nil {
+		return err
+	}
+	if !o.Persist || len(report.Drifts) == 0 {
+		return nil
+	}
+	return o.persist(report.Drifts)
+}
+
+// detect compares the config files and the runtime parameters of the instances with the ConfigMaps.
+func (o *ConfigDriftOptions) detect() (*configDriftReport, error) {
+	objects, err := New(o.Name, o.Namespace, o.Dynamic, o.Components...).GetObjects()
+	if err != nil {
+		return nil, err
+	}
+	report := &configDriftReport{Cluster: o.Name, Namespace: o.Namespace, Drifts: []configDrift{}}
+	components := o.Components
+	if len(components) == 0 {
+		components = getComponentNames(objects.Cluster)
+	}
+	for _, component := range components {
+		var characterType string
+		if compSpec := objects.Cluster.Spec.GetComponentByName(component); compSpec != nil {
+			if compDef := objects.ClusterDef.GetComponentDefByName(compSpec.ComponentDefRef); compDef != nil {
+				characterType = compDef.CharacterType
+			}
+		}
+		pods, err := o.getComponentPods(component)
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range objects.ConfigSpecs[component] {
+			if spec.ConfigSpec == nil || spec.ConfigMap == nil || spec.ConfigConstraint == nil || spec.ConfigConstraint.Spec.FormatterConfig == nil {
+				continue
+			}
+			if o.ConfigSpec != "" && spec.Spec.Name != o.ConfigSpec {
+				continue
+			}
+			for _, file := range sortedKeys(spec.ConfigMap.Data) {
+				if (o.ConfigFile != "" && file != o.ConfigFile) || !core.IsSupportConfigFileReconfigure(*spec.ConfigSpec, file) {
+					continue
+				}
+				o.detectConfigFile(report, component, characterType, spec, file, pods)
+			}
+		}
+	}
+	return report, nil
+}
+
+// detectConfigFile compares the config file of the instances with the ConfigMap, and the runtime parameters
+// of the instances with the parameters in the ConfigMap.
+func (o *ConfigDriftOptions) detectConfigFile(report *configDriftReport, component, characterType string, spec *configSpecMeta, file string, pods []*corev1.Pod) {
+	formatter := spec.ConfigConstraint.Spec.FormatterConfig
+	expected, err := parseConfigParams(file, spec.ConfigMap.Data[file], formatter)
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("failed to parse the config file %s of config spec %s: %s", file, spec.Spec.Name, err.Error()))
+		return
+	}
+	// the values such as "1" and "ON" are only the same for the parameters typed as boolean by the schema
+	paramTypes, err := util.GetParameterTypes(&spec.ConfigConstraint.Spec)
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("failed to get the schema of config spec %s, the boolean values are compared as they are: %s", spec.Spec.Name, err.Error()))
+	}
+	isBoolean := func(param string) bool {
+		return paramTypes[param] == "boolean"
+	}
+	newDrift := func(pod *corev1.Pod, param, source string, expectedValue, actualValue *string) configDrift {
+		return configDrift{
+			Instance:   pod.Name,
+			Component:  component,
+			ConfigSpec: spec.Spec.Name,
+			ConfigFile: file,
+			Parameter:  param,
+			Source:     source,
+			Expected:   expectedValue,
+			Actual:     actualValue,
+		}
+	}
+	probe, supportRuntime := runtimeConfigProbes[characterType]
+	for _, pod := range pods {
+		container, mountPath := findVolumeMount(pod, spec.Spec.VolumeName)
+		if container == "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("instance %s does not mount the volume %s of config spec %s", pod.Name, spec.Spec.VolumeName, spec.Spec.Name))
+		} else if actual, err := o.readConfigFile(pod, container, filepath.Join(mountPath, file), formatter); err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("failed to read the config file %s of instance %s: %s", file, pod.Name, err.Error()))
+		} else {
+			params := maps.Clone(expected)
+			maps.Copy(params, actual)
+			for _, param := range sortedKeys(params) {
+				expectedValue, expectedOK := expected[param]
+				actualValue, actualOK := actual[param]
+				if expectedOK && actualOK && sameConfigValue(expectedValue, actualValue, isBoolean(param)) {
+					continue
+				}
+				report.Drifts = append(report.Drifts, newDrift(pod, param, configDriftSourceFile, optionalValue(expectedValue, expectedOK), optionalValue(actualValue, actualOK)))
+			}
+		}
+
+		if !o.CheckRuntime || !supportRuntime {
+			continue
+		}
+		runtime, err := o.readRuntimeConfig(pod, characterType, probe)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("failed to read the runtime parameters of instance %s: %s", pod.Name, err.Error()))
+			continue
+		}
+		for _, param := range sortedKeys(expected) {
+			// the parameters which are not variables of the engine, such as the options of the server, are skipped
+			actualValue, ok := runtime[normalizeParamName(param)]
+			if !ok || sameConfigValue(expected[param], actualValue, isBoolean(param)) {
+				continue
+			}
+			report.Drifts = append(report.Drifts, newDrift(pod, param, configDriftSourceRuntime, optionalValue(expected[param], true), optionalValue(actualValue, true)))
+		}
+	}
+}
+
+func (o *ConfigDriftOptions) getComponentPods(component string) ([]*corev1.Pod, error) {
+	selector := util.BuildComponentNameLabels(util.BuildLabelSelectorByNames("", []string{o.Name}), []string{component})
+	pods, err := o.Client.CoreV1().Pods(o.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var res []*corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if len(o.Instances) > 0 && !slices.Contains(o.Instances, pod.Name) {
+			continue
+		}
+		res = append(res, pod)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+func (o *ConfigDriftOptions) readConfigFile(pod *corev1.Pod, container, path string, formatter *appsv1alpha1.FormatterConfig) (map[string]string, error) {
+	output, err := o.execInPod(pod, container, []string{"cat", path})
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigParams(filepath.Base(path), output, formatter)
+}
+
+func (o *ConfigDriftOptions) readRuntimeConfig(pod *corev1.Pod, characterType string, probe runtimeConfigProbe) (map[string]string, error) {
+	engine, err := register.NewClusterCommands(characterType)
+	if err != nil {
+		return nil, err
+	}
+	command := engine.ConnectCommand(nil)
+	command[len(command)-1] = fmt.Sprintf("%s %s", command[len(command)-1], probe.args)
+	output, err := o.execInPod(pod, engine.Container(), command)
+	if err != nil {
+		return nil, err
+	}
+	runtime := map[string]string{}
+	for name, value := range probe.parse(output) {
+		runtime[normalizeParamName(name)] = value
+	}
+	return runtime, nil
+}
+
+func (o *ConfigDriftOptions) printReport(report *configDriftReport) error {
+	switch o.Format {
+	case printer.JSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.Out, string(data))
+	case printer.YAML:
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(o.Out, string(data))
+	default:
+		for _, warning := range report.Warnings {
+			printer.Warning(o.ErrOut, "%s\n", warning)
+		}
+		if len(report.Drifts) == 0 {
+			fmt.Fprintf(o.Out, "No configuration drift found in cluster %s\n", report.Cluster)
+			return nil
+		}
+		tbl := printer.NewTablePrinter(o.Out)
+		tbl.SetHeader("INSTANCE", "COMPONENT", "CONFIG-SPEC", "FILE", "PARAMETER", "SOURCE", "EXPECTED", "ACTUAL")
+		for _, d := range report.Drifts {
+			tbl.AddRow(d.Instance, d.Component, d.ConfigSpec, d.ConfigFile, d.Parameter, d.Source, printDriftValue(d.Expected), printDriftValue(d.Actual))
+		}
+		tbl.Print()
+	}
+	return nil
+}
+
+// persist creates a Reconfiguring OpsRequest for each drifted config file, which updates the ConfigMap
+// to the effective values of the instances. The runtime values take precedence over the file values.
+// The parameters absent from the instances are skipped, since persisting them removes the configured
+// values from the ConfigMap.
+func (o *ConfigDriftOptions) persist(drifts []configDrift) error {
+	type configFileKey struct {
+		component, configSpec, file string
+	}
+	params := map[configFileKey]map[string]configDrift{}
+	var keys []configFileKey
+	for _, d := range drifts {
+		if d.Actual == nil {
+			printer.Warning(o.ErrOut, "parameter %s is absent from instance %s, skip persisting it to keep the configured value %s in %s\n",
+				d.Parameter, d.Instance, printDriftValue(d.Expected), d.ConfigFile)
+			continue
+		}
+		key := configFileKey{d.Component, d.ConfigSpec, d.ConfigFile}
+		if _, ok := params[key]; !ok {
+			params[key] = map[string]configDrift{}
+			keys = append(keys, key)
+		}
+		prev, ok := params[key][d.Parameter]
+		switch {
+		case !ok:
+			params[key][d.Parameter] = d
+		case prev.Instance != d.Instance && printDriftValue(prev.Actual) != printDriftValue(d.Actual):
+			return fmt.Errorf("parameter %s drifts to different values on instance %s and %s, please specify the instance to persist by --instance", d.Parameter, prev.Instance, d.Instance)
+		case d.Source == configDriftSourceRuntime:
+			params[key][d.Parameter] = d
+		}
+	}
+
+	if len(keys) == 0 {
+		fmt.Fprintln(o.Out, "No drifted value to persist")
+		return nil
+	}
+	for _, key := range keys {
+		ops := newBaseOperationsOptions(o.Factory, o.IOStreams, appsv1alpha1.ReconfiguringType, true)
+		if err := ops.CreateOptions.Complete(); err != nil {
+			return err
+		}
+		ops.Dynamic = o.Dynamic
+		ops.Client = o.Client
+		ops.Namespace = o.Namespace
+		ops.Name = o.Name
+		ops.ComponentNames = []string{key.component}
+		ops.CfgTemplateName = key.configSpec
+		ops.CfgFile = key.file
+		ops.autoApprove = o.AutoApprove
+		for param, d := range params[key] {
+			ops.KeyValues[param] = d.Actual
+		}
+		if err := ops.Validate(); err != nil {
+			return err
+		}
+		if err := ops.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseConfigParams parses the parameters of the config file with the formatter of the config constraint.
+func parseConfigParams(file, content string, formatter *appsv1alpha1.FormatterConfig) (map[string]string, error) {
+	params, err := diffConfigParams(map[string]string{file: ""}, map[string]string{file: content}, formatter)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]string{}
+	for param, value := range fromKeyValuesToMap(params, file) {
+		if value != nil {
+			res[param] = *value
+		}
+	}
+	return res, nil
+}
+
+// parseSeparatedRuntimeConfig parses the runtime parameters output line by line, the name
+// and the value of a parameter are separated by the separator.
+func parseSeparatedRuntimeConfig(separator string) func(output string) map[string]string {
+	return func(output string) map[string]string {
+		res := map[string]string{}
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.SplitN(strings.TrimRight(line, "\r"), separator, 2)
+			if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" {
+				continue
+			}
+			res[strings.TrimSpace(fields[0])] = strings.TrimSpace(fields[1])
+		}
+		return res
+	}
+}
+
+// parseRedisRuntimeConfig parses the output of `CONFIG GET *`, the names and the values are in alternate lines.
+func parseRedisRuntimeConfig(output string) map[string]string {
+	res := map[string]string{}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	for i := 0; i+1 < len(lines); i += 2 {
+		res[strings.TrimSpace(lines[i])] = strings.TrimSpace(lines[i+1])
+	}
+	return res
+}
+
+func normalizeParamName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+}
+
+// sameConfigValue checks whether the values are the same, the quotes, the letter case and the size
+// units are ignored, and the different forms of the boolean values are ignored for the boolean parameter.
+func sameConfigValue(expected, actual string, boolean bool) bool {
+	normalize := func(v string) string {
+		v = strings.ToLower(strings.Trim(strings.TrimSpace(v), `"'`))
+		if !boolean {
+			return v
+		}
+		switch v {
+		case "on", "true", "yes", "1":
+			return "on"
+		case "off", "false", "no", "0":
+			return "off"
+		}
+		return v
+	}
+	expected, actual = normalize(expected), normalize(actual)
+	if expected == actual {
+		return true
+	}
+	expectedSize, ok1 := parseConfigSize(expected)
+	actualSize, ok2 := parseConfigSize(actual)
+	return ok1 && ok2 && expectedSize == actualSize
+}
+
+// parseConfigSize parses the size with the unit such as 128M or 2gb into bytes.
+func parseConfigSize(v string) (uint64, bool) {
+	units := []struct {
+		suffix string
+		factor uint64
+	}{
+		{"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+		{"t", 1 << 40}, {"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10}, {"b", 1},
+	}
+	factor := uint64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(v, unit.suffix) {
+			v, factor = strings.TrimSuffix(v, unit.suffix), unit.factor
+			break
+		}
+	}
+	size, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return size * factor, true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := maps.Keys(m)
+	sort.Strings(keys)
+	return keys
+}
+
+func optionalValue(v string, ok bool) *string {
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+func printDriftValue(v *string) string {
+	if v == nil {
+		return "<unset>"
+	}
+	return *v
+}
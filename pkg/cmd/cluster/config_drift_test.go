@@ -0,0 +1,189 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	cfgcore "github.com/apecloud/kubeblocks/pkg/configuration/core"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	testapps "github.com/apecloud/kubeblocks/pkg/testutil/apps"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("config drift", func() {
+	const (
+		ns             = "default"
+		clusterDefName = "test-clusterdef"
+		clusterName    = "test-cluster"
+		compDefName    = "replicasets"
+		compName       = "mysql"
+		configSpecName = "mysql-config-tpl"
+		configVolume   = "mysql-config"
+		renderedConfig = "[mysqld]\nautomatic_sp_privileges=ON\ninnodb_buffer_pool_size=1G\nmax_connections=100\n"
+	)
+
+	var (
+		out    *bytes.Buffer
+		errOut *bytes.Buffer
+		o      *ConfigDriftOptions
+		// files and variables are the config files and the runtime variables of the instances
+		files     map[string]string
+		variables map[string]string
+	)
+
+	BeforeEach(func() {
+		configmap := testapps.NewCustomizedObj("resources/mysql-config-template.yaml", &corev1.ConfigMap{}, testapps.WithNamespace(ns))
+		constraint := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		componentConfig := testapps.NewConfigMap(ns, cfgcore.GetComponentCfgName(clusterName, compName, configSpecName), testapps.SetConfigMapData("my.cnf", renderedConfig))
+		clusterDefObj := testapps.NewClusterDefFactory(clusterDefName).
+			AddComponentDef(testapps.StatefulMySQLComponent, compDefName).
+			AddConfigTemplate(configSpecName, configmap.Name, constraint.Name, ns, configVolume).
+			GetObject()
+		clusterObj := testapps.NewClusterFactory(ns, clusterName, clusterDefObj.Name, "").
+			AddComponent(compName, compDefName).GetObject()
+		objs := []runtime.Object{configmap, constraint, clusterDefObj, clusterObj, componentConfig}
+		tf := cmdtesting.NewTestFactory().WithNamespace(ns)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(objs...)
+		DeferCleanup(tf.Cleanup)
+
+		pods := &corev1.PodList{}
+		for i := 0; i < 2; i++ {
+			pod := corev1.Pod{}
+			pod.Name = fmt.Sprintf("%s-%s-%d", clusterName, compName, i)
+			pod.Namespace = ns
+			pod.Labels = map[string]string{constant.AppInstanceLabelKey: clusterName, constant.KBAppComponentLabelKey: compName}
+			pod.Spec.Containers = []corev1.Container{{
+				Name:         compName,
+				VolumeMounts: []corev1.VolumeMount{{Name: configVolume, MountPath: "/etc/mysql"}},
+			}}
+			pods.Items = append(pods.Items, pod)
+		}
+
+		var streams genericiooptions.IOStreams
+		streams, _, out, errOut = genericiooptions.NewTestIOStreams()
+		files = map[string]string{
+			"test-cluster-mysql-0": strings.Replace(renderedConfig, "max_connections=100", "max_connections=200", 1),
+			"test-cluster-mysql-1": renderedConfig,
+		}
+		variables = map[string]string{
+			"test-cluster-mysql-0": "automatic_sp_privileges\t1\ninnodb_buffer_pool_size\t1073741824\nmax_connections\t200\n",
+			"test-cluster-mysql-1": "automatic_sp_privileges\tON\ninnodb_buffer_pool_size\t1073741824\nmax_connections\t300\n",
+		}
+		o = &ConfigDriftOptions{
+			Factory:      tf,
+			Client:       testing.FakeClientSet(pods),
+			Dynamic:      tf.FakeDynamicClient,
+			Namespace:    ns,
+			Name:         clusterName,
+			CheckRuntime: true,
+			AutoApprove:  true,
+			IOStreams:    streams,
+			execInPod: func(pod *corev1.Pod, container string, command []string) (string, error) {
+				if command[0] == "cat" {
+					Expect(command[1]).Should(Equal("/etc/mysql/my.cnf"))
+					return files[pod.Name], nil
+				}
+				Expect(command[len(command)-1]).Should(ContainSubstring("SHOW GLOBAL VARIABLES"))
+				return variables[pod.Name], nil
+			},
+		}
+	})
+
+	It("compare the values", func() {
+		Expect(sameConfigValue("ON", "1", true)).Should(BeTrue())
+		Expect(sameConfigValue("ON", "1", false)).Should(BeFalse())
+		Expect(sameConfigValue("'off'", "OFF", false)).Should(BeTrue())
+		Expect(sameConfigValue("1G", "1073741824", false)).Should(BeTrue())
+		Expect(sameConfigValue("128MB", "128M", false)).Should(BeTrue())
+		Expect(sameConfigValue("100", "200", false)).Should(BeFalse())
+		Expect(parseRedisRuntimeConfig("maxmemory\n0\nappendonly\nyes\n")).Should(Equal(map[string]string{"maxmemory": "0", "appendonly": "yes"}))
+	})
+
+	It("detect the drifts of the config files and the runtime parameters", func() {
+		Expect(NewConfigDriftCmd(testing.NewTestFactory(ns), genericiooptions.NewTestIOStreamsDiscard())).ShouldNot(BeNil())
+		report, err := o.detect()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Warnings).Should(BeEmpty())
+		Expect(report.Drifts).Should(HaveLen(4))
+		Expect(o.printReport(report)).Should(Succeed())
+		// automatic_sp_privileges is typed as a string of "ON" and "OFF" instead of a boolean
+		Expect(out.String()).Should(MatchRegexp(`test-cluster-mysql-0\s+.*automatic_sp_privileges\s+runtime\s+ON\s+1`))
+		Expect(out.String()).Should(MatchRegexp(`test-cluster-mysql-0\s+mysql\s+mysql-config-tpl\s+my.cnf\s+max_connections\s+file\s+100\s+200`))
+		Expect(out.String()).Should(MatchRegexp(`test-cluster-mysql-0\s+.*max_connections\s+runtime\s+100\s+200`))
+		Expect(out.String()).Should(MatchRegexp(`test-cluster-mysql-1\s+.*max_connections\s+runtime\s+100\s+300`))
+
+		By("output the drifts in JSON format")
+		out.Reset()
+		o.Format = printer.JSON
+		Expect(o.printReport(report)).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring(`"source": "runtime"`))
+
+		By("skip the runtime parameters")
+		o.CheckRuntime = false
+		report, err = o.detect()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Drifts).Should(HaveLen(1))
+	})
+
+	It("persist the drifts", func() {
+		report, err := o.detect()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(o.persist(report.Drifts)).Should(MatchError(ContainSubstring("drifts to different values")))
+
+		o.Instances = []string{"test-cluster-mysql-1"}
+		report, err = o.detect()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(report.Drifts).Should(HaveLen(1))
+		absent := report.Drifts[0]
+		absent.Parameter = "innodb_buffer_pool_size"
+		absent.Actual = nil
+		Expect(o.persist(append(report.Drifts, absent))).Should(Succeed())
+		Expect(errOut.String()).Should(ContainSubstring("parameter innodb_buffer_pool_size is absent from instance test-cluster-mysql-1"))
+		objs, err := o.Dynamic.Resource(types.OpsGVR()).Namespace(ns).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(objs.Items).Should(HaveLen(1))
+		ops := appsv1alpha1.OpsRequest{}
+		Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(objs.Items[0].Object, &ops)).Should(Succeed())
+		Expect(ops.Spec.Reconfigure.ComponentName).Should(Equal(compName))
+		keys := ops.Spec.Reconfigure.Configurations[0].Keys
+		Expect(keys[0].Key).Should(Equal("my.cnf"))
+		Expect(keys[0].Parameters).Should(HaveLen(1))
+		Expect(keys[0].Parameters[0].Key).Should(Equal("max_connections"))
+		Expect(*keys[0].Parameters[0].Value).Should(Equal("300"))
+	})
+})
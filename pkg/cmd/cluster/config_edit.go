@@ -241,7 +241,7 @@ func NewEditConfigureCmd(f cmdutil.Factory, streams genericiooptions.IOStreams)
 			cmdutil.CheckErr(o.CreateOptions.Complete())
 			util.CheckErr(o.Complete())
 			util.CheckErr(o.Validate())
-			util.CheckErr(o.Run(o.CreateOptions.Run))
+			util.CheckErr(o.Run(o.OperationsOptions.Run))
 		},
 	}
 	o.buildReconfigureCommonFlags(cmd, f)
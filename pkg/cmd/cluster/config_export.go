@@ -0,0 +1,360 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/configuration/core"
+	"github.com/apecloud/kubeblocks/pkg/configuration/validate"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+	"github.com/apecloud/kbcli/pkg/util/flags"
+)
+
+const (
+	configManifestKind = "ConfigManifest"
+	configManifestFile = "manifest.yaml"
+)
+
+var (
+	exportConfigExample = templates.Examples(`
+		# export the rendered config files of all the components to the directory mycluster-config
+		kbcli cluster export-config mycluster -o mycluster-config
+
+		# export the config files of the config spec mysql-3node-tpl of the component mysql
+		kbcli cluster export-config mycluster --component=mysql --config-spec=mysql-3node-tpl -o mycluster-config`)
+
+	importConfigExample = templates.Examples(`
+		# apply the config files exported by export-config to the cluster mycluster
+		kbcli cluster import-config mycluster --from-dir=mycluster-config
+
+		# only apply the config files of the component mysql
+		kbcli cluster import-config mycluster --from-dir=mycluster-config --component=mysql`)
+)
+
+// configManifest describes the source of the config files exported by export-config.
+type configManifest struct {
+	APIVersion        string           `json:"apiVersion"`
+	Kind              string           `json:"kind"`
+	Cluster           string           `json:"cluster"`
+	Namespace         string           `json:"namespace"`
+	ClusterDefinition string           `json:"clusterDefinition,omitempty"`
+	ClusterVersion    string           `json:"clusterVersion,omitempty"`
+	ExportTime        string           `json:"exportTime"`
+	Configs           []manifestConfig `json:"configs"`
+}
+
+// manifestConfig is the config files rendered from a config spec of a component.
+type manifestConfig struct {
+	Component        string `json:"component"`
+	ConfigSpec       string `json:"configSpec"`
+	ConfigConstraint string `json:"configConstraint,omitempty"`
+	Format           string `json:"format,omitempty"`
+	// Files are the paths of the config files relative to the manifest, keyed by the config file name
+	Files map[string]string `json:"files"`
+}
+
+type exportConfigOptions struct {
+	Factory   cmdutil.Factory
+	Dynamic   dynamic.Interface
+	Namespace string
+	Name      string
+
+	Component  string
+	ConfigSpec string
+	Dir        string
+
+	genericiooptions.IOStreams
+}
+
+func NewExportConfigCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &exportConfigOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:               "export-config NAME -o DIR",
+		Short:             "Export the rendered config files of the cluster in native formats.",
+		Example:           exportConfigExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.complete(args))
+			cmdutil.CheckErr(o.run())
+		},
+	}
+	flags.AddComponentFlag(f, cmd, &o.Component, "Specify the name of the component to export. If not specified, all the components are exported.")
+	cmd.Flags().StringVar(&o.ConfigSpec, "config-spec", "", "Specify the name of the configuration template to export. If not specified, all the configuration templates are exported.")
+	cmd.Flags().StringVarP(&o.Dir, "output", "o", "", "The directory to write the config files and the manifest to")
+	util.CheckErr(cmd.MarkFlagRequired("output"))
+	return cmd
+}
+
+func (o *exportConfigOptions) complete(args []string) error {
+	var err error
+	if len(args) == 0 {
+		return makeMissingClusterNameErr()
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("only support to export the config files of one cluster")
+	}
+	o.Name = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	o.Dynamic, err = o.Factory.DynamicClient()
+	return err
+}
+
+func (o *exportConfigOptions) run() error {
+	var components []string
+	if o.Component != "" {
+		components = []string{o.Component}
+	}
+	objects, err := New(o.Name, o.Namespace, o.Dynamic, components...).GetObjects()
+	if err != nil {
+		return err
+	}
+	if len(components) == 0 {
+		components = getComponentNames(objects.Cluster)
+	}
+
+	manifest := &configManifest{
+		APIVersion:        clusterBundleAPIVersion,
+		Kind:              configManifestKind,
+		Cluster:           o.Name,
+		Namespace:         o.Namespace,
+		ClusterDefinition: objects.Cluster.Spec.ClusterDefRef,
+		ClusterVersion:    objects.Cluster.Spec.ClusterVersionRef,
+		ExportTime:        time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, component := range components {
+		for _, spec := range objects.ConfigSpecs[component] {
+			if spec.ConfigSpec == nil || spec.ConfigMap == nil || (o.ConfigSpec != "" && spec.Spec.Name != o.ConfigSpec) {
+				continue
+			}
+			config := manifestConfig{
+				Component:  component,
+				ConfigSpec: spec.Spec.Name,
+				Files:      map[string]string{},
+			}
+			if spec.ConfigConstraint != nil {
+				config.ConfigConstraint = spec.ConfigConstraint.Name
+				if spec.ConfigConstraint.Spec.FormatterConfig != nil {
+					config.Format = string(spec.ConfigConstraint.Spec.FormatterConfig.Format)
+				}
+			}
+			for _, file := range sortedKeys(spec.ConfigMap.Data) {
+				path := filepath.Join(component, spec.Spec.Name, file)
+				if err = writeConfigFile(filepath.Join(o.Dir, path), spec.ConfigMap.Data[file]); err != nil {
+					return err
+				}
+				config.Files[file] = path
+			}
+			manifest.Configs = append(manifest.Configs, config)
+		}
+	}
+	if len(manifest.Configs) == 0 {
+		return fmt.Errorf("no config spec is found in cluster %s", o.Name)
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err = writeConfigFile(filepath.Join(o.Dir, configManifestFile), string(data)); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "The config files of cluster %s are exported to %s\n", o.Name, o.Dir)
+	return nil
+}
+
+func writeConfigFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+type importConfigOptions struct {
+	*configOpsOptions
+
+	Dir string
+}
+
+// importConfigFile is a config file to be applied by import-config
+type importConfigFile struct {
+	component  string
+	configSpec string
+	file       string
+	path       string
+}
+
+func NewImportConfigCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &importConfigOptions{
+		configOpsOptions: &configOpsOptions{
+			OperationsOptions: newBaseOperationsOptions(f, streams, appsv1alpha1.ReconfiguringType, false),
+		},
+	}
+	cmd := &cobra.Command{
+		Use:               "import-config NAME --from-dir DIR",
+		Short:             "Validate the config files exported by export-config and apply them to the cluster.",
+		Example:           importConfigExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Args = args
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.CreateOptions.Complete())
+			cmdutil.CheckErr(o.run())
+		},
+	}
+	o.addCommonFlags(cmd, f)
+	flags.AddComponentFlag(f, cmd, &o.ComponentName, "Specify the name of the component to import. If not specified, all the components in the manifest are imported.")
+	cmd.Flags().StringVar(&o.CfgTemplateName, "config-spec", "", "Specify the name of the configuration template to import. If not specified, all the configuration templates in the manifest are imported.")
+	cmd.Flags().StringVar(&o.Dir, "from-dir", "", "The directory of the config files and the manifest exported by export-config")
+	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before reconfiguring the cluster")
+	util.CheckErr(cmd.MarkFlagRequired("from-dir"))
+	return cmd
+}
+
+// run validates all the config files against the config constraints at first, then applies the
+// changed parameters of each config file by a Reconfiguring OpsRequest.
+func (o *importConfigOptions) run() error {
+	if o.Name == "" {
+		return makeMissingClusterNameErr()
+	}
+	files, err := o.validateConfigFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) > 1 && o.OpsRequestName != "" {
+		return fmt.Errorf("--name can not be specified if more than one config file is imported")
+	}
+	for _, f := range files {
+		c := o.newConfigOps(f)
+		if err = c.Complete(); err != nil {
+			return err
+		}
+		if err = c.Validate(); err != nil {
+			if errors.Is(err, errNoParameterChanged) {
+				fmt.Fprintf(o.Out, "No parameter is changed in the config file %s of component %s, skipped\n", f.file, f.component)
+				continue
+			}
+			return err
+		}
+		if err = c.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateConfigFiles reads the manifest and validates the config files against the config constraints of the cluster.
+func (o *importConfigOptions) validateConfigFiles() ([]importConfigFile, error) {
+	manifest, err := readConfigManifest(o.Dir)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := New(o.Name, o.Namespace, o.Dynamic).GetObjects()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.ClusterDefinition != "" && manifest.ClusterDefinition != objects.Cluster.Spec.ClusterDefRef {
+		return nil, fmt.Errorf("the config files are exported from a cluster of cluster definition %s, but cluster %s is of cluster definition %s",
+			manifest.ClusterDefinition, o.Name, objects.Cluster.Spec.ClusterDefRef)
+	}
+
+	var files []importConfigFile
+	for _, config := range manifest.Configs {
+		if (o.ComponentName != "" && config.Component != o.ComponentName) || (o.CfgTemplateName != "" && config.ConfigSpec != o.CfgTemplateName) {
+			continue
+		}
+		spec := objects.ConfigSpecs[config.Component].findByName(config.ConfigSpec)
+		if spec == nil || spec.ConfigSpec == nil {
+			return nil, makeConfigSpecNotExistErr(o.Name, config.Component, config.ConfigSpec)
+		}
+		if spec.ConfigConstraint == nil {
+			fmt.Fprintf(o.Out, "Config spec %s of component %s has no config constraint, skipped\n", config.ConfigSpec, config.Component)
+			continue
+		}
+		for _, file := range sortedKeys(config.Files) {
+			if !core.IsSupportConfigFileReconfigure(*spec.ConfigSpec, file) {
+				fmt.Fprintf(o.Out, "Config file %s of config spec %s does not support reconfiguring, skipped\n", file, config.ConfigSpec)
+				continue
+			}
+			path := filepath.Join(o.Dir, config.Files[file])
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			validator := validate.NewConfigValidator(&spec.ConfigConstraint.Spec, validate.WithKeySelector(spec.ConfigSpec.Keys))
+			if err = validator.Validate(map[string]string{file: string(content)}); err != nil {
+				return nil, core.WrapError(err, "failed to validate the config file %s", path)
+			}
+			files = append(files, importConfigFile{component: config.Component, configSpec: config.ConfigSpec, file: file, path: path})
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config file to import in %s", o.Dir)
+	}
+	return files, nil
+}
+
+// newConfigOps creates the options to reconfigure the cluster with the parameters changed in the config file.
+func (o *importConfigOptions) newConfigOps(f importConfigFile) *configOpsOptions {
+	ops := *o.OperationsOptions
+	ops.CreateOptions.Options = &ops
+	ops.ComponentNames = []string{f.component}
+	ops.CfgTemplateName = f.configSpec
+	ops.CfgFile = f.file
+	ops.KeyValues = map[string]*string{}
+	return &configOpsOptions{
+		OperationsOptions: &ops,
+		ComponentName:     f.component,
+		FromNativeFile:    f.path,
+	}
+}
+
+func readConfigManifest(dir string) (*configManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, configManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &configManifest{}
+	if err = yaml.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Kind != configManifestKind || manifest.APIVersion != clusterBundleAPIVersion {
+		return nil, fmt.Errorf("invalid manifest, the kind should be %s and the apiVersion should be %s", configManifestKind, clusterBundleAPIVersion)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	cfgcore "github.com/apecloud/kubeblocks/pkg/configuration/core"
+	testapps "github.com/apecloud/kubeblocks/pkg/testutil/apps"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("export and import config", func() {
+	const (
+		ns             = "default"
+		clusterDefName = "test-clusterdef"
+		clusterName    = "test-cluster"
+		compDefName    = "replicasets"
+		compName       = "mysql"
+		configSpecName = "mysql-config-tpl"
+		renderedConfig = "[mysqld]\nautomatic_sp_privileges=ON\ninnodb_autoinc_lock_mode=2\nmax_connections=100\n"
+	)
+
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+		dir     string
+	)
+
+	BeforeEach(func() {
+		configmap := testapps.NewCustomizedObj("resources/mysql-config-template.yaml", &corev1.ConfigMap{}, testapps.WithNamespace(ns))
+		constraint := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		componentConfig := testapps.NewConfigMap(ns, cfgcore.GetComponentCfgName(clusterName, compName, configSpecName), testapps.SetConfigMapData("my.cnf", renderedConfig))
+		clusterDefObj := testapps.NewClusterDefFactory(clusterDefName).
+			AddComponentDef(testapps.StatefulMySQLComponent, compDefName).
+			AddConfigTemplate(configSpecName, configmap.Name, constraint.Name, ns, "mysql-config").
+			GetObject()
+		clusterObj := testapps.NewClusterFactory(ns, clusterName, clusterDefObj.Name, "").
+			AddComponent(compName, compDefName).GetObject()
+		objs := []runtime.Object{configmap, constraint, clusterDefObj, clusterObj, componentConfig}
+
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(ns)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(objs...)
+		dir, _ = os.MkdirTemp(os.TempDir(), "test-")
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+		os.RemoveAll(dir)
+	})
+
+	exportConfig := func() {
+		o := &exportConfigOptions{Factory: tf, IOStreams: streams, Dir: dir}
+		Expect(o.complete([]string{clusterName})).Should(Succeed())
+		Expect(o.run()).Should(Succeed())
+	}
+
+	newImportOptions := func() *importConfigOptions {
+		o := &importConfigOptions{
+			configOpsOptions: &configOpsOptions{
+				OperationsOptions: newBaseOperationsOptions(tf, streams, appsv1alpha1.ReconfiguringType, false),
+			},
+			Dir: dir,
+		}
+		o.Args = []string{clusterName}
+		Expect(o.CreateOptions.Complete()).Should(Succeed())
+		o.autoApprove = true
+		return o
+	}
+
+	It("export the config files in native formats", func() {
+		Expect(NewExportConfigCmd(tf, streams)).ShouldNot(BeNil())
+		exportConfig()
+		data, err := os.ReadFile(filepath.Join(dir, compName, configSpecName, "my.cnf"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).Should(Equal(renderedConfig))
+
+		manifest, err := readConfigManifest(dir)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(manifest.Cluster).Should(Equal(clusterName))
+		Expect(manifest.ClusterDefinition).Should(Equal(clusterDefName))
+		Expect(manifest.Configs).Should(HaveLen(1))
+		Expect(manifest.Configs[0].ConfigConstraint).Should(Equal("mysql-tree-node-template-8.0"))
+		Expect(manifest.Configs[0].Format).Should(Equal("ini"))
+		Expect(manifest.Configs[0].Files).Should(HaveKeyWithValue("my.cnf", filepath.Join(compName, configSpecName, "my.cnf")))
+	})
+
+	It("import the config files", func() {
+		Expect(NewImportConfigCmd(tf, streams)).ShouldNot(BeNil())
+		exportConfig()
+		cnf := filepath.Join(dir, compName, configSpecName, "my.cnf")
+
+		By("the unchanged config files are skipped")
+		Expect(newImportOptions().run()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("No parameter is changed in the config file my.cnf"))
+
+		By("the invalid config files are refused")
+		Expect(os.WriteFile(cnf, []byte(strings.Replace(renderedConfig, "innodb_autoinc_lock_mode=2", "innodb_autoinc_lock_mode=5", 1)), 0644)).Should(Succeed())
+		Expect(newImportOptions().run()).Should(MatchError(ContainSubstring("failed to validate the config file")))
+
+		By("the changed parameters are applied")
+		Expect(os.WriteFile(cnf, []byte(strings.Replace(renderedConfig, "innodb_autoinc_lock_mode=2", "innodb_autoinc_lock_mode=1", 1)), 0644)).Should(Succeed())
+		Expect(newImportOptions().run()).Should(Succeed())
+		objs, err := tf.FakeDynamicClient.Resource(types.OpsGVR()).Namespace(ns).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(objs.Items).Should(HaveLen(1))
+		ops := appsv1alpha1.OpsRequest{}
+		Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(objs.Items[0].Object, &ops)).Should(Succeed())
+		params := ops.Spec.Reconfigure.Configurations[0].Keys[0].Parameters
+		Expect(params).Should(HaveLen(1))
+		Expect(params[0].Key).Should(Equal("innodb_autoinc_lock_mode"))
+		Expect(*params[0].Value).Should(Equal("1"))
+
+		By("the config spec should exist in the cluster")
+		o := newImportOptions()
+		o.CfgTemplateName = "not-exist"
+		Expect(o.run()).Should(MatchError(ContainSubstring("no config file to import")))
+	})
+})
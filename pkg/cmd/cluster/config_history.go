@@ -0,0 +1,469 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/configuration/core"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+	"github.com/apecloud/kbcli/pkg/util/flags"
+	"github.com/apecloud/kbcli/pkg/util/prompt"
+)
+
+var (
+	configHistoryExample = templates.Examples(`
+		# list the config revisions of cluster mycluster
+		kbcli cluster config-history mycluster
+
+		# list the config revisions of the config spec mysql-3node-tpl of component mysql
+		kbcli cluster config-history mycluster --components=mysql --config-spec=mysql-3node-tpl`)
+
+	rollbackConfigExample = templates.Examples(`
+		# roll back the config of cluster mycluster to the revision 3
+		kbcli cluster rollback-config mycluster --to=3
+
+		# roll back the config to the revision created by the OpsRequest mycluster-reconfiguring-x8kzb
+		kbcli cluster rollback-config mycluster --to=mycluster-reconfiguring-x8kzb
+
+		# preview the OpsRequest rolling back the config without creating it
+		kbcli cluster rollback-config mycluster --to=3 --dry-run`)
+)
+
+// configRevision is a revision of the configuration created by a Reconfiguring OpsRequest,
+// the revisions of a cluster are numbered from 1 in the order of the creation time.
+type configRevision struct {
+	Revision    int      `json:"revision"`
+	OpsName     string   `json:"opsName"`
+	Components  []string `json:"components"`
+	ConfigSpecs []string `json:"configSpecs"`
+	ChangedKeys []string `json:"changedKeys"`
+	Status      string   `json:"status"`
+	CreatedTime string   `json:"createdTime"`
+
+	ops *appsv1alpha1.OpsRequest
+}
+
+// listConfigRevisions lists the config revisions of the cluster.
+func listConfigRevisions(dynamic dynamic.Interface, namespace, clusterName string) ([]configRevision, error) {
+	// kubernetes not support fieldSelector with CRD: https://github.com/kubernetes/kubernetes/issues/51046
+	listOptions := metav1.ListOptions{
+		LabelSelector: strings.Join([]string{constant.AppInstanceLabelKey, clusterName}, "="),
+	}
+	opsList, err := dynamic.Resource(types.OpsGVR()).Namespace(namespace).List(context.TODO(), listOptions)
+	if err != nil {
+		return nil, err
+	}
+	sort.Stable(unstructuredList(opsList.Items))
+
+	var revisions []configRevision
+	for _, obj := range opsList.Items {
+		ops := &appsv1alpha1.OpsRequest{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ops); err != nil {
+			return nil, err
+		}
+		reconfigures := getReconfiguresFromOps(ops)
+		if ops.Spec.Type != appsv1alpha1.ReconfiguringType || len(reconfigures) == 0 {
+			continue
+		}
+		revision := configRevision{
+			Revision:    len(revisions) + 1,
+			OpsName:     ops.Name,
+			Status:      string(ops.Status.Phase),
+			CreatedTime: util.TimeFormat(&ops.CreationTimestamp),
+			ops:         ops,
+		}
+		for _, r := range reconfigures {
+			revision.Components = append(revision.Components, r.ComponentName)
+			for _, config := range r.Configurations {
+				if !slices.Contains(revision.ConfigSpecs, config.Name) {
+					revision.ConfigSpecs = append(revision.ConfigSpecs, config.Name)
+				}
+				for _, key := range config.Keys {
+					if key.FileContent != "" {
+						revision.ChangedKeys = append(revision.ChangedKeys, key.Key)
+					}
+					for _, p := range key.Parameters {
+						revision.ChangedKeys = append(revision.ChangedKeys, p.Key)
+					}
+				}
+			}
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+// getReconfiguresFromOps returns the reconfigurations of the components in the Reconfiguring OpsRequest.
+func getReconfiguresFromOps(ops *appsv1alpha1.OpsRequest) []appsv1alpha1.Reconfigure {
+	if ops.Spec.Reconfigure != nil {
+		return []appsv1alpha1.Reconfigure{*ops.Spec.Reconfigure}
+	}
+	return ops.Spec.Reconfigures
+}
+
+// ConfigHistoryOptions is the options of the config-history command.
+type ConfigHistoryOptions struct {
+	Factory   cmdutil.Factory
+	Dynamic   dynamic.Interface
+	Namespace string
+	Name      string
+
+	Components []string
+	ConfigSpec string
+	Format     printer.Format
+
+	genericiooptions.IOStreams
+}
+
+func NewConfigHistoryCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ConfigHistoryOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:               "config-history NAME",
+		Short:             "List the config revisions of the cluster created by the Reconfiguring OpsRequests.",
+		Example:           configHistoryExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	flags.AddComponentsFlag(f, cmd, &o.Components, "Specify the name of the components to list the config revisions. If not specified, the config revisions of all the components are listed.")
+	cmd.Flags().StringVar(&o.ConfigSpec, "config-spec", "", "Specify the name of the configuration template to list the config revisions.")
+	printer.AddOutputFlag(cmd, &o.Format)
+	return cmd
+}
+
+func (o *ConfigHistoryOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return makeMissingClusterNameErr()
+	}
+	o.Name = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	o.Dynamic, err = o.Factory.DynamicClient()
+	return err
+}
+
+func (o *ConfigHistoryOptions) Run() error {
+	revisions, err := listConfigRevisions(o.Dynamic, o.Namespace, o.Name)
+	if err != nil {
+		return err
+	}
+	var filtered []configRevision
+	for _, r := range revisions {
+		if len(o.Components) > 0 && !sets.New(o.Components...).HasAny(r.Components...) {
+			continue
+		}
+		if o.ConfigSpec != "" && !slices.Contains(r.ConfigSpecs, o.ConfigSpec) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+
+	switch o.Format {
+	case printer.JSON:
+		data, err := json.MarshalIndent(filtered, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.Out, string(data))
+	case printer.YAML:
+		data, err := yaml.Marshal(filtered)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(o.Out, string(data))
+	default:
+		if len(filtered) == 0 {
+			fmt.Fprintf(o.Out, "No config revision found in cluster %s\n", o.Name)
+			return nil
+		}
+		tbl := printer.NewTablePrinter(o.Out)
+		tbl.SetHeader("REVISION", "OPS-NAME", "COMPONENT", "CONFIG-SPEC", "STATUS", "CREATED-TIME", "CHANGED-KEYS")
+		for _, r := range filtered {
+			tbl.AddRow(r.Revision, r.OpsName, strings.Join(r.Components, ","), strings.Join(r.ConfigSpecs, ","),
+				r.Status, r.CreatedTime, strings.Join(r.ChangedKeys, ","))
+		}
+		tbl.Print()
+	}
+	return nil
+}
+
+// RollbackConfigOptions is the options of the rollback-config command.
+type RollbackConfigOptions struct {
+	Factory   cmdutil.Factory
+	Namespace string
+	Dynamic   dynamic.Interface
+	Client    kubernetes.Interface
+	Name      string
+
+	To          string
+	ConfigSpec  string
+	DryRun      string
+	AutoApprove bool
+	Wait        bool
+	Timeout     time.Duration
+
+	revision   *configRevision
+	component  string
+	configSpec string
+	// params are the parameters to restore the revision, keyed by the config file
+	params map[string]map[string]*string
+	// changes are the rows of FILE, PARAMETER, CURRENT and REVISION to be confirmed
+	changes [][]interface{}
+
+	genericiooptions.IOStreams
+}
+
+func NewRollbackConfigCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &RollbackConfigOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:   "rollback-config NAME --to OPS-NAME|REVISION",
+		Short: "Roll back the configuration of the cluster to a config revision.",
+		Long: templates.LongDesc(`
+			Roll back the configuration of the cluster to a config revision listed by config-history. The parameters
+			changed since the revision are restored by one Reconfiguring OpsRequest.`),
+		Example:           rollbackConfigExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVar(&o.To, "to", "", "Specify the config revision to roll back to, either the name of the Reconfiguring OpsRequest or the revision number")
+	cmd.Flags().StringVar(&o.ConfigSpec, "config-spec", "", "Specify the name of the configuration template to roll back, required if the revision changed more than one configuration template")
+	cmd.Flags().StringVar(&o.DryRun, "dry-run", "none", `Must be "client", or "server". If with client strategy, only print the object that would be sent, and no data is actually sent. If with server strategy, submit the server-side request, but no data is persistent.`)
+	cmd.Flags().Lookup("dry-run").NoOptDefVal = "unchanged"
+	cmd.Flags().BoolVar(&o.AutoApprove, "auto-approve", false, "Skip interactive approval before rolling back the configuration")
+	cmd.Flags().BoolVar(&o.Wait, "wait", false, "Wait for the OpsRequest to be completed and show the progress")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", defaultOpsWaitTimeout, "Time to wait for the OpsRequest to be completed, only valid with --wait")
+	util.CheckErr(cmd.MarkFlagRequired("to"))
+	return cmd
+}
+
+func (o *RollbackConfigOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return makeMissingClusterNameErr()
+	}
+	o.Name = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	o.Client, err = o.Factory.KubernetesClientSet()
+	return err
+}
+
+func (o *RollbackConfigOptions) Validate() error {
+	if o.To == "" {
+		return fmt.Errorf("please specify the config revision to roll back to by --to")
+	}
+	dryRun := &action.CreateOptions{DryRun: o.DryRun}
+	if _, err := dryRun.GetDryRunStrategy(); err != nil {
+		return err
+	}
+	revisions, err := listConfigRevisions(o.Dynamic, o.Namespace, o.Name)
+	if err != nil {
+		return err
+	}
+	for i, r := range revisions {
+		if r.OpsName == o.To || strconv.Itoa(r.Revision) == o.To {
+			o.revision = &revisions[i]
+			break
+		}
+	}
+	if o.revision == nil {
+		return fmt.Errorf("config revision %s of cluster %s is not found, please list the revisions by 'kbcli cluster config-history %s'", o.To, o.Name, o.Name)
+	}
+	if o.revision.ops.Status.Phase != appsv1alpha1.OpsSucceedPhase {
+		return fmt.Errorf("OpsRequest %s of revision %d is %s, only the succeeded revision can be rolled back to", o.revision.OpsName, o.revision.Revision, o.revision.Status)
+	}
+	if len(o.revision.Components) != 1 {
+		return fmt.Errorf("revision %d changed the components %v, only the revision of one component can be rolled back to", o.revision.Revision, o.revision.Components)
+	}
+	o.component = o.revision.Components[0]
+	switch {
+	case o.ConfigSpec != "":
+		if !slices.Contains(o.revision.ConfigSpecs, o.ConfigSpec) {
+			return fmt.Errorf("config spec %s is not changed by revision %d, the changed config specs are %v", o.ConfigSpec, o.revision.Revision, o.revision.ConfigSpecs)
+		}
+		o.configSpec = o.ConfigSpec
+	case len(o.revision.ConfigSpecs) == 1:
+		o.configSpec = o.revision.ConfigSpecs[0]
+	default:
+		return fmt.Errorf("revision %d changed the config specs %v, please specify one by --config-spec", o.revision.Revision, o.revision.ConfigSpecs)
+	}
+	return o.buildRollbackParams()
+}
+
+// buildRollbackParams diffs the current config files against the config files applied by the revision,
+// and builds the parameters to restore the revision.
+func (o *RollbackConfigOptions) buildRollbackParams() error {
+	status := findTemplateStatusByName(o.revision.ops.Status.ReconfiguringStatus, o.configSpec)
+	if status == nil || len(status.LastAppliedConfiguration) == 0 {
+		return fmt.Errorf("no applied configuration is recorded in OpsRequest %s", o.revision.OpsName)
+	}
+	objects, err := New(o.Name, o.Namespace, o.Dynamic, o.component).GetObjects()
+	if err != nil {
+		return err
+	}
+	spec := objects.ConfigSpecs[o.component].findByName(o.configSpec)
+	if spec == nil || spec.ConfigSpec == nil || spec.ConfigMap == nil {
+		return makeConfigSpecNotExistErr(o.Name, o.component, o.configSpec)
+	}
+	if spec.ConfigConstraint == nil || spec.ConfigConstraint.Spec.FormatterConfig == nil {
+		return core.MakeError("config spec[%s] not support reconfiguring!", o.configSpec)
+	}
+	cc := spec.ConfigConstraint.Spec
+
+	o.params = map[string]map[string]*string{}
+	for _, file := range sortedKeys(status.LastAppliedConfiguration) {
+		if !core.IsSupportConfigFileReconfigure(*spec.ConfigSpec, file) {
+			continue
+		}
+		current := spec.ConfigMap.Data[file]
+		diff, err := diffConfigParams(map[string]string{file: current}, map[string]string{file: status.LastAppliedConfiguration[file]}, cc.FormatterConfig)
+		if err != nil {
+			return err
+		}
+		params := fromKeyValuesToMap(diff, file)
+		if len(params) == 0 {
+			continue
+		}
+		if err = util.ValidateParametersModified2(sets.KeySet(params), cc); err != nil {
+			return err
+		}
+		currentParams, err := parseConfigParams(file, current, cc.FormatterConfig)
+		if err != nil {
+			return err
+		}
+		o.params[file] = params
+		for _, key := range sortedKeys(params) {
+			currentValue, ok := currentParams[key]
+			if !ok {
+				currentValue = "<unset>"
+			}
+			o.changes = append(o.changes, []interface{}{file, key, currentValue, printDriftValue(params[key])})
+		}
+	}
+	if len(o.params) == 0 {
+		return fmt.Errorf("the config spec %s of component %s is the same as revision %d, nothing to roll back", o.configSpec, o.component, o.revision.Revision)
+	}
+	return nil
+}
+
+func (o *RollbackConfigOptions) Run() error {
+	fmt.Fprintf(o.Out, "Roll back config spec %s of component %s in cluster %s to revision %d (%s):\n",
+		o.configSpec, o.component, o.Name, o.revision.Revision, o.revision.OpsName)
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("FILE", "PARAMETER", "CURRENT", fmt.Sprintf("REVISION-%d", o.revision.Revision))
+	for _, row := range o.changes {
+		tbl.AddRow(row...)
+	}
+	tbl.Print()
+	if !o.AutoApprove && o.DryRun == "none" {
+		if err := prompt.Confirm([]string{o.Name}, o.In, "", ""); err != nil {
+			return err
+		}
+	}
+
+	ops := newBaseOperationsOptions(o.Factory, o.IOStreams, appsv1alpha1.ReconfiguringType, true)
+	ops.Args = []string{o.Name}
+	if err := ops.Complete(); err != nil {
+		return err
+	}
+	ops.Namespace = o.Namespace
+	ops.Dynamic = o.Dynamic
+	ops.Client = o.Client
+	ops.Format = printer.YAML
+	ops.DryRun = o.DryRun
+	ops.Wait = o.Wait
+	ops.Timeout = o.Timeout
+	// the changes are confirmed above
+	ops.autoApprove = true
+	ops.ComponentNames = []string{o.component}
+	ops.CfgTemplateName = o.configSpec
+	files := sortedKeys(o.params)
+	ops.CfgFile = files[0]
+	ops.KeyValues = o.params[files[0]]
+	// all the config files are restored by one OpsRequest
+	ops.PreCreate = func(obj *unstructured.Unstructured) error {
+		opsRequest := &appsv1alpha1.OpsRequest{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, opsRequest); err != nil {
+			return err
+		}
+		config := appsv1alpha1.ConfigurationItem{Name: o.configSpec}
+		for _, file := range files {
+			key := appsv1alpha1.ParameterConfig{Key: file}
+			for _, param := range sortedKeys(o.params[file]) {
+				key.Parameters = append(key.Parameters, appsv1alpha1.ParameterPair{Key: param, Value: o.params[file][param]})
+			}
+			config.Keys = append(config.Keys, key)
+		}
+		opsRequest.Spec.Reconfigure = &appsv1alpha1.Reconfigure{
+			ComponentOps:   appsv1alpha1.ComponentOps{ComponentName: o.component},
+			Configurations: []appsv1alpha1.ConfigurationItem{config},
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(opsRequest)
+		if err != nil {
+			return err
+		}
+		obj.Object = content
+		return nil
+	}
+	if err := ops.Validate(); err != nil {
+		return err
+	}
+	return ops.Run()
+}
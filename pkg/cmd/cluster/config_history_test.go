@@ -0,0 +1,176 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	cfgcore "github.com/apecloud/kubeblocks/pkg/configuration/core"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	testapps "github.com/apecloud/kubeblocks/pkg/testutil/apps"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("config history and rollback", func() {
+	const (
+		ns             = "default"
+		clusterDefName = "test-clusterdef"
+		clusterName    = "test-cluster"
+		compDefName    = "replicasets"
+		compName       = "mysql"
+		configSpecName = "mysql-config-tpl"
+	)
+
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	newReconfigureOps := func(name string, created time.Time, phase appsv1alpha1.OpsPhase, applied string, params ...string) *appsv1alpha1.OpsRequest {
+		ops := &appsv1alpha1.OpsRequest{}
+		ops.Name = name
+		ops.Namespace = ns
+		ops.Labels = map[string]string{constant.AppInstanceLabelKey: clusterName}
+		ops.CreationTimestamp = metav1.NewTime(created)
+		ops.Spec.ClusterRef = clusterName
+		ops.Spec.Type = appsv1alpha1.ReconfiguringType
+		key := appsv1alpha1.ParameterConfig{Key: "my.cnf"}
+		for i := 0; i+1 < len(params); i += 2 {
+			key.Parameters = append(key.Parameters, appsv1alpha1.ParameterPair{Key: params[i], Value: &params[i+1]})
+		}
+		ops.Spec.Reconfigure = &appsv1alpha1.Reconfigure{
+			ComponentOps:   appsv1alpha1.ComponentOps{ComponentName: compName},
+			Configurations: []appsv1alpha1.ConfigurationItem{{Name: configSpecName, Keys: []appsv1alpha1.ParameterConfig{key}}},
+		}
+		ops.Status.Phase = phase
+		ops.Status.ReconfiguringStatus = &appsv1alpha1.ReconfiguringStatus{
+			ConfigurationStatus: []appsv1alpha1.ConfigurationItemStatus{{Name: configSpecName, LastAppliedConfiguration: map[string]string{"my.cnf": applied}}},
+		}
+		return ops
+	}
+
+	BeforeEach(func() {
+		configmap := testapps.NewCustomizedObj("resources/mysql-config-template.yaml", &corev1.ConfigMap{}, testapps.WithNamespace(ns))
+		constraint := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		componentConfig := testapps.NewConfigMap(ns, cfgcore.GetComponentCfgName(clusterName, compName, configSpecName),
+			testapps.SetConfigMapData("my.cnf", "[mysqld]\nmax_connections=300\ninnodb_autoinc_lock_mode=1\n"))
+		clusterDefObj := testapps.NewClusterDefFactory(clusterDefName).
+			AddComponentDef(testapps.StatefulMySQLComponent, compDefName).
+			AddConfigTemplate(configSpecName, configmap.Name, constraint.Name, ns, "mysql-config").
+			GetObject()
+		clusterObj := testapps.NewClusterFactory(ns, clusterName, clusterDefObj.Name, "").
+			AddComponent(compName, compDefName).GetObject()
+
+		now := time.Now()
+		restartOps := &appsv1alpha1.OpsRequest{}
+		restartOps.Name = clusterName + "-restart"
+		restartOps.Namespace = ns
+		restartOps.Labels = map[string]string{constant.AppInstanceLabelKey: clusterName}
+		restartOps.Spec = appsv1alpha1.OpsRequestSpec{ClusterRef: clusterName, Type: appsv1alpha1.RestartType}
+		objs := []runtime.Object{configmap, constraint, clusterDefObj, clusterObj, componentConfig, restartOps,
+			newReconfigureOps("reconfigure-2", now.Add(-time.Hour), appsv1alpha1.OpsSucceedPhase,
+				"[mysqld]\nmax_connections=300\ninnodb_autoinc_lock_mode=1\n", "max_connections", "300", "innodb_autoinc_lock_mode", "1"),
+			newReconfigureOps("reconfigure-1", now.Add(-2*time.Hour), appsv1alpha1.OpsSucceedPhase,
+				"[mysqld]\nmax_connections=200\n", "max_connections", "200"),
+			newReconfigureOps("reconfigure-3", now, appsv1alpha1.OpsFailedPhase, "", "max_connections", "400"),
+		}
+
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(ns)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(objs...)
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	It("list the config revisions", func() {
+		Expect(NewConfigHistoryCmd(tf, streams)).ShouldNot(BeNil())
+		o := &ConfigHistoryOptions{Factory: tf, IOStreams: streams}
+		Expect(o.Complete([]string{clusterName})).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`1\s+reconfigure-1\s+mysql\s+mysql-config-tpl\s+Succeed\s+.*max_connections`))
+		Expect(out.String()).Should(MatchRegexp(`2\s+reconfigure-2\s+mysql\s+mysql-config-tpl\s+Succeed\s+.*max_connections,innodb_autoinc_lock_mode`))
+		Expect(out.String()).Should(MatchRegexp(`3\s+reconfigure-3\s+.*Failed`))
+		Expect(out.String()).ShouldNot(ContainSubstring("restart"))
+
+		By("filter the revisions by the config spec")
+		out.Reset()
+		o.ConfigSpec = "not-exist"
+		o.Format = printer.JSON
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("null"))
+	})
+
+	It("roll back the config to a revision", func() {
+		Expect(NewRollbackConfigCmd(tf, streams)).ShouldNot(BeNil())
+		newOptions := func(to string) *RollbackConfigOptions {
+			o := &RollbackConfigOptions{Factory: tf, IOStreams: streams, To: to, DryRun: "none", AutoApprove: true}
+			Expect(o.Complete([]string{clusterName})).Should(Succeed())
+			return o
+		}
+
+		Expect(newOptions("4").Validate()).Should(MatchError(ContainSubstring("not found")))
+		Expect(newOptions("reconfigure-3").Validate()).Should(MatchError(ContainSubstring("only the succeeded revision")))
+		Expect(newOptions("2").Validate()).Should(MatchError(ContainSubstring("nothing to roll back")))
+
+		o := newOptions("1")
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`my.cnf\s+innodb_autoinc_lock_mode\s+1\s+<unset>`))
+		Expect(out.String()).Should(MatchRegexp(`my.cnf\s+max_connections\s+300\s+200`))
+
+		objs, err := tf.FakeDynamicClient.Resource(types.OpsGVR()).Namespace(ns).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		var created *appsv1alpha1.OpsRequest
+		for _, obj := range objs.Items {
+			ops := &appsv1alpha1.OpsRequest{}
+			Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ops)).Should(Succeed())
+			if ops.Spec.Type == appsv1alpha1.ReconfiguringType && ops.Status.Phase == "" {
+				created = ops
+			}
+		}
+		Expect(created).ShouldNot(BeNil())
+		Expect(created.Spec.Reconfigure.ComponentName).Should(Equal(compName))
+		keys := created.Spec.Reconfigure.Configurations[0].Keys
+		Expect(keys).Should(HaveLen(1))
+		Expect(keys[0].Parameters).Should(HaveLen(2))
+		Expect(keys[0].Parameters[0].Key).Should(Equal("innodb_autoinc_lock_mode"))
+		Expect(keys[0].Parameters[0].Value).Should(BeNil())
+		Expect(*keys[0].Parameters[1].Value).Should(Equal("200"))
+	})
+})
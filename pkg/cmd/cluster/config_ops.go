@@ -20,11 +20,14 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package cluster
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
@@ -36,6 +39,7 @@ import (
 	"github.com/apecloud/kubeblocks/pkg/configuration/core"
 	"github.com/apecloud/kubeblocks/pkg/controllerutil"
 
+	builderconfig "github.com/apecloud/kbcli/pkg/cmd/builder/config"
 	"github.com/apecloud/kbcli/pkg/printer"
 	"github.com/apecloud/kbcli/pkg/types"
 	"github.com/apecloud/kbcli/pkg/util"
@@ -51,12 +55,24 @@ type configOpsOptions struct {
 	// config file replace
 	replaceFile bool
 
+	// content of the native config file
+	nativeFileContent string
+
+	// validateOnly validates the updated parameters without reconfiguring the cluster
+	validateOnly bool
+	// configConstraintFile is the local ConfigConstraint file to validate the parameters without the cluster
+	configConstraintFile string
+
 	// Reconfiguring options
-	ComponentName string
-	LocalFilePath string   `json:"localFilePath"`
-	Parameters    []string `json:"parameters"`
+	ComponentName  string
+	LocalFilePath  string   `json:"localFilePath"`
+	FromNativeFile string   `json:"fromNativeFile"`
+	Parameters     []string `json:"parameters"`
 }
 
+// errNoParameterChanged is returned if the native config file is the same as the current config file
+var errNoParameterChanged = errors.New("no parameter is changed")
+
 var (
 	createReconfigureExample = templates.Examples(`
 		# update component params 
@@ -65,6 +81,24 @@ var (
 		# if only one component, and one config spec, and one config file, simplify the searching process of configure. e.g:
 		# update mysql max_connections, cluster name is mycluster
 		kbcli cluster configure mycluster --set max_connections=2000
+
+		# update max_connections of all the clusters labelled env=test, two clusters at a time
+		kbcli cluster configure -l env=test --set max_connections=2000 --max-unavailable=2
+
+		# update the static parameters in the maintenance window at 2 a.m. every Sunday
+		kbcli cluster configure mycluster --set innodb_buffer_pool_size=2G --schedule="0 2 * * 0"
+
+		# preview whether the parameters are applied dynamically and which instances will be restarted
+		kbcli cluster configure mycluster --set max_connections=2000,innodb_buffer_pool_size=2G --explain-impact
+
+		# update the parameters changed in the native config file, the parameters not changed are skipped
+		kbcli cluster configure mycluster --config-spec=mysql-3node-tpl --from-native-file=my.cnf
+
+		# validate the parameters against the config constraint without reconfiguring the cluster
+		kbcli cluster configure mycluster --set max_connections=2000,innodb_autoinc_lock_mode=1 --validate-only
+
+		# validate the parameters against a local config constraint file without connecting to the cluster
+		kbcli cluster configure --set max_connections=2000 --config-file=my.cnf --validate-only --config-constraint=mysql-config-constraint.yaml
 	`)
 )
 
@@ -93,6 +127,17 @@ func (o *configOpsOptions) Complete() error {
 }
 
 func (o *configOpsOptions) validateReconfigureOptions() error {
+	if o.FromNativeFile != "" {
+		if o.LocalFilePath != "" || len(o.Parameters) > 0 {
+			return core.MakeError("--from-native-file can not be specified with --local-file or --set")
+		}
+		b, err := os.ReadFile(o.FromNativeFile)
+		if err != nil {
+			return err
+		}
+		o.nativeFileContent = string(b)
+		return nil
+	}
 	if o.LocalFilePath != "" && o.CfgFile == "" {
 		return core.MakeError("config file is required when using --local-file")
 	}
@@ -112,8 +157,8 @@ func (o *configOpsOptions) validateReconfigureOptions() error {
 	return nil
 }
 
-// Validate command flags or args is legal
-func (o *configOpsOptions) Validate() error {
+// fillRequiredParams fills the config spec, config file and component resolved by the wrapper.
+func (o *configOpsOptions) fillRequiredParams() error {
 	if err := o.wrapper.ValidateRequiredParam(o.replaceFile); err != nil {
 		return err
 	}
@@ -123,10 +168,23 @@ func (o *configOpsOptions) Validate() error {
 	if len(o.ComponentNames) == 0 {
 		o.ComponentNames = []string{o.wrapper.ComponentName()}
 	}
+	return nil
+}
+
+// Validate command flags or args is legal
+func (o *configOpsOptions) Validate() error {
+	if err := o.fillRequiredParams(); err != nil {
+		return err
+	}
 
 	if o.editMode {
 		return nil
 	}
+	if o.FromNativeFile != "" {
+		if err := o.diffNativeConfigFile(); err != nil {
+			return err
+		}
+	}
 	if err := o.validateConfigParams(o.wrapper.ConfigTemplateSpec()); err != nil {
 		return err
 	}
@@ -137,6 +195,145 @@ func (o *configOpsOptions) Validate() error {
 	return nil
 }
 
+// diffNativeConfigFile diffs the native config file against the current config file of the component,
+// and only keeps the changed parameters. The parameters absent from the native config file are kept unchanged.
+func (o *configOpsOptions) diffNativeConfigFile() error {
+	tpl := o.wrapper.ConfigTemplateSpec()
+	if tpl.ConfigConstraintRef == "" {
+		return core.MakeError("config spec[%s] has no config constraint, --from-native-file is not supported", tpl.Name)
+	}
+	cc := appsv1alpha1.ConfigConstraint{}
+	if err := util.GetResourceObjectFromGVR(types.ConfigConstraintGVR(), client.ObjectKey{Name: tpl.ConfigConstraintRef}, o.Dynamic, &cc); err != nil {
+		return err
+	}
+	if cc.Spec.FormatterConfig == nil {
+		return core.MakeError("config spec[%s] not support reconfiguring!", tpl.Name)
+	}
+	cmObj := corev1.ConfigMap{}
+	cmKey := client.ObjectKey{
+		Name:      core.GetComponentCfgName(o.Name, o.wrapper.ComponentName(), o.CfgTemplateName),
+		Namespace: o.Namespace,
+	}
+	if err := util.GetResourceObjectFromGVR(types.ConfigmapGVR(), cmKey, o.Dynamic, &cmObj); err != nil {
+		return err
+	}
+
+	params, err := diffConfigParams(map[string]string{o.CfgFile: cmObj.Data[o.CfgFile]}, map[string]string{o.CfgFile: o.nativeFileContent}, cc.Spec.FormatterConfig)
+	if err != nil {
+		return core.WrapError(err, "failed to parse %s with the %s formatter", o.FromNativeFile, cc.Spec.FormatterConfig.Format)
+	}
+	changed := map[string]*string{}
+	var absent []string
+	for key, value := range fromKeyValuesToMap(params, o.CfgFile) {
+		if value == nil {
+			absent = append(absent, key)
+			continue
+		}
+		changed[key] = value
+	}
+	if len(changed) == 0 {
+		return fmt.Errorf("%w in %s from the config file %s of cluster %s", errNoParameterChanged, o.FromNativeFile, o.CfgFile, o.Name)
+	}
+
+	keys := sets.KeySet(changed)
+	if o.validateOnly {
+		// the immutable and unknown parameters are reported by the validation
+		o.KeyValues = changed
+		return nil
+	}
+	if immutable := sets.New(cc.Spec.ImmutableParameters...).Intersection(keys); immutable.Len() > 0 {
+		return core.MakeError("parameters %v in %s are immutable and can not be modified", sets.List(immutable), o.FromNativeFile)
+	}
+	unknown, err := util.GetUnknownParameters(&cc.Spec, keys)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return core.MakeError("parameters %v in %s are unknown, they are not defined by the config constraint %s", unknown, o.FromNativeFile, cc.Name)
+	}
+
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("PARAMETER", "VALUE")
+	for _, key := range sets.List(keys) {
+		tbl.AddRow(key, *changed[key])
+	}
+	fmt.Fprintf(o.Out, "Parameters changed in %s:\n", o.FromNativeFile)
+	tbl.Print()
+	if len(absent) > 0 {
+		sort.Strings(absent)
+		fmt.Fprintf(o.Out, "Parameters absent from %s are kept unchanged: %s\n", o.FromNativeFile, strings.Join(absent, ","))
+	}
+	o.KeyValues = changed
+	return nil
+}
+
+// validateParameters validates the updated parameters against the ConfigConstraint of the config spec
+// and prints the issues instead of reconfiguring the cluster.
+func (o *configOpsOptions) validateParameters() error {
+	if o.isFleet() {
+		return fmt.Errorf("--validate-only can not be specified with --selector or --all-namespaces")
+	}
+	if o.LocalFilePath != "" {
+		return fmt.Errorf("--validate-only only supports the parameters specified by --set or --from-native-file")
+	}
+	if err := o.Complete(); err != nil {
+		return err
+	}
+	if err := o.fillRequiredParams(); err != nil {
+		return err
+	}
+	if o.FromNativeFile != "" {
+		if err := o.diffNativeConfigFile(); err != nil {
+			return err
+		}
+	}
+	tpl := o.wrapper.ConfigTemplateSpec()
+	if tpl.ConfigConstraintRef == "" {
+		return core.MakeError("config spec[%s] has no config constraint, the parameters can not be validated", tpl.Name)
+	}
+	cc := appsv1alpha1.ConfigConstraint{}
+	if err := util.GetResourceObjectFromGVR(types.ConfigConstraintGVR(), client.ObjectKey{Name: tpl.ConfigConstraintRef}, o.Dynamic, &cc); err != nil {
+		return err
+	}
+	return o.printParameterIssues(&cc.Spec, o.KeyValues)
+}
+
+// validateLocalParameters validates the parameters specified by --set against the local ConfigConstraint
+// file, it does not connect to the cluster, so neither the cluster nor the kubeconfig is required.
+func (o *configOpsOptions) validateLocalParameters() error {
+	if !o.validateOnly {
+		return fmt.Errorf("--config-constraint can only be specified with --validate-only")
+	}
+	if o.isFleet() {
+		return fmt.Errorf("--validate-only can not be specified with --selector or --all-namespaces")
+	}
+	if o.LocalFilePath != "" || o.FromNativeFile != "" {
+		return fmt.Errorf("--config-constraint only supports the parameters specified by --set")
+	}
+	params, err := builderconfig.ParseParameters(o.Parameters)
+	if err != nil {
+		return err
+	}
+	cc, err := builderconfig.ReadConfigConstraint(o.configConstraintFile)
+	if err != nil {
+		return err
+	}
+	return o.printParameterIssues(&cc.Spec, params)
+}
+
+// printParameterIssues validates the parameters against the ConfigConstraint and prints the issues,
+// it returns an error with the exit code 2 if any parameter is invalid.
+func (o *configOpsOptions) printParameterIssues(cc *appsv1alpha1.ConfigConstraintSpec, params map[string]*string) error {
+	issues, err := util.ValidateParameters(cc, o.CfgFile, params)
+	if err != nil {
+		return err
+	}
+	if err = builderconfig.PrintParameterIssues(o.Out, issues, printer.Table); err != nil {
+		return err
+	}
+	return builderconfig.CheckParameterIssues(issues)
+}
+
 func (o *configOpsOptions) validateConfigParams(tpl *appsv1alpha1.ComponentConfigSpec) error {
 	configConstraintKey := client.ObjectKey{
 		Namespace: "",
@@ -229,6 +426,27 @@ func (o *configOpsOptions) parseUpdatedParams() (map[string]string, error) {
 	return keyValues, nil
 }
 
+// runOnClusters reconfigures the cluster specified by the args, or every cluster matching the label selector.
+func (o *configOpsOptions) runOnClusters() error {
+	prepare := func(c *configOpsOptions) error {
+		if err := c.Complete(); err != nil {
+			return err
+		}
+		return c.Validate()
+	}
+	if !o.isFleet() {
+		if err := prepare(o); err != nil {
+			return err
+		}
+		return o.Run()
+	}
+	return o.runFleet(func(namespace, name string) (*OperationsOptions, error) {
+		c := *o
+		c.OperationsOptions = o.cloneForCluster(namespace, name)
+		return c.OperationsOptions, prepare(&c)
+	})
+}
+
 func (o *configOpsOptions) printConfigureTips() {
 	fmt.Println("Will updated configure file meta:")
 	printer.PrintLineWithTabSeparator(
@@ -266,14 +484,29 @@ func NewReconfigureCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *c
 		Run: func(cmd *cobra.Command, args []string) {
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			if o.configConstraintFile != "" {
+				cmdutil.CheckErr(o.validateLocalParameters())
+				return
+			}
 			cmdutil.CheckErr(o.CreateOptions.Complete())
-			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			if o.ExplainImpact {
+				cmdutil.CheckErr(o.explainImpact())
+				return
+			}
+			if o.validateOnly {
+				cmdutil.CheckErr(o.validateParameters())
+				return
+			}
+			cmdutil.CheckErr(o.runOnClusters())
 		},
 	}
 
 	o.buildReconfigureCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before reconfiguring the cluster")
+	cmd.Flags().StringVar(&o.FromNativeFile, "from-native-file", "", "Specify the native configuration file of the engine (e.g. my.cnf), only the parameters changed from the current configuration are updated.")
+	cmd.Flags().BoolVar(&o.ExplainImpact, "explain-impact", false, "Print how each parameter is applied and the planned restart sequence without reconfiguring the cluster")
+	cmd.Flags().BoolVar(&o.validateOnly, "validate-only", false, "Validate the parameters against the config constraint without reconfiguring the cluster, exit with 2 if any parameter is invalid")
+	cmd.Flags().StringVar(&o.configConstraintFile, "config-constraint", "", "Specify a local ConfigConstraint YAML file to validate the parameters with --validate-only, the cluster is not required")
 	return cmd
 }
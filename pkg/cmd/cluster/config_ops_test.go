@@ -21,7 +21,11 @@ package cluster
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -29,15 +33,21 @@ import (
 	cfgcore "github.com/apecloud/kubeblocks/pkg/configuration/core"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/genericiooptions"
 	clientfake "k8s.io/client-go/rest/fake"
 	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+	"k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
 
 	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
 	testapps "github.com/apecloud/kubeblocks/pkg/testutil/apps"
 
+	"github.com/apecloud/kbcli/pkg/action"
+	builderconfig "github.com/apecloud/kbcli/pkg/cmd/builder/config"
 	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
 )
 
 var _ = Describe("reconfigure test", func() {
@@ -134,4 +144,147 @@ var _ = Describe("reconfigure test", func() {
 		Expect(o.Validate()).Should(Succeed())
 	})
 
+	It("reconfigure from the native config file", func() {
+		const (
+			ns             = "default"
+			clusterDefName = "test-clusterdef"
+			clusterName    = "test-cluster"
+			compDefName    = "replicasets"
+			compName       = "mysql"
+			configSpecName = "mysql-config-tpl"
+		)
+
+		configmap := testapps.NewCustomizedObj("resources/mysql-config-template.yaml", &corev1.ConfigMap{}, testapps.WithNamespace(ns))
+		constraint := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		constraint.Spec.ImmutableParameters = []string{"auto_increment_increment"}
+		componentConfig := testapps.NewConfigMap(ns, cfgcore.GetComponentCfgName(clusterName, compName, configSpecName),
+			testapps.SetConfigMapData("my.cnf", "[mysqld]\nautomatic_sp_privileges=ON\nauto_increment_increment=1\ninnodb_autoinc_lock_mode=1\n"))
+		clusterDefObj := testapps.NewClusterDefFactory(clusterDefName).
+			AddComponentDef(testapps.StatefulMySQLComponent, compDefName).
+			AddConfigTemplate(configSpecName, configmap.Name, constraint.Name, ns, "mysql-config").
+			GetObject()
+		clusterObj := testapps.NewClusterFactory(ns, clusterName, clusterDefObj.Name, "").
+			AddComponent(compName, compDefName).GetObject()
+
+		objs := []runtime.Object{configmap, constraint, clusterDefObj, clusterObj, componentConfig}
+		ttf, ops := NewFakeOperationsOptions(ns, clusterName, appsv1alpha1.ReconfiguringType, objs...)
+		defer ttf.Cleanup()
+		out := &bytes.Buffer{}
+		ops.Out = out
+		tmpDir, _ := os.MkdirTemp(os.TempDir(), "test-")
+		defer os.RemoveAll(tmpDir)
+		nativeFile := filepath.Join(tmpDir, "my.cnf")
+
+		diffNativeFile := func(content string) (*configOpsOptions, error) {
+			Expect(os.WriteFile(nativeFile, []byte(content), 0644)).Should(Succeed())
+			o := &configOpsOptions{OperationsOptions: &OperationsOptions{CreateOptions: *ops}}
+			o.CfgTemplateName = configSpecName
+			o.FromNativeFile = nativeFile
+			if err := o.Complete(); err != nil {
+				return o, err
+			}
+			if err := o.fillRequiredParams(); err != nil {
+				return o, err
+			}
+			return o, o.diffNativeConfigFile()
+		}
+
+		By("only the changed parameters are updated")
+		o, err := diffNativeFile("[mysqld]\nautomatic_sp_privileges=ON\ninnodb_autoinc_lock_mode=2\n")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(o.KeyValues).Should(HaveLen(1))
+		Expect(*o.KeyValues["innodb_autoinc_lock_mode"]).Should(Equal("2"))
+		Expect(out.String()).Should(MatchRegexp(`innodb_autoinc_lock_mode\s+2`))
+		Expect(out.String()).Should(ContainSubstring("kept unchanged: auto_increment_increment"))
+
+		By("the immutable parameters can not be modified")
+		_, err = diffNativeFile("[mysqld]\nautomatic_sp_privileges=ON\nauto_increment_increment=2\n")
+		Expect(err).Should(MatchError(ContainSubstring("[auto_increment_increment] in " + nativeFile + " are immutable")))
+
+		By("the parameters not defined by the config constraint are unknown")
+		_, err = diffNativeFile("[mysqld]\nautomatic_sp_privileges=ON\nno_such_param=1\n")
+		Expect(err).Should(MatchError(ContainSubstring("[no_such_param] in " + nativeFile + " are unknown")))
+
+		By("no parameter is changed")
+		_, err = diffNativeFile("[mysqld]\nautomatic_sp_privileges=ON\n")
+		Expect(errors.Is(err, errNoParameterChanged)).Should(BeTrue())
+
+		By("the native file can not be specified with --set")
+		o = &configOpsOptions{OperationsOptions: &OperationsOptions{CreateOptions: *ops}, FromNativeFile: nativeFile, Parameters: []string{"a=b"}}
+		Expect(o.validateReconfigureOptions()).Should(HaveOccurred())
+	})
+
+	It("validate the parameters without reconfiguring", func() {
+		const (
+			ns             = "default"
+			clusterDefName = "test-clusterdef"
+			clusterName    = "test-cluster"
+			compDefName    = "replicasets"
+			compName       = "mysql"
+			configSpecName = "mysql-config-tpl"
+		)
+
+		configmap := testapps.NewCustomizedObj("resources/mysql-config-template.yaml", &corev1.ConfigMap{}, testapps.WithNamespace(ns))
+		constraint := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		componentConfig := testapps.NewConfigMap(ns, cfgcore.GetComponentCfgName(clusterName, compName, configSpecName),
+			testapps.SetConfigMapData("my.cnf", "[mysqld]\nautomatic_sp_privileges=ON\n"))
+		clusterDefObj := testapps.NewClusterDefFactory(clusterDefName).
+			AddComponentDef(testapps.StatefulMySQLComponent, compDefName).
+			AddConfigTemplate(configSpecName, configmap.Name, constraint.Name, ns, "mysql-config").
+			GetObject()
+		clusterObj := testapps.NewClusterFactory(ns, clusterName, clusterDefObj.Name, "").
+			AddComponent(compName, compDefName).GetObject()
+
+		ttf, ops := NewFakeOperationsOptions(ns, clusterName, appsv1alpha1.ReconfiguringType, configmap, constraint, clusterDefObj, clusterObj, componentConfig)
+		defer ttf.Cleanup()
+		out := &bytes.Buffer{}
+		ops.Out = out
+		validate := func(params ...string) error {
+			o := &configOpsOptions{OperationsOptions: &OperationsOptions{CreateOptions: *ops}, Parameters: params, validateOnly: true}
+			return o.validateParameters()
+		}
+
+		Expect(validate("innodb_autoinc_lock_mode=1")).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("All parameters are valid."))
+
+		out.Reset()
+		err := validate("innodb_autoinc_lock_mode=5,automatic_sp_privileges=OFF")
+		var exitErr exec.CodeExitError
+		Expect(errors.As(err, &exitErr)).Should(BeTrue())
+		Expect(exitErr.ExitStatus()).Should(Equal(builderconfig.ExitCodeInvalidParameters))
+		Expect(out.String()).Should(MatchRegexp(`innodb_autoinc_lock_mode\s+5\s+error\s+range`))
+		Expect(out.String()).Should(MatchRegexp(`automatic_sp_privileges\s+OFF\s+warning\s+static`))
+		Expect(ops.Dynamic.Resource(types.OpsGVR()).Namespace(ns).List(context.TODO(), metav1.ListOptions{})).Should(HaveField("Items", BeEmpty()))
+	})
+
+	It("validate the parameters against a local config constraint file", func() {
+		dir := GinkgoT().TempDir()
+		cc := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		data, err := yaml.Marshal(cc)
+		Expect(err).ShouldNot(HaveOccurred())
+		constraintFile := filepath.Join(dir, "cc.yaml")
+		Expect(os.WriteFile(constraintFile, data, 0644)).Should(Succeed())
+
+		out := &bytes.Buffer{}
+		// no factory is set, the validation must not connect to the cluster
+		validate := func(validateOnly bool, params ...string) error {
+			o := &configOpsOptions{
+				OperationsOptions:    &OperationsOptions{CreateOptions: action.CreateOptions{IOStreams: genericiooptions.IOStreams{Out: out}}},
+				Parameters:           params,
+				validateOnly:         validateOnly,
+				configConstraintFile: constraintFile,
+			}
+			return o.validateLocalParameters()
+		}
+		Expect(validate(false, "innodb_autoinc_lock_mode=1")).Should(MatchError(ContainSubstring("--validate-only")))
+		Expect(validate(true, "innodb_autoinc_lock_mode=1")).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("All parameters are valid."))
+
+		out.Reset()
+		err = validate(true, "innodb_autoinc_lock_mode=5")
+		var exitErr exec.CodeExitError
+		Expect(errors.As(err, &exitErr)).Should(BeTrue())
+		Expect(exitErr.ExitStatus()).Should(Equal(builderconfig.ExitCodeInvalidParameters))
+		Expect(out.String()).Should(MatchRegexp(`innodb_autoinc_lock_mode\s+5\s+error\s+range`))
+	})
 })
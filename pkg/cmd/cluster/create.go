@@ -260,8 +260,10 @@ type CreateOptions struct {
 	CPUOversellRatio    float64  `json:"-"`
 	MemoryOversellRatio float64  `json:"-"`
 
-	// backup name to restore in creation
+	// backup name and namespace to restore in creation, the namespace of the cluster is used if
+	// the backup namespace is not specified
 	Backup              string `json:"backup,omitempty"`
+	BackupNamespace     string `json:"-"`
 	RestoreTime         string `json:"restoreTime,omitempty"`
 	VolumeRestorePolicy string `json:"-"`
 
@@ -353,12 +355,19 @@ func getBackupObjectFromRestoreArgs(o *CreateOptions, backup *dpv1alpha1.Backup)
 	if o.Backup == "" {
 		return nil
 	}
-	if err := util.GetK8SClientObject(o.Dynamic, backup, types.BackupGVR(), o.Namespace, o.Backup); err != nil {
+	if err := util.GetK8SClientObject(o.Dynamic, backup, types.BackupGVR(), o.getBackupNamespace(), o.Backup); err != nil {
 		return err
 	}
 	return nil
 }
 
+func (o *CreateOptions) getBackupNamespace() string {
+	if o.BackupNamespace != "" {
+		return o.BackupNamespace
+	}
+	return o.Namespace
+}
+
 func fillClusterInfoFromBackup(o *CreateOptions, cls **appsv1alpha1.Cluster) error {
 	if o.Backup == "" {
 		return nil
@@ -399,7 +408,7 @@ func setBackup(o *CreateOptions, components []map[string]interface{}) error {
 		return nil
 	}
 	backup := &dpv1alpha1.Backup{}
-	if err := util.GetK8SClientObject(o.Dynamic, backup, types.BackupGVR(), o.Namespace, backupName); err != nil {
+	if err := util.GetK8SClientObject(o.Dynamic, backup, types.BackupGVR(), o.getBackupNamespace(), backupName); err != nil {
 		return err
 	}
 	if backup.Status.Phase != dpv1alpha1.BackupPhaseCompleted &&
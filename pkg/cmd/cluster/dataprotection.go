@@ -103,6 +103,12 @@ var (
 	createRestoreExample = templates.Examples(`
 		# restore a new cluster from a backup
 		kbcli cluster restore new-cluster-name --backup backup-name
+
+		# restore a new cluster to the specified time of the source cluster
+		kbcli cluster restore new-cluster-name --source-cluster mycluster --restore-to-time "Oct 17,2026 10:00:00 UTC+0800"
+
+		# restore a new cluster to the latest recoverable time of the source cluster
+		kbcli cluster restore new-cluster-name --source-cluster mycluster --restore-to-latest
 	`)
 	describeBackupExample = templates.Examples(`
 		# describe a backup
@@ -502,12 +508,25 @@ type CreateRestoreOptions struct {
 	OpsType        string                   `json:"opsType"`
 	OpsRequestName string                   `json:"opsRequestName"`
 
+	// SourceCluster is the cluster whose restore points the backup is chosen from
+	SourceCluster   string `json:"-"`
+	RestoreToLatest bool   `json:"-"`
+
 	action.CreateOptions `json:"-"`
 }
 
 func (o *CreateRestoreOptions) Validate() error {
-	if o.RestoreSpec.BackupName == "" {
-		return fmt.Errorf("must be specified one of the --backup ")
+	if o.RestoreSpec.BackupName == "" && o.SourceCluster == "" {
+		return fmt.Errorf("must be specified one of the --backup or --source-cluster")
+	}
+	if o.RestoreToLatest && o.RestoreSpec.RestoreTimeStr != "" {
+		return fmt.Errorf("--restore-to-time and --restore-to-latest can not be specified at the same time")
+	}
+	if o.RestoreSpec.BackupName == "" && o.RestoreSpec.RestoreTimeStr == "" && !o.RestoreToLatest {
+		return fmt.Errorf("--source-cluster must be specified with --restore-to-time or --restore-to-latest")
+	}
+	if err := o.validateRestoreTime(); err != nil {
+		return err
 	}
 
 	if o.Name == "" {
@@ -529,6 +548,70 @@ func (o *CreateRestoreOptions) Validate() error {
 	return nil
 }
 
+// validateRestoreTime validates the restore time against the restore timeline of the source cluster, and
+// chooses the backup to restore from. The full backup is restored without the restore time.
+func (o *CreateRestoreOptions) validateRestoreTime() error {
+	if o.RestoreSpec.RestoreTimeStr == "" && !o.RestoreToLatest {
+		return nil
+	}
+	sourceCluster := o.SourceCluster
+	if sourceCluster == "" {
+		backup := &dpv1alpha1.Backup{}
+		if err := util.GetK8SClientObject(o.Dynamic, backup, types.BackupGVR(), o.Namespace, o.RestoreSpec.BackupName); err != nil {
+			return err
+		}
+		if sourceCluster = backup.Labels[constant.AppInstanceLabelKey]; sourceCluster == "" {
+			return fmt.Errorf("failed to get the source cluster of backup %s, please specify it by --source-cluster", backup.Name)
+		}
+	}
+	timeline, err := getRestoreTimeline(o.Dynamic, o.Namespace, sourceCluster)
+	if err != nil {
+		return err
+	}
+	if len(timeline.Windows) == 0 {
+		return fmt.Errorf("no restore point found in cluster %s", sourceCluster)
+	}
+
+	var (
+		restoreTime time.Time
+		window      *restoreWindow
+	)
+	if o.RestoreToLatest {
+		window = timeline.latest()
+		restoreTime = window.End
+	} else {
+		if restoreTime, err = util.TimeParse(o.RestoreSpec.RestoreTimeStr, time.Second); err != nil {
+			if restoreTime, err = time.Parse(time.RFC3339, o.RestoreSpec.RestoreTimeStr); err != nil {
+				return fmt.Errorf("invalid restore-to-time %s, it should be like %s or in RFC3339 format", o.RestoreSpec.RestoreTimeStr,
+					util.TimeTimeFormatWithDuration(time.Now(), time.Second))
+			}
+		}
+		// the continuous backups may overlap, the specified backup is validated against its own window
+		if o.RestoreSpec.BackupName != "" {
+			window = timeline.findInBackup(o.RestoreSpec.BackupName, restoreTime)
+		} else {
+			window = timeline.find(restoreTime)
+		}
+		if window == nil {
+			if found := timeline.find(restoreTime); found != nil {
+				return makeBackupNotRecoverableErr(o.RestoreSpec.BackupName, restoreTime, found.Backup)
+			}
+			return makeRestoreTimeNotRecoverableErr(timeline, restoreTime)
+		}
+	}
+	if o.RestoreSpec.BackupName != "" && o.RestoreSpec.BackupName != window.Backup {
+		return makeBackupNotRecoverableErr(o.RestoreSpec.BackupName, restoreTime, window.Backup)
+	}
+
+	o.RestoreSpec.BackupName = window.Backup
+	o.RestoreSpec.RestoreTimeStr = ""
+	if window.Type == restoreWindowContinuous {
+		o.RestoreSpec.RestoreTimeStr = restoreTime.UTC().Format(time.RFC3339)
+	}
+	fmt.Fprintf(o.Out, "Restore to %s from %s backup %s\n", util.TimeTimeFormatWithDuration(restoreTime, time.Second), strings.ToLower(window.Type), window.Backup)
+	return nil
+}
+
 func NewCreateRestoreCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
 	customOutPut := func(opt *action.CreateOptions) {
 		output := fmt.Sprintf("Cluster %s created", opt.Name)
@@ -558,7 +641,9 @@ func NewCreateRestoreCmd(f cmdutil.Factory, streams genericiooptions.IOStreams)
 		},
 	}
 	cmd.Flags().StringVar(&o.RestoreSpec.BackupName, "backup", "", "Backup name")
-	cmd.Flags().StringVar(&o.RestoreSpec.RestoreTimeStr, "restore-to-time", "", "point in time recovery(PITR)")
+	cmd.Flags().StringVar(&o.RestoreSpec.RestoreTimeStr, "restore-to-time", "", "point in time recovery(PITR), the time should be recoverable in 'kbcli cluster list-restore-points'")
+	cmd.Flags().BoolVar(&o.RestoreToLatest, "restore-to-latest", false, "Restore to the latest recoverable time of the source cluster")
+	cmd.Flags().StringVar(&o.SourceCluster, "source-cluster", "", "The source cluster to restore, the backup is chosen from its restore points by --restore-to-time or --restore-to-latest")
 	cmd.Flags().StringVar(&o.RestoreSpec.VolumeRestorePolicy, "volume-restore-policy", "Parallel", "the volume claim restore policy, supported values: [Serial, Parallel]")
 	return cmd
 }
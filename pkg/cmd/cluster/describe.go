@@ -21,6 +21,7 @@ package cluster
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -35,9 +36,11 @@ import (
 	clientset "k8s.io/client-go/kubernetes"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
 
 	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
 	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	dptypes "github.com/apecloud/kubeblocks/pkg/dataprotection/types"
 	"github.com/apecloud/kubeblocks/pkg/dataprotection/utils/boolptr"
 
 	"github.com/apecloud/kbcli/pkg/cluster"
@@ -49,7 +52,10 @@ import (
 var (
 	describeExample = templates.Examples(`
 		# describe a specified cluster
-		kbcli cluster describe mycluster`)
+		kbcli cluster describe mycluster
+
+		# describe a specified cluster in JSON format, which is suitable for scripts and dashboards
+		kbcli cluster describe mycluster -o json`)
 
 	newTbl = func(out io.Writer, title string, header ...interface{}) *printer.TablePrinter {
 		fmt.Fprintln(out, title)
@@ -69,6 +75,8 @@ type describeOptions struct {
 	gvr   schema.GroupVersionResource
 	names []string
 
+	format printer.Format
+
 	*cluster.ClusterObjects
 	genericiooptions.IOStreams
 }
@@ -84,8 +92,14 @@ func newOptions(f cmdutil.Factory, streams genericiooptions.IOStreams) *describe
 func NewDescribeCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
 	o := newOptions(f, streams)
 	cmd := &cobra.Command{
-		Use:               "describe NAME",
-		Short:             "Show details of a specific cluster.",
+		Use:   "describe NAME",
+		Short: "Show details of a specific cluster.",
+		Long: templates.LongDesc(`
+			Show details of a specific cluster.
+
+			With -o json or -o yaml, a ClusterDescription document of apiVersion kbcli.kubeblocks.io/v1alpha1
+			is printed, which contains the keys cluster, components, instances, endpoints, dataProtection and
+			events. A ClusterDescriptionList with the descriptions in items is printed for multiple clusters.`),
 		Example:           describeExample,
 		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
 		Run: func(cmd *cobra.Command, args []string) {
@@ -93,6 +107,7 @@ func NewDescribeCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobr
 			util.CheckErr(o.run())
 		},
 	}
+	printer.AddOutputFlag(cmd, &o.format)
 	return cmd
 }
 
@@ -119,6 +134,9 @@ func (o *describeOptions) complete(args []string) error {
 }
 
 func (o *describeOptions) run() error {
+	if o.format == printer.JSON || o.format == printer.YAML {
+		return o.printDescriptions()
+	}
 	for _, name := range o.names {
 		if err := o.describeCluster(name); err != nil {
 			return err
@@ -127,7 +145,7 @@ func (o *describeOptions) run() error {
 	return nil
 }
 
-func (o *describeOptions) describeCluster(name string) error {
+func (o *describeOptions) getClusterObjects(name string, withEvent bool) error {
 	clusterGetter := cluster.ObjectsGetter{
 		Client:    o.client,
 		Dynamic:   o.dynamic,
@@ -138,12 +156,18 @@ func (o *describeOptions) describeCluster(name string) error {
 			WithService:        true,
 			WithPod:            true,
 			WithPVC:            true,
+			WithEvent:          withEvent,
 			WithDataProtection: true,
 		},
 	}
 
 	var err error
-	if o.ClusterObjects, err = clusterGetter.Get(); err != nil {
+	o.ClusterObjects, err = clusterGetter.Get()
+	return err
+}
+
+func (o *describeOptions) describeCluster(name string) error {
+	if err := o.getClusterObjects(name, false); err != nil {
 		return err
 	}
 
@@ -194,6 +218,290 @@ func (o *describeOptions) getDefaultBackupRepo() (string, error) {
 	return printer.NoneString, nil
 }
 
+const (
+	clusterDescriptionAPIVersion = "kbcli.kubeblocks.io/v1alpha1"
+	clusterDescriptionKind       = "ClusterDescription"
+	clusterDescriptionListKind   = "ClusterDescriptionList"
+
+	// maxDescribeWarningEvents is the max number of recent warning events in the cluster description
+	maxDescribeWarningEvents = 10
+)
+
+// clusterDescription is the structured document printed by `kbcli cluster describe -o json|yaml`, all
+// the keys are in lowerCamelCase:
+//   - cluster: the name, namespace, cluster definition, version, status and termination policy
+//   - components: the type, replicas, resources, image and storage of each component
+//   - instances: the role, status, resources, storage and node of each pod
+//   - endpoints: the internal and external endpoints of each component
+//   - dataProtection: the default backup repo, the backup policies and the backup schedules
+//   - events: the recent warning events
+//
+// The components and the instances are converted from the shared ComponentInfo and InstanceInfo
+// instead of being embedded, so the document does not change with the keys of those types. The
+// apiVersion should be bumped if a field is removed or its meaning is changed.
+type clusterDescription struct {
+	APIVersion     string                    `json:"apiVersion"`
+	Kind           string                    `json:"kind"`
+	Cluster        *cluster.ClusterInfo      `json:"cluster"`
+	Components     []componentDescription    `json:"components"`
+	Instances      []instanceDescription     `json:"instances"`
+	Endpoints      []componentEndpoints      `json:"endpoints"`
+	DataProtection dataProtectionDescription `json:"dataProtection"`
+	// Events are the recent warning events of the cluster and its instances, the latest is the last one
+	Events []eventDescription `json:"events"`
+}
+
+type clusterDescriptionList struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Items      []*clusterDescription `json:"items"`
+}
+
+type componentDescription struct {
+	Name     string               `json:"name"`
+	Type     string               `json:"type"`
+	Status   string               `json:"status,omitempty"`
+	Replicas string               `json:"replicas"`
+	CPU      string               `json:"cpu,omitempty"`
+	Memory   string               `json:"memory,omitempty"`
+	Image    string               `json:"image,omitempty"`
+	Storage  []storageDescription `json:"storage,omitempty"`
+}
+
+type instanceDescription struct {
+	Name        string               `json:"name"`
+	Component   string               `json:"component"`
+	Status      string               `json:"status,omitempty"`
+	Role        string               `json:"role,omitempty"`
+	AccessMode  string               `json:"accessMode,omitempty"`
+	AZ          string               `json:"az,omitempty"`
+	Region      string               `json:"region,omitempty"`
+	CPU         string               `json:"cpu,omitempty"`
+	Memory      string               `json:"memory,omitempty"`
+	Storage     []storageDescription `json:"storage,omitempty"`
+	Node        string               `json:"node,omitempty"`
+	CreatedTime string               `json:"createdTime,omitempty"`
+}
+
+type storageDescription struct {
+	Name         string `json:"name"`
+	Size         string `json:"size,omitempty"`
+	StorageClass string `json:"storageClass,omitempty"`
+	AccessMode   string `json:"accessMode,omitempty"`
+}
+
+type componentEndpoints struct {
+	Component string   `json:"component"`
+	Internal  []string `json:"internal,omitempty"`
+	External  []string `json:"external,omitempty"`
+}
+
+type dataProtectionDescription struct {
+	DefaultBackupRepo string                      `json:"defaultBackupRepo,omitempty"`
+	BackupPolicies    []backupPolicyDescription   `json:"backupPolicies"`
+	BackupSchedules   []backupScheduleDescription `json:"backupSchedules"`
+}
+
+type backupPolicyDescription struct {
+	Name          string   `json:"name"`
+	Default       bool     `json:"default"`
+	BackupRepo    string   `json:"backupRepo,omitempty"`
+	BackupMethods []string `json:"backupMethods,omitempty"`
+}
+
+type backupScheduleDescription struct {
+	Name         string                      `json:"name"`
+	BackupPolicy string                      `json:"backupPolicy"`
+	Schedules    []schedulePolicyDescription `json:"schedules,omitempty"`
+}
+
+type schedulePolicyDescription struct {
+	BackupMethod    string `json:"backupMethod"`
+	Enabled         bool   `json:"enabled"`
+	CronExpression  string `json:"cronExpression"`
+	RetentionPeriod string `json:"retentionPeriod,omitempty"`
+}
+
+type eventDescription struct {
+	Time    string `json:"time"`
+	Reason  string `json:"reason"`
+	Object  string `json:"object"`
+	Message string `json:"message"`
+}
+
+// printDescriptions prints the structured descriptions of the clusters, a single cluster is
+// printed as a ClusterDescription and multiple clusters are printed as a ClusterDescriptionList.
+func (o *describeOptions) printDescriptions() error {
+	var descriptions []*clusterDescription
+	for _, name := range o.names {
+		desc, err := o.buildDescription(name)
+		if err != nil {
+			return err
+		}
+		descriptions = append(descriptions, desc)
+	}
+
+	var obj interface{} = descriptions[0]
+	if len(descriptions) > 1 {
+		obj = &clusterDescriptionList{
+			APIVersion: clusterDescriptionAPIVersion,
+			Kind:       clusterDescriptionListKind,
+			Items:      descriptions,
+		}
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	switch o.format {
+	case printer.JSON:
+		data, err = json.MarshalIndent(obj, "", "  ")
+	case printer.YAML:
+		data, err = yaml.Marshal(obj)
+	default:
+		return fmt.Errorf("unsupported output format %s", o.format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(o.Out, string(data))
+	return err
+}
+
+// buildDescription builds the structured description of the cluster from its objects.
+func (o *describeOptions) buildDescription(name string) (*clusterDescription, error) {
+	if err := o.getClusterObjects(name, true); err != nil {
+		return nil, err
+	}
+
+	defaultBackupRepo, err := o.getDefaultBackupRepo()
+	if err != nil {
+		return nil, err
+	}
+	if defaultBackupRepo == printer.NoneString {
+		defaultBackupRepo = ""
+	}
+
+	desc := &clusterDescription{
+		APIVersion: clusterDescriptionAPIVersion,
+		Kind:       clusterDescriptionKind,
+		Cluster:    o.GetClusterInfo(),
+		Components: []componentDescription{},
+		Instances:  []instanceDescription{},
+		Endpoints:  []componentEndpoints{},
+		DataProtection: dataProtectionDescription{
+			DefaultBackupRepo: defaultBackupRepo,
+			BackupPolicies:    []backupPolicyDescription{},
+			BackupSchedules:   []backupScheduleDescription{},
+		},
+		Events: []eventDescription{},
+	}
+
+	for _, comp := range o.GetComponentInfo() {
+		desc.Components = append(desc.Components, componentDescription{
+			Name:     comp.Name,
+			Type:     comp.Type,
+			Status:   comp.Status,
+			Replicas: comp.Replicas,
+			CPU:      comp.CPU,
+			Memory:   comp.Memory,
+			Image:    comp.Image,
+			Storage:  buildStorageDescriptions(comp.Storage),
+		})
+	}
+	for _, instance := range o.GetInstanceInfo() {
+		desc.Instances = append(desc.Instances, instanceDescription{
+			Name:        instance.Name,
+			Component:   instance.Component,
+			Status:      instance.Status,
+			Role:        instance.Role,
+			AccessMode:  instance.AccessMode,
+			AZ:          instance.AZ,
+			Region:      instance.Region,
+			CPU:         instance.CPU,
+			Memory:      instance.Memory,
+			Storage:     buildStorageDescriptions(instance.Storage),
+			Node:        instance.Node,
+			CreatedTime: instance.CreatedTime,
+		})
+	}
+
+	for i := range o.Cluster.Spec.ComponentSpecs {
+		comp := &o.Cluster.Spec.ComponentSpecs[i]
+		internalEndpoints, externalEndpoints := cluster.GetComponentEndpoints(o.Services, comp)
+		if len(internalEndpoints) == 0 && len(externalEndpoints) == 0 {
+			continue
+		}
+		desc.Endpoints = append(desc.Endpoints, componentEndpoints{
+			Component: comp.Name,
+			Internal:  internalEndpoints,
+			External:  externalEndpoints,
+		})
+	}
+
+	for _, policy := range o.BackupPolicies {
+		p := backupPolicyDescription{
+			Name:       policy.Name,
+			Default:    policy.Annotations[dptypes.DefaultBackupPolicyAnnotationKey] == TrueValue,
+			BackupRepo: defaultBackupRepo,
+		}
+		if policy.Spec.BackupRepoName != nil {
+			p.BackupRepo = *policy.Spec.BackupRepoName
+		}
+		for _, method := range policy.Spec.BackupMethods {
+			p.BackupMethods = append(p.BackupMethods, method.Name)
+		}
+		desc.DataProtection.BackupPolicies = append(desc.DataProtection.BackupPolicies, p)
+	}
+
+	for _, schedule := range o.BackupSchedules {
+		s := backupScheduleDescription{
+			Name:         schedule.Name,
+			BackupPolicy: schedule.Spec.BackupPolicyName,
+		}
+		for _, policy := range schedule.Spec.Schedules {
+			s.Schedules = append(s.Schedules, schedulePolicyDescription{
+				BackupMethod:    policy.BackupMethod,
+				Enabled:         boolptr.IsSetToTrue(policy.Enabled),
+				CronExpression:  policy.CronExpression,
+				RetentionPeriod: policy.RetentionPeriod.String(),
+			})
+		}
+		desc.DataProtection.BackupSchedules = append(desc.DataProtection.BackupSchedules, s)
+	}
+
+	if o.Events != nil {
+		events := *util.SortEventsByLastTimestamp(o.Events, corev1.EventTypeWarning)
+		if len(events) > maxDescribeWarningEvents {
+			events = events[len(events)-maxDescribeWarningEvents:]
+		}
+		for _, obj := range events {
+			e := obj.(*corev1.Event)
+			desc.Events = append(desc.Events, eventDescription{
+				Time:    util.GetEventTimeStr(e),
+				Reason:  e.Reason,
+				Object:  util.GetEventObject(e),
+				Message: e.Message,
+			})
+		}
+	}
+	return desc, nil
+}
+
+func buildStorageDescriptions(infos []cluster.StorageInfo) []storageDescription {
+	var storages []storageDescription
+	for _, info := range infos {
+		storages = append(storages, storageDescription{
+			Name:         info.Name,
+			Size:         info.Size,
+			StorageClass: info.StorageClass,
+			AccessMode:   info.AccessMode,
+		})
+	}
+	return storages
+}
+
 func showCluster(c *appsv1alpha1.Cluster, out io.Writer) {
 	if c == nil {
 		return
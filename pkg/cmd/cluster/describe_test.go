@@ -21,6 +21,7 @@ package cluster
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"strings"
 
@@ -34,9 +35,11 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	clientfake "k8s.io/client-go/rest/fake"
 	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+	"sigs.k8s.io/yaml"
 
 	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
 
+	"github.com/apecloud/kbcli/pkg/printer"
 	"github.com/apecloud/kbcli/pkg/testing"
 	"github.com/apecloud/kbcli/pkg/types"
 )
@@ -106,6 +109,36 @@ var _ = Describe("Expose", func() {
 		Expect(o.run()).Should(Succeed())
 	})
 
+	It("run with structured output", func() {
+		var out *bytes.Buffer
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		o := newOptions(tf, streams)
+		o.format = printer.JSON
+		Expect(o.complete([]string{clusterName})).Should(Succeed())
+		Expect(o.run()).Should(Succeed())
+
+		desc := &clusterDescription{}
+		Expect(json.Unmarshal(out.Bytes(), desc)).Should(Succeed())
+		Expect(desc.APIVersion).Should(Equal(clusterDescriptionAPIVersion))
+		Expect(desc.Kind).Should(Equal(clusterDescriptionKind))
+		Expect(desc.Cluster.Name).Should(Equal(clusterName))
+		Expect(desc.Components).ShouldNot(BeEmpty())
+		Expect(desc.Instances).Should(HaveLen(len(pods.Items)))
+		Expect(desc.Instances[0].Storage).ShouldNot(BeEmpty())
+		Expect(out.String()).Should(ContainSubstring(`"storageClass"`))
+		Expect(out.String()).ShouldNot(ContainSubstring(`"Storage"`))
+
+		By("describe multiple clusters in YAML")
+		out.Reset()
+		o.format = printer.YAML
+		Expect(o.complete([]string{clusterName, clusterName})).Should(Succeed())
+		Expect(o.run()).Should(Succeed())
+		list := &clusterDescriptionList{}
+		Expect(yaml.Unmarshal(out.Bytes(), list)).Should(Succeed())
+		Expect(list.Kind).Should(Equal(clusterDescriptionListKind))
+		Expect(list.Items).Should(HaveLen(2))
+	})
+
 	It("showEvents", func() {
 		out := &bytes.Buffer{}
 		showEvents("test-cluster", namespace, out)
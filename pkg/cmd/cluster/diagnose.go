@@ -0,0 +1,574 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var diagnoseExample = templates.Examples(`
+		# diagnose a cluster
+		kbcli cluster diagnose mycluster
+
+		# diagnose a cluster and output the findings in JSON format
+		kbcli cluster diagnose mycluster -o json
+
+		# diagnose a cluster without checking the volume usage inside the instances
+		kbcli cluster diagnose mycluster --check-volume-usage=false`)
+
+type diagnoseSeverity string
+
+const (
+	severityCritical diagnoseSeverity = "Critical"
+	severityWarning  diagnoseSeverity = "Warning"
+	severityInfo     diagnoseSeverity = "Info"
+)
+
+// diagnoseFinding is a problem found by a diagnose rule.
+type diagnoseFinding struct {
+	Rule       string           `json:"rule"`
+	Severity   diagnoseSeverity `json:"severity"`
+	Object     string           `json:"object"`
+	Message    string           `json:"message"`
+	Suggestion string           `json:"suggestion,omitempty"`
+}
+
+// diagnoseReport is the structured output of the diagnose command.
+type diagnoseReport struct {
+	Cluster   string            `json:"cluster"`
+	Namespace string            `json:"namespace"`
+	Findings  []diagnoseFinding `json:"findings"`
+}
+
+// diagnoseInput is the data checked by the diagnose rules.
+type diagnoseInput struct {
+	*cluster.ClusterObjects
+	OpsRequests    []appsv1alpha1.OpsRequest
+	StorageClasses map[string]*storagev1.StorageClass
+	// VolumeUsages is the used percentage of the volumes, keyed by the PVC name
+	VolumeUsages map[string]int
+
+	Now                  time.Time
+	OpsTimeout           time.Duration
+	VolumeUsageThreshold int
+}
+
+// diagnoseRule is a pluggable check over the objects of a cluster, it returns the problems it found.
+type diagnoseRule struct {
+	name  string
+	check func(in *diagnoseInput) []diagnoseFinding
+}
+
+var defaultDiagnoseRules = []diagnoseRule{
+	{name: "crash-loop", check: checkCrashLoop},
+	{name: "pending-pvc", check: checkPendingPVC},
+	{name: "no-leader", check: checkNoLeader},
+	{name: "failed-backup", check: checkLastBackup},
+	{name: "stuck-ops", check: checkStuckOps},
+	{name: "volume-usage", check: checkVolumeUsage},
+	{name: "volume-expansion", check: checkVolumeExpansion},
+}
+
+type DiagnoseOptions struct {
+	Factory   cmdutil.Factory
+	Client    clientset.Interface
+	Dynamic   dynamic.Interface
+	Namespace string
+	Name      string
+
+	Format               printer.Format
+	OpsTimeout           time.Duration
+	VolumeUsageThreshold int
+	CheckVolumeUsage     bool
+
+	rules []diagnoseRule
+	genericiooptions.IOStreams
+}
+
+func NewDiagnoseCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &DiagnoseOptions{
+		Factory:   f,
+		IOStreams: streams,
+		rules:     defaultDiagnoseRules,
+	}
+	cmd := &cobra.Command{
+		Use:   "diagnose NAME",
+		Short: "Inspect a cluster and report the problems found with suggestions to fix them.",
+		Long: templates.LongDesc(`
+			Inspect a cluster and report the problems found with suggestions to fix them.
+			The command exits with a non-zero code if a critical or warning problem is found,
+			so it can be used as a periodical health check.`),
+		Example:           diagnoseExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	printer.AddOutputFlag(cmd, &o.Format)
+	cmd.Flags().DurationVar(&o.OpsTimeout, "ops-timeout", 30*time.Minute, "The duration after which a running OpsRequest is reported as stuck")
+	cmd.Flags().IntVar(&o.VolumeUsageThreshold, "volume-usage-threshold", 85, "The used percentage above which a volume is reported")
+	cmd.Flags().BoolVar(&o.CheckVolumeUsage, "check-volume-usage", true, "Check the volume usage by executing df in the instances")
+	return cmd
+}
+
+func (o *DiagnoseOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return fmt.Errorf("only one cluster name should be specified")
+	}
+	o.Name = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	o.Client, err = o.Factory.KubernetesClientSet()
+	return err
+}
+
+func (o *DiagnoseOptions) Validate() error {
+	if o.VolumeUsageThreshold <= 0 || o.VolumeUsageThreshold > 100 {
+		return fmt.Errorf("--volume-usage-threshold should be in the range of (0, 100]")
+	}
+	if o.OpsTimeout <= 0 {
+		return fmt.Errorf("--ops-timeout should be greater than 0")
+	}
+	return nil
+}
+
+func (o *DiagnoseOptions) Run() error {
+	in, err := o.buildInput()
+	if err != nil {
+		return err
+	}
+	report := &diagnoseReport{
+		Cluster:   o.Name,
+		Namespace: o.Namespace,
+		Findings:  o.diagnose(in),
+	}
+	if err = o.printReport(report); err != nil {
+		return err
+	}
+
+	problems := 0
+	for _, f := range report.Findings {
+		if f.Severity != severityInfo {
+			problems++
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("found %d problem(s) in cluster %s", problems, o.Name)
+	}
+	return nil
+}
+
+// diagnose runs the rules and returns the findings sorted by severity.
+func (o *DiagnoseOptions) diagnose(in *diagnoseInput) []diagnoseFinding {
+	findings := make([]diagnoseFinding, 0)
+	for _, rule := range o.rules {
+		for _, f := range rule.check(in) {
+			f.Rule = rule.name
+			findings = append(findings, f)
+		}
+	}
+	severityOrder := map[diagnoseSeverity]int{severityCritical: 0, severityWarning: 1, severityInfo: 2}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityOrder[findings[i].Severity] < severityOrder[findings[j].Severity]
+	})
+	return findings
+}
+
+// buildInput gathers the objects of the cluster checked by the rules.
+func (o *DiagnoseOptions) buildInput() (*diagnoseInput, error) {
+	getter := cluster.ObjectsGetter{
+		Client:    o.Client,
+		Dynamic:   o.Dynamic,
+		Name:      o.Name,
+		Namespace: o.Namespace,
+		GetOptions: cluster.GetOptions{
+			WithClusterDef:     true,
+			WithPod:            true,
+			WithPVC:            true,
+			WithDataProtection: true,
+		},
+	}
+	objs, err := getter.Get()
+	if err != nil {
+		return nil, err
+	}
+	in := &diagnoseInput{
+		ClusterObjects:       objs,
+		StorageClasses:       map[string]*storagev1.StorageClass{},
+		VolumeUsages:         map[string]int{},
+		Now:                  time.Now(),
+		OpsTimeout:           o.OpsTimeout,
+		VolumeUsageThreshold: o.VolumeUsageThreshold,
+	}
+
+	opsList, err := o.Dynamic.Resource(types.OpsGVR()).Namespace(o.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: util.BuildLabelSelectorByNames("", []string{o.Name}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range opsList.Items {
+		ops := appsv1alpha1.OpsRequest{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &ops); err != nil {
+			return nil, err
+		}
+		in.OpsRequests = append(in.OpsRequests, ops)
+	}
+
+	for _, pvc := range objs.PVCs.Items {
+		scName := pvc.Spec.StorageClassName
+		if scName == nil || *scName == "" {
+			continue
+		}
+		if _, ok := in.StorageClasses[*scName]; ok {
+			continue
+		}
+		sc, err := o.Client.StorageV1().StorageClasses().Get(context.TODO(), *scName, metav1.GetOptions{})
+		if err != nil {
+			// the missing storage class makes the PVC pending, which is reported by the pending-pvc rule
+			in.StorageClasses[*scName] = nil
+			continue
+		}
+		in.StorageClasses[*scName] = sc
+	}
+
+	if o.CheckVolumeUsage {
+		in.VolumeUsages = o.getVolumeUsages(objs)
+	}
+	return in, nil
+}
+
+// getVolumeUsages executes df in the instances to get the used percentage of the mounted PVCs,
+// the volumes that fail to be checked are ignored.
+func (o *DiagnoseOptions) getVolumeUsages(objs *cluster.ClusterObjects) map[string]int {
+	usages := map[string]int{}
+	for i := range objs.Pods.Items {
+		pod := &objs.Pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, vol := range pod.Spec.Volumes {
+			if vol.PersistentVolumeClaim == nil {
+				continue
+			}
+			container, mountPath := findVolumeMount(pod, vol.Name)
+			if container == "" {
+				continue
+			}
+			out := &bytes.Buffer{}
+			exec := action.NewExecOptions(o.Factory, genericiooptions.IOStreams{In: o.In, Out: out, ErrOut: io.Discard})
+			if err := exec.Complete(); err != nil {
+				return usages
+			}
+			exec.Pod = pod
+			exec.ContainerName = container
+			exec.Command = []string{"df", "-P", mountPath}
+			exec.Stdin = false
+			exec.TTY = false
+			exec.Quiet = true
+			if err := exec.Run(); err != nil {
+				continue
+			}
+			if usage, err := parseDFUsage(out.String()); err == nil {
+				usages[vol.PersistentVolumeClaim.ClaimName] = usage
+			}
+		}
+	}
+	return usages
+}
+
+// findVolumeMount returns the first container mounting the volume and the mount path.
+func findVolumeMount(pod *corev1.Pod, volume string) (string, string) {
+	for _, c := range pod.Spec.Containers {
+		for _, m := range c.VolumeMounts {
+			if m.Name == volume {
+				return c.Name, m.MountPath
+			}
+		}
+	}
+	return "", ""
+}
+
+// parseDFUsage parses the used percentage from the output of `df -P`.
+func parseDFUsage(output string) (int, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unexpected df output: %s", output)
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 5 {
+		return 0, fmt.Errorf("unexpected df output: %s", output)
+	}
+	return strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+}
+
+func (o *DiagnoseOptions) printReport(report *diagnoseReport) error {
+	switch o.Format {
+	case printer.JSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.Out, string(data))
+	case printer.YAML:
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(o.Out, string(data))
+	default:
+		if len(report.Findings) == 0 {
+			fmt.Fprintf(o.Out, "No problem found in cluster %s\n", report.Cluster)
+			return nil
+		}
+		tbl := printer.NewTablePrinter(o.Out)
+		tbl.SetHeader("SEVERITY", "RULE", "OBJECT", "MESSAGE", "SUGGESTION")
+		for _, f := range report.Findings {
+			tbl.AddRow(f.Severity, f.Rule, f.Object, f.Message, util.CheckEmpty(f.Suggestion))
+		}
+		tbl.Print()
+	}
+	return nil
+}
+
+func checkCrashLoop(in *diagnoseInput) []diagnoseFinding {
+	var findings []diagnoseFinding
+	for _, pod := range in.Pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting == nil || status.State.Waiting.Reason != "CrashLoopBackOff" {
+				continue
+			}
+			findings = append(findings, diagnoseFinding{
+				Severity:   severityCritical,
+				Object:     "Instance/" + pod.Name,
+				Message:    fmt.Sprintf("container %s is in CrashLoopBackOff, restarted %d times", status.Name, status.RestartCount),
+				Suggestion: fmt.Sprintf("kbcli cluster logs %s --instance %s -n %s", in.Cluster.Name, pod.Name, pod.Namespace),
+			})
+		}
+	}
+	return findings
+}
+
+func checkPendingPVC(in *diagnoseInput) []diagnoseFinding {
+	var findings []diagnoseFinding
+	for _, pvc := range in.PVCs.Items {
+		if pvc.Status.Phase != corev1.ClaimPending {
+			continue
+		}
+		message := "PVC is pending"
+		if scName := pvc.Spec.StorageClassName; scName != nil && *scName != "" {
+			if sc, ok := in.StorageClasses[*scName]; ok && sc == nil {
+				message = fmt.Sprintf("PVC is pending, its StorageClass %s is not found", *scName)
+			}
+		}
+		findings = append(findings, diagnoseFinding{
+			Severity:   severityCritical,
+			Object:     "PVC/" + pvc.Name,
+			Message:    message,
+			Suggestion: fmt.Sprintf("kbcli cluster list-events %s -n %s", in.Cluster.Name, pvc.Namespace),
+		})
+	}
+	return findings
+}
+
+// checkNoLeader checks the components whose workload type has a primary or leader role.
+func checkNoLeader(in *diagnoseInput) []diagnoseFinding {
+	if in.ClusterDef == nil {
+		return nil
+	}
+	var findings []diagnoseFinding
+	for _, comp := range in.Cluster.Spec.ComponentSpecs {
+		var leaderRoles []string
+		for _, compDef := range in.ClusterDef.Spec.ComponentDefs {
+			if compDef.Name != comp.ComponentDefRef {
+				continue
+			}
+			switch compDef.WorkloadType {
+			case appsv1alpha1.Replication:
+				leaderRoles = []string{constant.Primary}
+			case appsv1alpha1.Consensus:
+				leaderRoles = []string{constant.Leader}
+				if compDef.ConsensusSpec != nil && compDef.ConsensusSpec.Leader.Name != "" {
+					leaderRoles = append(leaderRoles, compDef.ConsensusSpec.Leader.Name)
+				}
+			}
+		}
+		if len(leaderRoles) == 0 {
+			continue
+		}
+		var pods []string
+		hasLeader := false
+		for _, pod := range in.Pods.Items {
+			if pod.Labels[constant.KBAppComponentLabelKey] != comp.Name {
+				continue
+			}
+			pods = append(pods, pod.Name)
+			for _, role := range leaderRoles {
+				if pod.Labels[constant.RoleLabelKey] == role {
+					hasLeader = true
+				}
+			}
+		}
+		if hasLeader || len(pods) == 0 {
+			continue
+		}
+		findings = append(findings, diagnoseFinding{
+			Severity: severityCritical,
+			Object:   "Component/" + comp.Name,
+			Message:  fmt.Sprintf("no instance is labelled as %s", strings.Join(leaderRoles, " or ")),
+			Suggestion: fmt.Sprintf("kbcli cluster promote %s --component %s --instance %s -n %s",
+				in.Cluster.Name, comp.Name, pods[0], in.Cluster.Namespace),
+		})
+	}
+	return findings
+}
+
+func checkLastBackup(in *diagnoseInput) []diagnoseFinding {
+	if len(in.Backups) == 0 {
+		return nil
+	}
+	last := in.Backups[0]
+	for _, backup := range in.Backups[1:] {
+		if last.CreationTimestamp.Before(&backup.CreationTimestamp) {
+			last = backup
+		}
+	}
+	if last.Status.Phase != dpv1alpha1.BackupPhaseFailed {
+		return nil
+	}
+	message := "the last backup is failed"
+	if last.Status.FailureReason != "" {
+		message += ": " + last.Status.FailureReason
+	}
+	return []diagnoseFinding{{
+		Severity:   severityWarning,
+		Object:     "Backup/" + last.Name,
+		Message:    message,
+		Suggestion: fmt.Sprintf("kbcli cluster describe-backup %s -n %s", last.Name, last.Namespace),
+	}}
+}
+
+func checkStuckOps(in *diagnoseInput) []diagnoseFinding {
+	var findings []diagnoseFinding
+	for _, ops := range in.OpsRequests {
+		if ops.Status.Phase != appsv1alpha1.OpsRunningPhase && ops.Status.Phase != appsv1alpha1.OpsCreatingPhase {
+			continue
+		}
+		start := ops.Status.StartTimestamp
+		if start.IsZero() {
+			start = ops.CreationTimestamp
+		}
+		duration := in.Now.Sub(start.Time)
+		if duration < in.OpsTimeout {
+			continue
+		}
+		findings = append(findings, diagnoseFinding{
+			Severity:   severityWarning,
+			Object:     "OpsRequest/" + ops.Name,
+			Message:    fmt.Sprintf("%s OpsRequest is %s for %s, progress %s", ops.Spec.Type, ops.Status.Phase, duration.Round(time.Second), util.CheckEmpty(ops.Status.Progress)),
+			Suggestion: fmt.Sprintf("kbcli cluster describe-ops %s -n %s", ops.Name, ops.Namespace),
+		})
+	}
+	return findings
+}
+
+func checkVolumeUsage(in *diagnoseInput) []diagnoseFinding {
+	var findings []diagnoseFinding
+	for _, pvc := range in.PVCs.Items {
+		usage, ok := in.VolumeUsages[pvc.Name]
+		if !ok || usage < in.VolumeUsageThreshold {
+			continue
+		}
+		suggestion := ""
+		capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+		if ok && pvc.Labels[constant.VolumeClaimTemplateNameLabelKey] != "" {
+			storage := resource.NewQuantity(capacity.Value()*2, resource.BinarySI)
+			suggestion = fmt.Sprintf("kbcli cluster volume-expand %s --components %s --volume-claim-templates %s --storage %s -n %s",
+				in.Cluster.Name, pvc.Labels[constant.KBAppComponentLabelKey], pvc.Labels[constant.VolumeClaimTemplateNameLabelKey], storage.String(), pvc.Namespace)
+		}
+		findings = append(findings, diagnoseFinding{
+			Severity:   severityWarning,
+			Object:     "PVC/" + pvc.Name,
+			Message:    fmt.Sprintf("volume usage is %d%%, above the threshold %d%%", usage, in.VolumeUsageThreshold),
+			Suggestion: suggestion,
+		})
+	}
+	return findings
+}
+
+func checkVolumeExpansion(in *diagnoseInput) []diagnoseFinding {
+	var names []string
+	for name, sc := range in.StorageClasses {
+		if sc == nil || (sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var findings []diagnoseFinding
+	for _, name := range names {
+		findings = append(findings, diagnoseFinding{
+			Severity:   severityInfo,
+			Object:     "StorageClass/" + name,
+			Message:    "StorageClass does not allow volume expansion, the volumes of the cluster can not be expanded",
+			Suggestion: fmt.Sprintf(`kubectl patch storageclass %s -p '{"allowVolumeExpansion": true}'`, name),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,187 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/testing"
+)
+
+var _ = Describe("cluster diagnose", func() {
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	newInput := func() *diagnoseInput {
+		return &diagnoseInput{
+			ClusterObjects: &cluster.ClusterObjects{
+				Cluster:    testing.FakeCluster(testing.ClusterName, testing.Namespace),
+				ClusterDef: testing.FakeClusterDef(),
+				Pods:       testing.FakePods(3, testing.Namespace, testing.ClusterName),
+				PVCs:       &corev1.PersistentVolumeClaimList{},
+			},
+			StorageClasses:       map[string]*storagev1.StorageClass{},
+			VolumeUsages:         map[string]int{},
+			Now:                  time.Now(),
+			OpsTimeout:           30 * time.Minute,
+			VolumeUsageThreshold: 85,
+		}
+	}
+
+	It("diagnose command", func() {
+		Expect(NewDiagnoseCmd(tf, streams)).ShouldNot(BeNil())
+	})
+
+	It("check the instances", func() {
+		in := newInput()
+		in.ClusterDef.Spec.ComponentDefs[0].WorkloadType = appsv1alpha1.Consensus
+		Expect(checkCrashLoop(in)).Should(BeEmpty())
+		Expect(checkNoLeader(in)).Should(BeEmpty())
+
+		in.Pods.Items[1].Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:         "mysql",
+			RestartCount: 5,
+			State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		}}
+		findings := checkCrashLoop(in)
+		Expect(findings).Should(HaveLen(1))
+		Expect(findings[0].Object).Should(Equal("Instance/" + in.Pods.Items[1].Name))
+		Expect(findings[0].Suggestion).Should(ContainSubstring("kbcli cluster logs"))
+
+		in.Pods.Items[0].Labels[constant.RoleLabelKey] = "follower"
+		findings = checkNoLeader(in)
+		Expect(findings).Should(HaveLen(1))
+		Expect(findings[0].Severity).Should(Equal(severityCritical))
+		Expect(findings[0].Suggestion).Should(ContainSubstring("kbcli cluster promote"))
+	})
+
+	It("check the volumes", func() {
+		in := newInput()
+		scName := "standard"
+		pvc := corev1.PersistentVolumeClaim{}
+		pvc.Name = "data-" + testing.ClusterName + "-mysql-0"
+		pvc.Namespace = testing.Namespace
+		pvc.Labels = map[string]string{
+			constant.KBAppComponentLabelKey:          testing.ComponentName,
+			constant.VolumeClaimTemplateNameLabelKey: "data",
+		}
+		pvc.Spec.StorageClassName = &scName
+		pvc.Status.Phase = corev1.ClaimPending
+		pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")}
+		in.PVCs.Items = append(in.PVCs.Items, pvc)
+		in.StorageClasses[scName] = nil
+
+		findings := checkPendingPVC(in)
+		Expect(findings).Should(HaveLen(1))
+		Expect(findings[0].Message).Should(ContainSubstring("is not found"))
+
+		in.VolumeUsages[pvc.Name] = 90
+		findings = checkVolumeUsage(in)
+		Expect(findings).Should(HaveLen(1))
+		Expect(findings[0].Suggestion).Should(ContainSubstring("--storage 20Gi"))
+
+		in.StorageClasses[scName] = &storagev1.StorageClass{}
+		findings = checkVolumeExpansion(in)
+		Expect(findings).Should(HaveLen(1))
+		Expect(findings[0].Severity).Should(Equal(severityInfo))
+
+		usage, err := parseDFUsage("Filesystem     1024-blocks    Used Available Capacity Mounted on\n/dev/vdb          10255636 8716748   1522504      86% /data\n")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(usage).Should(Equal(86))
+	})
+
+	It("check the backups and OpsRequests", func() {
+		in := newInput()
+		failed := testing.FakeBackup("backup-failed")
+		failed.Status.Phase = dpv1alpha1.BackupPhaseFailed
+		failed.CreationTimestamp = metav1.NewTime(in.Now.Add(-time.Hour))
+		completed := testing.FakeBackup("backup-completed")
+		completed.Status.Phase = dpv1alpha1.BackupPhaseCompleted
+		completed.CreationTimestamp = metav1.NewTime(in.Now.Add(-2 * time.Hour))
+		in.Backups = []dpv1alpha1.Backup{*completed, *failed}
+		Expect(checkLastBackup(in)).Should(HaveLen(1))
+		in.Backups = []dpv1alpha1.Backup{*failed, *completed}
+		in.Backups[1].CreationTimestamp = metav1.NewTime(in.Now)
+		Expect(checkLastBackup(in)).Should(BeEmpty())
+
+		ops := appsv1alpha1.OpsRequest{}
+		ops.Name = "test-restart"
+		ops.Spec.Type = appsv1alpha1.RestartType
+		ops.Status.Phase = appsv1alpha1.OpsRunningPhase
+		ops.Status.StartTimestamp = metav1.NewTime(in.Now.Add(-10 * time.Minute))
+		in.OpsRequests = []appsv1alpha1.OpsRequest{ops}
+		Expect(checkStuckOps(in)).Should(BeEmpty())
+		in.OpsRequests[0].Status.StartTimestamp = metav1.NewTime(in.Now.Add(-time.Hour))
+		Expect(checkStuckOps(in)).Should(HaveLen(1))
+	})
+
+	It("run and report the findings", func() {
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace), testing.FakeClusterDef())
+		o := &DiagnoseOptions{Factory: tf, IOStreams: streams, rules: defaultDiagnoseRules, Format: printer.JSON,
+			OpsTimeout: time.Hour, VolumeUsageThreshold: 85}
+		Expect(o.Complete([]string{testing.ClusterName})).Should(Succeed())
+		Expect(o.Validate()).Should(Succeed())
+		o.Client = testing.FakeClientSet()
+		Expect(o.Run()).Should(Succeed())
+		report := &diagnoseReport{}
+		Expect(json.Unmarshal(out.Bytes(), report)).Should(Succeed())
+		Expect(report.Findings).Should(BeEmpty())
+
+		By("return an error if a problem is found")
+		o.rules = append(o.rules, diagnoseRule{name: "fake", check: func(in *diagnoseInput) []diagnoseFinding {
+			return []diagnoseFinding{{Severity: severityWarning, Object: "Cluster/" + in.Cluster.Name, Message: "fake problem"}}
+		}})
+		out.Reset()
+		o.Format = printer.Table
+		Expect(o.Run()).Should(MatchError(ContainSubstring("found 1 problem(s)")))
+		Expect(out.String()).Should(ContainSubstring("fake problem"))
+	})
+})
@@ -0,0 +1,342 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+const (
+	clusterBundleAPIVersion = "kbcli.kubeblocks.io/v1alpha1"
+	clusterBundleKind       = "ClusterBundle"
+)
+
+// strippedClusterAnnotations are the annotations set by the client or the KubeBlocks controllers for the
+// running cluster, they are not exported since they refer to the restores or operations of the source cluster.
+var strippedClusterAnnotations = []string{
+	corev1.LastAppliedConfigAnnotation,
+	constant.RestoreFromBackupAnnotationKey,
+	constant.OpsRequestAnnotationKey,
+	constant.ClusterSnapshotAnnotationKey,
+	constant.SnapShotForStartAnnotationKey,
+	constant.ReconcileAnnotationKey,
+}
+
+var exportExample = templates.Examples(`
+	# export the cluster mycluster to a bundle file
+	kbcli cluster export mycluster --file mycluster-bundle.yaml
+
+	# export the cluster with the passwords of the accounts
+	kbcli cluster export mycluster --include-secrets --file mycluster-bundle.yaml
+
+	# print the bundle in JSON format
+	kbcli cluster export mycluster -o json`)
+
+// clusterBundle is a self-contained description of a cluster which can be used
+// to recreate the cluster in another namespace or Kubernetes cluster.
+type clusterBundle struct {
+	APIVersion        string `json:"apiVersion"`
+	Kind              string `json:"kind"`
+	ClusterDefinition string `json:"clusterDefinition,omitempty"`
+	ClusterVersion    string `json:"clusterVersion,omitempty"`
+
+	// Cluster is the cluster object without the status and the fields managed by the server
+	Cluster         map[string]interface{} `json:"cluster"`
+	Configs         []bundleConfig         `json:"configs,omitempty"`
+	BackupPolicies  []bundleBackupPolicy   `json:"backupPolicies,omitempty"`
+	BackupSchedules []bundleBackupSchedule `json:"backupSchedules,omitempty"`
+	Accounts        []bundleAccount        `json:"accounts,omitempty"`
+}
+
+// bundleConfig is the content of the config files rendered from a config spec with config constraint
+type bundleConfig struct {
+	Component        string            `json:"component"`
+	ConfigSpec       string            `json:"configSpec"`
+	ConfigConstraint string            `json:"configConstraint"`
+	Data             map[string]string `json:"data"`
+}
+
+// bundleBackupPolicy is the settings of a backup policy, the name is the suffix after the cluster name
+type bundleBackupPolicy struct {
+	Name           string  `json:"name"`
+	BackupRepoName *string `json:"backupRepoName,omitempty"`
+	BackoffLimit   *int32  `json:"backoffLimit,omitempty"`
+}
+
+// bundleBackupSchedule is the settings of a backup schedule, the name is the suffix after the cluster name
+type bundleBackupSchedule struct {
+	Name                    string                      `json:"name"`
+	StartingDeadlineMinutes *int64                      `json:"startingDeadlineMinutes,omitempty"`
+	Schedules               []dpv1alpha1.SchedulePolicy `json:"schedules"`
+}
+
+// bundleAccount is a system account of the component, the password is only exported with --include-secrets
+type bundleAccount struct {
+	Component string `json:"component"`
+	Name      string `json:"name"`
+	Password  string `json:"password,omitempty"`
+}
+
+type ExportOptions struct {
+	Factory   cmdutil.Factory
+	Namespace string
+	Name      string
+	Dynamic   dynamic.Interface
+	Client    kubernetes.Interface
+
+	File           string
+	Format         printer.Format
+	IncludeSecrets bool
+
+	genericiooptions.IOStreams
+}
+
+func NewExportCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ExportOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:               "export NAME",
+		Short:             "Export a cluster to a portable bundle.",
+		Example:           exportExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVar(&o.File, "file", "", "The file to write the bundle to, print the bundle to stdout if not specified")
+	cmd.Flags().BoolVar(&o.IncludeSecrets, "include-secrets", false, "Export the passwords of the accounts, the bundle should be stored safely")
+	printer.AddOutputFlagForCreate(cmd, &o.Format, false)
+	return cmd
+}
+
+func (o *ExportOptions) Complete(args []string) error {
+	var err error
+	if len(args) == 0 {
+		return makeMissingClusterNameErr()
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("only support to export one cluster")
+	}
+	o.Name = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	o.Client, err = o.Factory.KubernetesClientSet()
+	return err
+}
+
+func (o *ExportOptions) Run() error {
+	bundle, err := o.buildBundle()
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch o.Format {
+	case printer.JSON:
+		data, err = json.MarshalIndent(bundle, "", "  ")
+	case printer.YAML:
+		data, err = yaml.Marshal(bundle)
+	default:
+		return fmt.Errorf("unsupported output format %s, only support JSON and YAML", o.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if o.File == "" {
+		_, err = fmt.Fprintln(o.Out, string(data))
+		return err
+	}
+	if err = os.WriteFile(o.File, data, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Cluster %s is exported to %s\n", o.Name, o.File)
+	return nil
+}
+
+// buildBundle builds the bundle from the objects of the cluster.
+func (o *ExportOptions) buildBundle() (*clusterBundle, error) {
+	getter := cluster.ObjectsGetter{
+		Client:    o.Client,
+		Dynamic:   o.Dynamic,
+		Name:      o.Name,
+		Namespace: o.Namespace,
+		GetOptions: cluster.GetOptions{
+			WithConfigMap:      true,
+			WithSecret:         true,
+			WithDataProtection: true,
+		},
+	}
+	objs, err := getter.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	clusterObj, err := stripCluster(objs.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &clusterBundle{
+		APIVersion:        clusterBundleAPIVersion,
+		Kind:              clusterBundleKind,
+		ClusterDefinition: objs.Cluster.Spec.ClusterDefRef,
+		ClusterVersion:    objs.Cluster.Spec.ClusterVersionRef,
+		Cluster:           clusterObj,
+		Configs:           buildBundleConfigs(objs.ConfigMaps),
+		Accounts:          buildBundleAccounts(objs.Secrets, o.IncludeSecrets),
+	}
+	for _, policy := range objs.BackupPolicies {
+		bundle.BackupPolicies = append(bundle.BackupPolicies, bundleBackupPolicy{
+			Name:           trimClusterPrefix(policy.Name, o.Name),
+			BackupRepoName: policy.Spec.BackupRepoName,
+			BackoffLimit:   policy.Spec.BackoffLimit,
+		})
+	}
+	for _, schedule := range objs.BackupSchedules {
+		bundle.BackupSchedules = append(bundle.BackupSchedules, bundleBackupSchedule{
+			Name:                    trimClusterPrefix(schedule.Name, o.Name),
+			StartingDeadlineMinutes: schedule.Spec.StartingDeadlineMinutes,
+			Schedules:               schedule.Spec.Schedules,
+		})
+	}
+	return bundle, nil
+}
+
+// stripCluster converts the cluster to a map and removes the status, the fields managed
+// by the server and the annotations managed by the controllers, the namespace is removed
+// too so that the cluster can be imported to any namespace.
+func stripCluster(cls interface{}) (map[string]interface{}, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cls)
+	if err != nil {
+		return nil, err
+	}
+	delete(obj, "status")
+	obj["apiVersion"] = types.ClusterGVR().GroupVersion().String()
+	obj["kind"] = types.KindCluster
+
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	stripped := map[string]interface{}{}
+	for _, key := range []string{"name", "labels", "annotations"} {
+		if v, ok := metadata[key]; ok {
+			stripped[key] = v
+		}
+	}
+	if annotations, ok := stripped["annotations"].(map[string]interface{}); ok {
+		for _, key := range strippedClusterAnnotations {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			delete(stripped, "annotations")
+		}
+	}
+	obj["metadata"] = stripped
+	return obj, nil
+}
+
+// buildBundleConfigs collects the config files rendered from the config specs with
+// config constraint, these files may be customized by the users.
+func buildBundleConfigs(cms *corev1.ConfigMapList) []bundleConfig {
+	if cms == nil {
+		return nil
+	}
+	var configs []bundleConfig
+	for _, cm := range cms.Items {
+		labels := cm.GetLabels()
+		if labels[constant.CMConfigurationConstraintsNameLabelKey] == "" || labels[constant.CMConfigurationSpecProviderLabelKey] == "" {
+			continue
+		}
+		configs = append(configs, bundleConfig{
+			Component:        labels[constant.KBAppComponentLabelKey],
+			ConfigSpec:       labels[constant.CMConfigurationSpecProviderLabelKey],
+			ConfigConstraint: labels[constant.CMConfigurationConstraintsNameLabelKey],
+			Data:             cm.Data,
+		})
+	}
+	sort.Slice(configs, func(i, j int) bool {
+		if configs[i].Component != configs[j].Component {
+			return configs[i].Component < configs[j].Component
+		}
+		return configs[i].ConfigSpec < configs[j].ConfigSpec
+	})
+	return configs
+}
+
+// buildBundleAccounts collects the accounts of the cluster from the account secrets.
+func buildBundleAccounts(secrets *corev1.SecretList, includeSecrets bool) []bundleAccount {
+	if secrets == nil {
+		return nil
+	}
+	var accounts []bundleAccount
+	for _, secret := range secrets.Items {
+		labels := secret.GetLabels()
+		if labels[constant.ClusterAccountLabelKey] == "" {
+			continue
+		}
+		account := bundleAccount{
+			Component: labels[constant.KBAppComponentLabelKey],
+			Name:      string(secret.Data[constant.AccountNameForSecret]),
+		}
+		if account.Name == "" {
+			account.Name = labels[constant.ClusterAccountLabelKey]
+		}
+		if includeSecrets {
+			account.Password = string(secret.Data[constant.AccountPasswdForSecret])
+		}
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Component != accounts[j].Component {
+			return accounts[i].Component < accounts[j].Component
+		}
+		return accounts[i].Name < accounts[j].Name
+	})
+	return accounts
+}
+
+func trimClusterPrefix(name, clusterName string) string {
+	return strings.TrimPrefix(name, clusterName+"-")
+}
@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/testing"
+)
+
+var _ = Describe("cluster export", func() {
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+	})
+
+	It("export command", func() {
+		tf := cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		defer tf.Cleanup()
+		Expect(NewExportCmd(tf, streams)).ShouldNot(BeNil())
+	})
+
+	It("export the cluster to a bundle", func() {
+		clusterLabels := map[string]string{
+			constant.AppInstanceLabelKey:    testing.ClusterName,
+			constant.AppManagedByLabelKey:   constant.AppName,
+			constant.KBAppComponentLabelKey: testing.ComponentName,
+		}
+		cm := testing.FakeConfigMap("test-cluster-mysql-mysql-config", testing.Namespace, map[string]string{"my.cnf": "[mysqld]\nmax_connections=2000\n"})
+		cm.Labels = map[string]string{
+			constant.CMConfigurationSpecProviderLabelKey:    "mysql-config",
+			constant.CMConfigurationConstraintsNameLabelKey: "mysql-config-constraints",
+		}
+		for k, v := range clusterLabels {
+			cm.Labels[k] = v
+		}
+		secret := &corev1.Secret{}
+		secret.Name = constant.GenerateAccountSecretName(testing.ClusterName, testing.ComponentName, "kbadmin")
+		secret.Namespace = testing.Namespace
+		secret.Labels = map[string]string{constant.ClusterAccountLabelKey: "kbadmin"}
+		for k, v := range clusterLabels {
+			secret.Labels[k] = v
+		}
+		secret.Data = map[string][]byte{
+			constant.AccountNameForSecret:   []byte("kbadmin"),
+			constant.AccountPasswdForSecret: []byte("kbadmin-password"),
+		}
+
+		newExportOptions := func(includeSecrets bool) *ExportOptions {
+			return &ExportOptions{
+				Namespace: testing.Namespace,
+				Name:      testing.ClusterName,
+				Dynamic: testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace),
+					testing.FakeBackupPolicy(testing.ClusterName+"-mysql-backup-policy", testing.ClusterName),
+					testing.FakeBackupSchedule(testing.ClusterName+"-mysql-backup-schedule", testing.ClusterName+"-mysql-backup-policy")),
+				Client:         testing.FakeClientSet(cm, secret),
+				Format:         printer.YAML,
+				IncludeSecrets: includeSecrets,
+				IOStreams:      streams,
+			}
+		}
+
+		Expect(newExportOptions(false).Run()).Should(Succeed())
+		bundle, err := parseClusterBundle(out.Bytes())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(bundle.ClusterDefinition).Should(Equal(testing.ClusterDefName))
+		Expect(bundle.ClusterVersion).Should(Equal(testing.ClusterVersionName))
+		Expect(bundle.Cluster).ShouldNot(HaveKey("status"))
+		Expect(bundle.Cluster["metadata"]).Should(HaveKeyWithValue("name", testing.ClusterName))
+		Expect(bundle.Cluster["metadata"]).ShouldNot(HaveKey("namespace"))
+		Expect(bundle.Configs).Should(HaveLen(1))
+		Expect(bundle.Configs[0].ConfigSpec).Should(Equal("mysql-config"))
+		Expect(bundle.Configs[0].Data).Should(HaveKey("my.cnf"))
+		Expect(bundle.BackupPolicies).Should(HaveLen(1))
+		Expect(bundle.BackupPolicies[0].Name).Should(Equal("mysql-backup-policy"))
+		Expect(bundle.BackupSchedules).Should(HaveLen(1))
+		Expect(bundle.BackupSchedules[0].Name).Should(Equal("mysql-backup-schedule"))
+		Expect(bundle.BackupSchedules[0].Schedules).Should(HaveLen(1))
+		Expect(bundle.Accounts).Should(Equal([]bundleAccount{{Component: testing.ComponentName, Name: "kbadmin"}}))
+
+		By("export the passwords of the accounts")
+		out.Reset()
+		Expect(newExportOptions(true).Run()).Should(Succeed())
+		bundle, err = parseClusterBundle(out.Bytes())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(bundle.Accounts[0].Password).Should(Equal("kbadmin-password"))
+	})
+
+	It("export the restored cluster with an operation in progress", func() {
+		cls := testing.FakeCluster(testing.ClusterName, testing.Namespace)
+		cls.Annotations = map[string]string{
+			constant.RestoreFromBackupAnnotationKey: `{"mysql":{"name":"backup-1","namespace":"default"}}`,
+			constant.OpsRequestAnnotationKey:        `[{"name":"restart-1","type":"Restart"}]`,
+			corev1.LastAppliedConfigAnnotation:      "{}",
+			"example.com/owner":                     "team-a",
+		}
+		o := &ExportOptions{
+			Namespace: testing.Namespace,
+			Name:      testing.ClusterName,
+			Dynamic:   testing.FakeDynamicClient(cls),
+			Client:    testing.FakeClientSet(),
+			Format:    printer.YAML,
+			IOStreams: streams,
+		}
+		Expect(o.Run()).Should(Succeed())
+		bundle, err := parseClusterBundle(out.Bytes())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(bundle.Cluster["metadata"]).Should(HaveKeyWithValue("annotations", map[string]interface{}{"example.com/owner": "team-a"}))
+	})
+})
@@ -0,0 +1,249 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+	"github.com/apecloud/kbcli/pkg/util/prompt"
+)
+
+const defaultFleetMaxConcurrency = 5
+
+// fleetOptions are the options to run an operation on all the clusters matching a label selector.
+type fleetOptions struct {
+	LabelSelector string
+	AllNamespaces bool
+	// MaxConcurrency is the max number of clusters whose OpsRequests are created at the same time
+	MaxConcurrency int
+	// MaxUnavailable is the size of the batches rolled out one by one, the next batch is started
+	// after all the OpsRequests of the previous batch succeed
+	MaxUnavailable int
+}
+
+// fleetResult is the result of the OpsRequest created for a cluster.
+type fleetResult struct {
+	namespace  string
+	cluster    string
+	opsRequest string
+	phase      string
+	message    string
+}
+
+func (r *fleetResult) failed() bool {
+	return r.message != "" || r.phase == string(appsv1alpha1.OpsFailedPhase) || r.phase == string(appsv1alpha1.OpsCancelledPhase)
+}
+
+func (o *fleetOptions) addFleetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.LabelSelector, "selector", "l", "", "Selector (label query) to filter on, the operation is applied to every matching cluster instead of the cluster specified by NAME")
+	cmd.Flags().BoolVarP(&o.AllNamespaces, "all-namespaces", "A", false, "If present, the operation is applied to the matching clusters across all namespaces")
+	cmd.Flags().IntVar(&o.MaxConcurrency, "max-concurrency", defaultFleetMaxConcurrency, "The max number of clusters operated at the same time, only valid with --selector or --all-namespaces")
+	cmd.Flags().IntVar(&o.MaxUnavailable, "max-unavailable", 0, "Roll out the operation in batches of this size, waiting for each batch to succeed before starting the next, only valid with --selector or --all-namespaces")
+}
+
+func (o *fleetOptions) isFleet() bool {
+	return o.LabelSelector != "" || o.AllNamespaces
+}
+
+// runOnClusters runs the operation on the cluster specified by the args, or on every cluster matching
+// the label selector. The completeFns complete the options of a cluster before they are validated.
+func (o *OperationsOptions) runOnClusters(completeFns ...func(o *OperationsOptions) error) error {
+	prepare := func(ops *OperationsOptions) error {
+		for _, fn := range completeFns {
+			if err := fn(ops); err != nil {
+				return err
+			}
+		}
+		return ops.Validate()
+	}
+	if !o.isFleet() {
+		if err := prepare(o); err != nil {
+			return err
+		}
+		return o.Run()
+	}
+	return o.runFleet(func(namespace, name string) (*OperationsOptions, error) {
+		ops := o.cloneForCluster(namespace, name)
+		return ops, prepare(ops)
+	})
+}
+
+// cloneForCluster clones the options to create the OpsRequest for the specified cluster,
+// the confirmation and the output are skipped since they are handled by runFleet.
+func (o *OperationsOptions) cloneForCluster(namespace, name string) *OperationsOptions {
+	ops := *o
+	ops.CreateOptions.Options = &ops
+	ops.Namespace = namespace
+	ops.Name = name
+	ops.Args = []string{name}
+	ops.ComponentNames = slices.Clone(o.ComponentNames)
+	ops.KeyValues = maps.Clone(o.KeyValues)
+	ops.Quiet = true
+	ops.Wait = false
+	ops.autoApprove = true
+	return &ops
+}
+
+// runFleet creates one OpsRequest for every cluster matching the label selector with bounded concurrency,
+// and prints the summary of the OpsRequests. The newOps returns the validated options of a cluster.
+func (o *OperationsOptions) runFleet(newOps func(namespace, name string) (*OperationsOptions, error)) error {
+	if len(o.Args) > 0 {
+		return fmt.Errorf("cluster name can not be specified with --selector or --all-namespaces")
+	}
+	if o.OpsRequestName != "" {
+		return fmt.Errorf("--name can not be specified with --selector or --all-namespaces")
+	}
+	if o.isScheduled() {
+		return fmt.Errorf("--schedule and --at can not be specified with --selector or --all-namespaces")
+	}
+	if o.MaxConcurrency <= 0 {
+		return fmt.Errorf("--max-concurrency should be greater than 0")
+	}
+	if o.MaxUnavailable < 0 {
+		return fmt.Errorf("--max-unavailable should not be less than 0")
+	}
+	dryRun, err := o.GetDryRunStrategy()
+	if err != nil {
+		return err
+	}
+
+	namespace := o.Namespace
+	if o.AllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	clusterList, err := o.Dynamic.Resource(types.ClusterGVR()).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: o.LabelSelector,
+	})
+	if err != nil {
+		return err
+	}
+	if len(clusterList.Items) == 0 {
+		fmt.Fprintln(o.Out, "No cluster found")
+		return nil
+	}
+
+	results := make([]*fleetResult, len(clusterList.Items))
+	var names []string
+	for i, item := range clusterList.Items {
+		results[i] = &fleetResult{namespace: item.GetNamespace(), cluster: item.GetName(), phase: printer.NoneString}
+		names = append(names, item.GetNamespace()+"/"+item.GetName())
+	}
+	if !o.autoApprove && dryRun == action.DryRunNone {
+		msg := fmt.Sprintf("%s OpsRequests will be created for %d clusters:\n  %s", o.OpsType, len(names), strings.Join(names, "\n  "))
+		if err = prompt.Confirm(nil, o.In, msg, `Please type "yes" to confirm:`); err != nil {
+			return err
+		}
+	}
+
+	// the OpsRequests are waited to be completed in the rollout to make sure the batch succeeds
+	wait := (o.Wait || o.MaxUnavailable > 0) && dryRun == action.DryRunNone
+	batchSize := len(results)
+	if o.MaxUnavailable > 0 {
+		batchSize = o.MaxUnavailable
+	}
+	for start := 0; start < len(results); start += batchSize {
+		batch := results[start:min(start+batchSize, len(results))]
+		o.runFleetBatch(batch, newOps, wait)
+		if o.MaxUnavailable == 0 || !slices.ContainsFunc(batch, (*fleetResult).failed) {
+			continue
+		}
+		for _, r := range results[start+len(batch):] {
+			r.message = "skipped since the previous batch failed"
+		}
+		break
+	}
+
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("NAMESPACE", "CLUSTER", "OPS-REQUEST", "PHASE", "MESSAGE")
+	failed := 0
+	for _, r := range results {
+		if r.failed() {
+			failed++
+		}
+		tbl.AddRow(r.namespace, r.cluster, util.CheckEmpty(r.opsRequest), r.phase, r.message)
+	}
+	tbl.Print()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d %s OpsRequests failed", failed, len(results), o.OpsType)
+	}
+	return nil
+}
+
+// runFleetBatch creates the OpsRequests for the clusters in the batch with at most MaxConcurrency
+// at the same time, and waits for them to be completed if wait is true.
+func (o *OperationsOptions) runFleetBatch(batch []*fleetResult, newOps func(namespace, name string) (*OperationsOptions, error), wait bool) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, o.MaxConcurrency)
+	for i := range batch {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *fleetResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			o.runFleetOps(r, newOps, wait)
+		}(batch[i])
+	}
+	wg.Wait()
+}
+
+func (o *OperationsOptions) runFleetOps(r *fleetResult, newOps func(namespace, name string) (*OperationsOptions, error), wait bool) {
+	ops, err := newOps(r.namespace, r.cluster)
+	if err != nil {
+		r.message = err.Error()
+		return
+	}
+	if err = ops.CreateOptions.Run(); err != nil {
+		r.message = err.Error()
+		return
+	}
+	if dryRun, _ := ops.GetDryRunStrategy(); dryRun != action.DryRunNone {
+		return
+	}
+	// the name of CreateOptions is set to the name of OpsRequest after it is created
+	r.opsRequest = ops.CreateOptions.Name
+	if wait {
+		if err = newOpsRequestWaiter(o.Dynamic, o.Client, r.namespace, r.opsRequest, o.Timeout, io.Discard).wait(); err != nil {
+			r.message = err.Error()
+		}
+	}
+	opsRequest := &appsv1alpha1.OpsRequest{}
+	if err = util.GetK8SClientObject(o.Dynamic, opsRequest, types.OpsGVR(), r.namespace, r.opsRequest); err != nil {
+		r.message = err.Error()
+		return
+	}
+	r.phase = util.CheckEmpty(string(opsRequest.Status.Phase))
+}
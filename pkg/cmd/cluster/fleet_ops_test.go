@@ -0,0 +1,121 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("fleet operations", func() {
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+
+		var objs []runtime.Object
+		for _, name := range []string{"test-fleet-0", "test-fleet-1", "test-other"} {
+			cls := testing.FakeCluster(name, testing.Namespace)
+			cls.Labels = map[string]string{"env": "test"}
+			if name == "test-other" {
+				cls.Labels["env"] = "prod"
+			}
+			objs = append(objs, cls)
+		}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(objs...)
+		// the fake client does not generate the names of the OpsRequests
+		tf.FakeDynamicClient.PrependReactor("create", "opsrequests", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+			if obj.GetName() == "" {
+				obj.SetName(obj.GetGenerateName() + rand.String(5))
+			}
+			return false, nil, nil
+		})
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	newRestartOptions := func() *OperationsOptions {
+		o := newBaseOperationsOptions(tf, streams, appsv1alpha1.RestartType, true)
+		Expect(o.Complete()).Should(Succeed())
+		o.Client = testing.FakeClientSet()
+		o.DryRun = "none"
+		o.LabelSelector = "env=test"
+		o.MaxConcurrency = defaultFleetMaxConcurrency
+		o.Timeout = 100 * time.Millisecond
+		o.autoApprove = true
+		return o
+	}
+
+	listOps := func() []unstructured.Unstructured {
+		opsList, err := tf.FakeDynamicClient.Resource(types.OpsGVR()).Namespace(testing.Namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		return opsList.Items
+	}
+
+	It("restart the clusters matching the selector", func() {
+		o := newRestartOptions()
+		Expect(o.runOnClusters((*OperationsOptions).CompleteRestartOps)).Should(Succeed())
+		Expect(listOps()).Should(HaveLen(2))
+		Expect(out.String()).Should(ContainSubstring("test-fleet-0"))
+		Expect(out.String()).Should(ContainSubstring("test-fleet-1"))
+		Expect(out.String()).ShouldNot(ContainSubstring("test-other"))
+
+		By("cluster name and selector are exclusive")
+		o = newRestartOptions()
+		o.Args = []string{"test-fleet-0"}
+		Expect(o.runOnClusters()).Should(MatchError(ContainSubstring("can not be specified")))
+	})
+
+	It("roll out in batches", func() {
+		o := newRestartOptions()
+		o.MaxUnavailable = 1
+		// the OpsRequests are never completed by the fake client, so the first batch times out
+		Expect(o.runOnClusters((*OperationsOptions).CompleteRestartOps)).Should(MatchError(ContainSubstring("2 of 2 Restart OpsRequests failed")))
+		Expect(listOps()).Should(HaveLen(1))
+		Expect(out.String()).Should(ContainSubstring("timed out"))
+		Expect(out.String()).Should(ContainSubstring("skipped since the previous batch failed"))
+	})
+})
@@ -0,0 +1,322 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+// parameterImpact describes how an updated parameter is applied.
+type parameterImpact struct {
+	component      string
+	parameter      string
+	value          string
+	classification util.ParameterClassification
+}
+
+// restartImpact describes the planned restart sequence of a component, the instances
+// of a batch are restarted at the same time, and the batches are restarted one by one.
+type restartImpact struct {
+	component    string
+	workloadType appsv1alpha1.WorkloadType
+	strategy     appsv1alpha1.UpdateStrategy
+	batches      [][]*cluster.InstanceInfo
+}
+
+// explainRestartImpact prints the restart sequence of the components instead of restarting them.
+func (o *OperationsOptions) explainRestartImpact() error {
+	if o.isFleet() {
+		return fmt.Errorf("--explain-impact can not be specified with --selector or --all-namespaces")
+	}
+	if err := o.CompleteRestartOps(); err != nil {
+		return err
+	}
+	impacts, err := o.buildRestartImpacts()
+	if err != nil {
+		return err
+	}
+	o.printRestartImpacts(impacts)
+	return nil
+}
+
+// explainImpact prints how the updated parameters are applied and the restart sequence
+// of the components if a restart is required, instead of reconfiguring the cluster.
+func (o *configOpsOptions) explainImpact() error {
+	if o.isFleet() {
+		return fmt.Errorf("--explain-impact can not be specified with --selector or --all-namespaces")
+	}
+	if o.LocalFilePath != "" {
+		return fmt.Errorf("--explain-impact only supports the parameters specified by --set or --from-native-file")
+	}
+	if err := o.Complete(); err != nil {
+		return err
+	}
+	if err := o.fillRequiredParams(); err != nil {
+		return err
+	}
+	if o.FromNativeFile != "" {
+		if err := o.diffNativeConfigFile(); err != nil {
+			return err
+		}
+	}
+	tpl := o.wrapper.ConfigTemplateSpec()
+	cc := appsv1alpha1.ConfigConstraint{}
+	if err := util.GetResourceObjectFromGVR(types.ConfigConstraintGVR(), client.ObjectKey{Name: tpl.ConfigConstraintRef}, o.Dynamic, &cc); err != nil {
+		return err
+	}
+
+	var params []parameterImpact
+	needRestart := o.ForceRestart
+	for _, component := range o.ComponentNames {
+		for key, value := range o.KeyValues {
+			impact := parameterImpact{component: component, parameter: key, value: "<unset>", classification: util.ClassifyParameter(&cc.Spec, key)}
+			if value != nil {
+				impact.value = *value
+			}
+			if impact.classification == util.StaticParameter {
+				needRestart = true
+			}
+			params = append(params, impact)
+		}
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		if params[i].component != params[j].component {
+			return params[i].component < params[j].component
+		}
+		return params[i].parameter < params[j].parameter
+	})
+
+	fmt.Fprintf(o.Out, "Config spec %s, config file %s:\n", o.CfgTemplateName, o.CfgFile)
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("COMPONENT", "PARAMETER", "VALUE", "CLASSIFICATION")
+	immutableParams := sets.New[string]()
+	for _, p := range params {
+		tbl.AddRow(p.component, p.parameter, p.value, p.classification)
+		if p.classification == util.ImmutableParameter {
+			immutableParams.Insert(p.parameter)
+		}
+	}
+	tbl.Print()
+	fmt.Fprintln(o.Out)
+	if immutableParams.Len() > 0 {
+		printer.Warning(o.Out, "the immutable parameters %s can not be updated, the reconfiguring will be rejected\n\n", strings.Join(sets.List(immutableParams), ","))
+	}
+
+	if !needRestart {
+		fmt.Fprintln(o.Out, "All the parameters are applied dynamically, no instance will be restarted.")
+		return nil
+	}
+	impacts, err := o.buildRestartImpacts()
+	if err != nil {
+		return err
+	}
+	o.printRestartImpacts(impacts)
+	return nil
+}
+
+// buildRestartImpacts plans the restart sequence of the components by their workload types,
+// update strategies and the roles of the instances.
+func (o *OperationsOptions) buildRestartImpacts() ([]restartImpact, error) {
+	clusterObj, err := cluster.GetClusterByName(o.Dynamic, o.Name, o.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	var clusterDef *appsv1alpha1.ClusterDefinition
+	if clusterObj.Spec.ClusterDefRef != "" {
+		if clusterDef, err = cluster.GetClusterDefByName(o.Dynamic, clusterObj.Spec.ClusterDefRef); err != nil {
+			return nil, err
+		}
+	}
+
+	var impacts []restartImpact
+	for _, compName := range o.ComponentNames {
+		compSpec := clusterObj.Spec.GetComponentByName(compName)
+		if compSpec == nil {
+			return nil, fmt.Errorf("component %s not found in cluster %s", compName, o.Name)
+		}
+		var compDef *appsv1alpha1.ClusterComponentDefinition
+		if clusterDef != nil {
+			compDef = clusterDef.GetComponentDefByName(compSpec.ComponentDefRef)
+		}
+		instances, err := o.getComponentInstances(compName)
+		if err != nil {
+			return nil, err
+		}
+		impacts = append(impacts, planRestartSequence(compName, compDef, instances))
+	}
+	return impacts, nil
+}
+
+// getComponentInstances gets the instances of the component and their roles from the pods.
+func (o *OperationsOptions) getComponentInstances(compName string) ([]*cluster.InstanceInfo, error) {
+	selector := util.BuildComponentNameLabels(util.BuildLabelSelectorByNames("", []string{o.Name}), []string{compName})
+	pods, err := o.Client.CoreV1().Pods(o.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]*cluster.InstanceInfo, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		instances = append(instances, &cluster.InstanceInfo{Name: pod.Name, Role: pod.Labels[constant.RoleLabelKey]})
+	}
+	return instances, nil
+}
+
+// planRestartSequence plans the restart sequence of the instances. The learners are restarted
+// first and the leader or primary is restarted last, the other instances are restarted in the
+// descending order of their ordinals like the StatefulSet does.
+func planRestartSequence(compName string, compDef *appsv1alpha1.ClusterComponentDefinition, instances []*cluster.InstanceInfo) restartImpact {
+	impact := restartImpact{component: compName, workloadType: appsv1alpha1.Stateful, strategy: appsv1alpha1.SerialStrategy}
+	leaderRoles := map[string]bool{constant.Leader: true, constant.Primary: true}
+	learnerRoles := map[string]bool{constant.Learner: true}
+	if compDef != nil && compDef.WorkloadType != "" {
+		impact.workloadType = compDef.WorkloadType
+	}
+	if compDef != nil {
+		switch compDef.WorkloadType {
+		case appsv1alpha1.Stateless:
+			// the Deployment rolls out all the instances by itself
+			impact.strategy = ""
+			if len(instances) > 0 {
+				impact.batches = [][]*cluster.InstanceInfo{instances}
+			}
+			return impact
+		case appsv1alpha1.Consensus:
+			if spec := compDef.ConsensusSpec; spec != nil {
+				impact.strategy = spec.GetUpdateStrategy()
+				leaderRoles[spec.Leader.Name] = true
+				if spec.Learner != nil {
+					learnerRoles[spec.Learner.Name] = true
+				}
+			}
+		case appsv1alpha1.Replication:
+			if compDef.ReplicationSpec != nil {
+				impact.strategy = compDef.ReplicationSpec.GetUpdateStrategy()
+			}
+		case appsv1alpha1.Stateful:
+			if compDef.StatefulSpec != nil {
+				impact.strategy = compDef.StatefulSpec.GetUpdateStrategy()
+			}
+		}
+	}
+
+	if impact.strategy == "" {
+		impact.strategy = appsv1alpha1.SerialStrategy
+	}
+
+	var learners, members, leaders []*cluster.InstanceInfo
+	for _, ins := range instances {
+		switch {
+		case leaderRoles[ins.Role]:
+			leaders = append(leaders, ins)
+		case learnerRoles[ins.Role]:
+			learners = append(learners, ins)
+		default:
+			members = append(members, ins)
+		}
+	}
+	sortInstances := func(instances []*cluster.InstanceInfo) {
+		sort.SliceStable(instances, func(i, j int) bool {
+			return instanceOrdinal(instances[i].Name) > instanceOrdinal(instances[j].Name)
+		})
+	}
+	sortInstances(learners)
+	sortInstances(members)
+
+	addBatches := func(instances []*cluster.InstanceInfo, size int) {
+		for i := 0; i < len(instances); i += size {
+			end := i + size
+			if end > len(instances) {
+				end = len(instances)
+			}
+			impact.batches = append(impact.batches, instances[i:end])
+		}
+	}
+	switch impact.strategy {
+	case appsv1alpha1.ParallelStrategy:
+		addBatches(instances, len(instances))
+	case appsv1alpha1.BestEffortParallelStrategy:
+		// restart the minority of the voting members at the same time to keep the quorum
+		size := (len(members) + len(leaders) - 1) / 2
+		if size < 1 {
+			size = 1
+		}
+		addBatches(learners, len(learners))
+		addBatches(members, size)
+		addBatches(leaders, len(leaders))
+	default:
+		addBatches(learners, 1)
+		addBatches(members, 1)
+		addBatches(leaders, 1)
+	}
+	return impact
+}
+
+// instanceOrdinal returns the ordinal of the instance parsed from the suffix of its name.
+func instanceOrdinal(name string) int {
+	ordinal, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+func (o *OperationsOptions) printRestartImpacts(impacts []restartImpact) {
+	fmt.Fprintln(o.Out, "Restart sequence:")
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("COMPONENT", "WORKLOAD-TYPE", "UPDATE-STRATEGY", "STEP", "INSTANCES")
+	for _, impact := range impacts {
+		strategy := string(impact.strategy)
+		if strategy == "" {
+			strategy = "RollingUpdate"
+		}
+		if len(impact.batches) == 0 {
+			tbl.AddRow(impact.component, impact.workloadType, strategy, "-", "<none>")
+			continue
+		}
+		for i, batch := range impact.batches {
+			var names []string
+			for _, ins := range batch {
+				if ins.Role == "" {
+					names = append(names, ins.Name)
+				} else {
+					names = append(names, fmt.Sprintf("%s(%s)", ins.Name, ins.Role))
+				}
+			}
+			tbl.AddRow(impact.component, impact.workloadType, strategy, i+1, strings.Join(names, ","))
+		}
+	}
+	tbl.Print()
+}
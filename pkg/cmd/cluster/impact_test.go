@@ -0,0 +1,216 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	cfgcore "github.com/apecloud/kubeblocks/pkg/configuration/core"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	testapps "github.com/apecloud/kubeblocks/pkg/testutil/apps"
+
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var _ = Describe("explain impact", func() {
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	instances := func(roles ...string) []*cluster.InstanceInfo {
+		var res []*cluster.InstanceInfo
+		for i, role := range roles {
+			res = append(res, &cluster.InstanceInfo{Name: fmt.Sprintf("mycluster-mysql-%d", i), Role: role})
+		}
+		return res
+	}
+
+	sequence := func(impact restartImpact) [][]string {
+		var res [][]string
+		for _, batch := range impact.batches {
+			var names []string
+			for _, ins := range batch {
+				names = append(names, ins.Name)
+			}
+			res = append(res, names)
+		}
+		return res
+	}
+
+	It("classify the parameters", func() {
+		cc := &appsv1alpha1.ConfigConstraintSpec{
+			ReloadOptions: &appsv1alpha1.ReloadOptions{
+				UnixSignalTrigger: &appsv1alpha1.UnixSignalTrigger{Signal: "SIGHUP", ProcessName: "mysqld"},
+			},
+			StaticParameters:    []string{"innodb_buffer_pool_size"},
+			DynamicParameters:   []string{"max_connections"},
+			ImmutableParameters: []string{"datadir"},
+		}
+		Expect(util.ClassifyParameter(cc, "max_connections")).Should(Equal(util.DynamicParameter))
+		Expect(util.ClassifyParameter(cc, "innodb_buffer_pool_size")).Should(Equal(util.StaticParameter))
+		Expect(util.ClassifyParameter(cc, "datadir")).Should(Equal(util.ImmutableParameter))
+		Expect(util.ClassifyParameter(cc, "general_log")).Should(Equal(util.StaticParameter))
+
+		By("reload is the default behavior if no dynamic parameter is declared")
+		cc.DynamicParameters = nil
+		Expect(util.ClassifyParameter(cc, "general_log")).Should(Equal(util.DynamicParameter))
+
+		By("all the parameters are static if reload is not supported")
+		cc.ReloadOptions = nil
+		Expect(util.ClassifyParameter(cc, "general_log")).Should(Equal(util.StaticParameter))
+		Expect(util.ClassifyParameter(cc, "datadir")).Should(Equal(util.ImmutableParameter))
+	})
+
+	It("plan the restart sequence", func() {
+		compDef := &appsv1alpha1.ClusterComponentDefinition{
+			WorkloadType: appsv1alpha1.Consensus,
+			ConsensusSpec: &appsv1alpha1.ConsensusSetSpec{
+				Leader: appsv1alpha1.ConsensusMember{Name: constant.Leader},
+			},
+		}
+		consensus := instances(constant.Follower, constant.Leader, constant.Follower, constant.Follower, constant.Follower, constant.Learner)
+		Expect(sequence(planRestartSequence("mysql", compDef, consensus))).Should(Equal([][]string{
+			{"mycluster-mysql-5"}, {"mycluster-mysql-4"}, {"mycluster-mysql-3"}, {"mycluster-mysql-2"}, {"mycluster-mysql-0"}, {"mycluster-mysql-1"},
+		}))
+
+		By("restart the minority of the followers at the same time")
+		compDef.ConsensusSpec.UpdateStrategy = appsv1alpha1.BestEffortParallelStrategy
+		Expect(sequence(planRestartSequence("mysql", compDef, consensus))).Should(Equal([][]string{
+			{"mycluster-mysql-5"}, {"mycluster-mysql-4", "mycluster-mysql-3"}, {"mycluster-mysql-2", "mycluster-mysql-0"}, {"mycluster-mysql-1"},
+		}))
+
+		By("restart all the instances at the same time")
+		compDef.ConsensusSpec.UpdateStrategy = appsv1alpha1.ParallelStrategy
+		Expect(planRestartSequence("mysql", compDef, consensus).batches).Should(HaveLen(1))
+
+		By("restart the primary last")
+		compDef = &appsv1alpha1.ClusterComponentDefinition{WorkloadType: appsv1alpha1.Replication}
+		Expect(sequence(planRestartSequence("redis", compDef, instances(constant.Primary, constant.Secondary)))).Should(Equal([][]string{
+			{"mycluster-mysql-1"}, {"mycluster-mysql-0"},
+		}))
+
+		By("roll out the stateless instances by the deployment")
+		compDef = &appsv1alpha1.ClusterComponentDefinition{WorkloadType: appsv1alpha1.Stateless}
+		impact := planRestartSequence("nginx", compDef, instances("", ""))
+		Expect(impact.strategy).Should(BeEmpty())
+		Expect(impact.batches).Should(HaveLen(1))
+
+		By("restart the instances in descending order without the component definition")
+		Expect(sequence(planRestartSequence("mysql", nil, instances("", "", "")))).Should(Equal([][]string{
+			{"mycluster-mysql-2"}, {"mycluster-mysql-1"}, {"mycluster-mysql-0"},
+		}))
+	})
+
+	It("explain the restart impact", func() {
+		clusterDef := testing.FakeClusterDef()
+		clusterDef.Spec.ComponentDefs[0].WorkloadType = appsv1alpha1.Consensus
+		clusterDef.Spec.ComponentDefs[0].ConsensusSpec = &appsv1alpha1.ConsensusSetSpec{
+			Leader: appsv1alpha1.ConsensusMember{Name: constant.Leader},
+		}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace), clusterDef)
+		o := newBaseOperationsOptions(tf, streams, appsv1alpha1.RestartType, true)
+		o.Args = []string{testing.ClusterName}
+		o.ExplainImpact = true
+		Expect(o.Complete()).Should(Succeed())
+		o.Client = testing.FakeClientSet(testing.FakePods(3, testing.Namespace, testing.ClusterName))
+		o.ComponentNames = []string{testing.ComponentName}
+		Expect(o.explainRestartImpact()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`Consensus\s+Serial\s+1\s+fake-cluster-name-pod-2\(follower\)`))
+		Expect(out.String()).Should(MatchRegexp(`3\s+fake-cluster-name-pod-0\(leader\)`))
+
+		By("the impact can not be explained in fleet mode")
+		o.LabelSelector = "env=test"
+		Expect(o.explainRestartImpact()).Should(HaveOccurred())
+	})
+
+	It("explain the reconfiguring impact", func() {
+		const (
+			ns             = "default"
+			clusterDefName = "test-clusterdef"
+			clusterName    = "test-cluster"
+			compDefName    = "replicasets"
+			compName       = "mysql"
+			configSpecName = "mysql-config-tpl"
+		)
+		configmap := testapps.NewCustomizedObj("resources/mysql-config-template.yaml", &corev1.ConfigMap{}, testapps.WithNamespace(ns))
+		constraint := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		componentConfig := testapps.NewConfigMap(ns, cfgcore.GetComponentCfgName(clusterName, compName, configSpecName), testapps.SetConfigMapData("my.cnf", ""))
+		clusterDefObj := testapps.NewClusterDefFactory(clusterDefName).
+			AddComponentDef(testapps.StatefulMySQLComponent, compDefName).
+			AddConfigTemplate(configSpecName, configmap.Name, constraint.Name, ns, "mysql-config").
+			GetObject()
+		clusterObj := testapps.NewClusterFactory(ns, clusterName, clusterDefObj.Name, "").
+			AddComponent(compName, compDefName).GetObject()
+
+		objs := []runtime.Object{configmap, constraint, clusterDefObj, clusterObj, componentConfig}
+		ttf, ops := NewFakeOperationsOptions(ns, clusterName, appsv1alpha1.ReconfiguringType, objs...)
+		defer ttf.Cleanup()
+		ops.IOStreams = streams
+		o := &configOpsOptions{OperationsOptions: &OperationsOptions{CreateOptions: *ops, ExplainImpact: true}}
+		pods := &corev1.PodList{}
+		for i := 0; i < 2; i++ {
+			pod := corev1.Pod{}
+			pod.Name = fmt.Sprintf("%s-%s-%d", clusterName, compName, i)
+			pod.Namespace = ns
+			pod.Labels = map[string]string{constant.AppInstanceLabelKey: clusterName, constant.KBAppComponentLabelKey: compName}
+			pods.Items = append(pods.Items, pod)
+		}
+		o.Client = testing.FakeClientSet(pods)
+		o.CfgTemplateName = configSpecName
+
+		By("the dynamic parameters do not restart the instances")
+		o.Parameters = []string{"innodb_autoinc_lock_mode=2"}
+		Expect(o.explainImpact()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`mysql\s+innodb_autoinc_lock_mode\s+2\s+dynamic`))
+		Expect(out.String()).Should(ContainSubstring("no instance will be restarted"))
+
+		By("the static parameters restart the instances one by one")
+		out.Reset()
+		o.Parameters = []string{"innodb_autoinc_lock_mode=2,automatic_sp_privileges=OFF"}
+		Expect(o.explainImpact()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`mysql\s+automatic_sp_privileges\s+OFF\s+static`))
+		Expect(out.String()).Should(MatchRegexp(`Stateful\s+Serial\s+1\s+test-cluster-mysql-1`))
+		Expect(out.String()).Should(MatchRegexp(`2\s+test-cluster-mysql-0`))
+	})
+})
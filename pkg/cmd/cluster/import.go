@@ -0,0 +1,424 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/configuration/core"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+const importCueTemplateName = "cluster_import_template.cue"
+
+var importExample = templates.Examples(`
+	# import the cluster from a bundle exported by "kbcli cluster export"
+	kbcli cluster import -f mycluster-bundle.yaml
+
+	# import the cluster to the namespace prod with a new name
+	kbcli cluster import -f mycluster-bundle.yaml --name mycluster-new -n prod
+
+	# print the cluster that would be created without creating it
+	kbcli cluster import -f mycluster-bundle.yaml --dry-run`)
+
+type ImportOptions struct {
+	action.CreateOptions `json:"-"`
+
+	// Cluster is the cluster object in the bundle without the name and namespace,
+	// they are filled by the cue template
+	Cluster map[string]interface{} `json:"cluster"`
+
+	File    string        `json:"-"`
+	Timeout time.Duration `json:"-"`
+
+	bundle *clusterBundle
+	// sourceName is the name of the exported cluster
+	sourceName string
+}
+
+func NewImportCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ImportOptions{
+		CreateOptions: action.CreateOptions{
+			Factory:         f,
+			IOStreams:       streams,
+			CueTemplateName: importCueTemplateName,
+			GVR:             types.ClusterGVR(),
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "import -f FILENAME",
+		Short: "Import a cluster from a bundle exported by 'kbcli cluster export'.",
+		Long: templates.LongDesc(`
+			Import a cluster from a bundle exported by 'kbcli cluster export'. The target Kubernetes cluster
+			is validated against the bundle before importing, then the accounts with passwords are created,
+			the cluster is created, and after the cluster is running, the settings of the backup policies
+			and backup schedules are restored and the customized config parameters are applied by
+			Reconfiguring OpsRequests.`),
+		Example: importExample,
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete())
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVarP(&o.File, "file", "f", "", "The bundle file to import, it can be a local file, a URL or '-' for stdin")
+	cmd.Flags().StringVar(&o.Name, "name", "", "The name of the imported cluster, use the name in the bundle if not specified")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", defaultOpsWaitTimeout, "Time to wait for the cluster to be running before restoring the backup settings and configs")
+	cmd.Flags().StringVar(&o.DryRun, "dry-run", "none", `Must be "client", or "server". If with client strategy, only print the object that would be sent, and no data is actually sent. If with server strategy, submit the server-side request, but no data is persistent.`)
+	cmd.Flags().Lookup("dry-run").NoOptDefVal = "unchanged"
+	printer.AddOutputFlagForCreate(cmd, &o.Format, false)
+	util.CheckErr(cmd.MarkFlagRequired("file"))
+	return cmd
+}
+
+func (o *ImportOptions) Complete() error {
+	if o.File == "" {
+		return fmt.Errorf("missing bundle file, please specify it by the \"-f\" flag")
+	}
+	// the name specified by the flag will be reset by CreateOptions.Complete if there is no args
+	name := o.Name
+	if err := o.CreateOptions.Complete(); err != nil {
+		return err
+	}
+	o.Name = name
+
+	data, err := MultipleSourceComponents(o.File, o.In)
+	if err != nil {
+		return err
+	}
+	if o.bundle, err = parseClusterBundle(data); err != nil {
+		return err
+	}
+
+	o.Cluster = runtime.DeepCopyJSON(o.bundle.Cluster)
+	metadata, _ := o.Cluster["metadata"].(map[string]interface{})
+	if metadata != nil {
+		o.sourceName, _ = metadata["name"].(string)
+		delete(metadata, "name")
+		delete(metadata, "namespace")
+	}
+	if o.Name == "" {
+		o.Name = o.sourceName
+	}
+	o.dropSourceServiceAccount()
+
+	o.CreateOptions.Options = o
+	o.CreateDependencies = o.createAccounts
+	o.CleanUpFn = o.deleteAccounts
+	return nil
+}
+
+// dropSourceServiceAccount removes the service account created by kbcli for the source cluster,
+// KubeBlocks will create the service account for the imported cluster.
+func (o *ImportOptions) dropSourceServiceAccount() {
+	if o.sourceName == o.Name {
+		return
+	}
+	spec, _ := o.Cluster["spec"].(map[string]interface{})
+	comps, _ := spec["componentSpecs"].([]interface{})
+	for _, c := range comps {
+		comp, ok := c.(map[string]interface{})
+		if ok && comp["serviceAccountName"] == saNamePrefix+o.sourceName {
+			delete(comp, "serviceAccountName")
+		}
+	}
+}
+
+// Validate validates the target Kubernetes cluster against the bundle, all the
+// problems are reported together.
+func (o *ImportOptions) Validate() error {
+	if o.Name == "" {
+		return makeMissingClusterNameErr()
+	}
+
+	cls := &appsv1alpha1.Cluster{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Cluster, cls); err != nil {
+		return err
+	}
+
+	var allErrs []error
+	checked := map[string]bool{}
+	checkExists := func(gvr schema.GroupVersionResource, kind, namespace, name string) {
+		key := kind + "/" + name
+		if name == "" || checked[key] {
+			return
+		}
+		checked[key] = true
+		_, err := o.Dynamic.Resource(gvr).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			allErrs = append(allErrs, fmt.Errorf("%s %s is not found", kind, name))
+		case err != nil:
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	checkExists(types.ClusterDefGVR(), "ClusterDefinition", "", o.bundle.ClusterDefinition)
+	checkExists(types.ClusterVersionGVR(), "ClusterVersion", "", o.bundle.ClusterVersion)
+	for _, comp := range cls.Spec.ComponentSpecs {
+		for _, vct := range comp.VolumeClaimTemplates {
+			if vct.Spec.StorageClassName != nil {
+				checkExists(types.StorageClassGVR(), "StorageClass", "", *vct.Spec.StorageClassName)
+			}
+		}
+	}
+	for _, cfg := range o.bundle.Configs {
+		checkExists(types.ConfigConstraintGVR(), "ConfigConstraint", "", cfg.ConfigConstraint)
+	}
+	if cls.Spec.Backup != nil && cls.Spec.Backup.RepoName != "" {
+		checkExists(types.BackupRepoGVR(), "BackupRepo", "", cls.Spec.Backup.RepoName)
+	}
+	for _, policy := range o.bundle.BackupPolicies {
+		if policy.BackupRepoName != nil {
+			checkExists(types.BackupRepoGVR(), "BackupRepo", "", *policy.BackupRepoName)
+		}
+	}
+
+	_, err := o.Dynamic.Resource(types.ClusterGVR()).Namespace(o.Namespace).Get(context.TODO(), o.Name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		allErrs = append(allErrs, fmt.Errorf("cluster %s already exists in namespace %s", o.Name, o.Namespace))
+	case !apierrors.IsNotFound(err):
+		allErrs = append(allErrs, err)
+	}
+	return utilerrors.NewAggregate(allErrs)
+}
+
+func (o *ImportOptions) Run() error {
+	if err := o.CreateOptions.Run(); err != nil {
+		return err
+	}
+	dryRun, err := o.GetDryRunStrategy()
+	if err != nil {
+		return err
+	}
+	if dryRun != action.DryRunNone {
+		return nil
+	}
+	if len(o.bundle.BackupPolicies) == 0 && len(o.bundle.BackupSchedules) == 0 && len(o.bundle.Configs) == 0 {
+		return nil
+	}
+
+	if err = waitForClusterRunning(o.Dynamic, o.Namespace, o.Name, o.Timeout, o.Out); err != nil {
+		return err
+	}
+	if err = o.importBackupSettings(); err != nil {
+		return err
+	}
+	return o.importConfigs()
+}
+
+// createAccounts creates the account secrets with the passwords in the bundle before
+// creating the cluster, KubeBlocks will use them instead of generating new passwords.
+func (o *ImportOptions) createAccounts(dryRun []string) error {
+	for _, account := range o.bundle.Accounts {
+		if account.Password == "" {
+			continue
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      constant.GenerateAccountSecretName(o.Name, account.Component, account.Name),
+				Namespace: o.Namespace,
+				Labels: map[string]string{
+					constant.AppInstanceLabelKey:    o.Name,
+					constant.AppManagedByLabelKey:   constant.AppName,
+					constant.KBAppComponentLabelKey: account.Component,
+					constant.ClusterAccountLabelKey: account.Name,
+				},
+			},
+			Data: map[string][]byte{
+				constant.AccountNameForSecret:   []byte(account.Name),
+				constant.AccountPasswdForSecret: []byte(account.Password),
+			},
+		}
+		if _, err := o.Client.CoreV1().Secrets(o.Namespace).Create(context.TODO(), secret, metav1.CreateOptions{DryRun: dryRun}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAccounts deletes the account secrets if failed to create the cluster.
+func (o *ImportOptions) deleteAccounts() error {
+	var allErrs []error
+	for _, account := range o.bundle.Accounts {
+		if account.Password == "" {
+			continue
+		}
+		name := constant.GenerateAccountSecretName(o.Name, account.Component, account.Name)
+		if err := o.Client.CoreV1().Secrets(o.Namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return utilerrors.NewAggregate(allErrs)
+}
+
+// importBackupSettings patches the backup policies and backup schedules created by
+// KubeBlocks with the settings in the bundle.
+func (o *ImportOptions) importBackupSettings() error {
+	patch := func(gvr schema.GroupVersionResource, name string, spec map[string]interface{}) error {
+		if len(spec) == 0 {
+			return nil
+		}
+		data, err := json.Marshal(map[string]interface{}{"spec": spec})
+		if err != nil {
+			return err
+		}
+		_, err = o.Dynamic.Resource(gvr).Namespace(o.Namespace).Patch(context.TODO(), name, k8stypes.MergePatchType, data, metav1.PatchOptions{})
+		if apierrors.IsNotFound(err) {
+			printer.Warning(o.Out, "%s %s is not found, skip restoring its settings\n", gvr.Resource, name)
+			return nil
+		}
+		if err == nil {
+			fmt.Fprintf(o.Out, "%s %s is updated\n", gvr.Resource, name)
+		}
+		return err
+	}
+
+	for _, policy := range o.bundle.BackupPolicies {
+		spec := map[string]interface{}{}
+		if policy.BackupRepoName != nil {
+			spec["backupRepoName"] = *policy.BackupRepoName
+		}
+		if policy.BackoffLimit != nil {
+			spec["backoffLimit"] = *policy.BackoffLimit
+		}
+		if err := patch(types.BackupPolicyGVR(), o.Name+"-"+policy.Name, spec); err != nil {
+			return err
+		}
+	}
+	for _, schedule := range o.bundle.BackupSchedules {
+		spec := map[string]interface{}{"schedules": schedule.Schedules}
+		if schedule.StartingDeadlineMinutes != nil {
+			spec["startingDeadlineMinutes"] = *schedule.StartingDeadlineMinutes
+		}
+		if err := patch(types.BackupScheduleGVR(), o.Name+"-"+schedule.Name, spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importConfigs compares the config files in the bundle with the config files of the
+// imported cluster, and applies the changed parameters by Reconfiguring OpsRequests.
+func (o *ImportOptions) importConfigs() error {
+	for _, cfg := range o.bundle.Configs {
+		cmName := core.GetComponentCfgName(o.Name, cfg.Component, cfg.ConfigSpec)
+		cm, err := o.Client.CoreV1().ConfigMaps(o.Namespace).Get(context.TODO(), cmName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		cc := &appsv1alpha1.ConfigConstraint{}
+		if err = util.GetK8SClientObject(o.Dynamic, cc, types.ConfigConstraintGVR(), "", cfg.ConfigConstraint); err != nil {
+			return err
+		}
+		params, err := diffConfigParams(cm.Data, cfg.Data, cc.Spec.FormatterConfig)
+		if err != nil {
+			return err
+		}
+		for _, param := range params {
+			if err = o.reconfigure(cfg, param); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o *ImportOptions) reconfigure(cfg bundleConfig, param core.VisualizedParam) error {
+	ops := newBaseOperationsOptions(o.Factory, o.IOStreams, appsv1alpha1.ReconfiguringType, true)
+	if err := ops.CreateOptions.Complete(); err != nil {
+		return err
+	}
+	ops.Dynamic = o.Dynamic
+	ops.Client = o.Client
+	ops.Namespace = o.Namespace
+	ops.Name = o.Name
+	ops.Format = printer.YAML
+	ops.ComponentNames = []string{cfg.Component}
+	ops.CfgTemplateName = cfg.ConfigSpec
+	ops.CfgFile = param.Key
+	ops.Wait = true
+	ops.Timeout = o.Timeout
+	ops.autoApprove = true
+	for _, p := range param.Parameters {
+		ops.KeyValues[p.Key] = p.Value
+	}
+	if err := ops.Validate(); err != nil {
+		return err
+	}
+	return ops.Run()
+}
+
+// diffConfigParams returns the parameters changed from the old config files to the new config files,
+// a parameter with nil value means it is deleted.
+func diffConfigParams(oldData, newData map[string]string, formatter *appsv1alpha1.FormatterConfig) ([]core.VisualizedParam, error) {
+	if formatter == nil {
+		return nil, fmt.Errorf("the formatter of the config constraint is required")
+	}
+	patch, _, err := core.CreateConfigPatch(oldData, newData, formatter.Format, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	params := core.GenerateVisualizedParamsList(patch, formatter, nil)
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Key < params[j].Key
+	})
+	return params, nil
+}
+
+func parseClusterBundle(data []byte) (*clusterBundle, error) {
+	bundle := &clusterBundle{}
+	if err := yaml.Unmarshal(data, bundle); err != nil {
+		return nil, err
+	}
+	if bundle.Kind != clusterBundleKind || bundle.APIVersion != clusterBundleAPIVersion {
+		return nil, fmt.Errorf("invalid bundle, the kind should be %s and the apiVersion should be %s", clusterBundleKind, clusterBundleAPIVersion)
+	}
+	if len(bundle.Cluster) == 0 {
+		return nil, fmt.Errorf("invalid bundle, the cluster is missing")
+	}
+	return bundle, nil
+}
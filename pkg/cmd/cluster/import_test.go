@@ -0,0 +1,173 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var _ = Describe("cluster import", func() {
+	const newClusterName = "test-import"
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+		tmpDir  string
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace),
+			testing.FakeClusterDef(), testing.FakeClusterVersion(),
+			testing.FakeBackupPolicy(newClusterName+"-mysql-backup-policy", newClusterName))
+		tmpDir = GinkgoT().TempDir()
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	writeBundle := func(bundle *clusterBundle) string {
+		data, err := yaml.Marshal(bundle)
+		Expect(err).ShouldNot(HaveOccurred())
+		file := filepath.Join(tmpDir, "bundle.yaml")
+		Expect(os.WriteFile(file, data, 0644)).Should(Succeed())
+		return file
+	}
+
+	newBundle := func() *clusterBundle {
+		cls := testing.FakeCluster(testing.ClusterName, testing.Namespace)
+		cls.Spec.ComponentSpecs[0].ServiceAccountName = saNamePrefix + testing.ClusterName
+		obj, err := stripCluster(cls)
+		Expect(err).ShouldNot(HaveOccurred())
+		return &clusterBundle{
+			APIVersion:        clusterBundleAPIVersion,
+			Kind:              clusterBundleKind,
+			ClusterDefinition: testing.ClusterDefName,
+			ClusterVersion:    testing.ClusterVersionName,
+			Cluster:           obj,
+			Accounts: []bundleAccount{
+				{Component: testing.ComponentName, Name: "kbadmin", Password: "kbadmin-password"},
+				{Component: testing.ComponentName, Name: "kbprobe"},
+			},
+		}
+	}
+
+	newImportOptions := func(bundle *clusterBundle, name string) *ImportOptions {
+		o := &ImportOptions{}
+		o.Factory = tf
+		o.IOStreams = streams
+		o.CueTemplateName = importCueTemplateName
+		o.GVR = types.ClusterGVR()
+		o.File = writeBundle(bundle)
+		o.Name = name
+		o.DryRun = "none"
+		Expect(o.Complete()).Should(Succeed())
+		o.Client = testing.FakeClientSet()
+		return o
+	}
+
+	It("import command", func() {
+		Expect(NewImportCmd(tf, streams)).ShouldNot(BeNil())
+	})
+
+	It("validate the target against the bundle", func() {
+		bundle := newBundle()
+		bundle.ClusterVersion = "not-exist-version"
+		o := newImportOptions(bundle, "")
+		Expect(o.Name).Should(Equal(testing.ClusterName))
+		err := o.Validate()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("ClusterVersion not-exist-version is not found"))
+		Expect(err.Error()).Should(ContainSubstring("already exists"))
+
+		o = newImportOptions(newBundle(), newClusterName)
+		Expect(o.Validate()).Should(Succeed())
+	})
+
+	It("import the cluster with a new name", func() {
+		o := newImportOptions(newBundle(), newClusterName)
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+
+		cls := &appsv1alpha1.Cluster{}
+		Expect(util.GetK8SClientObject(tf.FakeDynamicClient, cls, types.ClusterGVR(), testing.Namespace, newClusterName)).Should(Succeed())
+		Expect(cls.Namespace).Should(Equal(testing.Namespace))
+		Expect(cls.Spec.ClusterDefRef).Should(Equal(testing.ClusterDefName))
+		Expect(cls.Spec.ComponentSpecs[0].ServiceAccountName).Should(BeEmpty())
+
+		secrets, err := o.Client.CoreV1().Secrets(testing.Namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(secrets.Items).Should(HaveLen(1))
+		Expect(secrets.Items[0].Name).Should(Equal(constant.GenerateAccountSecretName(newClusterName, testing.ComponentName, "kbadmin")))
+	})
+
+	It("restore the backup settings", func() {
+		bundle := newBundle()
+		repoName := "test-repo"
+		bundle.BackupPolicies = []bundleBackupPolicy{{Name: "mysql-backup-policy", BackupRepoName: &repoName}}
+		bundle.BackupSchedules = []bundleBackupSchedule{{Name: "mysql-backup-schedule"}}
+		o := newImportOptions(bundle, newClusterName)
+		Expect(o.importBackupSettings()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("is not found"))
+
+		obj, err := tf.FakeDynamicClient.Resource(types.BackupPolicyGVR()).Namespace(testing.Namespace).
+			Get(context.TODO(), newClusterName+"-mysql-backup-policy", metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(obj.Object["spec"]).Should(HaveKeyWithValue("backupRepoName", repoName))
+	})
+
+	It("diff config params", func() {
+		formatter := &appsv1alpha1.FormatterConfig{
+			Format: appsv1alpha1.Ini,
+			FormatterOptions: appsv1alpha1.FormatterOptions{
+				IniConfig: &appsv1alpha1.IniConfig{SectionName: "mysqld"},
+			},
+		}
+		params, err := diffConfigParams(map[string]string{"my.cnf": "[mysqld]\nmax_connections=1000\n"},
+			map[string]string{"my.cnf": "[mysqld]\nmax_connections=2000\n"}, formatter)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(params).Should(HaveLen(1))
+		Expect(params[0].Key).Should(Equal("my.cnf"))
+		Expect(params[0].Parameters).Should(HaveLen(1))
+		Expect(params[0].Parameters[0].Key).Should(Equal("max_connections"))
+		Expect(*params[0].Parameters[0].Value).Should(Equal("2000"))
+	})
+})
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/apecloud/kubeblocks/pkg/common"
 	jsonpatch "github.com/evanphx/json-patch"
@@ -66,6 +67,22 @@ type OperationsOptions struct {
 	OpsRequestName         string   `json:"opsRequestName"`
 	TTLSecondsAfterSucceed int      `json:"ttlSecondsAfterSucceed"`
 
+	// Wait waits for the OpsRequest to be completed and prints the progress
+	Wait    bool          `json:"-"`
+	Timeout time.Duration `json:"-"`
+
+	// ExplainImpact prints the impact of the operation instead of creating the OpsRequest
+	ExplainImpact bool `json:"-"`
+
+	// fleetOptions runs the operation on all the clusters matching the label selector
+	fleetOptions `json:"-"`
+
+	// scheduleOptions submits the OpsRequest later by a CronJob
+	scheduleOptions `json:"-"`
+
+	// promoteOptions chooses the instance to promote interactively
+	promoteOptions `json:"-"`
+
 	// OpsType operation type
 	OpsType appsv1alpha1.OpsType `json:"type"`
 
@@ -146,11 +163,34 @@ func (o *OperationsOptions) addCommonFlags(cmd *cobra.Command, f cmdutil.Factory
 	cmd.Flags().IntVar(&o.TTLSecondsAfterSucceed, "ttlSecondsAfterSucceed", 0, "Time to live after the OpsRequest succeed")
 	cmd.Flags().StringVar(&o.DryRun, "dry-run", "none", `Must be "client", or "server". If with client strategy, only print the object that would be sent, and no data is actually sent. If with server strategy, submit the server-side request, but no data is persistent.`)
 	cmd.Flags().Lookup("dry-run").NoOptDefVal = "unchanged"
+	cmd.Flags().BoolVar(&o.Wait, "wait", false, "Wait for the OpsRequest to be completed and show the progress, exit with non-zero code if it is failed or cancelled")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", defaultOpsWaitTimeout, "Time to wait for the OpsRequest to be completed, only valid with --wait")
+	o.addScheduleFlags(cmd)
 	if o.HasComponentNamesFlag {
 		flags.AddComponentsFlag(f, cmd, &o.ComponentNames, "Component names to this operations")
 	}
 }
 
+// Run creates the OpsRequest, and waits for it to be completed if --wait is specified.
+// If --schedule or --at is specified, the OpsRequest is submitted later by a CronJob.
+func (o *OperationsOptions) Run() error {
+	if o.isScheduled() {
+		return o.createScheduledOps()
+	}
+	if err := o.CreateOptions.Run(); err != nil {
+		return err
+	}
+	dryRun, err := o.GetDryRunStrategy()
+	if err != nil {
+		return err
+	}
+	if !o.Wait || dryRun != action.DryRunNone {
+		return nil
+	}
+	// the name of CreateOptions is set to the name of OpsRequest after it is created
+	return newOpsRequestWaiter(o.Dynamic, o.Client, o.Namespace, o.CreateOptions.Name, o.Timeout, o.Out).wait()
+}
+
 // CompleteRestartOps restarts all components of the cluster
 // we should set all component names to ComponentNames flag.
 func (o *OperationsOptions) CompleteRestartOps() error {
@@ -579,6 +619,15 @@ var restartExample = templates.Examples(`
 
 		# specified component to restart, separate with commas for multiple components
 		kbcli cluster restart mycluster --components=mysql
+
+		# restart all the clusters labelled env=test in all namespaces, wait for each batch of two clusters to succeed
+		kbcli cluster restart -l env=test -A --max-unavailable=2
+
+		# restart the cluster in the maintenance window at 2 a.m. every day
+		kbcli cluster restart mycluster --schedule="0 2 * * *"
+
+		# preview the order in which the instances will be restarted
+		kbcli cluster restart mycluster --explain-impact
 `)
 
 // NewRestartCmd creates a restart command
@@ -593,13 +642,17 @@ func NewRestartCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.CompleteRestartOps())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			if o.ExplainImpact {
+				cmdutil.CheckErr(o.explainRestartImpact())
+				return
+			}
+			cmdutil.CheckErr(o.runOnClusters((*OperationsOptions).CompleteRestartOps))
 		},
 	}
 	o.addCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before restarting the cluster")
+	cmd.Flags().BoolVar(&o.ExplainImpact, "explain-impact", false, "Print the planned restart sequence of the instances without restarting the cluster")
 	return cmd
 }
 
@@ -620,11 +673,11 @@ func NewUpgradeCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			cmdutil.CheckErr(o.runOnClusters())
 		},
 	}
 	o.addCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	cmd.Flags().StringVar(&o.ClusterVersionRef, "cluster-version", "", "Reference cluster version (required)")
 	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before upgrading the cluster")
 	_ = cmd.MarkFlagRequired("cluster-version")
@@ -637,6 +690,9 @@ var verticalScalingExample = templates.Examples(`
 
 		# scale the computing resources of specified components by class, run command 'kbcli class list --cluster-definition cluster-definition-name' to get available classes
 		kbcli cluster vscale mycluster --components=mysql --class=general-2c4g
+
+		# scale the computing resources once at the specified time
+		kbcli cluster vscale mycluster --components=mysql --cpu=1 --memory=2Gi --at=2023-11-11T02:00:00+08:00
 `)
 
 // NewVerticalScalingCmd creates a vertical scaling command
@@ -651,12 +707,11 @@ func NewVerticalScalingCmd(f cmdutil.Factory, streams genericiooptions.IOStreams
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.CompleteComponentsFlag())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			cmdutil.CheckErr(o.runOnClusters((*OperationsOptions).CompleteComponentsFlag))
 		},
 	}
 	o.addCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	cmd.Flags().StringVar(&o.CPU, "cpu", "", "Request and limit size of component cpu")
 	cmd.Flags().StringVar(&o.Memory, "memory", "", "Request and limit size of component memory")
 	cmd.Flags().StringVar(&o.Class, "class", "", "Component class")
@@ -682,13 +737,12 @@ func NewHorizontalScalingCmd(f cmdutil.Factory, streams genericiooptions.IOStrea
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.CompleteComponentsFlag())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			cmdutil.CheckErr(o.runOnClusters((*OperationsOptions).CompleteComponentsFlag))
 		},
 	}
 
 	o.addCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	cmd.Flags().IntVar(&o.Replicas, "replicas", o.Replicas, "Replicas with the specified components")
 	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before horizontally scaling the cluster")
 	_ = cmd.MarkFlagRequired("replicas")
@@ -713,12 +767,11 @@ func NewVolumeExpansionCmd(f cmdutil.Factory, streams genericiooptions.IOStreams
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.CompleteComponentsFlag())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			cmdutil.CheckErr(o.runOnClusters((*OperationsOptions).CompleteComponentsFlag))
 		},
 	}
 	o.addCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	cmd.Flags().StringSliceVarP(&o.VCTNames, "volume-claim-templates", "t", nil, "VolumeClaimTemplate names in components (required)")
 	cmd.Flags().StringVar(&o.Storage, "storage", "", "Volume storage size (required)")
 	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before expanding the cluster volume")
@@ -753,13 +806,11 @@ func NewExposeCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.CompleteComponentsFlag())
-			cmdutil.CheckErr(o.fillExpose())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			cmdutil.CheckErr(o.runOnClusters((*OperationsOptions).CompleteComponentsFlag, (*OperationsOptions).fillExpose))
 		},
 	}
 	o.addCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	cmd.Flags().StringVar(&o.ExposeType, "type", "", "Expose type, currently supported types are 'vpc', 'internet'")
 	cmd.Flags().StringVar(&o.ExposeEnabled, "enable", "", "Enable or disable the expose, values can be true or false")
 	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before exposing the cluster")
@@ -792,11 +843,11 @@ func NewStopCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Co
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			cmdutil.CheckErr(o.runOnClusters())
 		},
 	}
 	o.addCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before stopping the cluster")
 	return cmd
 }
@@ -819,11 +870,11 @@ func NewStartCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.C
 			o.Args = args
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
+			cmdutil.CheckErr(o.runOnClusters())
 		},
 	}
 	o.addCommonFlags(cmd, f)
+	o.addFleetFlags(cmd)
 	return cmd
 }
 
@@ -902,6 +953,9 @@ var promoteExample = templates.Examples(`
 
 		# If the cluster has multiple components, you need to specify a component, otherwise an error will be reported.
 	    kbcli cluster promote mycluster --component=mysql --instance mycluster-mysql-1
+
+		# Choose the instance to promote from the instances with their replication lags, the most up-to-date healthy follower is recommended.
+		kbcli cluster promote mycluster --interactive
 `)
 
 // NewPromoteCmd creates a promote command
@@ -917,13 +971,19 @@ func NewPromoteCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra
 			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
 			cmdutil.CheckErr(o.Complete())
 			cmdutil.CheckErr(o.CompleteComponentsFlag())
+			if o.Interactive {
+				cmdutil.CheckErr(o.selectPromoteCandidate())
+			}
 			cmdutil.CheckErr(o.Validate())
 			cmdutil.CheckErr(o.Run())
 		},
 	}
+	o.execInPod = newInstanceCommandExecutor(f)
 	flags.AddComponentFlag(f, cmd, &o.Component, "Specify the component name of the cluster, if the cluster has multiple components, you need to specify a component")
 	cmd.Flags().StringVar(&o.Instance, "instance", "", "Specify the instance name as the new primary or leader of the cluster, you can get the instance name by running \"kbcli cluster list-instances\"")
 	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before promote the instance")
+	cmd.Flags().BoolVar(&o.Interactive, "interactive", false, "List the instances with their roles, readiness, nodes and replication lags, and choose the instance to promote")
+	cmd.Flags().BoolVar(&o.Force, "force", false, "Promote the instance chosen interactively even if it is not ready or on the same node as the current primary")
 	o.addCommonFlags(cmd, f)
 	return cmd
 }
@@ -940,6 +1000,9 @@ var customOpsExample = templates.Examples(`
 
 		# example for kafka quota
         kbcli cluster custom-ops kafka-quota --cluster mycluster --user client --producerByteRate 1024 --consumerByteRate 2048
+
+		# print the parameters of the custom ops
+		kbcli cluster custom-ops kafka-topic --help
 `)
 
 type customOperations struct {
@@ -958,7 +1021,7 @@ func NewCustomOpsCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cob
 	cmd := &cobra.Command{
 		Use:                "custom-ops OpsDef --cluster <clusterName> <your custom params>",
 		Example:            customOpsExample,
-		ValidArgsFunction:  util.ResourceNameCompletionFunc(f, types.OpsDefinitionGVR()),
+		ValidArgsFunction:  o.completeCustomOpsArgs(f),
 		DisableFlagParsing: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			o.Args = args
@@ -1041,38 +1104,217 @@ func (o *customOperations) validateAndCompleteComponentName() error {
 }
 
 func (o *customOperations) parseOpsDefinitionAndParams(cmd *cobra.Command, args []string) error {
-	fmt.Printf("args: %v\n", args)
-	if len(args) == 0 {
+	o.OpsDefinitionName = firstNonFlagArg(cmd, args)
+	if o.OpsDefinitionName == "" {
+		if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+			return pflag.ErrHelp
+		}
 		return fmt.Errorf("please specify the custom ops which you want to do")
 	}
-	_ = flags.NewTmpFlagSet()
-	return flags.BuildFlagsWithOpenAPISchema(cmd, args, func() (*v1.JSONSchemaProps, error) {
+	err := flags.BuildFlagsWithOpenAPISchema(cmd, args, o.getParametersSchema)
+	if errors.Is(err, pflag.ErrHelp) && o.SchemaProperties != nil {
+		// print the parameters of the OpsDefinition in the help
+		cmd.Long = buildOpsDefinitionHelp(o.OpsDefinitionName, buildOpsDefinitionParams(o.SchemaProperties))
+	}
+	return err
+}
+
+// firstNonFlagArg returns the first argument which is neither a flag nor the value of a flag,
+// since the flags are not parsed before the OpsDefinition is resolved, e.g. "--help OpsDef".
+func firstNonFlagArg(cmd *cobra.Command, args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return arg
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		// skip the value of the known flag which is not a boolean flag
+		var flag *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			flag = cmd.Flags().Lookup(strings.TrimPrefix(arg, "--"))
+		} else {
+			flag = cmd.Flags().ShorthandLookup(arg[len(arg)-1:])
+		}
+		if flag != nil && flag.NoOptDefVal == "" {
+			i++
+		}
+	}
+	return ""
+}
+
+// getParametersSchema gets the parametersSchema of the OpsDefinition from API server.
+func (o *customOperations) getParametersSchema() (*v1.JSONSchemaProps, error) {
+	opsDef := &appsv1alpha1.OpsDefinition{}
+	if err := util.GetK8SClientObject(o.Dynamic, opsDef, types.OpsDefinitionGVR(), "", o.OpsDefinitionName); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("OpsDefintion \"%s\" is not found", o.OpsDefinitionName)
+		}
+		return nil, err
+	}
+	parametersSchema := opsDef.Spec.ParametersSchema
+	if parametersSchema == nil {
+		return nil, nil
+	}
+	o.SchemaProperties = parametersSchema.OpenAPIV3Schema
+	return parametersSchema.OpenAPIV3Schema, nil
+}
+
+// completeCustomOpsArgs completes the OpsDefinition name, and the flags and enum values of its parameters.
+// The flags are not parsed by cobra since they depend on the OpsDefinition, so they are completed here.
+func (o *customOperations) completeCustomOpsArgs(f cmdutil.Factory) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return util.ResourceNameCompletionFunc(f, types.OpsDefinitionGVR())(cmd, args, toComplete)
+		}
+		if o.init() != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
 		o.OpsDefinitionName = args[0]
-		// Get ops Definition from API server
-		opsDef := &appsv1alpha1.OpsDefinition{}
-		if err := util.GetK8SClientObject(o.Dynamic, opsDef, types.OpsDefinitionGVR(), "", o.OpsDefinitionName); err != nil {
-			if apierrors.IsNotFound(err) {
-				return nil, fmt.Errorf("OpsDefintion \"%s\" is not found", o.OpsDefinitionName)
+		schema, err := o.getParametersSchema()
+		if err != nil || schema == nil || flags.BuildFlagsByOpenAPISchema(cmd, schema) != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		// complete the value of the previous flag
+		last := args[len(args)-1]
+		if strings.HasPrefix(last, "-") && !strings.Contains(last, "=") && !strings.HasPrefix(toComplete, "-") {
+			flagName := strings.TrimLeft(last, "-")
+			if flagName == "cluster" {
+				return util.ResourceNameCompletionFunc(f, types.ClusterGVR())(cmd, nil, toComplete)
+			}
+			for _, param := range buildOpsDefinitionParams(schema) {
+				if param.flag == flagName {
+					return param.enum, cobra.ShellCompDirectiveNoFileComp
+				}
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		// complete the flags which are not specified
+		var comps []string
+		cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+			name := "--" + flag.Name
+			if flag.Hidden || !strings.HasPrefix(name, toComplete) || slices.Contains(args, name) {
+				return
 			}
-			return nil, err
+			comps = append(comps, fmt.Sprintf("%s\t%s", name, flag.Usage))
+		})
+		return comps, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// opsDefinitionParam is a parameter in the parametersSchema of the OpsDefinition, which is specified by the flag.
+type opsDefinitionParam struct {
+	flag         string
+	paramType    string
+	required     bool
+	defaultValue string
+	enum         []string
+	description  string
+}
+
+// buildOpsDefinitionParams flattens the parametersSchema to the parameters, the flag names are
+// built in the same way as flags.BuildFlagsBySchema.
+func buildOpsDefinitionParams(schema *v1.JSONSchemaProps) []opsDefinitionParam {
+	formatValue := func(raw []byte) string {
+		var val interface{}
+		if err := json.Unmarshal(raw, &val); err != nil {
+			return string(raw)
 		}
-		parametersSchema := opsDef.Spec.ParametersSchema
-		if parametersSchema == nil {
-			return nil, nil
+		return fmt.Sprintf("%v", val)
+	}
+	var params []opsDefinitionParam
+	var walk func(prefix string, isArray bool, props map[string]v1.JSONSchemaProps, required []string)
+	walk = func(prefix string, isArray bool, props map[string]v1.JSONSchemaProps, required []string) {
+		for name, prop := range props {
+			flagName := strcase.KebabCase(name)
+			if prefix != "" {
+				flagName = strcase.KebabCase(fmt.Sprintf("%s.%s", prefix, name))
+			}
+			paramType := prop.Type
+			if paramType == "" {
+				paramType = "string"
+			}
+			paramIsArray := isArray
+			switch {
+			case paramType == "object":
+				walk(flagName, isArray, prop.Properties, nil)
+				continue
+			case paramType == "array" && prop.Items != nil && prop.Items.Schema != nil:
+				if prop.Items.Schema.Type == "object" {
+					walk(flagName, true, prop.Items.Schema.Properties, nil)
+					continue
+				}
+				paramType = prop.Items.Schema.Type
+				prop.Enum = prop.Items.Schema.Enum
+				paramIsArray = true
+			}
+			param := opsDefinitionParam{
+				flag:        flagName,
+				paramType:   paramType,
+				required:    slices.Contains(required, name) && prop.Type != "array",
+				description: prop.Description,
+			}
+			if paramIsArray {
+				param.paramType = "[]" + paramType
+			}
+			if prop.Default != nil {
+				param.defaultValue = formatValue(prop.Default.Raw)
+			}
+			for _, e := range prop.Enum {
+				param.enum = append(param.enum, formatValue(e.Raw))
+			}
+			params = append(params, param)
 		}
-		o.SchemaProperties = parametersSchema.OpenAPIV3Schema
-		return parametersSchema.OpenAPIV3Schema, nil
+	}
+	walk("", false, schema.Properties, schema.Required)
+	slices.SortFunc(params, func(a, b opsDefinitionParam) bool {
+		return a.flag < b.flag
 	})
+	return params
+}
+
+// buildOpsDefinitionHelp builds the help of the parameters of the OpsDefinition.
+func buildOpsDefinitionHelp(opsDefName string, params []opsDefinitionParam) string {
+	if len(params) == 0 {
+		return fmt.Sprintf("The OpsDefinition %q has no parameter.", opsDefName)
+	}
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "The parameters of the OpsDefinition %q:\n\n", opsDefName)
+	tbl := printer.NewTablePrinter(buf)
+	tbl.SetHeader("FLAG", "TYPE", "REQUIRED", "DEFAULT", "DESCRIPTION")
+	for _, p := range params {
+		description := p.description
+		if len(p.enum) > 0 {
+			description = strings.TrimSpace(fmt.Sprintf("%s Legal values [%s].", description, strings.Join(p.enum, ", ")))
+		}
+		tbl.AddRow("--"+p.flag, p.paramType, p.required, p.defaultValue, description)
+	}
+	tbl.Print()
+	return buf.String()
 }
 
 func (o *customOperations) completeCustomSpec(cmd *cobra.Command) error {
 	param := map[string]string{}
 	// Construct config and credential map from flags
 	if o.SchemaProperties != nil {
-		fromFlags := flags.FlagsToValues(cmd.LocalNonPersistentFlags(), true)
-		for name := range o.SchemaProperties.Properties {
+		fromFlags := flags.FlagsToValues(cmd.LocalNonPersistentFlags(), false)
+		for name, prop := range o.SchemaProperties.Properties {
 			flagName := strcase.KebabCase(name)
-			if val, ok := fromFlags[flagName]; ok {
+			val, ok := fromFlags[flagName]
+			if !ok {
+				continue
+			}
+			// the parameters which are not specified are set to the default values declared in the schema
+			if cmd.Flags().Changed(flagName) || prop.Default != nil {
 				param[name] = val.String()
 			}
 		}
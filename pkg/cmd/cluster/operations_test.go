@@ -26,6 +26,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -104,6 +105,12 @@ var _ = Describe("operations", func() {
 						Properties: map[string]apiextensionsv1.JSONSchemaProps{
 							"p1": {Type: "string"},
 							"p2": {Type: "integer"},
+							"p3": {
+								Type:        "string",
+								Description: "the mode.",
+								Enum:        []apiextensionsv1.JSON{{Raw: []byte(`"fast"`)}, {Raw: []byte(`"safe"`)}},
+								Default:     &apiextensionsv1.JSON{Raw: []byte(`"safe"`)},
+							},
 						},
 						Required: []string{"p1"},
 					},
@@ -474,6 +481,34 @@ var _ = Describe("operations", func() {
 		Expect(customOperations.validateAndCompleteComponentName()).Should(Succeed())
 		Expect(customOperations.Component).Should(Equal(testing.ComponentName))
 
+		By("the parameters not specified are set to the default values")
+		Expect(customOperations.completeCustomSpec(cmd1)).Should(Succeed())
+		Expect(customOperations.Params[0]).Should(Equal(map[string]string{"p1": "test", "p3": "safe"}))
+
+		By("print the parameters of the opsDefinition in the help")
+		Expect(customOperations.parseOpsDefinitionAndParams(NewCustomOpsCmd(tf, streams), []string{"--help"})).Should(MatchError(pflag.ErrHelp))
+		helpCmd := NewCustomOpsCmd(tf, streams)
+		Expect(customOperations.parseOpsDefinitionAndParams(helpCmd, []string{opsDefName, "--help"})).Should(MatchError(pflag.ErrHelp))
+		Expect(helpCmd.Long).Should(MatchRegexp(`--p1\s+string\s+true`))
+		Expect(helpCmd.Long).Should(MatchRegexp(`--p3\s+string\s+false\s+safe\s+the mode. Legal values \[fast, safe\].`))
+		helpCmd = NewCustomOpsCmd(tf, streams)
+		Expect(customOperations.parseOpsDefinitionAndParams(helpCmd, []string{"--help", opsDefName})).Should(MatchError(pflag.ErrHelp))
+		Expect(customOperations.OpsDefinitionName).Should(Equal(opsDefName))
+		Expect(helpCmd.Long).Should(MatchRegexp(`--p1\s+string\s+true`))
+		helpCmd = NewCustomOpsCmd(tf, streams)
+		Expect(customOperations.parseOpsDefinitionAndParams(helpCmd, []string{"--cluster", clusterNameWithCompDef, "-h", opsDefName})).Should(MatchError(pflag.ErrHelp))
+		Expect(customOperations.OpsDefinitionName).Should(Equal(opsDefName))
+		Expect(helpCmd.Long).Should(MatchRegexp(`--p1\s+string\s+true`))
+
+		By("complete the flags and the enum values of the parameters")
+		compCmd := NewCustomOpsCmd(tf, streams)
+		comps, _ := compCmd.ValidArgsFunction(compCmd, []string{opsDefName, "--p1", "test"}, "--p")
+		Expect(comps).Should(HaveLen(2))
+		Expect(comps[0]).Should(HavePrefix("--p2\t"))
+		compCmd = NewCustomOpsCmd(tf, streams)
+		comps, _ = compCmd.ValidArgsFunction(compCmd, []string{opsDefName, "--p3"}, "")
+		Expect(comps).Should(Equal([]string{"fast", "safe"}))
+
 		By("expect to create custom ops successfully")
 		cmd2 := NewCustomOpsCmd(tf, streams)
 		done := testing.Capture()
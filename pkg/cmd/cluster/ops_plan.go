@@ -0,0 +1,521 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+	"github.com/apecloud/kbcli/pkg/util/prompt"
+)
+
+const (
+	// opsPlanLabelKey is the label of the OpsRequests and the progress ConfigMap of the ops plan
+	opsPlanLabelKey = "kbcli.kubeblocks.io/ops-plan"
+
+	opsPlanConfigMapPrefix = "kbcli-ops-plan-"
+	opsPlanFileKey         = "plan.yaml"
+	opsPlanProgressKey     = "progress.json"
+)
+
+// opsPlanFailurePolicy is the policy when the OpsRequest of a step fails.
+type opsPlanFailurePolicy string
+
+const (
+	// opsPlanStop stops the plan, it is the default policy
+	opsPlanStop opsPlanFailurePolicy = "Stop"
+	// opsPlanCancel cancels the running OpsRequest and stops the plan
+	opsPlanCancel opsPlanFailurePolicy = "Cancel"
+	// opsPlanContinue ignores the failure and continues with the next step
+	opsPlanContinue opsPlanFailurePolicy = "Continue"
+)
+
+type opsPlanStepPhase string
+
+const (
+	opsPlanStepPending opsPlanStepPhase = "Pending"
+	opsPlanStepRunning opsPlanStepPhase = "Running"
+	opsPlanStepSucceed opsPlanStepPhase = "Succeed"
+	opsPlanStepFailed  opsPlanStepPhase = "Failed"
+)
+
+// opsPlan is a sequence of OpsRequests run on a cluster one by one.
+type opsPlan struct {
+	Name    string        `json:"name"`
+	Cluster string        `json:"cluster"`
+	Steps   []opsPlanStep `json:"steps"`
+}
+
+type opsPlanStep struct {
+	Name string `json:"name"`
+	// Spec is the spec of the OpsRequest, the clusterRef is set to the cluster of the plan
+	Spec      appsv1alpha1.OpsRequestSpec `json:"spec"`
+	Gate      opsPlanGate                 `json:"gate,omitempty"`
+	OnFailure opsPlanFailurePolicy        `json:"onFailure,omitempty"`
+}
+
+// opsPlanGate controls when the plan moves on from a step.
+type opsPlanGate struct {
+	// Confirm requires an interactive confirmation before the OpsRequest is created
+	Confirm bool `json:"confirm,omitempty"`
+	// WaitForSucceed waits for the OpsRequest to succeed before the next step, defaults to true
+	WaitForSucceed *bool `json:"waitForSucceed,omitempty"`
+	// Timeout is the time to wait for the OpsRequest to succeed, defaults to --timeout
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// Delay is the time to wait after the step succeeds
+	Delay metav1.Duration `json:"delay,omitempty"`
+}
+
+// opsPlanStepStatus is the progress of a step persisted in the ConfigMap.
+type opsPlanStepStatus struct {
+	Name       string           `json:"name"`
+	OpsRequest string           `json:"opsRequest,omitempty"`
+	Phase      opsPlanStepPhase `json:"phase"`
+	Message    string           `json:"message,omitempty"`
+}
+
+var (
+	opsExample = templates.Examples(`
+		# run the ops plan
+		kbcli cluster ops run -f plan.yaml`)
+
+	opsRunExample = templates.Examples(`
+		# run the ops plan
+		kbcli cluster ops run -f plan.yaml
+
+		# resume the interrupted ops plan from the step where it stopped
+		kbcli cluster ops run -f plan.yaml --resume
+
+		# the ops plan backs up the cluster, upgrades it and then restarts the components one by one
+		# name: upgrade-mycluster
+		# cluster: mycluster
+		# steps:
+		# - name: backup
+		#   spec:
+		#     type: Backup
+		#     backupSpec:
+		#       backupMethod: xtrabackup
+		# - name: upgrade
+		#   spec:
+		#     type: Upgrade
+		#     upgrade:
+		#       clusterVersionRef: ac-mysql-8.0.30
+		#   gate:
+		#     confirm: true
+		#   onFailure: Cancel
+		# - name: restart-mysql
+		#   spec:
+		#     type: Restart
+		#     restart:
+		#     - componentName: mysql
+		#   gate:
+		#     delay: 5m
+		# - name: restart-proxy
+		#   spec:
+		#     type: Restart
+		#     restart:
+		#     - componentName: proxy
+		#   onFailure: Continue`)
+)
+
+// OpsPlanOptions is the options of the ops run command.
+type OpsPlanOptions struct {
+	Factory   cmdutil.Factory
+	Namespace string
+	Dynamic   dynamic.Interface
+	Client    kubernetes.Interface
+
+	// Filename is the ops plan, it can be a local file, a URL or "-" for stdin
+	Filename    string
+	Resume      bool
+	Timeout     time.Duration
+	AutoApprove bool
+
+	planData []byte
+	plan     *opsPlan
+	progress []opsPlanStepStatus
+	// sleep waits for the delay of the steps
+	sleep func(time.Duration)
+
+	genericiooptions.IOStreams
+}
+
+func NewOpsCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "ops",
+		Short:   "Run a multi-step ops plan.",
+		Example: opsExample,
+	}
+	cmd.AddCommand(newOpsRunCmd(f, streams))
+	return cmd
+}
+
+func newOpsRunCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &OpsPlanOptions{Factory: f, IOStreams: streams, sleep: time.Sleep}
+	cmd := &cobra.Command{
+		Use:   "run -f FILENAME",
+		Short: "Run the OpsRequests of an ops plan one by one.",
+		Long: templates.LongDesc(`
+			Run the OpsRequests of an ops plan one by one. Each step of the plan is the spec of an OpsRequest
+			with an optional gate, which requires a confirmation before the OpsRequest is created, waits for it
+			to succeed and waits for a delay after it, and an on-failure policy, which is one of Stop, Cancel
+			and Continue. The progress of the plan is persisted in a ConfigMap, so an interrupted plan can be
+			resumed by --resume.`),
+		Example: opsRunExample,
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete())
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVarP(&o.Filename, "filename", "f", "", "The ops plan to run, it can be a local file, a URL or '-' for stdin")
+	cmd.Flags().BoolVar(&o.Resume, "resume", false, "Resume the interrupted ops plan from the step where it stopped")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", defaultOpsWaitTimeout, "Time to wait for the OpsRequest of each step to succeed, it can be overridden by the gate of the step")
+	cmd.Flags().BoolVar(&o.AutoApprove, "auto-approve", false, "Skip the confirmations required by the gates of the steps")
+	util.CheckErr(cmd.MarkFlagRequired("filename"))
+	return cmd
+}
+
+func (o *OpsPlanOptions) Complete() error {
+	var err error
+	if o.Filename == "" {
+		return fmt.Errorf("missing ops plan, please specify it by the \"-f\" flag")
+	}
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	if o.Client, err = o.Factory.KubernetesClientSet(); err != nil {
+		return err
+	}
+	if o.planData, err = MultipleSourceComponents(o.Filename, o.In); err != nil {
+		return err
+	}
+	o.plan = &opsPlan{}
+	if err = yaml.UnmarshalStrict(o.planData, o.plan); err != nil {
+		return fmt.Errorf("failed to parse the ops plan: %v", err)
+	}
+	return nil
+}
+
+func (o *OpsPlanOptions) Validate() error {
+	if o.plan.Name == "" {
+		return fmt.Errorf("missing the name of the ops plan")
+	}
+	if errs := validation.IsDNS1123Subdomain(opsPlanConfigMapPrefix + o.plan.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name of the ops plan %q: %v", o.plan.Name, errs)
+	}
+	if o.plan.Cluster == "" {
+		return fmt.Errorf("missing the cluster of the ops plan")
+	}
+	if len(o.plan.Steps) == 0 {
+		return fmt.Errorf("the ops plan has no step")
+	}
+	names := map[string]bool{}
+	for i, step := range o.plan.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("missing the name of step %d", i+1)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("duplicated step %s", step.Name)
+		}
+		names[step.Name] = true
+		if step.Spec.Type == "" {
+			return fmt.Errorf("missing the OpsRequest type of step %s", step.Name)
+		}
+		switch step.OnFailure {
+		case "", opsPlanStop, opsPlanCancel, opsPlanContinue:
+		default:
+			return fmt.Errorf("invalid onFailure %q of step %s, it should be one of %s, %s and %s",
+				step.OnFailure, step.Name, opsPlanStop, opsPlanCancel, opsPlanContinue)
+		}
+	}
+	_, err := cluster.GetClusterByName(o.Dynamic, o.plan.Cluster, o.Namespace)
+	return err
+}
+
+func (o *OpsPlanOptions) Run() error {
+	if err := o.loadProgress(); err != nil {
+		return err
+	}
+	var runErr error
+	for i := range o.plan.Steps {
+		step, status := &o.plan.Steps[i], &o.progress[i]
+		if status.Phase == opsPlanStepSucceed || (status.Phase == opsPlanStepFailed && step.OnFailure == opsPlanContinue) {
+			continue
+		}
+		fmt.Fprintf(o.Out, "Step %d/%d %s:\n", i+1, len(o.plan.Steps), step.Name)
+		if runErr = o.runStep(step, status); runErr == nil {
+			continue
+		}
+		// the confirmation is declined or the progress fails to be saved if the step is not failed
+		if status.Phase == opsPlanStepFailed && step.OnFailure == opsPlanContinue {
+			fmt.Fprintf(o.Out, "%v, continue with the next step\n", runErr)
+			runErr = nil
+			continue
+		}
+		break
+	}
+	o.printProgress()
+	if runErr != nil {
+		return runErr
+	}
+	for _, status := range o.progress {
+		if status.Phase == opsPlanStepFailed {
+			return fmt.Errorf("ops plan %s is completed with failed steps", o.plan.Name)
+		}
+	}
+	return nil
+}
+
+// runStep creates the OpsRequest of the step, or waits for the OpsRequest created before the plan was interrupted.
+func (o *OpsPlanOptions) runStep(step *opsPlanStep, status *opsPlanStepStatus) error {
+	if status.Phase != opsPlanStepRunning || status.OpsRequest == "" {
+		if step.Gate.Confirm && !o.AutoApprove {
+			msg := fmt.Sprintf("Step %s will create a %s OpsRequest for cluster %s", step.Name, step.Spec.Type, o.plan.Cluster)
+			if err := prompt.Confirm(nil, o.In, msg, `Please type "yes" to confirm:`); err != nil {
+				return err
+			}
+		}
+		status.OpsRequest = fmt.Sprintf("%s-%s-%s", o.plan.Cluster, step.Name, rand.String(5))
+		status.Phase = opsPlanStepRunning
+		status.Message = ""
+		// the name is persisted before the OpsRequest is created, so it is not created twice on resuming
+		if err := o.saveProgress(); err != nil {
+			return err
+		}
+	}
+
+	err := o.ensureOpsRequest(step, status.OpsRequest)
+	if err == nil && (step.Gate.WaitForSucceed == nil || *step.Gate.WaitForSucceed) {
+		timeout := o.Timeout
+		if step.Gate.Timeout.Duration > 0 {
+			timeout = step.Gate.Timeout.Duration
+		}
+		err = newOpsRequestWaiter(o.Dynamic, o.Client, o.Namespace, status.OpsRequest, timeout, o.Out).wait()
+	}
+	if err != nil {
+		status.Phase = opsPlanStepFailed
+		status.Message = err.Error()
+		if step.OnFailure == opsPlanCancel {
+			if cancelErr := o.cancelOpsRequest(status.OpsRequest); cancelErr != nil {
+				status.Message += fmt.Sprintf(", failed to cancel it: %v", cancelErr)
+			} else {
+				status.Message += ", cancelled"
+			}
+		}
+		if saveErr := o.saveProgress(); saveErr != nil {
+			return saveErr
+		}
+		return fmt.Errorf("step %s failed: %s", step.Name, status.Message)
+	}
+
+	if step.Gate.Delay.Duration > 0 {
+		fmt.Fprintf(o.Out, "Waiting for %s before the next step\n", step.Gate.Delay.Duration)
+		o.sleep(step.Gate.Delay.Duration)
+	}
+	status.Phase = opsPlanStepSucceed
+	return o.saveProgress()
+}
+
+// ensureOpsRequest creates the OpsRequest of the step if it does not exist.
+func (o *OpsPlanOptions) ensureOpsRequest(step *opsPlanStep, name string) error {
+	_, err := o.Dynamic.Resource(types.OpsGVR()).Namespace(o.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+	ops := &appsv1alpha1.OpsRequest{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: fmt.Sprintf("%s/%s", types.AppsAPIGroup, types.AppsAPIVersion),
+			Kind:       types.KindOps,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: o.Namespace,
+			Labels: map[string]string{
+				constant.AppInstanceLabelKey: o.plan.Cluster,
+				opsPlanLabelKey:              o.plan.Name,
+			},
+		},
+		Spec: *step.Spec.DeepCopy(),
+	}
+	ops.Spec.ClusterRef = o.plan.Cluster
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ops)
+	if err != nil {
+		return err
+	}
+	if _, err = o.Dynamic.Resource(types.OpsGVR()).Namespace(o.Namespace).Create(context.TODO(), &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "OpsRequest %s created\n", name)
+	return nil
+}
+
+func (o *OpsPlanOptions) cancelOpsRequest(name string) error {
+	if name == "" {
+		return nil
+	}
+	ops := &OperationsOptions{
+		autoApprove: true,
+		CreateOptions: action.CreateOptions{
+			Dynamic:   o.Dynamic,
+			Namespace: o.Namespace,
+			Name:      name,
+			GVR:       types.OpsGVR(),
+			IOStreams: o.IOStreams,
+		},
+	}
+	return cancelOps(ops)
+}
+
+func (o *OpsPlanOptions) configMapName() string {
+	return opsPlanConfigMapPrefix + o.plan.Name
+}
+
+// loadProgress loads the progress of the plan from the ConfigMap, or starts a new one.
+func (o *OpsPlanOptions) loadProgress() error {
+	cm, err := o.Client.CoreV1().ConfigMaps(o.Namespace).Get(context.TODO(), o.configMapName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if apierrors.IsNotFound(err) {
+		if o.Resume {
+			return fmt.Errorf("no progress of ops plan %s is found to resume", o.plan.Name)
+		}
+		o.resetProgress()
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      o.configMapName(),
+				Namespace: o.Namespace,
+				Labels: map[string]string{
+					constant.AppManagedByLabelKey: "kbcli",
+					constant.AppInstanceLabelKey:  o.plan.Cluster,
+					opsPlanLabelKey:               o.plan.Name,
+				},
+			},
+		}
+		if cm.Data, err = o.progressData(); err != nil {
+			return err
+		}
+		_, err = o.Client.CoreV1().ConfigMaps(o.Namespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+		return err
+	}
+
+	var progress []opsPlanStepStatus
+	if err = json.Unmarshal([]byte(cm.Data[opsPlanProgressKey]), &progress); err != nil {
+		return fmt.Errorf("failed to parse the progress of ops plan %s: %v", o.plan.Name, err)
+	}
+	changed := len(progress) != len(o.plan.Steps) || !bytes.Equal([]byte(cm.Data[opsPlanFileKey]), o.planData)
+	// the progress is checked against the plan it was run with, which may have different steps
+	started := &opsPlan{}
+	if err = yaml.Unmarshal([]byte(cm.Data[opsPlanFileKey]), started); err != nil || len(started.Steps) != len(progress) {
+		started = &opsPlan{Steps: make([]opsPlanStep, len(progress))}
+	}
+	completed := true
+	for i, status := range progress {
+		if status.Phase != opsPlanStepSucceed && !(status.Phase == opsPlanStepFailed && started.Steps[i].OnFailure == opsPlanContinue) {
+			completed = false
+		}
+	}
+	switch {
+	case o.Resume && changed:
+		return fmt.Errorf("ops plan %s is changed since it was started, it can not be resumed", o.plan.Name)
+	case changed && !completed:
+		return fmt.Errorf("ops plan %s is changed since it was started and it is not completed, please restore the plan and run with --resume, or delete the ConfigMap %s to run it from the beginning", o.plan.Name, o.configMapName())
+	case o.Resume:
+		o.progress = progress
+		return nil
+	case !completed:
+		return fmt.Errorf("ops plan %s is not completed, run with --resume to resume it", o.plan.Name)
+	default:
+		// the completed plan is run again from the first step
+		o.resetProgress()
+		return o.saveProgress()
+	}
+}
+
+func (o *OpsPlanOptions) resetProgress() {
+	o.progress = make([]opsPlanStepStatus, len(o.plan.Steps))
+	for i, step := range o.plan.Steps {
+		o.progress[i] = opsPlanStepStatus{Name: step.Name, Phase: opsPlanStepPending}
+	}
+}
+
+func (o *OpsPlanOptions) progressData() (map[string]string, error) {
+	progress, err := json.Marshal(o.progress)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		opsPlanFileKey:     string(o.planData),
+		opsPlanProgressKey: string(progress),
+	}, nil
+}
+
+func (o *OpsPlanOptions) saveProgress() error {
+	cm, err := o.Client.CoreV1().ConfigMaps(o.Namespace).Get(context.TODO(), o.configMapName(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if cm.Data, err = o.progressData(); err != nil {
+		return err
+	}
+	_, err = o.Client.CoreV1().ConfigMaps(o.Namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	return err
+}
+
+func (o *OpsPlanOptions) printProgress() {
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("STEP", "TYPE", "OPS-REQUEST", "PHASE", "MESSAGE")
+	for i, status := range o.progress {
+		tbl.AddRow(status.Name, o.plan.Steps[i].Spec.Type, util.CheckEmpty(status.OpsRequest), status.Phase, status.Message)
+	}
+	tbl.Print()
+}
@@ -0,0 +1,172 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+)
+
+var _ = Describe("ops plan", func() {
+	const planTemplate = `
+name: test-plan
+cluster: fake-cluster-name
+steps:
+- name: restart
+  spec:
+    type: Restart
+    restart:
+    - componentName: fake-component-name
+- name: vscale
+  spec:
+    type: VerticalScaling
+    verticalScaling:
+    - componentName: fake-component-name
+      requests:
+        cpu: "1"
+  onFailure: ON_FAILURE
+- name: restart-again
+  spec:
+    type: Restart
+    restart:
+    - componentName: fake-component-name
+  gate:
+    waitForSucceed: false
+    delay: 1m
+`
+	var (
+		streams genericiooptions.IOStreams
+		tf      *cmdtesting.TestFactory
+		// failedSteps are the steps whose OpsRequests fail
+		failedSteps map[string]bool
+		sleeps      []time.Duration
+	)
+
+	BeforeEach(func() {
+		streams, _, _, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace))
+		// the OpsRequests are completed once they are created
+		failedSteps = map[string]bool{}
+		sleeps = nil
+		tf.FakeDynamicClient.PrependReactor("create", "opsrequests", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+			phase := appsv1alpha1.OpsSucceedPhase
+			for step := range failedSteps {
+				if strings.HasPrefix(obj.GetName(), testing.ClusterName+"-"+step+"-") {
+					phase = appsv1alpha1.OpsFailedPhase
+				}
+			}
+			Expect(unstructured.SetNestedField(obj.Object, string(phase), "status", "phase")).Should(Succeed())
+			return false, nil, nil
+		})
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	newOptions := func(onFailure opsPlanFailurePolicy) *OpsPlanOptions {
+		file := filepath.Join(GinkgoT().TempDir(), "plan.yaml")
+		Expect(os.WriteFile(file, []byte(strings.Replace(planTemplate, "ON_FAILURE", string(onFailure), 1)), 0644)).Should(Succeed())
+		o := &OpsPlanOptions{Factory: tf, IOStreams: streams, Filename: file, Timeout: time.Minute,
+			sleep: func(d time.Duration) { sleeps = append(sleeps, d) }}
+		Expect(o.Complete()).Should(Succeed())
+		return o
+	}
+
+	phases := func(o *OpsPlanOptions) []opsPlanStepPhase {
+		cm, err := o.Client.CoreV1().ConfigMaps(testing.Namespace).Get(context.TODO(), "kbcli-ops-plan-test-plan", metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		var progress []opsPlanStepStatus
+		Expect(json.Unmarshal([]byte(cm.Data[opsPlanProgressKey]), &progress)).Should(Succeed())
+		var res []opsPlanStepPhase
+		for _, status := range progress {
+			res = append(res, status.Phase)
+		}
+		return res
+	}
+
+	It("ops command", func() {
+		Expect(NewOpsCmd(tf, streams)).ShouldNot(BeNil())
+		o := newOptions("Retry")
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("invalid onFailure")))
+		o = newOptions(opsPlanStop)
+		o.plan.Steps[1].Name = "restart"
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("duplicated step")))
+	})
+
+	It("continue on failure", func() {
+		failedSteps["vscale"] = true
+		o := newOptions(opsPlanContinue)
+		Expect(o.Validate()).Should(Succeed())
+		o.Client = testing.FakeClientSet()
+		Expect(o.Run()).Should(MatchError(ContainSubstring("completed with failed steps")))
+		Expect(phases(o)).Should(Equal([]opsPlanStepPhase{opsPlanStepSucceed, opsPlanStepFailed, opsPlanStepSucceed}))
+		Expect(sleeps).Should(Equal([]time.Duration{time.Minute}))
+	})
+
+	It("stop on failure and resume", func() {
+		failedSteps["vscale"] = true
+		o := newOptions(opsPlanStop)
+		client := testing.FakeClientSet()
+		o.Client = client
+		Expect(o.Run()).Should(MatchError(ContainSubstring("step vscale failed")))
+		Expect(phases(o)).Should(Equal([]opsPlanStepPhase{opsPlanStepSucceed, opsPlanStepFailed, opsPlanStepPending}))
+
+		By("the plan can not be run again before it is completed")
+		o = newOptions(opsPlanStop)
+		o.Client = client
+		Expect(o.Run()).Should(MatchError(ContainSubstring("run with --resume")))
+
+		By("the plan with fewer steps is a changed plan")
+		shorter := newOptions(opsPlanStop)
+		shorter.Client = client
+		shorter.plan.Steps = shorter.plan.Steps[:1]
+		Expect(shorter.Run()).Should(MatchError(ContainSubstring("is changed since it was started and it is not completed")))
+		shorter.Resume = true
+		Expect(shorter.Run()).Should(MatchError(ContainSubstring("it can not be resumed")))
+
+		By("resume the plan from the failed step")
+		delete(failedSteps, "vscale")
+		o.Resume = true
+		Expect(o.Run()).Should(Succeed())
+		Expect(phases(o)).Should(Equal([]opsPlanStepPhase{opsPlanStepSucceed, opsPlanStepSucceed, opsPlanStepSucceed}))
+	})
+})
@@ -0,0 +1,362 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/podutils"
+
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	"github.com/apecloud/kubeblocks/pkg/lorry/engines/models"
+	"github.com/apecloud/kubeblocks/pkg/lorry/engines/register"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/util"
+	"github.com/apecloud/kbcli/pkg/util/prompt"
+)
+
+// promoteOptions are the options to choose the instance to promote interactively.
+type promoteOptions struct {
+	// Interactive lists the instances of the component and asks for the instance to promote
+	Interactive bool
+	// Force promotes the instance which is not ready or on the same node as the current primary
+	Force bool
+
+	// execInPod runs the command in the container of the pod and returns the stdout
+	execInPod func(pod *corev1.Pod, container string, command []string) (string, error)
+}
+
+// replicationPositionProbe gets the replication position of an instance by appending the arguments
+// to the connect command of the engine, the instance with the greater position is more up to date.
+type replicationPositionProbe struct {
+	args  string
+	unit  string
+	parse func(output string) (uint64, error)
+}
+
+var (
+	mysqlPositionProbe = replicationPositionProbe{
+		args:  `-N -s -e "SELECT @@GLOBAL.gtid_executed"`,
+		unit:  "transactions",
+		parse: parseGTIDSetSize,
+	}
+	postgresPositionProbe = replicationPositionProbe{
+		args:  `-A -t -c "SELECT COALESCE(pg_last_wal_replay_lsn(), pg_current_wal_lsn())"`,
+		unit:  "bytes",
+		parse: parseLSN,
+	}
+
+	// replicationPositionProbes are the probes of the engines, keyed by the character type
+	replicationPositionProbes = map[string]replicationPositionProbe{
+		string(models.MySQL):              mysqlPositionProbe,
+		string(models.WeSQL):              mysqlPositionProbe,
+		string(models.PolarDBX):           mysqlPositionProbe,
+		string(models.PostgreSQL):         postgresPositionProbe,
+		string(models.OfficialPostgreSQL): postgresPositionProbe,
+		string(models.ApecloudPostgreSQL): postgresPositionProbe,
+	}
+)
+
+// promoteCandidate is an instance of the component to promote.
+type promoteCandidate struct {
+	name    string
+	role    string
+	node    string
+	ready   bool
+	primary bool
+	// position is the replication position, it is nil if it can not be gathered
+	position *uint64
+	lag      string
+	// refused is the reason why the instance can not be promoted without --force
+	refused string
+}
+
+// selectPromoteCandidate lists the instances of the component with their roles, readiness, nodes and
+// replication lags, recommends the most up-to-date healthy follower and asks for the instance to promote.
+func (o *OperationsOptions) selectPromoteCandidate() error {
+	if o.Instance != "" {
+		return fmt.Errorf("--instance can not be specified with --interactive")
+	}
+	clusterObj, err := cluster.GetClusterByName(o.Dynamic, o.Name, o.Namespace)
+	if err != nil {
+		return err
+	}
+	if o.Component == "" {
+		if len(clusterObj.Spec.ComponentSpecs) != 1 {
+			return fmt.Errorf("there are multiple components in cluster, please use --component to specify the component for promote")
+		}
+		o.Component = clusterObj.Spec.ComponentSpecs[0].Name
+	}
+	candidates, err := o.getPromoteCandidates(clusterObj.Spec.ClusterDefRef, clusterObj.Spec.GetComponentDefRefName(o.Component))
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no instance found in the component %s", o.Component)
+	}
+
+	recommended := recommendPromoteCandidate(candidates)
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("INSTANCE", "ROLE", "STATUS", "NODE", "LAG", "NOTE")
+	for _, c := range candidates {
+		status := "Ready"
+		if !c.ready {
+			status = "NotReady"
+		}
+		note := c.refused
+		switch {
+		case c.primary:
+			note = "current primary"
+		case recommended != nil && c.name == recommended.name:
+			note = "recommended"
+		}
+		tbl.AddRow(c.name, c.role, status, c.node, c.lag, note)
+	}
+	tbl.Print()
+
+	label := "Please type the name of the instance to promote"
+	if recommended != nil {
+		label = fmt.Sprintf("%s (default %s)", label, recommended.name)
+	}
+	var selected *promoteCandidate
+	_, err = prompt.NewPrompt(label+":", func(input string) error {
+		input = strings.TrimSpace(input)
+		if input == "" {
+			if recommended == nil {
+				return fmt.Errorf("no instance is recommended, please type the name of the instance")
+			}
+			selected = recommended
+			return nil
+		}
+		for i := range candidates {
+			if candidates[i].name == input {
+				selected = &candidates[i]
+				return nil
+			}
+		}
+		return fmt.Errorf("instance %s not found in the component %s", input, o.Component)
+	}, o.In).Run()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case selected.primary:
+		return fmt.Errorf("instance %s cannot be promoted because it is already the primary or leader", selected.name)
+	case selected.refused != "" && !o.Force:
+		return fmt.Errorf("instance %s cannot be promoted because it is %s, use --force to promote it anyway", selected.name, selected.refused)
+	}
+	o.Instance = selected.name
+	return nil
+}
+
+// getPromoteCandidates gets the instances of the component, the replication positions are gathered by
+// the connect command of the engine if the engine is supported.
+func (o *OperationsOptions) getPromoteCandidates(clusterDefName, compDefName string) ([]promoteCandidate, error) {
+	selector := util.BuildComponentNameLabels(util.BuildLabelSelectorByNames("", []string{o.Name}), []string{o.Component})
+	pods, err := o.Client.CoreV1().Pods(o.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	var characterType string
+	if clusterDefName != "" {
+		clusterDef, err := cluster.GetClusterDefByName(o.Dynamic, clusterDefName)
+		if err != nil {
+			return nil, err
+		}
+		if compDef := clusterDef.GetComponentDefByName(compDefName); compDef != nil {
+			characterType = compDef.CharacterType
+		}
+	}
+	probe, supported := replicationPositionProbes[characterType]
+
+	var primaryNodes []string
+	candidates := make([]promoteCandidate, 0, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		role := pod.Labels[constant.RoleLabelKey]
+		c := promoteCandidate{
+			name:    pod.Name,
+			role:    role,
+			node:    pod.Spec.NodeName,
+			ready:   podutils.IsPodReady(pod),
+			primary: role == constant.Primary || role == constant.Leader,
+			lag:     "<unknown>",
+		}
+		if c.primary {
+			primaryNodes = append(primaryNodes, c.node)
+		}
+		if supported && c.ready {
+			if c.position, err = o.getReplicationPosition(pod, characterType, probe); err != nil {
+				fmt.Fprintf(o.ErrOut, "failed to get the replication position of instance %s: %s\n", pod.Name, err.Error())
+			}
+		}
+		candidates = append(candidates, c)
+	}
+
+	// the lag is relative to the current primary, or the most up-to-date instance if the primary is unavailable
+	var reference *uint64
+	for i := range candidates {
+		c := &candidates[i]
+		if c.position != nil && (c.primary || reference == nil || *c.position > *reference) {
+			reference = c.position
+			if c.primary {
+				break
+			}
+		}
+	}
+	for i := range candidates {
+		c := &candidates[i]
+		switch {
+		case c.position == nil:
+		case *c.position >= *reference:
+			c.lag = "0"
+		default:
+			c.lag = fmt.Sprintf("%d %s", *reference-*c.position, probe.unit)
+		}
+		if c.primary {
+			continue
+		}
+		switch {
+		case !c.ready:
+			c.refused = "not ready"
+		case c.node != "" && slices.Contains(primaryNodes, c.node):
+			c.refused = "on the same node as the primary"
+		}
+	}
+	return candidates, nil
+}
+
+// getReplicationPosition runs the probe in the instance by the connect command of the engine.
+func (o *OperationsOptions) getReplicationPosition(pod *corev1.Pod, characterType string, probe replicationPositionProbe) (*uint64, error) {
+	engine, err := register.NewClusterCommands(characterType)
+	if err != nil {
+		return nil, err
+	}
+	command := engine.ConnectCommand(nil)
+	command[len(command)-1] = fmt.Sprintf("%s %s", command[len(command)-1], probe.args)
+	output, err := o.execInPod(pod, engine.Container(), command)
+	if err != nil {
+		return nil, err
+	}
+	position, err := probe.parse(strings.TrimSpace(output))
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+// newInstanceCommandExecutor returns the function which runs the command in the container of the pod and returns the stdout.
+func newInstanceCommandExecutor(f cmdutil.Factory) func(pod *corev1.Pod, container string, command []string) (string, error) {
+	return func(pod *corev1.Pod, container string, command []string) (string, error) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		execOptions := action.NewExecOptions(f, genericiooptions.IOStreams{Out: stdout, ErrOut: stderr})
+		execOptions.Stdin = false
+		execOptions.TTY = false
+		execOptions.Quiet = true
+		if err := execOptions.Complete(); err != nil {
+			return "", err
+		}
+		execOptions.Pod = pod
+		execOptions.ContainerName = container
+		execOptions.Command = command
+		if err := execOptions.Run(); err != nil {
+			return "", fmt.Errorf("%s, %s", err.Error(), strings.TrimSpace(stderr.String()))
+		}
+		return stdout.String(), nil
+	}
+}
+
+// recommendPromoteCandidate recommends the most up-to-date instance which can be promoted.
+func recommendPromoteCandidate(candidates []promoteCandidate) *promoteCandidate {
+	var recommended *promoteCandidate
+	for i := range candidates {
+		c := &candidates[i]
+		if c.primary || c.refused != "" {
+			continue
+		}
+		if recommended == nil || (c.position != nil && (recommended.position == nil || *c.position > *recommended.position)) {
+			recommended = c
+		}
+	}
+	return recommended
+}
+
+// parseGTIDSetSize parses the number of the transactions in the GTID set, such as
+// "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11-18,2174B383-5441-11E8-B90A-C80AA9429562:1-3".
+func parseGTIDSetSize(gtidSet string) (uint64, error) {
+	var size uint64
+	gtidSet = strings.Join(strings.Fields(gtidSet), "")
+	if gtidSet == "" {
+		return 0, nil
+	}
+	for _, set := range strings.Split(gtidSet, ",") {
+		intervals := strings.Split(set, ":")
+		for _, interval := range intervals[1:] {
+			bounds := strings.SplitN(interval, "-", 2)
+			start, err := strconv.ParseUint(bounds[0], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid GTID set %s", gtidSet)
+			}
+			end := start
+			if len(bounds) == 2 {
+				if end, err = strconv.ParseUint(bounds[1], 10, 64); err != nil {
+					return 0, fmt.Errorf("invalid GTID set %s", gtidSet)
+				}
+			}
+			size += end - start + 1
+		}
+	}
+	return size, nil
+}
+
+// parseLSN parses the PostgreSQL log sequence number such as "0/3000148" to the WAL position in bytes.
+func parseLSN(lsn string) (uint64, error) {
+	parts := strings.Split(lsn, "/")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid LSN %s", lsn)
+	}
+	high, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %s", lsn)
+	}
+	low, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %s", lsn)
+	}
+	return high<<32 | low, nil
+}
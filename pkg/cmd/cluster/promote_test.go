@@ -0,0 +1,133 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+)
+
+var _ = Describe("promote interactively", func() {
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+		// gtidSets are the GTID sets executed by the instances
+		gtidSets map[string]string
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace), testing.FakeClusterDef())
+		gtidSets = map[string]string{}
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	newOptions := func(input string, readyPods int) *OperationsOptions {
+		o := newBaseOperationsOptions(tf, streams, appsv1alpha1.SwitchoverType, false)
+		o.Args = []string{testing.ClusterName}
+		Expect(o.Complete()).Should(Succeed())
+		pods := testing.FakePods(4, testing.Namespace, testing.ClusterName)
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			pod.Spec.NodeName = fmt.Sprintf("node-%d", i)
+			if i < readyPods {
+				pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			}
+		}
+		// pod-2 is on the same node as the leader
+		pods.Items[2].Spec.NodeName = "node-0"
+		o.Client = testing.FakeClientSet(pods)
+		o.In = io.NopCloser(strings.NewReader(input + "\n"))
+		o.Component = testing.ComponentName
+		o.execInPod = func(pod *corev1.Pod, container string, command []string) (string, error) {
+			Expect(command[len(command)-1]).Should(ContainSubstring("gtid_executed"))
+			return gtidSets[pod.Name], nil
+		}
+		gtidSets = map[string]string{
+			"fake-cluster-name-pod-0": "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-100",
+			"fake-cluster-name-pod-1": "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-90",
+			"fake-cluster-name-pod-2": "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-100",
+			"fake-cluster-name-pod-3": "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-99",
+		}
+		return o
+	}
+
+	It("parse the replication positions", func() {
+		size, err := parseGTIDSetSize("3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11-18,\n2174B383-5441-11E8-B90A-C80AA9429562:1-3:7")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(size).Should(Equal(uint64(17)))
+		_, err = parseGTIDSetSize("3E11FA47-71CA-11E1-9E33-C80AA9429562:a-5")
+		Expect(err).Should(HaveOccurred())
+
+		position, err := parseLSN("1/3000148")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(position).Should(Equal(uint64(1<<32 + 0x3000148)))
+		_, err = parseLSN("3000148")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("recommend the most up-to-date healthy follower", func() {
+		o := newOptions("", 4)
+		Expect(o.selectPromoteCandidate()).Should(Succeed())
+		Expect(o.Instance).Should(Equal("fake-cluster-name-pod-3"))
+		Expect(out.String()).Should(MatchRegexp(`fake-cluster-name-pod-1\s+follower\s+Ready\s+node-1\s+10 transactions`))
+		Expect(out.String()).Should(MatchRegexp(`fake-cluster-name-pod-2\s+follower\s+Ready\s+node-0\s+0\s+on the same node as the primary`))
+		Expect(out.String()).Should(MatchRegexp(`fake-cluster-name-pod-3\s+follower\s+Ready\s+node-3\s+1 transactions\s+recommended`))
+
+		By("the instance can not be specified with --interactive")
+		Expect(o.selectPromoteCandidate()).Should(MatchError(ContainSubstring("--instance can not be specified")))
+	})
+
+	It("refuse the unhealthy candidates unless --force is specified", func() {
+		o := newOptions("fake-cluster-name-pod-2", 4)
+		Expect(o.selectPromoteCandidate()).Should(MatchError(ContainSubstring("on the same node as the primary")))
+
+		o = newOptions("fake-cluster-name-pod-3", 3)
+		Expect(o.selectPromoteCandidate()).Should(MatchError(ContainSubstring("not ready")))
+		Expect(out.String()).Should(MatchRegexp(`fake-cluster-name-pod-3\s+follower\s+NotReady\s+node-3\s+<unknown>`))
+
+		o = newOptions("fake-cluster-name-pod-2", 4)
+		o.Force = true
+		Expect(o.selectPromoteCandidate()).Should(Succeed())
+		Expect(o.Instance).Should(Equal("fake-cluster-name-pod-2"))
+
+		o = newOptions("fake-cluster-name-pod-0", 4)
+		Expect(o.selectPromoteCandidate()).Should(MatchError(ContainSubstring("already the primary or leader")))
+	})
+})
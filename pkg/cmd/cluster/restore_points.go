@@ -0,0 +1,374 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/yaml"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	dptypes "github.com/apecloud/kubeblocks/pkg/dataprotection/types"
+
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var listRestorePointsExample = templates.Examples(`
+	# list the times the cluster mycluster can be restored to
+	kbcli cluster list-restore-points mycluster
+
+	# output the restore points in JSON format
+	kbcli cluster list-restore-points mycluster -o json`)
+
+const (
+	restoreWindowFull       = "Full"
+	restoreWindowContinuous = "Continuous"
+
+	// timelineWidth is the number of the columns of the ASCII timeline
+	timelineWidth = 60
+)
+
+// restoreWindow is a time range the cluster can be restored to. A full backup can only be restored to its
+// end time, and a continuous backup can be restored to any time after the end of its base full backup.
+type restoreWindow struct {
+	Type   string `json:"type"`
+	Backup string `json:"backup"`
+	// BaseBackup is the full backup the continuous backup is applied to
+	BaseBackup string    `json:"baseBackup,omitempty"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+}
+
+// timeRange is a range of time which can not be restored to.
+type timeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// restoreTimeline merges the full backups and the continuous backups of a cluster.
+type restoreTimeline struct {
+	Cluster   string          `json:"cluster"`
+	Namespace string          `json:"namespace"`
+	Windows   []restoreWindow `json:"windows"`
+	Gaps      []timeRange     `json:"gaps"`
+}
+
+type ListRestorePointsOptions struct {
+	Factory   cmdutil.Factory
+	Dynamic   dynamic.Interface
+	Namespace string
+	Name      string
+	Format    printer.Format
+
+	genericiooptions.IOStreams
+}
+
+func NewListRestorePointsCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &ListRestorePointsOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:   "list-restore-points NAME",
+		Short: "List the times the cluster can be restored to, merged from the full and continuous backups.",
+		Long: templates.LongDesc(`
+			List the times the cluster can be restored to. A full backup can be restored to the time it is
+			completed, and a continuous backup can be restored to any time after the full backup it is based on.
+			The times which can not be restored to are listed as gaps.`),
+		Example:           listRestorePointsExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	printer.AddOutputFlag(cmd, &o.Format)
+	return cmd
+}
+
+func (o *ListRestorePointsOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return makeMissingClusterNameErr()
+	}
+	o.Name = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	o.Dynamic, err = o.Factory.DynamicClient()
+	return err
+}
+
+func (o *ListRestorePointsOptions) Run() error {
+	timeline, err := getRestoreTimeline(o.Dynamic, o.Namespace, o.Name)
+	if err != nil {
+		return err
+	}
+	switch o.Format {
+	case printer.JSON:
+		data, err := json.MarshalIndent(timeline, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.Out, string(data))
+	case printer.YAML:
+		data, err := yaml.Marshal(timeline)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(o.Out, string(data))
+	default:
+		if len(timeline.Windows) == 0 {
+			fmt.Fprintf(o.Out, "No restore point found in cluster %s\n", o.Name)
+			return nil
+		}
+		timeline.print(o.Out)
+	}
+	return nil
+}
+
+// getRestoreTimeline lists the backups of the cluster and builds the restore timeline.
+func getRestoreTimeline(dynamic dynamic.Interface, namespace, cluster string) (*restoreTimeline, error) {
+	objs, err := dynamic.Resource(types.BackupGVR()).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", constant.AppInstanceLabelKey, cluster),
+	})
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]dpv1alpha1.Backup, 0, len(objs.Items))
+	for _, obj := range objs.Items {
+		backup := dpv1alpha1.Backup{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &backup); err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+	timeline := buildRestoreTimeline(backups)
+	timeline.Cluster = cluster
+	timeline.Namespace = namespace
+	return timeline, nil
+}
+
+// buildRestoreTimeline builds the restore windows of the completed full backups and the continuous backups,
+// a continuous backup is recoverable from the end of the earliest full backup completed within its time range.
+func buildRestoreTimeline(backups []dpv1alpha1.Backup) *restoreTimeline {
+	var full, continuous []restoreWindow
+	for i := range backups {
+		backup := &backups[i]
+		start, end := backup.GetStartTime(), backup.GetEndTime()
+		if backup.Labels[dptypes.BackupTypeLabelKey] == string(dpv1alpha1.BackupTypeContinuous) {
+			if backup.Status.TimeRange == nil || start == nil || end == nil || backup.Status.Phase == dpv1alpha1.BackupPhaseFailed {
+				continue
+			}
+			continuous = append(continuous, restoreWindow{Type: restoreWindowContinuous, Backup: backup.Name, Start: start.Time, End: end.Time})
+			continue
+		}
+		if backup.Status.Phase != dpv1alpha1.BackupPhaseCompleted || end == nil {
+			continue
+		}
+		full = append(full, restoreWindow{Type: restoreWindowFull, Backup: backup.Name, Start: end.Time, End: end.Time})
+	}
+	sortRestoreWindows(full)
+
+	timeline := &restoreTimeline{Windows: append([]restoreWindow{}, full...), Gaps: []timeRange{}}
+	for _, w := range continuous {
+		for _, base := range full {
+			if base.End.Before(w.Start) || base.End.After(w.End) {
+				continue
+			}
+			w.BaseBackup = base.Backup
+			w.Start = base.End
+			timeline.Windows = append(timeline.Windows, w)
+			break
+		}
+	}
+	sortRestoreWindows(timeline.Windows)
+
+	// the gaps are the ranges between the merged windows
+	var last *time.Time
+	for _, w := range timeline.Windows {
+		if last != nil && w.Start.After(*last) {
+			timeline.Gaps = append(timeline.Gaps, timeRange{Start: *last, End: w.Start})
+		}
+		if last == nil || w.End.After(*last) {
+			end := w.End
+			last = &end
+		}
+	}
+	return timeline
+}
+
+func sortRestoreWindows(windows []restoreWindow) {
+	sort.SliceStable(windows, func(i, j int) bool {
+		if windows[i].Start.Equal(windows[j].Start) {
+			return windows[i].End.Before(windows[j].End)
+		}
+		return windows[i].Start.Before(windows[j].Start)
+	})
+}
+
+// find finds the window to restore to the time, the continuous windows are preferred.
+func (t *restoreTimeline) find(at time.Time) *restoreWindow {
+	var found *restoreWindow
+	for i := range t.Windows {
+		w := &t.Windows[i]
+		if at.Before(w.Start) || at.After(w.End) {
+			continue
+		}
+		if found == nil || w.Type == restoreWindowContinuous {
+			found = w
+		}
+	}
+	return found
+}
+
+// findInBackup finds the window of the backup to restore to the time.
+func (t *restoreTimeline) findInBackup(backup string, at time.Time) *restoreWindow {
+	for i := range t.Windows {
+		w := &t.Windows[i]
+		if w.Backup == backup && !at.Before(w.Start) && !at.After(w.End) {
+			return w
+		}
+	}
+	return nil
+}
+
+// latest returns the window with the latest recoverable time.
+func (t *restoreTimeline) latest() *restoreWindow {
+	var latest *restoreWindow
+	for i := range t.Windows {
+		w := &t.Windows[i]
+		if latest == nil || !w.End.Before(latest.End) {
+			latest = w
+		}
+	}
+	return latest
+}
+
+// nearest returns the latest recoverable time before the time and the earliest one after it.
+func (t *restoreTimeline) nearest(at time.Time) (before, after *time.Time) {
+	for i := range t.Windows {
+		w := t.Windows[i]
+		if w.End.Before(at) && (before == nil || w.End.After(*before)) {
+			before = &w.End
+		}
+		if w.Start.After(at) && (after == nil || w.Start.Before(*after)) {
+			after = &w.Start
+		}
+	}
+	return before, after
+}
+
+func (t *restoreTimeline) print(out io.Writer) {
+	tbl := printer.NewTablePrinter(out)
+	tbl.SetHeader("TYPE", "BACKUP", "BASE-BACKUP", "START", "END")
+	rows := make([]restoreWindow, 0, len(t.Windows)+len(t.Gaps))
+	rows = append(rows, t.Windows...)
+	for _, gap := range t.Gaps {
+		rows = append(rows, restoreWindow{Type: "Gap", Start: gap.Start, End: gap.End})
+	}
+	sortRestoreWindows(rows)
+	for _, w := range rows {
+		typ := w.Type
+		if typ == "Gap" {
+			typ = printer.BoldRed(typ)
+		}
+		tbl.AddRow(typ, w.Backup, w.BaseBackup, util.TimeTimeFormatWithDuration(w.Start, time.Second), util.TimeTimeFormatWithDuration(w.End, time.Second))
+	}
+	tbl.Print()
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%s [%s] %s\n", util.TimeTimeFormatWithDuration(t.Windows[0].Start, time.Second),
+		t.renderTimeline(), util.TimeTimeFormatWithDuration(t.latest().End, time.Second))
+	fmt.Fprintf(out, "'|' full backup, '=' continuous backup, '%s' gap\n", printer.BoldRed("."))
+}
+
+// renderTimeline renders the windows into the columns between the earliest and the latest recoverable times.
+func (t *restoreTimeline) renderTimeline() string {
+	first, last := t.Windows[0].Start, t.latest().End
+	span := last.Sub(first)
+	column := func(at time.Time) int {
+		if span <= 0 {
+			return 0
+		}
+		return int(float64(at.Sub(first)) / float64(span) * float64(timelineWidth-1))
+	}
+	cols := []rune(strings.Repeat(".", timelineWidth))
+	for _, w := range t.Windows {
+		if w.Type == restoreWindowContinuous {
+			for i := column(w.Start); i <= column(w.End); i++ {
+				cols[i] = '='
+			}
+		}
+	}
+	for _, w := range t.Windows {
+		if w.Type == restoreWindowFull {
+			cols[column(w.End)] = '|'
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(cols); {
+		j := i
+		for j < len(cols) && (cols[j] == '.') == (cols[i] == '.') {
+			j++
+		}
+		if cols[i] == '.' {
+			b.WriteString(printer.BoldRed(string(cols[i:j])))
+		} else {
+			b.WriteString(string(cols[i:j]))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// makeBackupNotRecoverableErr makes the error naming the backup recoverable to the time.
+func makeBackupNotRecoverableErr(backup string, at time.Time, recoverableBackup string) error {
+	return fmt.Errorf("backup %s can not be restored to %s, please restore from backup %s",
+		backup, util.TimeTimeFormatWithDuration(at, time.Second), recoverableBackup)
+}
+
+func makeRestoreTimeNotRecoverableErr(timeline *restoreTimeline, at time.Time) error {
+	var nearest []string
+	before, after := timeline.nearest(at)
+	if before != nil {
+		nearest = append(nearest, util.TimeTimeFormatWithDuration(*before, time.Second)+" (before)")
+	}
+	if after != nil {
+		nearest = append(nearest, util.TimeTimeFormatWithDuration(*after, time.Second)+" (after)")
+	}
+	return fmt.Errorf("restore-to-time %s is not recoverable in cluster %s, the nearest recoverable times are %s, run \"kbcli cluster list-restore-points %s -n %s\" for all the restore points",
+		util.TimeTimeFormatWithDuration(at, time.Second), timeline.Cluster, strings.Join(nearest, " and "), timeline.Cluster, timeline.Namespace)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	dptypes "github.com/apecloud/kubeblocks/pkg/dataprotection/types"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var _ = Describe("restore points", func() {
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+		now     time.Time
+	)
+
+	fakeBackup := func(name string, continuous bool, phase dpv1alpha1.BackupPhase, start, end time.Duration) *dpv1alpha1.Backup {
+		backup := testing.FakeBackupWithCluster(testing.FakeCluster(testing.ClusterName, testing.Namespace), name)
+		backup.Status.Phase = phase
+		startTime, endTime := metav1.NewTime(now.Add(-start)), metav1.NewTime(now.Add(-end))
+		backup.Status.StartTimestamp = &startTime
+		backup.Status.CompletionTimestamp = &endTime
+		if continuous {
+			backup.Labels[dptypes.BackupTypeLabelKey] = string(dpv1alpha1.BackupTypeContinuous)
+			backup.Status.CompletionTimestamp = nil
+			backup.Status.TimeRange = &dpv1alpha1.BackupTimeRange{Start: &startTime, End: &endTime}
+		}
+		return backup
+	}
+
+	BeforeEach(func() {
+		now = time.Now().Truncate(time.Second)
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(
+			fakeBackup("full-1", false, dpv1alpha1.BackupPhaseCompleted, 4*time.Hour, 3*time.Hour),
+			fakeBackup("full-2", false, dpv1alpha1.BackupPhaseCompleted, 2*time.Hour, time.Hour),
+			fakeBackup("full-failed", false, dpv1alpha1.BackupPhaseFailed, 2*time.Hour, 30*time.Minute),
+			// the continuous backup is recoverable from the end of full-2
+			fakeBackup("log-1", true, dpv1alpha1.BackupPhaseRunning, 2*time.Hour, 0),
+			// the continuous backup without a base full backup is not recoverable
+			fakeBackup("log-0", true, dpv1alpha1.BackupPhaseCompleted, 6*time.Hour, 5*time.Hour))
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	It("build the restore timeline", func() {
+		timeline, err := getRestoreTimeline(tf.FakeDynamicClient, testing.Namespace, testing.ClusterName)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(timeline.Windows).Should(HaveLen(3))
+		Expect(timeline.Windows[2]).Should(Equal(restoreWindow{Type: restoreWindowContinuous, Backup: "log-1", BaseBackup: "full-2", Start: now.Add(-time.Hour), End: now}))
+		Expect(timeline.Gaps).Should(Equal([]timeRange{{Start: now.Add(-3 * time.Hour), End: now.Add(-time.Hour)}}))
+
+		Expect(timeline.find(now.Add(-30 * time.Minute)).Backup).Should(Equal("log-1"))
+		Expect(timeline.find(now.Add(-time.Hour)).Backup).Should(Equal("log-1"))
+		Expect(timeline.find(now.Add(-3 * time.Hour)).Backup).Should(Equal("full-1"))
+		Expect(timeline.find(now.Add(-2 * time.Hour))).Should(BeNil())
+		Expect(timeline.latest().Backup).Should(Equal("log-1"))
+		before, after := timeline.nearest(now.Add(-2 * time.Hour))
+		Expect(*before).Should(Equal(now.Add(-3 * time.Hour)))
+		Expect(*after).Should(Equal(now.Add(-time.Hour)))
+
+		By("list the restore points")
+		Expect(NewListRestorePointsCmd(tf, streams)).ShouldNot(BeNil())
+		o := &ListRestorePointsOptions{Factory: tf, IOStreams: streams}
+		Expect(o.Complete([]string{testing.ClusterName})).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`Continuous\s+log-1\s+full-2`))
+		Expect(out.String()).Should(ContainSubstring("Gap"))
+		Expect(out.String()).Should(ContainSubstring("|"))
+
+		out.Reset()
+		o.Format = printer.JSON
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring(`"baseBackup": "full-2"`))
+	})
+
+	It("validate the restore time", func() {
+		newOptions := func() *CreateRestoreOptions {
+			o := &CreateRestoreOptions{}
+			o.CreateOptions = action.CreateOptions{
+				IOStreams:       streams,
+				Factory:         tf,
+				Options:         o,
+				GVR:             types.OpsGVR(),
+				CueTemplateName: "opsrequest_template.cue",
+				Args:            []string{"new-cluster"},
+			}
+			Expect(o.Complete()).Should(Succeed())
+			o.SourceCluster = testing.ClusterName
+			return o
+		}
+
+		o := newOptions()
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("must be specified with --restore-to-time or --restore-to-latest")))
+
+		By("the time in the gap is refused with the nearest recoverable times")
+		o.RestoreSpec.RestoreTimeStr = util.TimeTimeFormatWithDuration(now.Add(-2*time.Hour), time.Second)
+		Expect(o.Validate()).Should(MatchError(And(
+			ContainSubstring(util.TimeTimeFormatWithDuration(now.Add(-3*time.Hour), time.Second)+" (before)"),
+			ContainSubstring(util.TimeTimeFormatWithDuration(now.Add(-time.Hour), time.Second)+" (after)"))))
+
+		By("restore to the time in the continuous backup")
+		o.RestoreSpec.RestoreTimeStr = now.Add(-30 * time.Minute).Format(time.RFC3339)
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.RestoreSpec.BackupName).Should(Equal("log-1"))
+		Expect(o.RestoreSpec.RestoreTimeStr).Should(Equal(now.Add(-30 * time.Minute).UTC().Format(time.RFC3339)))
+
+		By("restore to the full backup")
+		o = newOptions()
+		o.RestoreSpec.RestoreTimeStr = util.TimeTimeFormatWithDuration(now.Add(-3*time.Hour), time.Second)
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.RestoreSpec.BackupName).Should(Equal("full-1"))
+		Expect(o.RestoreSpec.RestoreTimeStr).Should(BeEmpty())
+
+		By("restore to the latest time")
+		o = newOptions()
+		o.RestoreToLatest = true
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.RestoreSpec.BackupName).Should(Equal("log-1"))
+		Expect(o.RestoreSpec.RestoreTimeStr).Should(Equal(now.UTC().Format(time.RFC3339)))
+
+		By("the specified backup should be able to restore to the time")
+		o = newOptions()
+		o.SourceCluster = ""
+		o.RestoreSpec.BackupName = "full-1"
+		o.RestoreToLatest = true
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("please restore from backup log-1")))
+
+		By("the specified backup is validated against its own window when the continuous backups overlap")
+		tf.FakeDynamicClient = testing.FakeDynamicClient(
+			fakeBackup("full-2", false, dpv1alpha1.BackupPhaseCompleted, 2*time.Hour, time.Hour),
+			fakeBackup("log-1", true, dpv1alpha1.BackupPhaseRunning, 2*time.Hour, 0),
+			fakeBackup("log-2", true, dpv1alpha1.BackupPhaseCompleted, 90*time.Minute, 10*time.Minute))
+		for _, backup := range []string{"log-1", "log-2"} {
+			o = newOptions()
+			o.SourceCluster = ""
+			o.RestoreSpec.BackupName = backup
+			o.RestoreSpec.RestoreTimeStr = now.Add(-30 * time.Minute).Format(time.RFC3339)
+			Expect(o.Validate()).Should(Succeed())
+			Expect(o.RestoreSpec.BackupName).Should(Equal(backup))
+		}
+		o = newOptions()
+		o.SourceCluster = ""
+		o.RestoreSpec.BackupName = "log-2"
+		o.RestoreSpec.RestoreTimeStr = now.Add(-5 * time.Minute).Format(time.RFC3339)
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("please restore from backup log-1")))
+	})
+})
@@ -0,0 +1,334 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+	"github.com/apecloud/kbcli/pkg/util/prompt"
+)
+
+var revertOpsExample = templates.Examples(`
+		# revert the vertical scaling, the resources of the components are scaled back to the values before it
+		kbcli cluster revert-ops mycluster-verticalscaling-x8kzb
+
+		# preview the OpsRequests reverting the horizontal scaling without creating them
+		kbcli cluster revert-ops mycluster-horizontalscaling-8xq9z --dry-run
+`)
+
+// revertibleOpsTypes are the OpsRequest types which can be reverted by the last configuration.
+// The VolumeExpansion is not revertible since the volumes can not be shrunk, and the Upgrade is
+// only reverted with --allow-downgrade since the data formats of the engine may not be downgraded.
+var revertibleOpsTypes = []appsv1alpha1.OpsType{
+	appsv1alpha1.VerticalScalingType,
+	appsv1alpha1.HorizontalScalingType,
+	appsv1alpha1.UpgradeType,
+}
+
+// RevertOpsOptions is the options of the revert-ops command.
+type RevertOpsOptions struct {
+	Factory   cmdutil.Factory
+	Namespace string
+	Dynamic   dynamic.Interface
+	Client    kubernetes.Interface
+
+	OpsRequestName string
+	DryRun         string
+	AutoApprove    bool
+	AllowDowngrade bool
+	Wait           bool
+	Timeout        time.Duration
+
+	opsRequest *appsv1alpha1.OpsRequest
+	// reverts are the OpsRequests to revert the changes, one for each component
+	reverts []*OperationsOptions
+	// changes are the rows of COMPONENT, FIELD, FROM and TO to be confirmed
+	changes [][]interface{}
+
+	genericiooptions.IOStreams
+}
+
+func NewRevertOpsCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &RevertOpsOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:   "revert-ops OPS-NAME",
+		Short: "Revert a succeeded OpsRequest by creating the inverse OpsRequest from its last configuration.",
+		Long: templates.LongDesc(`
+			Revert a succeeded OpsRequest by creating the inverse OpsRequest from the last configuration recorded
+			in its status. Only the VerticalScaling, HorizontalScaling and Upgrade OpsRequests can be reverted,
+			and the Upgrade OpsRequest is reverted only with --allow-downgrade.`),
+		Example:           revertOpsExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.OpsGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVar(&o.DryRun, "dry-run", "none", `Must be "client", or "server". If with client strategy, only print the object that would be sent, and no data is actually sent. If with server strategy, submit the server-side request, but no data is persistent.`)
+	cmd.Flags().Lookup("dry-run").NoOptDefVal = "unchanged"
+	cmd.Flags().BoolVar(&o.AutoApprove, "auto-approve", false, "Skip interactive approval before reverting the OpsRequest")
+	cmd.Flags().BoolVar(&o.AllowDowngrade, "allow-downgrade", false, "Allow to revert the Upgrade OpsRequest, which downgrades the cluster version and may be incompatible with the data of the upgraded engine")
+	cmd.Flags().BoolVar(&o.Wait, "wait", false, "Wait for the OpsRequests to be completed and show the progress")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", defaultOpsWaitTimeout, "Time to wait for each OpsRequest to be completed, only valid with --wait")
+	return cmd
+}
+
+func (o *RevertOpsOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return fmt.Errorf("please specify the name of the OpsRequest to revert")
+	}
+	o.OpsRequestName = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	if o.Client, err = o.Factory.KubernetesClientSet(); err != nil {
+		return err
+	}
+	o.opsRequest = &appsv1alpha1.OpsRequest{}
+	return util.GetK8SClientObject(o.Dynamic, o.opsRequest, types.OpsGVR(), o.Namespace, o.OpsRequestName)
+}
+
+func (o *RevertOpsOptions) Validate() error {
+	ops := o.opsRequest
+	if !slices.Contains(revertibleOpsTypes, ops.Spec.Type) {
+		return fmt.Errorf("%s OpsRequest %s can not be reverted, only %v OpsRequests are supported", ops.Spec.Type, ops.Name, revertibleOpsTypes)
+	}
+	// the failed or cancelled OpsRequest may not be applied at all, reverting it changes the cluster
+	// to the last configuration which is never changed
+	if ops.Status.Phase != appsv1alpha1.OpsSucceedPhase {
+		return fmt.Errorf("OpsRequest %s is %s, only the succeeded OpsRequest can be reverted", ops.Name, ops.Status.Phase)
+	}
+	if ops.Spec.Type == appsv1alpha1.UpgradeType {
+		if !o.AllowDowngrade {
+			return fmt.Errorf("reverting Upgrade OpsRequest %s downgrades the cluster version, which is not safe since the data of the upgraded engine may not be compatible with the old version, specify --allow-downgrade to revert it anyway", ops.Name)
+		}
+		printer.Warning(o.ErrOut, "reverting Upgrade OpsRequest %s downgrades the cluster version, make sure the old version is compatible with the data of the upgraded engine\n", ops.Name)
+	}
+	dryRun := &action.CreateOptions{DryRun: o.DryRun}
+	if _, err := dryRun.GetDryRunStrategy(); err != nil {
+		return err
+	}
+	if err := o.buildReverts(); err != nil {
+		return err
+	}
+	// all the reverts are validated before any of them is created, so the revert is not half applied
+	for _, r := range o.reverts {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildReverts builds the OpsRequests to revert the changes from the last configuration.
+func (o *RevertOpsOptions) buildReverts() error {
+	ops := o.opsRequest
+	last := ops.Status.LastConfiguration
+	if ops.Spec.Type == appsv1alpha1.UpgradeType {
+		if last.ClusterVersionRef == "" || ops.Spec.Upgrade == nil {
+			return fmt.Errorf("no last cluster version is recorded in OpsRequest %s", ops.Name)
+		}
+		r, err := o.newRevertOps(appsv1alpha1.UpgradeType, false)
+		if err != nil {
+			return err
+		}
+		r.ClusterVersionRef = last.ClusterVersionRef
+		o.reverts = append(o.reverts, r)
+		o.changes = append(o.changes, []interface{}{"", "clusterVersion", ops.Spec.Upgrade.ClusterVersionRef, last.ClusterVersionRef})
+		return nil
+	}
+
+	// the components changed by the OpsRequest, keyed by the component name
+	targets := map[string]interface{}{}
+	for i, s := range ops.Spec.VerticalScalingList {
+		targets[s.ComponentName] = &ops.Spec.VerticalScalingList[i]
+	}
+	for i, s := range ops.Spec.HorizontalScalingList {
+		targets[s.ComponentName] = &ops.Spec.HorizontalScalingList[i]
+	}
+	compNames := maps.Keys(targets)
+	sort.Strings(compNames)
+	for _, compName := range compNames {
+		lastComp, ok := last.Components[compName]
+		if !ok {
+			return fmt.Errorf("no last configuration of component %s is recorded in OpsRequest %s", compName, ops.Name)
+		}
+		switch target := targets[compName].(type) {
+		case *appsv1alpha1.HorizontalScaling:
+			if lastComp.Replicas == nil {
+				return fmt.Errorf("no last replicas of component %s is recorded in OpsRequest %s", compName, ops.Name)
+			}
+			r, err := o.newRevertOps(appsv1alpha1.HorizontalScalingType, true)
+			if err != nil {
+				return err
+			}
+			r.ComponentNames = []string{compName}
+			r.Replicas = int(*lastComp.Replicas)
+			o.reverts = append(o.reverts, r)
+			o.changes = append(o.changes, []interface{}{compName, "replicas", strconv.Itoa(int(target.Replicas)), strconv.Itoa(r.Replicas)})
+		case *appsv1alpha1.VerticalScaling:
+			r, err := o.newVScaleRevertOps(compName, lastComp)
+			if err != nil {
+				return err
+			}
+			o.reverts = append(o.reverts, r)
+			if target.ClassDefRef != nil || lastComp.ClassDefRef != nil {
+				o.changes = append(o.changes, []interface{}{compName, "class", formatClassDefRef(target.ClassDefRef), formatClassDefRef(lastComp.ClassDefRef)})
+			}
+			for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+				o.changes = append(o.changes,
+					[]interface{}{compName, "requests." + name, formatResource(target.Requests, name), formatResource(lastComp.Requests, name)},
+					[]interface{}{compName, "limits." + name, formatResource(target.Limits, name), formatResource(lastComp.Limits, name)})
+			}
+		}
+	}
+	if len(o.reverts) == 0 {
+		return fmt.Errorf("no component is changed by OpsRequest %s", ops.Name)
+	}
+	return nil
+}
+
+// newVScaleRevertOps builds the VerticalScaling OpsRequest which is validated with the cpu and memory
+// of the last requests, and the requests and limits are restored as they were when it is created.
+func (o *RevertOpsOptions) newVScaleRevertOps(compName string, lastComp appsv1alpha1.LastComponentConfiguration) (*OperationsOptions, error) {
+	r, err := o.newRevertOps(appsv1alpha1.VerticalScalingType, true)
+	if err != nil {
+		return nil, err
+	}
+	r.ComponentNames = []string{compName}
+	if lastComp.ClassDefRef != nil && lastComp.ClassDefRef.Class != "" {
+		r.Class = formatClassDefRef(lastComp.ClassDefRef)
+	} else {
+		resources := lastComp.Requests
+		if len(resources) == 0 {
+			resources = lastComp.Limits
+		}
+		if cpu, ok := resources[corev1.ResourceCPU]; ok {
+			r.CPU = cpu.String()
+		}
+		if memory, ok := resources[corev1.ResourceMemory]; ok {
+			r.Memory = memory.String()
+		}
+		if r.CPU == "" && r.Memory == "" {
+			return nil, fmt.Errorf("no last resources of component %s is recorded in OpsRequest %s", compName, o.opsRequest.Name)
+		}
+	}
+	r.PreCreate = func(obj *unstructured.Unstructured) error {
+		ops := &appsv1alpha1.OpsRequest{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ops); err != nil {
+			return err
+		}
+		ops.Spec.VerticalScalingList = []appsv1alpha1.VerticalScaling{{
+			ComponentOps:         appsv1alpha1.ComponentOps{ComponentName: compName},
+			ResourceRequirements: *lastComp.ResourceRequirements.DeepCopy(),
+			ClassDefRef:          lastComp.ClassDefRef,
+		}}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ops)
+		if err != nil {
+			return err
+		}
+		obj.Object = content
+		return nil
+	}
+	return r, nil
+}
+
+func (o *RevertOpsOptions) newRevertOps(opsType appsv1alpha1.OpsType, hasComponentNamesFlag bool) (*OperationsOptions, error) {
+	r := newBaseOperationsOptions(o.Factory, o.IOStreams, opsType, hasComponentNamesFlag)
+	r.Args = []string{o.opsRequest.Spec.ClusterRef}
+	if err := r.Complete(); err != nil {
+		return nil, err
+	}
+	r.Namespace = o.Namespace
+	r.Dynamic = o.Dynamic
+	r.Client = o.Client
+	r.Format = printer.YAML
+	r.DryRun = o.DryRun
+	r.Wait = o.Wait
+	r.Timeout = o.Timeout
+	// the changes are confirmed once for all the OpsRequests
+	r.autoApprove = true
+	return r, nil
+}
+
+func (o *RevertOpsOptions) Run() error {
+	fmt.Fprintf(o.Out, "Revert %s OpsRequest %s of cluster %s:\n", o.opsRequest.Spec.Type, o.opsRequest.Name, o.opsRequest.Spec.ClusterRef)
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("COMPONENT", "FIELD", "FROM", "TO")
+	for _, row := range o.changes {
+		tbl.AddRow(row...)
+	}
+	tbl.Print()
+	if !o.AutoApprove && o.DryRun == "none" {
+		if err := prompt.Confirm([]string{o.opsRequest.Name}, o.In, "", ""); err != nil {
+			return err
+		}
+	}
+	for _, r := range o.reverts {
+		if err := r.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatClassDefRef(ref *appsv1alpha1.ClassDefRef) string {
+	if ref == nil || ref.Class == "" {
+		return printer.NoneString
+	}
+	if ref.Name == "" {
+		return ref.Class
+	}
+	return ref.Name + ":" + ref.Class
+}
+
+func formatResource(resources corev1.ResourceList, name corev1.ResourceName) string {
+	if q, ok := resources[name]; ok {
+		return q.String()
+	}
+	return printer.NoneString
+}
@@ -0,0 +1,187 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+	"k8s.io/utils/pointer"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("revert ops", func() {
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		errOut  *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	newOps := func(name string, opsType appsv1alpha1.OpsType, phase appsv1alpha1.OpsPhase) *appsv1alpha1.OpsRequest {
+		ops := &appsv1alpha1.OpsRequest{}
+		ops.Name = name
+		ops.Namespace = testing.Namespace
+		ops.Spec.ClusterRef = testing.ClusterName
+		ops.Spec.Type = opsType
+		ops.Status.Phase = phase
+		return ops
+	}
+
+	BeforeEach(func() {
+		streams, _, out, errOut = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+
+		hscale := newOps("test-hscale", appsv1alpha1.HorizontalScalingType, appsv1alpha1.OpsSucceedPhase)
+		hscale.Spec.HorizontalScalingList = []appsv1alpha1.HorizontalScaling{{
+			ComponentOps: appsv1alpha1.ComponentOps{ComponentName: testing.ComponentName},
+			Replicas:     5,
+		}}
+		hscale.Status.LastConfiguration.Components = map[string]appsv1alpha1.LastComponentConfiguration{
+			testing.ComponentName: {Replicas: pointer.Int32(3)},
+		}
+		vscale := newOps("test-vscale", appsv1alpha1.VerticalScalingType, appsv1alpha1.OpsSucceedPhase)
+		vscale.Spec.VerticalScalingList = []appsv1alpha1.VerticalScaling{{
+			ComponentOps: appsv1alpha1.ComponentOps{ComponentName: testing.ComponentName},
+			ResourceRequirements: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			},
+		}}
+		vscale.Status.LastConfiguration.Components = map[string]appsv1alpha1.LastComponentConfiguration{
+			testing.ComponentName: {ResourceRequirements: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			}},
+		}
+		volumeExpand := newOps("test-volume-expand", appsv1alpha1.VolumeExpansionType, appsv1alpha1.OpsSucceedPhase)
+		running := newOps("test-running", appsv1alpha1.HorizontalScalingType, appsv1alpha1.OpsRunningPhase)
+		failed := newOps("test-failed", appsv1alpha1.HorizontalScalingType, appsv1alpha1.OpsFailedPhase)
+		failed.Spec.HorizontalScalingList = hscale.Spec.HorizontalScalingList
+		failed.Status.LastConfiguration = hscale.Status.LastConfiguration
+		upgrade := newOps("test-upgrade", appsv1alpha1.UpgradeType, appsv1alpha1.OpsSucceedPhase)
+		upgrade.Spec.Upgrade = &appsv1alpha1.Upgrade{ClusterVersionRef: "new-version"}
+		upgrade.Status.LastConfiguration.ClusterVersionRef = testing.ClusterVersionName
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace),
+			testing.FakeClusterDef(), hscale, vscale, volumeExpand, running, failed, upgrade)
+		// the fake client does not generate the names of the OpsRequests
+		tf.FakeDynamicClient.PrependReactor("create", "opsrequests", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			obj := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured)
+			if obj.GetName() == "" {
+				obj.SetName(obj.GetGenerateName() + rand.String(5))
+			}
+			return false, nil, nil
+		})
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	newOptions := func(opsName string) *RevertOpsOptions {
+		o := &RevertOpsOptions{Factory: tf, IOStreams: streams, DryRun: "none", AutoApprove: true}
+		Expect(o.Complete([]string{opsName})).Should(Succeed())
+		o.Client = testing.FakeClientSet()
+		return o
+	}
+
+	listOps := func(opsType appsv1alpha1.OpsType) []appsv1alpha1.OpsRequest {
+		objs, err := tf.FakeDynamicClient.Resource(types.OpsGVR()).Namespace(testing.Namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		var res []appsv1alpha1.OpsRequest
+		for _, obj := range objs.Items {
+			ops := appsv1alpha1.OpsRequest{}
+			Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &ops)).Should(Succeed())
+			if ops.Spec.Type == opsType && ops.Status.Phase == "" {
+				res = append(res, ops)
+			}
+		}
+		return res
+	}
+
+	It("refuse the OpsRequests which can not be reverted", func() {
+		Expect(NewRevertOpsCmd(tf, streams)).ShouldNot(BeNil())
+		Expect(newOptions("test-volume-expand").Validate()).Should(MatchError(ContainSubstring("can not be reverted")))
+		Expect(newOptions("test-running").Validate()).Should(MatchError(ContainSubstring("only the succeeded OpsRequest")))
+		Expect(newOptions("test-failed").Validate()).Should(MatchError(ContainSubstring("test-failed is Failed")))
+	})
+
+	It("revert the horizontal scaling", func() {
+		o := newOptions("test-hscale")
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`replicas\s+5\s+3`))
+		opsList := listOps(appsv1alpha1.HorizontalScalingType)
+		Expect(opsList).Should(HaveLen(1))
+		Expect(opsList[0].Spec.HorizontalScalingList[0].Replicas).Should(Equal(int32(3)))
+	})
+
+	It("revert the vertical scaling", func() {
+		o := newOptions("test-vscale")
+		Expect(o.Validate()).Should(Succeed())
+		Expect(o.Run()).Should(Succeed())
+		Expect(out.String()).Should(MatchRegexp(`limits.cpu\s+2\s+1`))
+		opsList := listOps(appsv1alpha1.VerticalScalingType)
+		Expect(opsList).Should(HaveLen(1))
+		resources := opsList[0].Spec.VerticalScalingList[0].ResourceRequirements
+		Expect(resources.Requests.Cpu().String()).Should(Equal("500m"))
+		Expect(resources.Limits.Cpu().String()).Should(Equal("1"))
+	})
+
+	It("revert the upgrade only with --allow-downgrade", func() {
+		Expect(newOptions("test-upgrade").Validate()).Should(MatchError(ContainSubstring("--allow-downgrade")))
+
+		o := newOptions("test-upgrade")
+		o.AllowDowngrade = true
+		Expect(o.Validate()).Should(Succeed())
+		Expect(errOut.String()).Should(ContainSubstring("downgrades the cluster version"))
+		Expect(o.Run()).Should(Succeed())
+		opsList := listOps(appsv1alpha1.UpgradeType)
+		Expect(opsList).Should(HaveLen(1))
+		Expect(opsList[0].Spec.Upgrade.ClusterVersionRef).Should(Equal(testing.ClusterVersionName))
+	})
+
+	It("validate the reverts before prompting", func() {
+		Expect(tf.FakeDynamicClient.Resource(types.ClusterGVR()).Namespace(testing.Namespace).
+			Delete(context.TODO(), testing.ClusterName, metav1.DeleteOptions{})).Should(Succeed())
+		o := newOptions("test-hscale")
+		// the prompt would fail to read the empty input, the validation must fail first
+		o.AutoApprove = false
+		Expect(o.Validate()).Should(MatchError(ContainSubstring("not found")))
+		Expect(listOps(appsv1alpha1.HorizontalScalingType)).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,489 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"k8s.io/utils/pointer"
+
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+const (
+	// scheduledOpsLabelKey marks the CronJobs created by kbcli to submit the scheduled OpsRequests
+	scheduledOpsLabelKey = "kbcli.kubeblocks.io/scheduled-ops"
+	// scheduledOpsAtAnnotationKey records the time of the one-off scheduled OpsRequest
+	scheduledOpsAtAnnotationKey = "kbcli.kubeblocks.io/scheduled-at"
+
+	// scheduledOpsServiceAccount is the service account used by the CronJobs to submit the OpsRequests
+	scheduledOpsServiceAccount = "kbcli-scheduled-ops"
+	scheduledOpsEnvName        = "OPS_REQUEST"
+
+	// maxScheduledOpsNameLength is the max length of the CronJob name, the name of the Job created
+	// by the CronJob is appended with a suffix of 11 characters
+	maxScheduledOpsNameLength = 52
+)
+
+var (
+	listScheduledOpsExample = templates.Examples(`
+		# list all the scheduled OpsRequests
+		kbcli cluster list-scheduled-ops
+
+		# list the scheduled OpsRequests of the specified cluster
+		kbcli cluster list-scheduled-ops mycluster`)
+
+	deleteScheduledOpsExample = templates.Examples(`
+		# delete the scheduled OpsRequest
+		kbcli cluster delete-scheduled-ops mycluster-restart-x8kzb
+
+		# delete all the scheduled OpsRequests of the specified cluster
+		kbcli cluster delete-scheduled-ops -l app.kubernetes.io/instance=mycluster`)
+)
+
+// scheduleOptions are the options to submit the OpsRequest later by a CronJob.
+type scheduleOptions struct {
+	// Schedule is the cron expression to submit the OpsRequest periodically
+	Schedule string
+	// At is the RFC3339 time to submit the OpsRequest once
+	At string
+	// Image is the image of the CronJob, which contains kubectl
+	Image string
+
+	atTime time.Time
+}
+
+func (o *scheduleOptions) addScheduleFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Schedule, "schedule", "", `Submit the OpsRequest periodically on the cron schedule instead of right away, e.g. "0 2 * * *"`)
+	cmd.Flags().StringVar(&o.At, "at", "", `Submit the OpsRequest once at the RFC3339 time instead of right away, rounded up to the minute, e.g. "2023-11-11T02:00:00+08:00"`)
+	cmd.Flags().StringVar(&o.Image, "image", "", "The image containing kubectl to submit the scheduled OpsRequest, only valid with --schedule or --at. If not specified, use the tools image of the installed KubeBlocks")
+}
+
+func (o *scheduleOptions) isScheduled() bool {
+	return o.Schedule != "" || o.At != ""
+}
+
+func (o *scheduleOptions) validateSchedule(now time.Time) error {
+	if o.Schedule != "" && o.At != "" {
+		return fmt.Errorf("--schedule and --at can not be specified at the same time")
+	}
+	if o.Schedule != "" {
+		if _, err := cron.ParseStandard(o.Schedule); err != nil {
+			return fmt.Errorf("invalid --schedule %q: %v", o.Schedule, err)
+		}
+		return nil
+	}
+	at, err := time.Parse(time.RFC3339, o.At)
+	if err != nil {
+		return fmt.Errorf("invalid --at %q, it should be a RFC3339 time: %v", o.At, err)
+	}
+	// the CronJob is triggered at the beginning of a minute
+	o.atTime = at.UTC().Add(time.Minute - time.Nanosecond).Truncate(time.Minute)
+	if !o.atTime.After(now) {
+		return fmt.Errorf("--at %s is not in the future", o.At)
+	}
+	// the cron expression has no year field
+	if o.atTime.After(now.AddDate(1, 0, 0)) {
+		return fmt.Errorf("--at %s should be within one year", o.At)
+	}
+	return nil
+}
+
+// cronSchedule returns the schedule and the time zone of the CronJob.
+func (o *scheduleOptions) cronSchedule() (string, *string) {
+	if o.Schedule != "" {
+		return o.Schedule, nil
+	}
+	return fmt.Sprintf("%d %d %d %d *", o.atTime.Minute(), o.atTime.Hour(), o.atTime.Day(), int(o.atTime.Month())), pointer.String("Etc/UTC")
+}
+
+// createScheduledOps creates a CronJob holding the rendered OpsRequest, which is submitted by the CronJob
+// on the schedule. The one-off CronJob deletes itself after the OpsRequest is submitted.
+func (o *OperationsOptions) createScheduledOps() error {
+	if err := o.validateSchedule(time.Now()); err != nil {
+		return err
+	}
+	if o.Wait {
+		return fmt.Errorf("--wait can not be specified with --schedule or --at")
+	}
+	if o.Schedule != "" && o.OpsRequestName != "" {
+		return fmt.Errorf("--name can not be specified with --schedule since the OpsRequest is created repeatedly")
+	}
+	dryRun, err := o.GetDryRunStrategy()
+	if err != nil {
+		return err
+	}
+	if o.Image == "" {
+		if o.Image, err = util.GetKubeBlocksToolsImage(o.Client); err != nil {
+			return fmt.Errorf("%v, please specify the image by --image", err)
+		}
+	}
+	opsRequest, err := o.BuildResourceObj()
+	if err != nil {
+		return err
+	}
+	opsRequest.SetNamespace(o.Namespace)
+	cronJob, err := o.buildScheduledOpsCronJob(opsRequest)
+	if err != nil {
+		return err
+	}
+	if dryRun != action.DryRunClient {
+		createOptions := metav1.CreateOptions{}
+		if dryRun == action.DryRunServer {
+			createOptions.DryRun = []string{metav1.DryRunAll}
+		}
+		if err = o.createScheduledOpsRBAC(createOptions); err != nil {
+			return err
+		}
+		// the one-off CronJob may run once it is created, so it must be allowed to delete itself before that
+		name := cronJob.GetName()
+		if o.At != "" {
+			if err = o.createScheduledOpsDeletionRBAC(name, createOptions); err != nil {
+				return err
+			}
+		}
+		if cronJob, err = o.Dynamic.Resource(types.CronJobGVR()).Namespace(o.Namespace).Create(context.TODO(), cronJob, createOptions); err != nil {
+			if o.At != "" && dryRun == action.DryRunNone {
+				o.deleteScheduledOpsDeletionRBAC(name)
+			}
+			return err
+		}
+		if o.At != "" && dryRun == action.DryRunNone {
+			if err = o.setScheduledOpsDeletionRBACOwner(cronJob); err != nil {
+				return err
+			}
+		}
+		if dryRun == action.DryRunNone {
+			if !o.Quiet {
+				schedule, _ := o.cronSchedule()
+				if o.At != "" {
+					schedule = o.atTime.Format(time.RFC3339)
+				}
+				fmt.Fprintf(o.Out, "%s OpsRequest of cluster %s is scheduled at %s by CronJob %s, you can view the scheduled OpsRequests:\n", o.OpsType, o.Name, schedule, cronJob.GetName())
+				fmt.Fprintf(o.Out, "\tkbcli cluster list-scheduled-ops %s -n %s\n", o.Name, o.Namespace)
+			}
+			return nil
+		}
+	}
+	p, err := o.ToPrinter(nil, false)
+	if err != nil {
+		return err
+	}
+	return p.PrintObj(cronJob, o.Out)
+}
+
+func (o *OperationsOptions) buildScheduledOpsCronJob(opsRequest *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	manifest, err := opsRequest.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("%s-%s-", o.Name, o.OpsTypeLower)
+	if len(prefix) > maxScheduledOpsNameLength-5 {
+		prefix = prefix[:maxScheduledOpsNameLength-5]
+	}
+	name := prefix + rand.String(5)
+	script := fmt.Sprintf(`echo "$%s" | kubectl create -f -`, scheduledOpsEnvName)
+	annotations := map[string]string{}
+	if o.At != "" {
+		// the one-off CronJob is deleted even if the OpsRequest fails to be created, as it is never retried
+		script += fmt.Sprintf("; rc=$?; kubectl delete cronjob %s -n %s --wait=false || true; exit $rc", name, o.Namespace)
+		annotations[scheduledOpsAtAnnotationKey] = o.atTime.Format(time.RFC3339)
+	}
+	schedule, timeZone := o.cronSchedule()
+	// the instance label is not set to the pods, or they will be listed as the instances of the cluster
+	podLabels := map[string]string{
+		constant.AppManagedByLabelKey: "kbcli",
+		scheduledOpsLabelKey:          "true",
+	}
+	cronJob := &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+			Kind:       "CronJob",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: o.Namespace,
+			Labels: map[string]string{
+				constant.AppManagedByLabelKey:   "kbcli",
+				constant.AppInstanceLabelKey:    o.Name,
+				constant.OpsRequestTypeLabelKey: string(o.OpsType),
+				scheduledOpsLabelKey:            "true",
+			},
+			Annotations: annotations,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:          schedule,
+			TimeZone:          timeZone,
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec: batchv1.JobSpec{
+					// the OpsRequest is not created again if the job fails, as it may have been created
+					BackoffLimit: pointer.Int32(0),
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+						Spec: corev1.PodSpec{
+							ServiceAccountName: scheduledOpsServiceAccount,
+							RestartPolicy:      corev1.RestartPolicyNever,
+							Containers: []corev1.Container{{
+								Name:    "submit-ops",
+								Image:   o.Image,
+								Command: []string{"/bin/sh", "-c", script},
+								Env:     []corev1.EnvVar{{Name: scheduledOpsEnvName, Value: string(manifest)}},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cronJob)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+// createScheduledOpsRBAC creates the service account allowed to submit the OpsRequests if it does not exist.
+func (o *OperationsOptions) createScheduledOpsRBAC(opts metav1.CreateOptions) error {
+	ctx := context.TODO()
+	labels := map[string]string{constant.AppManagedByLabelKey: "kbcli"}
+	meta := metav1.ObjectMeta{Name: scheduledOpsServiceAccount, Namespace: o.Namespace, Labels: labels}
+	ignoreExists := func(err error) error {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	if _, err := o.Client.CoreV1().ServiceAccounts(o.Namespace).Create(ctx, &corev1.ServiceAccount{ObjectMeta: meta}, opts); ignoreExists(err) != nil {
+		return err
+	}
+	role := &rbacv1.Role{
+		ObjectMeta: meta,
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{types.AppsAPIGroup}, Resources: []string{types.ResourceOpsRequests}, Verbs: []string{"create"}},
+		},
+	}
+	if _, err := o.Client.RbacV1().Roles(o.Namespace).Create(ctx, role, opts); ignoreExists(err) != nil {
+		return err
+	}
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: meta,
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: scheduledOpsServiceAccount, Namespace: o.Namespace}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: scheduledOpsServiceAccount},
+	}
+	if _, err := o.Client.RbacV1().RoleBindings(o.Namespace).Create(ctx, roleBinding, opts); ignoreExists(err) != nil {
+		return err
+	}
+	return nil
+}
+
+// createScheduledOpsDeletionRBAC allows the service account to delete the one-off CronJob only, the role
+// and the binding are owned by the CronJob once it is created, so they are deleted with it.
+func (o *OperationsOptions) createScheduledOpsDeletionRBAC(name string, opts metav1.CreateOptions) error {
+	ctx := context.TODO()
+	meta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: o.Namespace,
+		Labels:    map[string]string{constant.AppManagedByLabelKey: "kbcli"},
+	}
+	role := &rbacv1.Role{
+		ObjectMeta: meta,
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{types.K8SBatchAPIGroup}, Resources: []string{types.ResourceCronJobs}, ResourceNames: []string{name}, Verbs: []string{"delete"}},
+		},
+	}
+	if _, err := o.Client.RbacV1().Roles(o.Namespace).Create(ctx, role, opts); err != nil {
+		return err
+	}
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: meta,
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: scheduledOpsServiceAccount, Namespace: o.Namespace}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+	}
+	_, err := o.Client.RbacV1().RoleBindings(o.Namespace).Create(ctx, roleBinding, opts)
+	return err
+}
+
+// setScheduledOpsDeletionRBACOwner sets the CronJob as the owner of the role and the binding to delete it.
+func (o *OperationsOptions) setScheduledOpsDeletionRBACOwner(cronJob *unstructured.Unstructured) error {
+	ctx := context.TODO()
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"ownerReferences": []metav1.OwnerReference{{
+				APIVersion: batchv1.SchemeGroupVersion.String(),
+				Kind:       "CronJob",
+				Name:       cronJob.GetName(),
+				UID:        cronJob.GetUID(),
+			}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = o.Client.RbacV1().Roles(o.Namespace).Patch(ctx, cronJob.GetName(), k8stypes.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+	_, err = o.Client.RbacV1().RoleBindings(o.Namespace).Patch(ctx, cronJob.GetName(), k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// deleteScheduledOpsDeletionRBAC deletes the role and the binding if the CronJob fails to be created.
+func (o *OperationsOptions) deleteScheduledOpsDeletionRBAC(name string) {
+	ctx := context.TODO()
+	if err := o.Client.RbacV1().RoleBindings(o.Namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		fmt.Fprintf(o.ErrOut, "failed to delete rolebinding %s: %v\n", name, err)
+	}
+	if err := o.Client.RbacV1().Roles(o.Namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		fmt.Fprintf(o.ErrOut, "failed to delete role %s: %v\n", name, err)
+	}
+}
+
+type scheduledOpsListOptions struct {
+	*action.ListOptions
+}
+
+func NewListScheduledOpsCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &scheduledOpsListOptions{
+		ListOptions: action.NewListOptions(f, streams, types.CronJobGVR()),
+	}
+	cmd := &cobra.Command{
+		Use:               "list-scheduled-ops [NAME]",
+		Short:             "List the OpsRequests scheduled by --schedule or --at.",
+		Example:           listScheduledOpsExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			// args are the cluster names, the scheduled OpsRequests are selected by labels
+			o.LabelSelector = util.BuildLabelSelectorByNames(scheduledOpsSelector(o.LabelSelector), args)
+			o.Names = nil
+			util.CheckErr(o.Complete())
+			util.CheckErr(o.printScheduledOps())
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd
+}
+
+// scheduledOpsSelector appends the label of the scheduled OpsRequests to the selector.
+func scheduledOpsSelector(selector string) string {
+	label := scheduledOpsLabelKey + "=true"
+	if selector == "" {
+		return label
+	}
+	return selector + "," + label
+}
+
+func (o *scheduledOpsListOptions) printScheduledOps() error {
+	if o.Format == printer.JSON || o.Format == printer.YAML {
+		_, err := o.Run()
+		return err
+	}
+	dynamic, err := o.Factory.DynamicClient()
+	if err != nil {
+		return err
+	}
+	if o.AllNamespaces {
+		o.Namespace = ""
+	}
+	cronJobList, err := dynamic.Resource(types.CronJobGVR()).Namespace(o.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: o.LabelSelector,
+		FieldSelector: o.FieldSelector,
+	})
+	if err != nil {
+		return err
+	}
+	if len(cronJobList.Items) == 0 {
+		o.PrintNotFoundResources()
+		return nil
+	}
+	sort.Sort(unstructuredList(cronJobList.Items))
+
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("NAME", "NAMESPACE", "CLUSTER", "TYPE", "SCHEDULE", "LAST-SCHEDULE", "CREATED-TIME")
+	for _, obj := range cronJobList.Items {
+		cronJob := &batchv1.CronJob{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, cronJob); err != nil {
+			return err
+		}
+		schedule := cronJob.Spec.Schedule
+		if at, ok := cronJob.Annotations[scheduledOpsAtAnnotationKey]; ok {
+			schedule = "at " + at
+		}
+		tbl.AddRow(cronJob.Name, cronJob.Namespace, cronJob.Labels[constant.AppInstanceLabelKey],
+			cronJob.Labels[constant.OpsRequestTypeLabelKey], schedule,
+			util.TimeFormat(cronJob.Status.LastScheduleTime), util.TimeFormat(&cronJob.CreationTimestamp))
+	}
+	tbl.Print()
+	return nil
+}
+
+func NewDeleteScheduledOpsCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := action.NewDeleteOptions(f, streams, types.CronJobGVR())
+	o.PreDeleteHook = preDeleteScheduledOps
+	cmd := &cobra.Command{
+		Use:     "delete-scheduled-ops [NAME]",
+		Short:   "Delete the OpsRequests scheduled by --schedule or --at.",
+		Example: deleteScheduledOpsExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Names = args
+			if o.LabelSelector != "" {
+				o.LabelSelector = scheduledOpsSelector(o.LabelSelector)
+			}
+			util.CheckErr(o.Run())
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd
+}
+
+// preDeleteScheduledOps makes sure only the CronJobs of the scheduled OpsRequests are deleted.
+func preDeleteScheduledOps(o *action.DeleteOptions, obj runtime.Object) error {
+	cronJob, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+	if cronJob.GetLabels()[scheduledOpsLabelKey] != "true" {
+		return fmt.Errorf(`CronJob "%s" is not a scheduled OpsRequest`, cronJob.GetName())
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("scheduled operations", func() {
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace))
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	It("validate the schedule", func() {
+		now := time.Date(2023, 11, 10, 12, 0, 0, 0, time.UTC)
+		o := &scheduleOptions{Schedule: "0 2 * * *", At: "2023-11-11T02:00:00Z"}
+		Expect(o.validateSchedule(now)).Should(HaveOccurred())
+		o.At = ""
+		Expect(o.validateSchedule(now)).Should(Succeed())
+		o.Schedule = "0 2 * *"
+		Expect(o.validateSchedule(now)).Should(HaveOccurred())
+
+		o = &scheduleOptions{At: "2023-11-11T02:00:30+08:00"}
+		Expect(o.validateSchedule(now)).Should(Succeed())
+		schedule, timeZone := o.cronSchedule()
+		Expect(schedule).Should(Equal("1 18 10 11 *"))
+		Expect(*timeZone).Should(Equal("Etc/UTC"))
+		o.At = "2023-11-10T11:00:00Z"
+		Expect(o.validateSchedule(now)).Should(MatchError(ContainSubstring("not in the future")))
+		o.At = "2025-11-10T11:00:00Z"
+		Expect(o.validateSchedule(now)).Should(MatchError(ContainSubstring("within one year")))
+	})
+
+	It("schedule a restart", func() {
+		o := newBaseOperationsOptions(tf, streams, appsv1alpha1.RestartType, true)
+		o.Args = []string{testing.ClusterName}
+		Expect(o.Complete()).Should(Succeed())
+		o.Client = testing.FakeClientSet()
+		o.DryRun = "none"
+		o.autoApprove = true
+		o.At = time.Now().Add(time.Hour).Format(time.RFC3339)
+		Expect(o.runOnClusters((*OperationsOptions).CompleteRestartOps)).Should(MatchError(ContainSubstring("--image")))
+		o.Image = "apecloud/kubeblocks-tools:0.8.0"
+		// the CronJob is allowed to delete itself before it is created
+		tf.FakeDynamicClient.PrependReactor("create", "cronjobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			name := action.(clienttesting.CreateAction).GetObject().(*unstructured.Unstructured).GetName()
+			_, err := o.Client.RbacV1().RoleBindings(testing.Namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			return err != nil, nil, err
+		})
+		Expect(o.runOnClusters((*OperationsOptions).CompleteRestartOps)).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("list-scheduled-ops"))
+
+		cronJobs, err := tf.FakeDynamicClient.Resource(types.CronJobGVR()).Namespace(testing.Namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(cronJobs.Items).Should(HaveLen(1))
+		cronJob := &batchv1.CronJob{}
+		Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(cronJobs.Items[0].Object, cronJob)).Should(Succeed())
+		Expect(cronJob.Labels[constant.AppInstanceLabelKey]).Should(Equal(testing.ClusterName))
+		Expect(cronJob.Annotations).Should(HaveKey(scheduledOpsAtAnnotationKey))
+		container := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+		Expect(container.Command[2]).Should(ContainSubstring("; rc=$?; kubectl delete cronjob " + cronJob.Name))
+		Expect(container.Command[2]).Should(ContainSubstring("|| true; exit $rc"))
+		Expect(*cronJob.Spec.JobTemplate.Spec.BackoffLimit).Should(BeZero())
+		Expect(container.Env[0].Value).Should(ContainSubstring(`"kind":"OpsRequest"`))
+		Expect(container.Image).Should(Equal(o.Image))
+		_, err = o.Client.RbacV1().RoleBindings(testing.Namespace).Get(context.TODO(), scheduledOpsServiceAccount, metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		By("the CronJob is only allowed to delete itself")
+		role, err := o.Client.RbacV1().Roles(testing.Namespace).Get(context.TODO(), scheduledOpsServiceAccount, metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(role.Rules).Should(HaveLen(1))
+		Expect(role.Rules[0].Resources).Should(ConsistOf(types.ResourceOpsRequests))
+		role, err = o.Client.RbacV1().Roles(testing.Namespace).Get(context.TODO(), cronJob.Name, metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(role.Rules[0].ResourceNames).Should(ConsistOf(cronJob.Name))
+		Expect(role.Rules[0].Verbs).Should(ConsistOf("delete"))
+		Expect(role.OwnerReferences).Should(HaveLen(1))
+		Expect(role.OwnerReferences[0].Name).Should(Equal(cronJob.Name))
+		roleBinding, err := o.Client.RbacV1().RoleBindings(testing.Namespace).Get(context.TODO(), cronJob.Name, metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(roleBinding.OwnerReferences).Should(HaveLen(1))
+
+		By("list the scheduled OpsRequests")
+		out.Reset()
+		listOptions := &scheduledOpsListOptions{ListOptions: action.NewListOptions(tf, streams, types.CronJobGVR())}
+		listOptions.LabelSelector = scheduledOpsSelector("")
+		Expect(listOptions.Complete()).Should(Succeed())
+		Expect(listOptions.printScheduledOps()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring(cronJob.Name))
+		Expect(out.String()).Should(ContainSubstring("at " + o.atTime.Format(time.RFC3339)))
+
+		By("only delete the scheduled OpsRequests")
+		Expect(preDeleteScheduledOps(nil, &cronJobs.Items[0])).Should(Succeed())
+		cronJobs.Items[0].SetLabels(nil)
+		Expect(preDeleteScheduledOps(nil, &cronJobs.Items[0])).Should(HaveOccurred())
+	})
+
+	It("commands", func() {
+		Expect(NewListScheduledOpsCmd(tf, streams)).ShouldNot(BeNil())
+		Expect(NewDeleteScheduledOpsCmd(tf, streams)).ShouldNot(BeNil())
+	})
+})
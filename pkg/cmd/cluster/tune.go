@@ -0,0 +1,384 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"embed"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/configuration/core"
+
+	classutil "github.com/apecloud/kbcli/pkg/cmd/class"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var (
+	//go:embed tune_profiles/*
+	builtinTuneProfiles embed.FS
+
+	// tuneFormulaPackages are the CUE packages which can be referenced by the formulas of the tuning profiles
+	tuneFormulaPackages = []string{"list", "math", "strconv", "strings"}
+
+	tuneExample = templates.Examples(`
+		# tune the parameters of the cluster mycluster for online transaction processing
+		kbcli cluster tune mycluster --profile oltp
+
+		# tune the parameters of the component mysql for online analytical processing
+		kbcli cluster tune mycluster --profile olap --components mysql
+
+		# print the tuned parameters and the OpsRequest without submitting it
+		kbcli cluster tune mycluster --profile memory-optimized --dry-run
+
+		# user-defined profiles are read from $HOME/.kbcli/tune_profiles/<profile>.yaml, and
+		# override the built-in profiles with the same name
+		kbcli cluster tune mycluster --profile my-profile`)
+)
+
+// tuneProfile is a set of parameter formulas, which are evaluated with the CPU and memory of the component.
+type tuneProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters are the formulas of the parameters, keyed by the character type of the component, e.g. mysql.
+	Parameters map[string]map[string]string `json:"parameters"`
+}
+
+// componentResources are the resources of a component the tuning formulas are evaluated with.
+type componentResources struct {
+	cpu    resource.Quantity
+	memory resource.Quantity
+}
+
+type tuneOptions struct {
+	*configOpsOptions
+
+	Profile string
+}
+
+func NewTuneCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &tuneOptions{
+		configOpsOptions: &configOpsOptions{
+			OperationsOptions: newBaseOperationsOptions(f, streams, appsv1alpha1.ReconfiguringType, true),
+		},
+	}
+	cmd := &cobra.Command{
+		Use:               "tune NAME --profile PROFILE",
+		Short:             "Tune the parameters of a component with a profile derived from its CPU and memory.",
+		Long:              "Tune the parameters of a component with a profile derived from its CPU and memory. The CPU and memory are taken from the class of the component, or the resource requests if it has no class. Built-in profiles are oltp, olap and memory-optimized.",
+		Example:           tuneExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Args = args
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.CreateOptions.Complete())
+			cmdutil.CheckErr(o.run())
+		},
+	}
+	o.addCommonFlags(cmd, f)
+	cmd.Flags().StringVar(&o.Profile, "profile", "", "The tuning profile, one of oltp, olap, memory-optimized or a user-defined profile in $HOME/.kbcli/tune_profiles")
+	cmd.Flags().StringVar(&o.CfgTemplateName, "config-spec", "", "Specify the name of the configuration template to be tuned. If not specified, the one supporting the tuned parameters is used.")
+	cmd.Flags().StringVar(&o.CfgFile, "config-file", "", "Specify the name of the configuration file to be tuned (e.g. for mysql: --config-file=my.cnf).")
+	cmd.Flags().BoolVar(&o.autoApprove, "auto-approve", false, "Skip interactive approval before reconfiguring the cluster")
+	util.CheckErr(cmd.MarkFlagRequired("profile"))
+	return cmd
+}
+
+// run evaluates the profile with the resources of the component, and reconfigures the parameters
+// different from the current config by a Reconfiguring OpsRequest.
+func (o *tuneOptions) run() error {
+	if o.Name == "" {
+		return makeMissingClusterNameErr()
+	}
+	profile, err := getTuneProfile(o.Profile)
+	if err != nil {
+		return err
+	}
+	// the components are tuned one by one since each of them has its own resources
+	if len(o.ComponentNames) > 1 {
+		return fmt.Errorf("only one component can be tuned at a time, but %d components are specified: %s", len(o.ComponentNames), strings.Join(o.ComponentNames, ","))
+	}
+	if len(o.ComponentNames) > 0 {
+		o.ComponentName = o.ComponentNames[0]
+	}
+	wrapper, err := newConfigWrapper(o.CreateOptions, o.Name, o.ComponentName, o.CfgTemplateName, o.CfgFile, nil)
+	if err != nil {
+		return err
+	}
+	if err = wrapper.fillComponent(); err != nil {
+		return err
+	}
+	compSpec := wrapper.clusterObj.Spec.GetComponentByName(wrapper.componentName)
+	if compSpec == nil {
+		return makeComponentNotExistErr(o.Name, wrapper.componentName)
+	}
+	compDef := wrapper.clusterDefObj.GetComponentDefByName(compSpec.ComponentDefRef)
+	if compDef == nil || compDef.CharacterType == "" {
+		return fmt.Errorf("the engine of component %s is unknown, it can not be tuned by a profile", compSpec.Name)
+	}
+	resources, err := getComponentResources(o.Dynamic, wrapper.clusterObj.Spec.ClusterDefRef, compSpec)
+	if err != nil {
+		return err
+	}
+	params, err := profile.evaluate(compDef.CharacterType, resources)
+	if err != nil {
+		return err
+	}
+
+	wrapper.updatedParams = params
+	if err = wrapper.AutoFillRequiredParam(); err != nil {
+		return err
+	}
+	o.wrapper = wrapper
+	o.ComponentName = wrapper.ComponentName()
+	if err = o.fillRequiredParams(); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Tune component %s with profile %s (cpu: %s, memory: %s)\n", o.ComponentName, profile.Name, resources.cpu.String(), resources.memory.String())
+	if o.KeyValues, err = o.diffTunedParams(params); err != nil {
+		return err
+	}
+	if len(o.KeyValues) == 0 {
+		fmt.Fprintf(o.Out, "The parameters of component %s are already tuned with profile %s\n", o.ComponentName, profile.Name)
+		return nil
+	}
+	if err = o.Validate(); err != nil {
+		return err
+	}
+	return o.Run()
+}
+
+// diffTunedParams prints the tuned parameters different from the current config file, and returns them.
+func (o *tuneOptions) diffTunedParams(params map[string]*string) (map[string]*string, error) {
+	tpl := o.wrapper.ConfigTemplateSpec()
+	if tpl.ConfigConstraintRef == "" {
+		return nil, core.MakeError("config spec[%s] has no config constraint, it can not be tuned", tpl.Name)
+	}
+	cc := appsv1alpha1.ConfigConstraint{}
+	if err := util.GetResourceObjectFromGVR(types.ConfigConstraintGVR(), client.ObjectKey{Name: tpl.ConfigConstraintRef}, o.Dynamic, &cc); err != nil {
+		return nil, err
+	}
+	cmObj := corev1.ConfigMap{}
+	cmKey := client.ObjectKey{
+		Name:      core.GetComponentCfgName(o.Name, o.ComponentName, o.CfgTemplateName),
+		Namespace: o.Namespace,
+	}
+	if err := util.GetResourceObjectFromGVR(types.ConfigmapGVR(), cmKey, o.Dynamic, &cmObj); err != nil {
+		return nil, err
+	}
+	current, err := parseConfigParams(o.CfgFile, cmObj.Data[o.CfgFile], cc.Spec.FormatterConfig)
+	if err != nil {
+		return nil, err
+	}
+	paramTypes, err := util.GetParameterTypes(&cc.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := map[string]*string{}
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("PARAMETER", "CURRENT", "TUNED")
+	for _, key := range sortedKeys(params) {
+		value, ok := current[key]
+		if ok && sameConfigValue(*params[key], value, paramTypes[key] == "boolean") {
+			continue
+		}
+		changed[key] = params[key]
+		tbl.AddRow(key, printDriftValue(optionalValue(value, ok)), *params[key])
+	}
+	if len(changed) > 0 {
+		tbl.Print()
+	}
+	return changed, nil
+}
+
+// getComponentResources gets the CPU and memory of the component from its class, or from the resource
+// requests (and the limits if not requested) if it has no class.
+func getComponentResources(dynamic dynamic.Interface, clusterDefName string, compSpec *appsv1alpha1.ClusterComponentSpec) (*componentResources, error) {
+	if compSpec.ClassDefRef != nil && compSpec.ClassDefRef.Class != "" {
+		clsMgr, _, err := classutil.GetManager(dynamic, clusterDefName)
+		if err != nil {
+			return nil, err
+		}
+		for _, cls := range clsMgr.GetClasses()[compSpec.ComponentDefRef] {
+			if cls.ClassDefRef.Class != compSpec.ClassDefRef.Class || (compSpec.ClassDefRef.Name != "" && cls.ClassDefRef.Name != compSpec.ClassDefRef.Name) {
+				continue
+			}
+			return &componentResources{cpu: cls.CPU, memory: cls.Memory}, nil
+		}
+		return nil, fmt.Errorf("class %s of component %s is not found", compSpec.ClassDefRef.Class, compSpec.Name)
+	}
+
+	res := &componentResources{}
+	for _, list := range []corev1.ResourceList{compSpec.Resources.Requests, compSpec.Resources.Limits} {
+		if cpu, ok := list[corev1.ResourceCPU]; ok && res.cpu.IsZero() {
+			res.cpu = cpu
+		}
+		if memory, ok := list[corev1.ResourceMemory]; ok && res.memory.IsZero() {
+			res.memory = memory
+		}
+	}
+	if res.cpu.IsZero() || res.memory.IsZero() {
+		return nil, fmt.Errorf("the CPU and memory of component %s are specified by neither the class nor the resources", compSpec.Name)
+	}
+	return res, nil
+}
+
+// loadTuneProfiles loads the built-in profiles and the user-defined profiles, the user-defined
+// profiles override the built-in ones with the same name.
+func loadTuneProfiles() (map[string]*tuneProfile, error) {
+	profiles := map[string]*tuneProfile{}
+	entries, err := builtinTuneProfiles.ReadDir("tune_profiles")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		data, err := builtinTuneProfiles.ReadFile("tune_profiles/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err = addTuneProfile(profiles, data, entry.Name()); err != nil {
+			return nil, err
+		}
+	}
+
+	homeDir, err := util.GetCliHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(homeDir, types.CliTuneProfilesDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err = addTuneProfile(profiles, data, file); err != nil {
+			return nil, err
+		}
+	}
+	return profiles, nil
+}
+
+func addTuneProfile(profiles map[string]*tuneProfile, data []byte, file string) error {
+	profile := &tuneProfile{}
+	if err := yaml.Unmarshal(data, profile); err != nil {
+		return fmt.Errorf("failed to parse the tuning profile %s: %v", file, err)
+	}
+	if profile.Name == "" {
+		profile.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	profiles[profile.Name] = profile
+	return nil
+}
+
+func getTuneProfile(name string) (*tuneProfile, error) {
+	profiles, err := loadTuneProfiles()
+	if err != nil {
+		return nil, err
+	}
+	if profile, ok := profiles[name]; ok {
+		return profile, nil
+	}
+	return nil, fmt.Errorf("tuning profile %s is not found, available profiles: %s", name, strings.Join(sortedKeys(profiles), ", "))
+}
+
+// evaluate evaluates the formulas of the engine with the resources of the component.
+func (p *tuneProfile) evaluate(engine string, res *componentResources) (map[string]*string, error) {
+	formulas, ok := p.Parameters[engine]
+	if !ok || len(formulas) == 0 {
+		return nil, fmt.Errorf("tuning profile %s has no parameter for engine %s", p.Name, engine)
+	}
+	vars := map[string]string{
+		"cpu":      strconv.FormatFloat(float64(res.cpu.MilliValue())/1000, 'f', -1, 64),
+		"memory":   strconv.FormatInt(res.memory.Value(), 10),
+		"memoryMi": strconv.FormatInt(res.memory.Value()/(1<<20), 10),
+	}
+	params := make(map[string]*string, len(formulas))
+	for param, formula := range formulas {
+		value, err := evalTuneFormula(formula, vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate the formula of parameter %s in tuning profile %s: %v", param, p.Name, err)
+		}
+		params[param] = &value
+	}
+	return params, nil
+}
+
+// evalTuneFormula evaluates the formula as a CUE expression, the integral numbers are formatted without fractions.
+func evalTuneFormula(formula string, vars map[string]string) (string, error) {
+	var b strings.Builder
+	for _, pkg := range tuneFormulaPackages {
+		if regexp.MustCompile(`\b` + pkg + `\.`).MatchString(formula) {
+			fmt.Fprintf(&b, "import %q\n", pkg)
+		}
+	}
+	for _, name := range sortedKeys(vars) {
+		fmt.Fprintf(&b, "%s: %s\n", name, vars[name])
+	}
+	fmt.Fprintf(&b, "result: %s\n", formula)
+
+	v := cuecontext.New().CompileString(b.String()).LookupPath(cue.ParsePath("result"))
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return "", err
+	}
+	switch v.Kind() {
+	case cue.StringKind:
+		return v.String()
+	case cue.BoolKind:
+		value, err := v.Bool()
+		return strconv.FormatBool(value), err
+	case cue.IntKind, cue.FloatKind, cue.NumberKind:
+		if i, err := v.Int64(); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return "", err
+		}
+		if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return strconv.FormatInt(int64(f), 10), nil
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported result %s of kind %s", v, v.Kind())
+	}
+}
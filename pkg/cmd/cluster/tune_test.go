@@ -0,0 +1,170 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	cfgcore "github.com/apecloud/kubeblocks/pkg/configuration/core"
+	testapps "github.com/apecloud/kubeblocks/pkg/testutil/apps"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("tune", func() {
+	const (
+		ns             = "default"
+		clusterDefName = "test-clusterdef"
+		clusterName    = "test-cluster"
+		compDefName    = "replicasets"
+		compName       = "mysql"
+		configSpecName = "mysql-config-tpl"
+		// the buffer pool is already tuned with the oltp profile for 4Gi memory
+		renderedConfig = "[mysqld]\ninnodb_buffer_pool_size=3G\nmax_connections=100\n"
+	)
+
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	BeforeEach(func() {
+		configmap := testapps.NewCustomizedObj("resources/mysql-config-template.yaml", &corev1.ConfigMap{}, testapps.WithNamespace(ns))
+		constraint := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		componentConfig := testapps.NewConfigMap(ns, cfgcore.GetComponentCfgName(clusterName, compName, configSpecName), testapps.SetConfigMapData("my.cnf", renderedConfig))
+		clusterDefObj := testapps.NewClusterDefFactory(clusterDefName).
+			AddComponentDef(testapps.StatefulMySQLComponent, compDefName).
+			AddConfigTemplate(configSpecName, configmap.Name, constraint.Name, ns, "mysql-config").
+			GetObject()
+		clusterObj := testapps.NewClusterFactory(ns, clusterName, clusterDefObj.Name, "").
+			AddComponent(compName, compDefName).
+			SetResources(corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			}}).GetObject()
+		objs := []runtime.Object{configmap, constraint, clusterDefObj, clusterObj, componentConfig}
+
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(ns)
+		tf.Client = &clientfake.RESTClient{}
+		tf.FakeDynamicClient = testing.FakeDynamicClient(objs...)
+		DeferCleanup(tf.Cleanup)
+
+		home, err := os.MkdirTemp(os.TempDir(), "test-")
+		Expect(err).ShouldNot(HaveOccurred())
+		DeferCleanup(os.RemoveAll, home)
+		GinkgoT().Setenv(types.CliHomeEnv, home)
+	})
+
+	newOptions := func(profile string) *tuneOptions {
+		o := &tuneOptions{
+			configOpsOptions: &configOpsOptions{
+				OperationsOptions: newBaseOperationsOptions(tf, streams, appsv1alpha1.ReconfiguringType, true),
+			},
+			Profile: profile,
+		}
+		o.Args = []string{clusterName}
+		Expect(o.CreateOptions.Complete()).Should(Succeed())
+		o.autoApprove = true
+		return o
+	}
+
+	It("evaluate the formulas of the profiles", func() {
+		vars := map[string]string{"cpu": "0.5", "memory": "1073741824", "memoryMi": "1024"}
+		Expect(evalTuneFormula("div(memory * 3, 4)", vars)).Should(Equal("805306368"))
+		Expect(evalTuneFormula("list.Max([1, math.Floor(cpu * 3)])", vars)).Should(Equal("1"))
+		Expect(evalTuneFormula(`"\(div(memoryMi, 4))MB"`, vars)).Should(Equal("256MB"))
+		Expect(evalTuneFormula("cpu * 3", vars)).Should(Equal("1.5"))
+		_, err := evalTuneFormula("cores * 2", vars)
+		Expect(err).Should(HaveOccurred())
+
+		profiles, err := loadTuneProfiles()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(profiles).Should(HaveKey("oltp"))
+		Expect(profiles).Should(HaveKey("olap"))
+		Expect(profiles).Should(HaveKey("memory-optimized"))
+		res := &componentResources{cpu: resource.MustParse("500m"), memory: resource.MustParse("1Gi")}
+		for _, profile := range profiles {
+			for _, engine := range []string{"mysql", "postgresql"} {
+				_, err = profile.evaluate(engine, res)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+		}
+
+		By("the user-defined profiles override the built-in ones")
+		home, _ := os.LookupEnv(types.CliHomeEnv)
+		Expect(os.MkdirAll(filepath.Join(home, types.CliTuneProfilesDir), 0755)).Should(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, types.CliTuneProfilesDir, "oltp.yaml"), []byte("parameters:\n  mysql:\n    max_connections: div(memoryMi, 2)\n"), 0644)).Should(Succeed())
+		profile, err := getTuneProfile("oltp")
+		Expect(err).ShouldNot(HaveOccurred())
+		params, err := profile.evaluate("mysql", res)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(params).Should(HaveLen(1))
+		Expect(*params["max_connections"]).Should(Equal("512"))
+		_, err = getTuneProfile("not-exist")
+		Expect(err).Should(MatchError(ContainSubstring("available profiles: memory-optimized, olap, oltp")))
+	})
+
+	It("tune the parameters of the component", func() {
+		Expect(NewTuneCmd(tf, streams)).ShouldNot(BeNil())
+		Expect(newOptions("oltp").run()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("Tune component mysql with profile oltp (cpu: 2, memory: 4Gi)"))
+		Expect(out.String()).Should(MatchRegexp(`max_connections\s+100\s+341`))
+		Expect(out.String()).Should(MatchRegexp(`thread_cache_size\s+<unset>\s+24`))
+		Expect(out.String()).ShouldNot(ContainSubstring("innodb_buffer_pool_size"))
+
+		objs, err := tf.FakeDynamicClient.Resource(types.OpsGVR()).Namespace(ns).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(objs.Items).Should(HaveLen(1))
+		ops := appsv1alpha1.OpsRequest{}
+		Expect(runtime.DefaultUnstructuredConverter.FromUnstructured(objs.Items[0].Object, &ops)).Should(Succeed())
+		Expect(ops.Spec.Reconfigure.ComponentName).Should(Equal(compName))
+		keys := ops.Spec.Reconfigure.Configurations[0].Keys
+		Expect(keys[0].Key).Should(Equal("my.cnf"))
+		Expect(keys[0].Parameters).Should(HaveLen(4))
+
+		By("only one component can be tuned at a time")
+		o := newOptions("oltp")
+		o.ComponentNames = []string{compName, "other"}
+		Expect(o.run()).Should(MatchError(ContainSubstring("only one component can be tuned at a time")))
+
+		By("the profile should support the engine")
+		home, _ := os.LookupEnv(types.CliHomeEnv)
+		Expect(os.MkdirAll(filepath.Join(home, types.CliTuneProfilesDir), 0755)).Should(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, types.CliTuneProfilesDir, "redis.yaml"), []byte("parameters:\n  redis:\n    maxmemory: div(memory, 2)\n"), 0644)).Should(Succeed())
+		Expect(newOptions("redis").run()).Should(MatchError(ContainSubstring("has no parameter for engine mysql")))
+	})
+})
@@ -0,0 +1,469 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+	"github.com/apecloud/kubeblocks/pkg/lorry/engines/models"
+	"github.com/apecloud/kubeblocks/pkg/lorry/engines/register"
+
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var verifyBackupExample = templates.Examples(`
+	# verify the backup by restoring it into a temporary cluster in a scratch namespace
+	kbcli cluster verify-backup mybackup
+
+	# verify the backup with the user-supplied SQL, and keep the restored cluster for inspection
+	kbcli cluster verify-backup mybackup --sql "SELECT COUNT(*) FROM mydb.orders" --keep
+
+	# restore the backup into the existing namespace verify with less resources
+	kbcli cluster verify-backup mybackup --target-namespace verify --set cpu=1,memory=1Gi`)
+
+const (
+	backupVerificationPassed = "Passed"
+	backupVerificationFailed = "Failed"
+)
+
+// backupVerification is the result of verifying a backup by test-restore, it is recorded as an annotation of the backup.
+type backupVerification struct {
+	Phase      string              `json:"phase"`
+	VerifiedAt string              `json:"verifiedAt"`
+	Cluster    string              `json:"cluster"`
+	Namespace  string              `json:"namespace"`
+	Checks     []backupSanityCheck `json:"checks,omitempty"`
+	Message    string              `json:"message,omitempty"`
+}
+
+// backupSanityCheck is a check run in an instance of the restored cluster.
+type backupSanityCheck struct {
+	Component string `json:"component"`
+	Instance  string `json:"instance"`
+	Check     string `json:"check"`
+	Passed    bool   `json:"passed"`
+	Output    string `json:"output,omitempty"`
+}
+
+// backupSanityProbe checks the restored data of an instance by appending the arguments to the connect
+// command of the engine. The connection is checked by counting the user tables or keys.
+type backupSanityProbe struct {
+	countArgs string
+	unit      string
+	// sqlArgs formats the user-supplied SQL, it is empty if the engine does not support SQL
+	sqlArgs string
+}
+
+var (
+	mysqlSanityProbe = backupSanityProbe{
+		countArgs: `-N -s -e "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')"`,
+		unit:      "tables",
+		sqlArgs:   "-N -s -e %s",
+	}
+	postgresSanityProbe = backupSanityProbe{
+		countArgs: `-A -t -c "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema NOT IN ('pg_catalog', 'information_schema')"`,
+		unit:      "tables",
+		sqlArgs:   "-A -t -c %s",
+	}
+	redisSanityProbe = backupSanityProbe{
+		countArgs: "DBSIZE",
+		unit:      "keys",
+	}
+
+	// backupSanityProbes are the probes of the engines, keyed by the character type
+	backupSanityProbes = map[string]backupSanityProbe{
+		string(models.MySQL):              mysqlSanityProbe,
+		string(models.WeSQL):              mysqlSanityProbe,
+		string(models.PolarDBX):           mysqlSanityProbe,
+		string(models.PostgreSQL):         postgresSanityProbe,
+		string(models.OfficialPostgreSQL): postgresSanityProbe,
+		string(models.ApecloudPostgreSQL): postgresSanityProbe,
+		string(models.Redis):              redisSanityProbe,
+	}
+)
+
+type VerifyBackupOptions struct {
+	Factory   cmdutil.Factory
+	Dynamic   dynamic.Interface
+	Client    kubernetes.Interface
+	Namespace string
+
+	BackupName      string
+	TargetNamespace string
+	SQL             []string
+	Values          []string
+	Keep            bool
+	Timeout         time.Duration
+
+	backup *dpv1alpha1.Backup
+	// target is the name of the restored cluster, createdNamespace is true if the scratch namespace is created by the command
+	target           string
+	createdNamespace bool
+
+	// execInPod runs the command in the container of the pod and returns the stdout
+	execInPod func(pod *corev1.Pod, container string, command []string) (string, error)
+	genericiooptions.IOStreams
+}
+
+func NewVerifyBackupCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &VerifyBackupOptions{Factory: f, IOStreams: streams, execInPod: newInstanceCommandExecutor(f)}
+	cmd := &cobra.Command{
+		Use:   "verify-backup BACKUP",
+		Short: "Verify a backup by restoring it into a temporary cluster and running sanity checks.",
+		Long: templates.LongDesc(`
+			Verify a backup by restoring it into a temporary cluster with one replica per component in a scratch
+			namespace. After the restore is completed, the sanity checks of the engine are run in an instance of each
+			component, including connecting to it, counting the tables or keys and the user-supplied SQL. The result
+			is recorded as the annotation dataprotection.kubeblocks.io/verification of the backup, and the temporary
+			cluster and namespace are deleted unless --keep is specified.`),
+		Example:           verifyBackupExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.BackupGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVar(&o.TargetNamespace, "target-namespace", "", "The existing namespace to restore the backup into, a scratch namespace is created and deleted if not specified")
+	cmd.Flags().StringArrayVar(&o.SQL, "sql", nil, "The SQL to run in the restored cluster, it fails the verification if the SQL fails, can be specified multiple times")
+	cmd.Flags().StringArrayVar(&o.Values, "set", []string{}, "Override the resources of the restored cluster including cpu, memory, replicas and storage, each set corresponds to a component (e.g. --set cpu=1,memory=1Gi)")
+	cmd.Flags().BoolVar(&o.Keep, "keep", false, "Keep the restored cluster for inspection instead of deleting it")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", defaultOpsWaitTimeout, "Time to wait for the cluster to be running and for the restore to be completed")
+	return cmd
+}
+
+func (o *VerifyBackupOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return fmt.Errorf("the backup name is required")
+	}
+	o.BackupName = args[0]
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	o.Client, err = o.Factory.KubernetesClientSet()
+	return err
+}
+
+func (o *VerifyBackupOptions) Validate() error {
+	o.backup = &dpv1alpha1.Backup{}
+	if err := util.GetK8SClientObject(o.Dynamic, o.backup, types.BackupGVR(), o.Namespace, o.BackupName); err != nil {
+		return err
+	}
+	if o.backup.Status.Phase != dpv1alpha1.BackupPhaseCompleted {
+		return fmt.Errorf("backup %s is %s, only the completed backup can be verified", o.BackupName, o.backup.Status.Phase)
+	}
+	if _, ok := o.backup.Annotations[constant.ClusterSnapshotAnnotationKey]; !ok {
+		return fmt.Errorf("backup %s has no snapshot of the source cluster, it can not be restored", o.BackupName)
+	}
+	return nil
+}
+
+// Run restores the backup, runs the sanity checks and records the result. The restored cluster
+// is deleted even if the verification fails unless --keep is specified.
+func (o *VerifyBackupOptions) Run() error {
+	suffix := rand.String(5)
+	o.target = "verify-" + suffix
+	if o.TargetNamespace == "" {
+		o.TargetNamespace = "kbcli-verify-" + suffix
+		fmt.Fprintf(o.Out, "Creating scratch namespace %s\n", o.TargetNamespace)
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: o.TargetNamespace}}
+		if _, err := o.Client.CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		o.createdNamespace = true
+	}
+
+	result := o.verify()
+	if result.Phase == backupVerificationPassed {
+		fmt.Fprintf(o.Out, "Backup %s is verified\n", o.BackupName)
+	} else {
+		fmt.Fprintf(o.Out, "Backup %s failed the verification: %s\n", o.BackupName, result.Message)
+	}
+	// the restored cluster is cleaned up even if the result fails to be recorded
+	var errs []error
+	if err := o.recordResult(result); err != nil {
+		errs = append(errs, fmt.Errorf("failed to record the result of the verification: %v", err))
+	}
+	if o.Keep {
+		fmt.Fprintf(o.Out, "Cluster %s in namespace %s is kept for inspection, delete it by \"kbcli cluster delete %s -n %s\"\n",
+			o.target, o.TargetNamespace, o.target, o.TargetNamespace)
+	} else if err := o.cleanup(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to clean up the restored cluster: %v", err))
+	}
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+	if result.Phase != backupVerificationPassed {
+		return fmt.Errorf("backup %s failed the verification", o.BackupName)
+	}
+	return nil
+}
+
+// verify restores the backup and runs the sanity checks, the failure is recorded in the result.
+func (o *VerifyBackupOptions) verify() *backupVerification {
+	result := &backupVerification{
+		Phase:     backupVerificationFailed,
+		Cluster:   o.target,
+		Namespace: o.TargetNamespace,
+	}
+	defer func() {
+		result.VerifiedAt = time.Now().UTC().Format(time.RFC3339)
+	}()
+
+	if err := o.createCluster(); err != nil {
+		result.Message = fmt.Sprintf("failed to restore the backup: %v", err)
+		return result
+	}
+	if err := waitForClusterRunning(o.Dynamic, o.TargetNamespace, o.target, o.Timeout, o.Out); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	// the data may still be loaded by the PostReady restore after the cluster is running
+	if err := waitForClusterRestored(o.Dynamic, o.TargetNamespace, o.target, o.Timeout, o.Out); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	checks, err := o.runSanityChecks()
+	result.Checks = checks
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	o.printChecks(checks)
+	for _, check := range checks {
+		if !check.Passed {
+			result.Message = fmt.Sprintf("check %q failed in instance %s: %s", check.Check, check.Instance, check.Output)
+			return result
+		}
+	}
+	result.Phase = backupVerificationPassed
+	return result
+}
+
+// createCluster restores the backup into a cluster with one replica per component.
+func (o *VerifyBackupOptions) createCluster() error {
+	src, err := getSourceClusterFromBackup(o.backup)
+	if err != nil {
+		return err
+	}
+	createOpts, err := newCreateOptionsFromBackup(o.Factory, o.IOStreams, src, o.target, o.TargetNamespace, o.BackupName, o.Namespace)
+	if err != nil {
+		return err
+	}
+	createOpts.Dynamic = o.Dynamic
+	createOpts.Client = o.Client
+	createOpts.TerminationPolicy = string(appsv1alpha1.WipeOut)
+	for _, comp := range src.Spec.ComponentSpecs {
+		createOpts.Values = append(createOpts.Values, fmt.Sprintf("type=%s,replicas=1", comp.ComponentDefRef))
+	}
+	createOpts.Values = append(createOpts.Values, o.Values...)
+	if err = createOpts.Complete(); err != nil {
+		return err
+	}
+	if err = createOpts.Validate(); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Restoring backup %s into cluster %s in namespace %s\n", o.BackupName, o.target, o.TargetNamespace)
+	return createOpts.Run()
+}
+
+// runSanityChecks runs the checks of the engine in the first running instance of each component.
+func (o *VerifyBackupOptions) runSanityChecks() ([]backupSanityCheck, error) {
+	cls, err := cluster.GetClusterByName(o.Dynamic, o.target, o.TargetNamespace)
+	if err != nil {
+		return nil, err
+	}
+	cd, err := cluster.GetClusterDefByName(o.Dynamic, cls.Spec.ClusterDefRef)
+	if err != nil {
+		return nil, err
+	}
+	src, err := getSourceClusterFromBackup(o.backup)
+	if err != nil {
+		return nil, err
+	}
+	var checks []backupSanityCheck
+	for _, comp := range cls.Spec.ComponentSpecs {
+		compDef := cd.GetComponentDefByName(comp.ComponentDefRef)
+		if compDef == nil {
+			continue
+		}
+		probe, ok := backupSanityProbes[compDef.CharacterType]
+		if !ok {
+			fmt.Fprintf(o.Out, "The engine %s of component %s is not supported to check, skipped\n", compDef.CharacterType, comp.Name)
+			continue
+		}
+		if len(o.SQL) > 0 && probe.sqlArgs == "" {
+			return nil, fmt.Errorf("the engine %s of component %s does not support SQL", compDef.CharacterType, comp.Name)
+		}
+		pod, err := o.getRunningInstance(o.TargetNamespace, o.target, comp.Name)
+		if err != nil {
+			return nil, err
+		}
+		countCheck := o.runSanityCheck(pod, comp.Name, compDef.CharacterType, "count "+probe.unit, probe.countArgs)
+		o.compareWithSource(&countCheck, src.Name, compDef.CharacterType, probe)
+		checks = append(checks, countCheck)
+		for _, sql := range o.SQL {
+			args := fmt.Sprintf(probe.sqlArgs, "'"+strings.ReplaceAll(sql, "'", `'\''`)+"'")
+			checks = append(checks, o.runSanityCheck(pod, comp.Name, compDef.CharacterType, sql, args))
+		}
+	}
+	if len(checks) == 0 {
+		return nil, fmt.Errorf("none of the components of cluster %s supports the sanity checks", o.target)
+	}
+	return checks, nil
+}
+
+// compareWithSource fails the count check if nothing is restored while the source cluster has data. If the
+// source cluster is not available, the empty restored data is only warned since the backup may be empty.
+func (o *VerifyBackupOptions) compareWithSource(check *backupSanityCheck, source, characterType string, probe backupSanityProbe) {
+	if !check.Passed {
+		return
+	}
+	if count, err := strconv.ParseInt(check.Output, 10, 64); err != nil || count != 0 {
+		return
+	}
+	pod, err := o.getRunningInstance(o.Namespace, source, check.Component)
+	if err != nil {
+		printer.Warning(o.Out, "no %s are restored in instance %s, and the source cluster %s is not available to compare: %v\n", probe.unit, check.Instance, source, err)
+		return
+	}
+	sourceCheck := o.runSanityCheck(pod, check.Component, characterType, check.Check, probe.countArgs)
+	sourceCount, err := strconv.ParseInt(sourceCheck.Output, 10, 64)
+	if !sourceCheck.Passed || err != nil {
+		printer.Warning(o.Out, "no %s are restored in instance %s, and failed to count the %s of the source cluster %s: %s\n", probe.unit, check.Instance, probe.unit, source, sourceCheck.Output)
+		return
+	}
+	if sourceCount > 0 {
+		check.Passed = false
+		check.Output = fmt.Sprintf("no %s are restored, but the source cluster %s has %d %s", probe.unit, source, sourceCount, probe.unit)
+	}
+}
+
+func (o *VerifyBackupOptions) getRunningInstance(namespace, clusterName, component string) (*corev1.Pod, error) {
+	pods, err := o.Client.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s,%s=%s", constant.AppInstanceLabelKey, clusterName, constant.KBAppComponentLabelKey, component),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no running instance of component %s of cluster %s is found", component, clusterName)
+}
+
+func (o *VerifyBackupOptions) runSanityCheck(pod *corev1.Pod, component, characterType, check, args string) backupSanityCheck {
+	result := backupSanityCheck{Component: component, Instance: pod.Name, Check: check}
+	engine, err := register.NewClusterCommands(characterType)
+	if err != nil {
+		result.Output = err.Error()
+		return result
+	}
+	command := engine.ConnectCommand(nil)
+	command[len(command)-1] = fmt.Sprintf("%s %s", command[len(command)-1], args)
+	output, err := o.execInPod(pod, engine.Container(), command)
+	if err != nil {
+		result.Output = err.Error()
+		return result
+	}
+	result.Passed = true
+	result.Output = strings.TrimSpace(output)
+	return result
+}
+
+func (o *VerifyBackupOptions) printChecks(checks []backupSanityCheck) {
+	tbl := printer.NewTablePrinter(o.Out)
+	tbl.SetHeader("COMPONENT", "INSTANCE", "CHECK", "RESULT", "OUTPUT")
+	for _, check := range checks {
+		result := printer.BoldGreen("Passed")
+		if !check.Passed {
+			result = printer.BoldRed("Failed")
+		}
+		tbl.AddRow(check.Component, check.Instance, check.Check, result, check.Output)
+	}
+	tbl.Print()
+}
+
+// recordResult records the result of the verification as the annotation of the backup.
+func (o *VerifyBackupOptions) recordResult(result *backupVerification) error {
+	value, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{types.BackupVerificationAnnotationKey: string(value)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = o.Dynamic.Resource(types.BackupGVR()).Namespace(o.Namespace).Patch(context.TODO(), o.BackupName, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// cleanup deletes the restored cluster, and the scratch namespace if it is created by the command.
+func (o *VerifyBackupOptions) cleanup() error {
+	fmt.Fprintf(o.Out, "Deleting cluster %s in namespace %s\n", o.target, o.TargetNamespace)
+	err := o.Dynamic.Resource(types.ClusterGVR()).Namespace(o.TargetNamespace).Delete(context.TODO(), o.target, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if !o.createdNamespace {
+		return nil
+	}
+	fmt.Fprintf(o.Out, "Deleting scratch namespace %s\n", o.TargetNamespace)
+	err = o.Client.CoreV1().Namespaces().Delete(context.TODO(), o.TargetNamespace, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
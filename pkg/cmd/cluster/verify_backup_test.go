@@ -0,0 +1,198 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	clienttesting "k8s.io/client-go/testing"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var _ = Describe("verify backup", func() {
+	const (
+		backupName      = "test-backup"
+		target          = "verify-test"
+		targetNamespace = "kbcli-verify-test"
+	)
+
+	var (
+		streams genericiooptions.IOStreams
+		out     *bytes.Buffer
+		tf      *cmdtesting.TestFactory
+	)
+
+	BeforeEach(func() {
+		streams, _, out, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	fakeBackup := func(phase dpv1alpha1.BackupPhase) *dpv1alpha1.Backup {
+		source := testing.FakeCluster(testing.ClusterName, testing.Namespace)
+		backup := testing.FakeBackupWithCluster(source, backupName)
+		backup.Status.Phase = phase
+		snapshot, _ := json.Marshal(source)
+		backup.Annotations = map[string]string{constant.ClusterSnapshotAnnotationKey: string(snapshot)}
+		return backup
+	}
+
+	newOptions := func(objs ...runtime.Object) *VerifyBackupOptions {
+		tf.FakeDynamicClient = testing.FakeDynamicClient(objs...)
+		o := &VerifyBackupOptions{Factory: tf, IOStreams: streams}
+		Expect(o.Complete([]string{backupName})).Should(Succeed())
+		return o
+	}
+
+	It("validate", func() {
+		Expect(NewVerifyBackupCmd(tf, streams)).ShouldNot(BeNil())
+		Expect(newOptions(fakeBackup(dpv1alpha1.BackupPhaseCompleted)).Validate()).Should(Succeed())
+		Expect(newOptions(fakeBackup(dpv1alpha1.BackupPhaseFailed)).Validate()).Should(MatchError(ContainSubstring("only the completed backup can be verified")))
+
+		backup := fakeBackup(dpv1alpha1.BackupPhaseCompleted)
+		backup.Annotations = nil
+		Expect(newOptions(backup).Validate()).Should(MatchError(ContainSubstring("has no snapshot of the source cluster")))
+	})
+
+	It("check the restored cluster and record the result", func() {
+		o := newOptions(fakeBackup(dpv1alpha1.BackupPhaseCompleted), testing.FakeCluster(target, targetNamespace), testing.FakeClusterDef())
+		Expect(o.Validate()).Should(Succeed())
+		pods := testing.FakePods(2, targetNamespace, target)
+		pods.Items[1].Labels[constant.KBAppComponentLabelKey] = testing.ComponentName + "-1"
+		o.Client = testing.FakeClientSet(pods, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: targetNamespace}})
+		o.target = target
+		o.TargetNamespace = targetNamespace
+		o.createdNamespace = true
+		o.SQL = []string{"SELECT COUNT(*) FROM mydb.t WHERE name = 'a'"}
+		o.execInPod = func(pod *corev1.Pod, container string, command []string) (string, error) {
+			cmd := command[len(command)-1]
+			if strings.Contains(cmd, "information_schema.tables") {
+				return "12\n", nil
+			}
+			Expect(cmd).Should(ContainSubstring(`-N -s -e 'SELECT COUNT(*) FROM mydb.t WHERE name = '\''a'\'''`))
+			if pod.Name == target+"-pod-1" {
+				return "", fmt.Errorf("table mydb.t doesn't exist")
+			}
+			return "3\n", nil
+		}
+
+		checks, err := o.runSanityChecks()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(checks).Should(HaveLen(4))
+		Expect(checks[0]).Should(Equal(backupSanityCheck{Component: testing.ComponentName, Instance: target + "-pod-0", Check: "count tables", Passed: true, Output: "12"}))
+		Expect(checks[1].Passed).Should(BeTrue())
+		Expect(checks[1].Output).Should(Equal("3"))
+		Expect(checks[3].Passed).Should(BeFalse())
+		Expect(checks[3].Output).Should(ContainSubstring("doesn't exist"))
+		o.printChecks(checks)
+		Expect(out.String()).Should(MatchRegexp(`fake-component-name\s+verify-test-pod-0\s+count tables\s+.*Passed.*\s+12`))
+
+		By("record the result as the annotation of the backup")
+		Expect(o.recordResult(&backupVerification{Phase: backupVerificationFailed, Cluster: target, Namespace: targetNamespace, Checks: checks})).Should(Succeed())
+		backup := &dpv1alpha1.Backup{}
+		Expect(util.GetK8SClientObject(o.Dynamic, backup, types.BackupGVR(), testing.Namespace, backupName)).Should(Succeed())
+		result := &backupVerification{}
+		Expect(json.Unmarshal([]byte(backup.Annotations[types.BackupVerificationAnnotationKey]), result)).Should(Succeed())
+		Expect(result.Phase).Should(Equal(backupVerificationFailed))
+		Expect(result.Checks).Should(HaveLen(4))
+		Expect(backup.Annotations).Should(HaveKey(constant.ClusterSnapshotAnnotationKey))
+
+		By("delete the restored cluster and the scratch namespace")
+		Expect(o.cleanup()).Should(Succeed())
+		_, err = o.Dynamic.Resource(types.ClusterGVR()).Namespace(targetNamespace).Get(context.TODO(), target, metav1.GetOptions{})
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		_, err = o.Client.CoreV1().Namespaces().Get(context.TODO(), targetNamespace, metav1.GetOptions{})
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("compare the empty restored data with the source cluster", func() {
+		o := newOptions(fakeBackup(dpv1alpha1.BackupPhaseCompleted), testing.FakeCluster(target, targetNamespace), testing.FakeClusterDef())
+		Expect(o.Validate()).Should(Succeed())
+		o.target = target
+		o.TargetNamespace = targetNamespace
+		o.execInPod = func(pod *corev1.Pod, container string, command []string) (string, error) {
+			if pod.Namespace == targetNamespace {
+				return "0\n", nil
+			}
+			return "12\n", nil
+		}
+		newPods := func(namespace, name string) *corev1.PodList {
+			pods := testing.FakePods(2, namespace, name)
+			pods.Items[1].Labels[constant.KBAppComponentLabelKey] = testing.ComponentName + "-1"
+			return pods
+		}
+
+		o.Client = testing.FakeClientSet(newPods(targetNamespace, target), newPods(testing.Namespace, testing.ClusterName))
+		checks, err := o.runSanityChecks()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(checks).Should(HaveLen(2))
+		Expect(checks[0].Passed).Should(BeFalse())
+		Expect(checks[0].Output).Should(Equal("no tables are restored, but the source cluster fake-cluster-name has 12 tables"))
+
+		By("only warn if the source cluster is not available")
+		o.Client = testing.FakeClientSet(newPods(targetNamespace, target))
+		checks, err = o.runSanityChecks()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(checks[0].Passed).Should(BeTrue())
+		Expect(out.String()).Should(ContainSubstring("the source cluster fake-cluster-name is not available to compare"))
+	})
+
+	It("clean up the restored cluster even if the result fails to be recorded", func() {
+		o := newOptions(fakeBackup(dpv1alpha1.BackupPhaseCompleted), testing.FakeClusterDef())
+		Expect(o.Validate()).Should(Succeed())
+		o.Client = testing.FakeClientSet(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: targetNamespace}})
+		o.TargetNamespace = targetNamespace
+		o.createdNamespace = true
+		tf.FakeDynamicClient.PrependReactor("create", "clusters", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, fmt.Errorf("failed to create the cluster")
+		})
+		tf.FakeDynamicClient.PrependReactor("patch", "backups", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, fmt.Errorf("failed to patch the backup")
+		})
+
+		err := o.Run()
+		Expect(err).Should(MatchError(ContainSubstring("failed to record the result of the verification: failed to patch the backup")))
+		_, err = o.Client.CoreV1().Namespaces().Get(context.TODO(), targetNamespace, metav1.GetOptions{})
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+	})
+})
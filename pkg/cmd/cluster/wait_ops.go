@@ -0,0 +1,231 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"golang.org/x/exp/maps"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	dpv1alpha1 "github.com/apecloud/kubeblocks/apis/dataprotection/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+const (
+	// defaultOpsWaitTimeout is the default timeout to wait for the OpsRequest to be completed
+	defaultOpsWaitTimeout = 30 * time.Minute
+
+	// lastWarningEventsCount is the count of the warning events printed when the OpsRequest failed
+	lastWarningEventsCount = 5
+)
+
+// opsRequestWaiter watches the OpsRequest until it is completed and prints the changes
+// of its phase and the progressDetails of the components.
+type opsRequestWaiter struct {
+	dynamic   dynamic.Interface
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	timeout   time.Duration
+	interval  time.Duration
+	out       io.Writer
+
+	// startTime is the time to start waiting, phase and progress are the last printed
+	// phase and status of the progressDetails
+	startTime metav1.Time
+	phase     appsv1alpha1.OpsPhase
+	progress  map[string]appsv1alpha1.ProgressStatus
+}
+
+func newOpsRequestWaiter(dynamic dynamic.Interface, client kubernetes.Interface, namespace, name string,
+	timeout time.Duration, out io.Writer) *opsRequestWaiter {
+	if timeout <= 0 {
+		timeout = defaultOpsWaitTimeout
+	}
+	return &opsRequestWaiter{
+		dynamic:   dynamic,
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		timeout:   timeout,
+		interval:  3 * time.Second,
+		out:       out,
+		progress:  map[string]appsv1alpha1.ProgressStatus{},
+	}
+}
+
+// wait waits for the OpsRequest to be completed, it returns an error if the OpsRequest
+// ends with Failed or Cancelled phase, or it is not completed before timeout.
+func (w *opsRequestWaiter) wait() error {
+	fmt.Fprintf(w.out, "Waiting for OpsRequest %s to be completed, timeout: %s\n", w.name, w.timeout)
+	w.startTime = metav1.Now()
+	ops := &appsv1alpha1.OpsRequest{}
+	err := wait.PollUntilContextTimeout(context.Background(), w.interval, w.timeout, true, func(_ context.Context) (bool, error) {
+		if err := util.GetK8SClientObject(w.dynamic, ops, types.OpsGVR(), w.namespace, w.name); err != nil {
+			return false, err
+		}
+		w.printProgress(ops)
+		return isOpsRequestCompleted(ops.Status.Phase), nil
+	})
+	if err != nil {
+		if wait.Interrupted(err) {
+			return fmt.Errorf("timed out waiting for OpsRequest %s to be completed, current phase: %s", w.name, ops.Status.Phase)
+		}
+		return err
+	}
+
+	duration := util.GetHumanReadableDuration(ops.Status.StartTimestamp, ops.Status.CompletionTimestamp)
+	if ops.Status.Phase == appsv1alpha1.OpsSucceedPhase {
+		fmt.Fprintf(w.out, "OpsRequest %s succeeded in %s\n", w.name, duration)
+		return nil
+	}
+	if err = w.printFailure(ops); err != nil {
+		return err
+	}
+	return fmt.Errorf("OpsRequest %s is %s after %s", w.name, ops.Status.Phase, duration)
+}
+
+// printProgress prints the phase of the OpsRequest and the progressDetails whose status changed.
+func (w *opsRequestWaiter) printProgress(ops *appsv1alpha1.OpsRequest) {
+	elapsed := util.GetHumanReadableDuration(w.startTime, metav1.Now())
+	if ops.Status.Phase != w.phase {
+		w.phase = ops.Status.Phase
+		fmt.Fprintf(w.out, "[%8s] %-12s progress: %s\n", elapsed, ops.Status.Phase, ops.Status.Progress)
+	}
+	compNames := maps.Keys(ops.Status.Components)
+	sort.Strings(compNames)
+	for _, compName := range compNames {
+		for _, detail := range ops.Status.Components[compName].ProgressDetails {
+			objectKey := detail.ObjectKey
+			if len(detail.Group) > 0 {
+				objectKey = fmt.Sprintf("%s(%s)", objectKey, detail.Group)
+			}
+			key := compName + "/" + objectKey
+			if status, ok := w.progress[key]; ok && status == detail.Status {
+				continue
+			}
+			w.progress[key] = detail.Status
+			fmt.Fprintf(w.out, "[%8s]   %-16s %-40s %-12s %s\n", elapsed, compName, objectKey, detail.Status, detail.Message)
+		}
+	}
+}
+
+// printFailure prints the failed condition and the last warning events of the OpsRequest.
+func (w *opsRequestWaiter) printFailure(ops *appsv1alpha1.OpsRequest) error {
+	if cond := getOpsRequestFailedCondition(ops); cond != nil {
+		fmt.Fprintf(w.out, "\nFailed Condition:\n  Type:    %s\n  Reason:  %s\n  Message: %s\n", cond.Type, cond.Reason, cond.Message)
+	}
+
+	events, err := w.client.CoreV1().Events(w.namespace).Search(scheme.Scheme, ops)
+	if err != nil {
+		return err
+	}
+	warnings := util.SortEventsByLastTimestamp(events, corev1.EventTypeWarning)
+	lastEvents := &corev1.EventList{}
+	for i := max(0, len(*warnings)-lastWarningEventsCount); i < len(*warnings); i++ {
+		lastEvents.Items = append(lastEvents.Items, *(*warnings)[i].(*corev1.Event))
+	}
+	printer.PrintAllWarningEvents(lastEvents, w.out)
+	return nil
+}
+
+// getOpsRequestFailedCondition gets the condition which causes the OpsRequest to be failed or cancelled.
+func getOpsRequestFailedCondition(ops *appsv1alpha1.OpsRequest) *metav1.Condition {
+	for i := len(ops.Status.Conditions) - 1; i >= 0; i-- {
+		cond := ops.Status.Conditions[i]
+		if cond.Type == appsv1alpha1.ConditionTypeFailed || cond.Type == appsv1alpha1.ConditionTypeCancelled ||
+			cond.Status == metav1.ConditionFalse {
+			return &cond
+		}
+	}
+	return nil
+}
+
+// waitForClusterRunning waits for the cluster to be running.
+func waitForClusterRunning(dynamic dynamic.Interface, namespace, name string, timeout time.Duration, out io.Writer) error {
+	fmt.Fprintf(out, "Waiting for cluster %s to be running, timeout: %s\n", name, timeout)
+	var phase appsv1alpha1.ClusterPhase
+	err := wait.PollUntilContextTimeout(context.Background(), 5*time.Second, timeout, true, func(_ context.Context) (bool, error) {
+		cls, err := cluster.GetClusterByName(dynamic, name, namespace)
+		if err != nil {
+			return false, err
+		}
+		phase = cls.Status.Phase
+		return phase == appsv1alpha1.RunningClusterPhase, nil
+	})
+	if wait.Interrupted(err) {
+		return fmt.Errorf("timed out waiting for cluster %s to be running, current phase: %s", name, phase)
+	}
+	return err
+}
+
+// waitForClusterRestored waits for the restore of the cluster created from a backup to be finished. The
+// PostReady restore runs after the cluster is running, the restore-from-backup annotation is removed by the
+// controller when all the restores are completed, so the backup should not be deleted before that.
+func waitForClusterRestored(dynamic dynamic.Interface, namespace, name string, timeout time.Duration, out io.Writer) error {
+	fmt.Fprintf(out, "Waiting for the restore of cluster %s to be completed, timeout: %s\n", name, timeout)
+	err := wait.PollUntilContextTimeout(context.Background(), 5*time.Second, timeout, true, func(_ context.Context) (bool, error) {
+		cls, err := cluster.GetClusterByName(dynamic, name, namespace)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := cls.Annotations[constant.RestoreFromBackupAnnotationKey]; !ok {
+			return true, nil
+		}
+		restores, err := dynamic.Resource(types.RestoreGVR()).Namespace(namespace).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", constant.AppInstanceLabelKey, name),
+		})
+		if err != nil {
+			return false, err
+		}
+		for _, restore := range restores.Items {
+			if phase, _, _ := unstructured.NestedString(restore.Object, "status", "phase"); phase == string(dpv1alpha1.RestorePhaseFailed) {
+				return false, fmt.Errorf("restore %s of cluster %s failed, run \"kubectl describe restore %s -n %s\" for more details",
+					restore.GetName(), name, restore.GetName(), namespace)
+			}
+		}
+		return false, nil
+	})
+	if wait.Interrupted(err) {
+		return fmt.Errorf("timed out waiting for the restore of cluster %s to be completed", name)
+	}
+	return err
+}
+
+func isOpsRequestCompleted(phase appsv1alpha1.OpsPhase) bool {
+	return phase == appsv1alpha1.OpsSucceedPhase || phase == appsv1alpha1.OpsFailedPhase || phase == appsv1alpha1.OpsCancelledPhase
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("wait ops", func() {
+	var (
+		out *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		_, _, out, _ = genericiooptions.NewTestIOStreams()
+	})
+
+	fakeOps := func(name string, phase appsv1alpha1.OpsPhase, conditions ...metav1.Condition) *appsv1alpha1.OpsRequest {
+		return &appsv1alpha1.OpsRequest{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: types.OpsGVR().GroupVersion().String(),
+				Kind:       types.KindOps,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: testing.Namespace,
+			},
+			Spec: appsv1alpha1.OpsRequestSpec{
+				ClusterRef: "test-cluster",
+				Type:       appsv1alpha1.RestartType,
+			},
+			Status: appsv1alpha1.OpsRequestStatus{
+				Phase:          phase,
+				Progress:       "1/1",
+				StartTimestamp: metav1.NewTime(time.Now().Add(-time.Minute)),
+				Components: map[string]appsv1alpha1.OpsRequestComponentStatus{
+					testing.ComponentName: {
+						ProgressDetails: []appsv1alpha1.ProgressStatusDetail{
+							{
+								ObjectKey: "Pod/test-cluster-mysql-0",
+								Status:    appsv1alpha1.SucceedProgressStatus,
+								Message:   "Successfully restart: Pod/test-cluster-mysql-0",
+							},
+						},
+					},
+				},
+				Conditions: conditions,
+			},
+		}
+	}
+
+	newWaiter := func(ops *appsv1alpha1.OpsRequest) *opsRequestWaiter {
+		w := newOpsRequestWaiter(testing.FakeDynamicClient(ops), testing.FakeClientSet(), testing.Namespace, ops.Name, time.Second, out)
+		w.interval = 100 * time.Millisecond
+		return w
+	}
+
+	It("wait for the succeed OpsRequest", func() {
+		Expect(newWaiter(fakeOps("restart-succeed", appsv1alpha1.OpsSucceedPhase)).wait()).Should(Succeed())
+		Expect(out.String()).Should(ContainSubstring("Pod/test-cluster-mysql-0"))
+		Expect(out.String()).Should(ContainSubstring("succeeded"))
+	})
+
+	It("wait for the failed OpsRequest", func() {
+		ops := fakeOps("restart-failed", appsv1alpha1.OpsFailedPhase, metav1.Condition{
+			Type:    appsv1alpha1.ConditionTypeFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  "OpsRequestFailed",
+			Message: "failed to restart",
+		})
+		err := newWaiter(ops).wait()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("is Failed"))
+		Expect(getOpsRequestFailedCondition(ops).Reason).Should(Equal("OpsRequestFailed"))
+		Expect(out.String()).Should(ContainSubstring("Message: failed to restart"))
+	})
+
+	It("timed out waiting for the running OpsRequest", func() {
+		err := newWaiter(fakeOps("restart-running", appsv1alpha1.OpsRunningPhase)).wait()
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("timed out"))
+	})
+})
@@ -0,0 +1,614 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ui "github.com/replicatedhq/termui/v3"
+	"github.com/replicatedhq/termui/v3/widgets"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/client-go/dynamic"
+	clientset "k8s.io/client-go/kubernetes"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/cmd/util/podcmd"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+
+	"github.com/apecloud/kbcli/pkg/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var watchExample = templates.Examples(`
+		# watch the instances, OpsRequests and warning events of a cluster in a terminal UI
+		kbcli cluster watch mycluster`)
+
+const (
+	watchMaxOpsRequests = 20
+	watchMaxEvents      = 20
+	watchLogTailLines   = 100
+)
+
+type watchPane int
+
+const (
+	instancePane watchPane = iota
+	opsPane
+)
+
+// watchAction is an action triggered by a key binding, it is performed after the user confirms it.
+type watchAction struct {
+	prompt string
+	do     func() error
+}
+
+type WatchOptions struct {
+	Factory   cmdutil.Factory
+	Client    clientset.Interface
+	Dynamic   dynamic.Interface
+	Namespace string
+	Name      string
+
+	objs      *cluster.ClusterObjects
+	instances []*cluster.InstanceInfo
+	opsList   []*appsv1alpha1.OpsRequest
+	events    []*corev1.Event
+
+	// instanceNames are the names of the instances to filter the watched events, which are
+	// accessed by the watchers too
+	instanceNames map[string]bool
+	mu            sync.RWMutex
+
+	// focus is the pane that the selection keys work on
+	focus    watchPane
+	selected map[watchPane]int
+
+	// popupTitle and popup are the title and text of the popup window, such as the logs of an instance
+	popupTitle string
+	popup      string
+
+	// pending is the action waiting for the confirmation
+	pending *watchAction
+
+	// message is shown in the status bar, such as the result of an action
+	message string
+
+	genericiooptions.IOStreams
+}
+
+func NewWatchCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &WatchOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:               "watch NAME",
+		Short:             "Watch the instances, OpsRequests and warning events of a cluster in a terminal UI.",
+		Example:           watchExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.ClusterGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	return cmd
+}
+
+func (o *WatchOptions) Complete(args []string) error {
+	var err error
+	if len(args) != 1 {
+		return fmt.Errorf("only one cluster name should be specified")
+	}
+	o.Name = args[0]
+	o.selected = map[watchPane]int{}
+	if o.Namespace, _, err = o.Factory.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	if o.Dynamic, err = o.Factory.DynamicClient(); err != nil {
+		return err
+	}
+	o.Client, err = o.Factory.KubernetesClientSet()
+	return err
+}
+
+func (o *WatchOptions) Run() error {
+	if err := o.refresh(); err != nil {
+		return err
+	}
+	if err := ui.Init(); err != nil {
+		return fmt.Errorf("failed to create terminal ui: %v", err)
+	}
+	defer ui.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := o.watchChanges(ctx)
+
+	o.draw()
+	uiEvents := ui.PollEvents()
+	for {
+		select {
+		case e := <-uiEvents:
+			if e.Type == ui.KeyboardEvent && o.handleKey(e.ID) {
+				return nil
+			}
+		case <-changes:
+			if err := o.refresh(); err != nil {
+				o.message = err.Error()
+			}
+		}
+		ui.Clear()
+		o.draw()
+	}
+}
+
+// watchChanges watches the cluster, its instances, OpsRequests and warning events,
+// the returned channel is notified when any of them changes.
+func (o *WatchOptions) watchChanges(ctx context.Context) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+	clusterListOpts := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", o.Name).String()}
+	listOpts := metav1.ListOptions{LabelSelector: util.BuildLabelSelectorByNames("", []string{o.Name})}
+	eventListOpts := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("type", corev1.EventTypeWarning).String()}
+	watchers := []func(ctx context.Context) (watch.Interface, error){
+		func(ctx context.Context) (watch.Interface, error) {
+			return o.Dynamic.Resource(types.ClusterGVR()).Namespace(o.Namespace).Watch(ctx, clusterListOpts)
+		},
+		func(ctx context.Context) (watch.Interface, error) {
+			return o.Dynamic.Resource(types.OpsGVR()).Namespace(o.Namespace).Watch(ctx, listOpts)
+		},
+		func(ctx context.Context) (watch.Interface, error) {
+			return o.Client.CoreV1().Pods(o.Namespace).Watch(ctx, listOpts)
+		},
+		func(ctx context.Context) (watch.Interface, error) {
+			w, err := o.Client.CoreV1().Events(o.Namespace).Watch(ctx, eventListOpts)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+				event, ok := e.Object.(*corev1.Event)
+				return e, ok && o.isClusterEvent(event)
+			}), nil
+		},
+	}
+	for i := range watchers {
+		go keepWatching(ctx, watchers[i], notify)
+	}
+	return changes
+}
+
+// isClusterEvent checks if the event is of the cluster or its instances, the events of the other
+// objects in the namespace are not shown, so they should not trigger the refresh.
+func (o *WatchOptions) isClusterEvent(event *corev1.Event) bool {
+	obj := event.InvolvedObject
+	switch obj.Kind {
+	case types.KindCluster:
+		return obj.Name == o.Name
+	case "Pod":
+		o.mu.RLock()
+		defer o.mu.RUnlock()
+		return o.instanceNames[obj.Name]
+	default:
+		return false
+	}
+}
+
+// keepWatching calls notify for every watch event, and rebuilds the watcher if it is closed by the server.
+func keepWatching(ctx context.Context, newWatcher func(ctx context.Context) (watch.Interface, error), notify func()) {
+	for {
+		if w, err := newWatcher(ctx); err == nil {
+			for range w.ResultChan() {
+				notify()
+			}
+			w.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// refresh gets the objects of the cluster and rebuilds the contents of the panes.
+func (o *WatchOptions) refresh() error {
+	getter := cluster.ObjectsGetter{
+		Client:    o.Client,
+		Dynamic:   o.Dynamic,
+		Name:      o.Name,
+		Namespace: o.Namespace,
+		GetOptions: cluster.GetOptions{
+			WithPod:   true,
+			WithEvent: true,
+		},
+	}
+	objs, err := getter.Get()
+	if err != nil {
+		return err
+	}
+	o.objs = objs
+	o.instances = objs.GetInstanceInfo()
+	sort.SliceStable(o.instances, func(i, j int) bool {
+		if o.instances[i].Component != o.instances[j].Component {
+			return o.instances[i].Component < o.instances[j].Component
+		}
+		return o.instances[i].Name < o.instances[j].Name
+	})
+	instanceNames := map[string]bool{}
+	for _, instance := range o.instances {
+		instanceNames[instance.Name] = true
+	}
+	o.mu.Lock()
+	o.instanceNames = instanceNames
+	o.mu.Unlock()
+
+	o.events = nil
+	if objs.Events != nil {
+		for _, obj := range *util.SortEventsByLastTimestamp(objs.Events, corev1.EventTypeWarning) {
+			o.events = append([]*corev1.Event{obj.(*corev1.Event)}, o.events...)
+		}
+		if len(o.events) > watchMaxEvents {
+			o.events = o.events[:watchMaxEvents]
+		}
+	}
+
+	opsList, err := o.Dynamic.Resource(types.OpsGVR()).Namespace(o.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: util.BuildLabelSelectorByNames("", []string{o.Name}),
+	})
+	if err != nil {
+		return err
+	}
+	// the latest OpsRequest is the first one
+	sort.Sort(sort.Reverse(unstructuredList(opsList.Items)))
+	o.opsList = nil
+	for _, obj := range opsList.Items {
+		if len(o.opsList) == watchMaxOpsRequests {
+			break
+		}
+		ops := &appsv1alpha1.OpsRequest{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ops); err != nil {
+			return err
+		}
+		o.opsList = append(o.opsList, ops)
+	}
+
+	// keep the selection in the range of the rows
+	for pane, rows := range map[watchPane]int{instancePane: len(o.instances), opsPane: len(o.opsList)} {
+		if o.selected[pane] >= rows && rows > 0 {
+			o.selected[pane] = rows - 1
+		}
+	}
+	return nil
+}
+
+// handleKey handles the key event, returns true if the UI should quit.
+func (o *WatchOptions) handleKey(key string) bool {
+	if o.pending != nil {
+		action := o.pending
+		o.pending = nil
+		switch key {
+		case "y", "Y":
+			if err := action.do(); err != nil {
+				o.message = err.Error()
+			}
+		default:
+			o.message = "Canceled"
+		}
+		return false
+	}
+
+	switch key {
+	case "<C-c>":
+		return true
+	case "q", "<Escape>":
+		if o.popup == "" {
+			return key == "q"
+		}
+		o.popupTitle, o.popup = "", ""
+	case "<Tab>":
+		if o.focus == instancePane {
+			o.focus = opsPane
+		} else {
+			o.focus = instancePane
+		}
+	case "<Down>", "j":
+		o.moveSelection(1)
+	case "<Up>", "k":
+		o.moveSelection(-1)
+	case "l":
+		o.showLogs()
+	case "d":
+		o.describeSelectedOps()
+	case "r":
+		if ins := o.selectedInstance(); ins != nil {
+			o.pending = &watchAction{
+				prompt: fmt.Sprintf("Restart component %s? [y/N]", ins.Component),
+				do:     func() error { return o.restart(ins.Component) },
+			}
+		}
+	case "p":
+		if ins := o.selectedInstance(); ins != nil {
+			o.pending = &watchAction{
+				prompt: fmt.Sprintf("Promote instance %s as the new primary or leader? [y/N]", ins.Name),
+				do:     func() error { return o.promote(ins.Component, ins.Name) },
+			}
+		}
+	}
+	return false
+}
+
+func (o *WatchOptions) moveSelection(delta int) {
+	rows := len(o.instances)
+	if o.focus == opsPane {
+		rows = len(o.opsList)
+	}
+	if rows == 0 {
+		return
+	}
+	o.selected[o.focus] = (o.selected[o.focus] + delta + rows) % rows
+}
+
+func (o *WatchOptions) selectedInstance() *cluster.InstanceInfo {
+	if o.focus != instancePane || len(o.instances) == 0 {
+		o.message = "Select an instance in the instances pane first"
+		return nil
+	}
+	return o.instances[o.selected[instancePane]]
+}
+
+func (o *WatchOptions) selectedOps() *appsv1alpha1.OpsRequest {
+	if o.focus != opsPane || len(o.opsList) == 0 {
+		o.message = "Select an OpsRequest in the OpsRequests pane first"
+		return nil
+	}
+	return o.opsList[o.selected[opsPane]]
+}
+
+// showLogs shows the recent logs of the default container of the selected instance in the popup.
+func (o *WatchOptions) showLogs() {
+	ins := o.selectedInstance()
+	if ins == nil {
+		return
+	}
+	var pod *corev1.Pod
+	for i := range o.objs.Pods.Items {
+		if o.objs.Pods.Items[i].Name == ins.Name {
+			pod = &o.objs.Pods.Items[i]
+		}
+	}
+	if pod == nil {
+		o.message = fmt.Sprintf("Instance %s is not found", ins.Name)
+		return
+	}
+	container, err := podcmd.FindOrDefaultContainerByName(pod, "", true, io.Discard)
+	if err != nil {
+		o.message = err.Error()
+		return
+	}
+	tailLines := int64(watchLogTailLines)
+	buf := &bytes.Buffer{}
+	if err = util.WritePogStreamingLog(context.TODO(), o.Client, pod,
+		corev1.PodLogOptions{Container: container.Name, TailLines: &tailLines}, buf); err != nil {
+		o.message = err.Error()
+		return
+	}
+	o.popupTitle = fmt.Sprintf("Logs of %s/%s", ins.Name, container.Name)
+	o.popup = buf.String()
+}
+
+// describeSelectedOps shows the details of the selected OpsRequest in the popup.
+func (o *WatchOptions) describeSelectedOps() {
+	ops := o.selectedOps()
+	if ops == nil {
+		return
+	}
+	o.popupTitle = fmt.Sprintf("OpsRequest %s", ops.Name)
+	o.popup = describeOpsText(ops)
+}
+
+func describeOpsText(ops *appsv1alpha1.OpsRequest) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Type: %s\nComponents: %s\nStatus: %s\nProgress: %s\n", ops.Spec.Type,
+		util.CheckEmpty(getComponentNameFromOps(ops)), ops.Status.Phase, util.CheckEmpty(ops.Status.Progress))
+	fmt.Fprintf(b, "Created Time: %s\nStart Time: %s\nCompletion Time: %s\n", util.TimeFormat(&ops.CreationTimestamp),
+		util.TimeFormat(&ops.Status.StartTimestamp), util.TimeFormat(&ops.Status.CompletionTimestamp))
+	comps := make([]string, 0, len(ops.Status.Components))
+	for name := range ops.Status.Components {
+		comps = append(comps, name)
+	}
+	sort.Strings(comps)
+	for _, name := range comps {
+		comp := ops.Status.Components[name]
+		fmt.Fprintf(b, "\nComponent %s: %s\n", name, comp.Phase)
+		for _, p := range comp.ProgressDetails {
+			fmt.Fprintf(b, "  %s/%s: %s %s\n", p.ObjectKey, p.Group, p.Status, p.Message)
+		}
+	}
+	if len(ops.Status.Conditions) > 0 {
+		fmt.Fprintln(b, "\nConditions:")
+		for _, c := range ops.Status.Conditions {
+			fmt.Fprintf(b, "  %s %s %s: %s\n", util.TimeFormat(&c.LastTransitionTime), c.Type, c.Status, c.Message)
+		}
+	}
+	return b.String()
+}
+
+// newWatchOpsOptions creates the options of the OpsRequest triggered in the terminal UI, its output
+// is discarded to keep the UI clean.
+func (o *WatchOptions) newWatchOpsOptions(opsType appsv1alpha1.OpsType) (*OperationsOptions, error) {
+	ops := newBaseOperationsOptions(o.Factory, genericiooptions.IOStreams{In: o.In, Out: io.Discard, ErrOut: io.Discard}, opsType, false)
+	if err := ops.CreateOptions.Complete(); err != nil {
+		return nil, err
+	}
+	ops.Dynamic = o.Dynamic
+	ops.Client = o.Client
+	ops.Namespace = o.Namespace
+	ops.Name = o.Name
+	ops.Format = printer.YAML
+	ops.Quiet = true
+	ops.autoApprove = true
+	return ops, nil
+}
+
+func (o *WatchOptions) restart(component string) error {
+	ops, err := o.newWatchOpsOptions(appsv1alpha1.RestartType)
+	if err != nil {
+		return err
+	}
+	ops.ComponentNames = []string{component}
+	return o.createOps(ops)
+}
+
+func (o *WatchOptions) promote(component, instance string) error {
+	ops, err := o.newWatchOpsOptions(appsv1alpha1.SwitchoverType)
+	if err != nil {
+		return err
+	}
+	ops.Component = component
+	ops.Instance = instance
+	return o.createOps(ops)
+}
+
+func (o *WatchOptions) createOps(ops *OperationsOptions) error {
+	if err := ops.Validate(); err != nil {
+		return err
+	}
+	if err := ops.Run(); err != nil {
+		return err
+	}
+	o.message = fmt.Sprintf("OpsRequest %s is created", ops.CreateOptions.Name)
+	return nil
+}
+
+func (o *WatchOptions) instanceRows() [][]string {
+	rows := [][]string{{"COMPONENT", "INSTANCE", "ROLE", "STATUS", "NODE", "CREATED-TIME"}}
+	for _, ins := range o.instances {
+		rows = append(rows, []string{ins.Component, ins.Name, ins.Role, ins.Status, ins.Node, ins.CreatedTime})
+	}
+	return rows
+}
+
+func (o *WatchOptions) opsRows() [][]string {
+	rows := [][]string{{"NAME", "TYPE", "COMPONENT", "STATUS", "PROGRESS", "CREATED-TIME"}}
+	for _, ops := range o.opsList {
+		rows = append(rows, []string{ops.Name, string(ops.Spec.Type), getComponentNameFromOps(ops),
+			string(ops.Status.Phase), ops.Status.Progress, util.TimeFormat(&ops.CreationTimestamp)})
+	}
+	return rows
+}
+
+func (o *WatchOptions) eventRows() []string {
+	rows := make([]string, 0, len(o.events))
+	for _, e := range o.events {
+		rows = append(rows, fmt.Sprintf("%s  %s  %s  %s", util.GetEventTimeStr(e), e.Reason, util.GetEventObject(e), e.Message))
+	}
+	return rows
+}
+
+func (o *WatchOptions) draw() {
+	termWidth, termHeight := ui.TerminalDimensions()
+
+	header := widgets.NewParagraph()
+	header.Border = false
+	header.TextStyle.Modifier = ui.ModifierBold
+	header.Text = fmt.Sprintf("Cluster: %s  Namespace: %s  Status: %s", o.Name, o.Namespace, o.objs.Cluster.Status.Phase)
+	header.SetRect(0, 0, termWidth, 1)
+
+	paneHeight := (termHeight - 2) / 3
+	instances := o.newTable("Instances", o.instanceRows(), o.focus == instancePane, o.selected[instancePane])
+	instances.SetRect(0, 1, termWidth, 1+paneHeight)
+	opsTable := o.newTable("OpsRequests", o.opsRows(), o.focus == opsPane, o.selected[opsPane])
+	opsTable.SetRect(0, 1+paneHeight, termWidth, 1+2*paneHeight)
+
+	events := widgets.NewList()
+	events.Title = "Warning Events"
+	events.Rows = o.eventRows()
+	events.WrapText = false
+	events.SetRect(0, 1+2*paneHeight, termWidth, termHeight-1)
+
+	footer := widgets.NewParagraph()
+	footer.Border = false
+	switch {
+	case o.pending != nil:
+		footer.Text = o.pending.prompt
+		footer.TextStyle.Fg = ui.ColorYellow
+	case o.message != "":
+		footer.Text = o.message
+	default:
+		footer.Text = "[q] quit    [tab] switch pane    [↑][↓] select    [l] logs    [d] describe ops    [r] restart    [p] promote"
+	}
+	footer.SetRect(0, termHeight-1, termWidth, termHeight)
+	ui.Render(header, instances, opsTable, events, footer)
+
+	if o.popup != "" {
+		popup := widgets.NewParagraph()
+		popup.Title = o.popupTitle + " ([esc] close)"
+		// the popup keeps at least one line of text on a small terminal
+		height := max(termHeight-4, 3)
+		popup.Text = popupTail(o.popup, height-2)
+		popup.SetRect(2, 2, termWidth-2, 2+height)
+		ui.Render(popup)
+	}
+}
+
+// popupTail returns the last lines of the text which fit into the popup.
+func popupTail(text string, lines int) string {
+	all := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(all) <= lines {
+		return text
+	}
+	return strings.Join(all[len(all)-lines:], "\n")
+}
+
+func (o *WatchOptions) newTable(title string, rows [][]string, focused bool, selected int) *widgets.Table {
+	tbl := widgets.NewTable()
+	tbl.Title = title
+	tbl.Rows = rows
+	tbl.RowSeparator = false
+	tbl.FillRow = true
+	tbl.TextAlignment = ui.AlignLeft
+	tbl.RowStyles[0] = ui.NewStyle(ui.ColorWhite, ui.ColorClear, ui.ModifierBold)
+	if focused {
+		tbl.BorderStyle.Fg = ui.ColorCyan
+		if len(rows) > 1 {
+			tbl.RowStyles[selected+1] = ui.NewStyle(ui.ColorCyan, ui.ColorClear, ui.ModifierReverse)
+		}
+	}
+	return tbl
+}
@@ -0,0 +1,141 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cluster
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	clientfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	"github.com/apecloud/kubeblocks/pkg/constant"
+
+	"github.com/apecloud/kbcli/pkg/testing"
+	"github.com/apecloud/kbcli/pkg/types"
+)
+
+var _ = Describe("cluster watch", func() {
+	var (
+		streams genericiooptions.IOStreams
+		tf      *cmdtesting.TestFactory
+		o       *WatchOptions
+	)
+
+	BeforeEach(func() {
+		streams, _, _, _ = genericiooptions.NewTestIOStreams()
+		tf = cmdtesting.NewTestFactory().WithNamespace(testing.Namespace)
+		tf.Client = &clientfake.RESTClient{}
+
+		ops := &appsv1alpha1.OpsRequest{}
+		ops.Name = "test-restart"
+		ops.Namespace = testing.Namespace
+		ops.Labels = map[string]string{constant.AppInstanceLabelKey: testing.ClusterName}
+		ops.Spec.ClusterRef = testing.ClusterName
+		ops.Spec.Type = appsv1alpha1.RestartType
+		ops.Spec.RestartList = []appsv1alpha1.ComponentOps{{ComponentName: testing.ComponentName}}
+		ops.Status.Phase = appsv1alpha1.OpsRunningPhase
+		ops.Status.Progress = "1/3"
+		tf.FakeDynamicClient = testing.FakeDynamicClient(testing.FakeCluster(testing.ClusterName, testing.Namespace), ops)
+
+		o = &WatchOptions{Factory: tf, IOStreams: streams}
+		Expect(o.Complete([]string{testing.ClusterName})).Should(Succeed())
+		o.Client = testing.FakeClientSet(testing.FakePods(3, testing.Namespace, testing.ClusterName))
+		Expect(o.refresh()).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		tf.Cleanup()
+	})
+
+	It("watch command", func() {
+		Expect(NewWatchCmd(tf, streams)).ShouldNot(BeNil())
+		Expect((&WatchOptions{Factory: tf}).Complete(nil)).Should(HaveOccurred())
+	})
+
+	It("build the rows of the panes", func() {
+		Expect(o.instanceRows()).Should(HaveLen(4))
+		Expect(o.instanceRows()[1][2]).Should(Equal("leader"))
+		opsRows := o.opsRows()
+		Expect(opsRows).Should(HaveLen(2))
+		Expect(opsRows[1][0]).Should(Equal("test-restart"))
+		Expect(opsRows[1][2]).Should(Equal(testing.ComponentName))
+		Expect(opsRows[1][4]).Should(Equal("1/3"))
+	})
+
+	It("filter the events of the cluster and its instances", func() {
+		newEvent := func(kind, name string) *corev1.Event {
+			return &corev1.Event{InvolvedObject: corev1.ObjectReference{Kind: kind, Name: name, Namespace: testing.Namespace}}
+		}
+		Expect(o.isClusterEvent(newEvent(types.KindCluster, testing.ClusterName))).Should(BeTrue())
+		Expect(o.isClusterEvent(newEvent("Pod", o.instances[0].Name))).Should(BeTrue())
+		Expect(o.isClusterEvent(newEvent(types.KindCluster, "other-cluster"))).Should(BeFalse())
+		Expect(o.isClusterEvent(newEvent("Pod", "other-cluster-mysql-0"))).Should(BeFalse())
+		Expect(o.isClusterEvent(newEvent("Node", "node-1"))).Should(BeFalse())
+	})
+
+	It("fit the tail of the text into the popup", func() {
+		Expect(popupTail("a\nb\nc\n", 2)).Should(Equal("b\nc"))
+		Expect(popupTail("a\nb\n", 2)).Should(Equal("a\nb\n"))
+		Expect(popupTail("a\nb\nc", 1)).Should(Equal("c"))
+	})
+
+	It("handle the keys", func() {
+		Expect(o.handleKey("<Down>")).Should(BeFalse())
+		Expect(o.selected[instancePane]).Should(Equal(1))
+		Expect(o.handleKey("<Up>")).Should(BeFalse())
+		Expect(o.handleKey("<Up>")).Should(BeFalse())
+		Expect(o.selected[instancePane]).Should(Equal(2))
+
+		By("describe an OpsRequest")
+		o.handleKey("d")
+		Expect(o.popup).Should(BeEmpty())
+		o.handleKey("<Tab>")
+		Expect(o.focus).Should(Equal(opsPane))
+		o.handleKey("d")
+		Expect(o.popup).Should(ContainSubstring("Progress: 1/3"))
+		Expect(o.handleKey("q")).Should(BeFalse())
+		Expect(o.popup).Should(BeEmpty())
+
+		By("cancel an action")
+		o.handleKey("<Tab>")
+		o.handleKey("r")
+		Expect(o.pending).ShouldNot(BeNil())
+		o.handleKey("n")
+		Expect(o.pending).Should(BeNil())
+		Expect(o.message).Should(Equal("Canceled"))
+
+		Expect(o.handleKey("q")).Should(BeTrue())
+	})
+
+	It("restart the component of the selected instance", func() {
+		o.handleKey("r")
+		o.handleKey("y")
+		Expect(o.message).Should(ContainSubstring("is created"))
+		opsList, err := tf.FakeDynamicClient.Resource(types.OpsGVR()).Namespace(testing.Namespace).List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(opsList.Items).Should(HaveLen(2))
+	})
+})
@@ -66,6 +66,22 @@ var (
 		# list all backups of specified cluster
 		kbcli dp list-backups --cluster mycluster
 	`)
+
+	downloadBackupExample = templates.Examples(`
+		# download the files of a backup from its backup repo to the directory ./mybackup
+		kbcli dp download-backup mybackup
+
+		# download the files of a backup to the specified directory
+		kbcli dp download-backup mybackup -o ./archive/mybackup
+	`)
+
+	uploadBackupExample = templates.Examples(`
+		# upload the downloaded backup to the backup repo myrepo, and create the backup in the current namespace
+		kbcli dp upload-backup ./mybackup --repo myrepo
+
+		# upload the downloaded backup to the default backup repo with another name
+		kbcli dp upload-backup ./mybackup --name mybackup-copy
+	`)
 )
 
 func newBackupCommand(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
@@ -198,3 +214,56 @@ func newListBackupCommand(f cmdutil.Factory, streams genericiooptions.IOStreams)
 
 	return cmd
 }
+
+func newDownloadBackupCommand(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := cluster.NewDownloadBackupOptions(f, streams)
+	cmd := &cobra.Command{
+		Use:   "download-backup NAME",
+		Short: "Download the files of a backup from its backup repo to the local disk.",
+		Long: templates.LongDesc(`
+			Download the files of a backup from its backup repo to the local disk. A short-lived pod accessing the
+			backup repo is started in the namespace of the backup, and the files are streamed by the exec channel and
+			verified by their SHA256 checksums. The files are saved in the data directory, along with the manifest
+			backup.json which is used to upload the backup.`),
+		Example:           downloadBackupExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.BackupGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "output", "o", "", "The local directory to save the backup, defaults to the backup name")
+	cmd.Flags().StringVar(&o.Image, "image", o.Image, "The image of the pod which accesses the backup repo, defaults to the tools image of the installed KubeBlocks")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", o.Timeout, "Time to wait for the pod which accesses the backup repo to be running")
+	return cmd
+}
+
+func newUploadBackupCommand(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := cluster.NewUploadBackupOptions(f, streams)
+	cmd := &cobra.Command{
+		Use:   "upload-backup DIR",
+		Short: "Upload a downloaded backup to a backup repo.",
+		Long: templates.LongDesc(`
+			Upload a backup downloaded by "kbcli dp download-backup" to a backup repo. The files are verified by their
+			SHA256 checksums before and while uploading, then a completed backup referring to the uploaded files is
+			created in the current namespace, so it can be restored like the source backup. The created backup refers to
+			the backup policy kbcli-uploaded-backup, which is created by kbcli with the action set of the same name if it
+			does not exist, and the backup controller leaves the backups of it to kbcli instead of running a backup for them.
+			The labels of the source backup are not kept, so the uploaded backup does not belong to the source cluster.`),
+		Example: uploadBackupExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			cmdutil.CheckErr(o.Complete(args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVar(&o.Repo, "repo", "", "The backup repo to upload the backup to, defaults to the default backup repo")
+	cmd.Flags().StringVar(&o.Name, "name", "", "The name of the uploaded backup, defaults to the name of the downloaded backup")
+	cmd.Flags().StringVar(&o.Image, "image", o.Image, "The image of the pod which accesses the backup repo, defaults to the tools image of the installed KubeBlocks")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", o.Timeout, "Time to wait for the pod which accesses the backup repo to be running")
+	util.CheckErr(cmd.RegisterFlagCompletionFunc("repo", util.ResourceNameCompletionFunc(f, types.BackupRepoGVR())))
+	return cmd
+}
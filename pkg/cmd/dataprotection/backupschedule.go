@@ -0,0 +1,182 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package dataprotection
+
+import (
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/templates"
+
+	"github.com/apecloud/kbcli/pkg/action"
+	"github.com/apecloud/kbcli/pkg/cmd/cluster"
+	"github.com/apecloud/kbcli/pkg/printer"
+	"github.com/apecloud/kbcli/pkg/types"
+	"github.com/apecloud/kbcli/pkg/util"
+)
+
+var (
+	listBackupScheduleExample = templates.Examples(`
+		# list all backup schedules with their next run times
+		kbcli dp backup-schedule list
+
+		# list the backup schedules of the specified cluster
+		kbcli dp backup-schedule list --cluster mycluster
+	`)
+
+	describeBackupScheduleExample = templates.Examples(`
+		# describe a backup schedule and the backups it produced
+		kbcli dp backup-schedule describe mycluster-mysql-backup-schedule
+	`)
+
+	enableBackupScheduleExample = templates.Examples(`
+		# enable the specified backup method of a backup schedule
+		kbcli dp backup-schedule enable mycluster-mysql-backup-schedule --method xtrabackup
+
+		# enable all the backup methods of a backup schedule
+		kbcli dp backup-schedule enable mycluster-mysql-backup-schedule
+	`)
+
+	disableBackupScheduleExample = templates.Examples(`
+		# disable the specified backup method of a backup schedule
+		kbcli dp backup-schedule disable mycluster-mysql-backup-schedule --method xtrabackup
+	`)
+
+	updateBackupScheduleExample = templates.Examples(`
+		# back up at 18:00 UTC every day and keep the backups for 7 days
+		kbcli dp backup-schedule update mycluster-mysql-backup-schedule --method xtrabackup --cron "0 18 * * *" --retention-period 7d
+
+		# start the missed backups in 30 minutes at most
+		kbcli dp backup-schedule update mycluster-mysql-backup-schedule --starting-deadline-minutes 30
+	`)
+)
+
+func newBackupScheduleCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "backup-schedule COMMAND",
+		Short:   "Manage the backup schedules.",
+		Aliases: []string{"bs"},
+	}
+	cmd.AddCommand(
+		newListBackupScheduleCmd(f, streams),
+		newDescribeBackupScheduleCmd(f, streams),
+		newEnableBackupScheduleCmd(f, streams, true),
+		newEnableBackupScheduleCmd(f, streams, false),
+		newUpdateBackupScheduleCmd(f, streams),
+	)
+	return cmd
+}
+
+func newListBackupScheduleCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := action.NewListOptions(f, streams, types.BackupScheduleGVR())
+	clusterName := ""
+	cmd := &cobra.Command{
+		Use:               "list",
+		Short:             "List backup schedules with their next run times and the last backups.",
+		Aliases:           []string{"ls"},
+		Example:           listBackupScheduleExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.BackupScheduleGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			if clusterName != "" {
+				o.LabelSelector = util.BuildLabelSelectorByNames(o.LabelSelector, []string{clusterName})
+			}
+			o.Names = args
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			util.CheckErr(o.Complete())
+			util.CheckErr(cluster.PrintBackupScheduleList(*o))
+		},
+	}
+	cmd.Flags().StringVar(&clusterName, "cluster", "", "The cluster name")
+	o.AddFlags(cmd)
+	util.RegisterClusterCompletionFunc(cmd, f)
+	return cmd
+}
+
+func newDescribeBackupScheduleCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &cluster.DescribeBackupScheduleOptions{Factory: f, IOStreams: streams}
+	cmd := &cobra.Command{
+		Use:               "describe NAME",
+		Short:             "Describe a backup schedule and the backups it produced.",
+		Aliases:           []string{"desc"},
+		Example:           describeBackupScheduleExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.BackupScheduleGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			util.CheckErr(o.Complete(args))
+			util.CheckErr(o.Run())
+		},
+	}
+	return cmd
+}
+
+func newEnableBackupScheduleCmd(f cmdutil.Factory, streams genericiooptions.IOStreams, enabled bool) *cobra.Command {
+	o := &cluster.UpdateBackupScheduleOptions{Factory: f, IOStreams: streams, Enabled: &enabled}
+	use, short, example := "enable NAME", "Enable the backup methods of a backup schedule.", enableBackupScheduleExample
+	if !enabled {
+		use, short, example = "disable NAME", "Disable the backup methods of a backup schedule.", disableBackupScheduleExample
+	}
+	cmd := &cobra.Command{
+		Use:               use,
+		Short:             short,
+		Example:           example,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.BackupScheduleGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			util.CheckErr(o.Complete(args))
+			util.CheckErr(o.Validate())
+			util.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVar(&o.Method, "method", "", "The backup method to enable or disable, all the backup methods of the schedule if not specified")
+	cmd.Flags().BoolVar(&o.Force, "force", false, "Update the backup method even if it is overridden by the backup settings of the cluster")
+	return cmd
+}
+
+func newUpdateBackupScheduleCmd(f cmdutil.Factory, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &cluster.UpdateBackupScheduleOptions{Factory: f, IOStreams: streams}
+	var startingDeadlineMinutes int64
+	cmd := &cobra.Command{
+		Use:   "update NAME",
+		Short: "Update the cron expression, retention period and starting deadline of a backup schedule.",
+		Long: templates.LongDesc(`
+			Update the cron expression and retention period of a backup method, and the starting deadline of a backup
+			schedule. The backup method is required if the schedule has multiple backup methods. Updating the backup
+			method set in the backup settings of the cluster is refused unless --force is specified, since the cluster
+			overrides its enabled, cron expression and retention period when it is updated, please update it by
+			"kbcli cluster update" instead.`),
+		Example:           updateBackupScheduleExample,
+		ValidArgsFunction: util.ResourceNameCompletionFunc(f, types.BackupScheduleGVR()),
+		Run: func(cmd *cobra.Command, args []string) {
+			if cmd.Flags().Changed("starting-deadline-minutes") {
+				o.StartingDeadlineMinutes = &startingDeadlineMinutes
+			}
+			cmdutil.BehaviorOnFatal(printer.FatalWithRedColor)
+			util.CheckErr(o.Complete(args))
+			util.CheckErr(o.Validate())
+			util.CheckErr(o.Run())
+		},
+	}
+	cmd.Flags().StringVar(&o.Method, "method", "", "The backup method to update")
+	cmd.Flags().StringVar(&o.CronExpression, "cron", "", "The cron expression of the backup method, the timezone is in UTC. see https://en.wikipedia.org/wiki/Cron.")
+	cmd.Flags().StringVar(&o.RetentionPeriod, "retention-period", "", "The retention period of the backups produced by the backup method, supported values: [1y, 1mo, 1d, 1h, 1m] or combine them [1y1mo1d1h1m]")
+	cmd.Flags().Int64Var(&startingDeadlineMinutes, "starting-deadline-minutes", 0, "The deadline in minutes for starting the backup if it misses the scheduled time, 0 removes the deadline")
+	cmd.Flags().BoolVar(&o.Force, "force", false, "Update the backup method even if it is overridden by the backup settings of the cluster")
+	return cmd
+}
@@ -36,9 +36,12 @@ func NewDataProtectionCmd(f cmdutil.Factory, streams genericiooptions.IOStreams)
 		newBackupDeleteCommand(f, streams),
 		newBackupDescribeCommand(f, streams),
 		newListBackupCommand(f, streams),
+		newDownloadBackupCommand(f, streams),
+		newUploadBackupCommand(f, streams),
 		newRestoreCommand(f, streams),
 		newListBackupPolicyCmd(f, streams),
 		newDescribeBackupPolicyCmd(f, streams),
+		newBackupScheduleCmd(f, streams),
 	)
 	return cmd
 }
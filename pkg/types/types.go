@@ -46,6 +46,9 @@ const (
 	// CliLogDir defines kbcli log dir name
 	CliLogDir = "logs"
 
+	// CliTuneProfilesDir defines kbcli user-defined parameter tuning profiles dir name
+	CliTuneProfilesDir = "tune_profiles"
+
 	// CliHomeEnv defines kbcli home system env
 	CliHomeEnv = "KBCLI_HOME"
 
@@ -121,6 +124,7 @@ const (
 	KindOps                             = "OpsRequest"
 	KindBackupSchedule                  = "BackupSchedule"
 	KindBackupPolicyTemplate            = "BackupPolicyTemplate"
+	KindActionSet                       = "ActionSet"
 	KindStatefulSet                     = "StatefulSet"
 	KindDeployment                      = "Deployment"
 	KindRSM                             = "ReplicatedStateMachine"
@@ -151,6 +155,13 @@ const (
 	ReloadConfigMapAnnotationKey = "kubeblocks.io/reload-configmap" // mark an annotation to load configmap
 
 	KBVersionValidateAnnotationKey = "addon.kubeblocks.io/kubeblocks-version"
+
+	// ConfigParametersAnnotationKey declares the component config parameters in a cluster manifest used by
+	// 'kbcli cluster apply', the value is a JSON object like {"mysql":{"max_connections":"2000"}}
+	ConfigParametersAnnotationKey = "kubeblocks.io/config-parameters"
+
+	// BackupVerificationAnnotationKey records the result of 'kbcli cluster verify-backup' on the backup
+	BackupVerificationAnnotationKey = "dataprotection.kubeblocks.io/verification"
 )
 
 // DataProtection API group
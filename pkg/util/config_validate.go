@@ -0,0 +1,277 @@
+/*
+Copyright (C) 2022-2023 ApeCloud Co., Ltd
+
+This file is part of KubeBlocks project
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
+	cfgcm "github.com/apecloud/kubeblocks/pkg/configuration/config_manager"
+	"github.com/apecloud/kubeblocks/pkg/configuration/core"
+	"github.com/apecloud/kubeblocks/pkg/configuration/openapi"
+	"github.com/apecloud/kubeblocks/pkg/controllerutil"
+)
+
+// ParameterClassification describes how an updated parameter is applied.
+type ParameterClassification string
+
+const (
+	DynamicParameter   ParameterClassification = "dynamic"
+	StaticParameter    ParameterClassification = "static"
+	ImmutableParameter ParameterClassification = "immutable"
+)
+
+// ClassifyParameter classifies the parameter in the same way as the reconfiguring controller,
+// a parameter not declared as dynamic is static unless the ConfigConstraint only declares the
+// static parameters.
+func ClassifyParameter(cc *appsv1alpha1.ConfigConstraintSpec, param string) ParameterClassification {
+	contains := func(params []string) bool {
+		for _, p := range params {
+			if p == param {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case contains(cc.ImmutableParameters):
+		return ImmutableParameter
+	case !cfgcm.IsSupportReload(cc.ReloadOptions), contains(cc.StaticParameters):
+		return StaticParameter
+	case contains(cc.DynamicParameters):
+		return DynamicParameter
+	case len(cc.StaticParameters) > 0 && len(cc.DynamicParameters) == 0:
+		return DynamicParameter
+	default:
+		return StaticParameter
+	}
+}
+
+// ParameterIssueType is the type of the issue found by ValidateParameters.
+type ParameterIssueType string
+
+const (
+	UnknownParameterIssue   ParameterIssueType = "unknown"
+	TypeMismatchIssue       ParameterIssueType = "type"
+	OutOfRangeIssue         ParameterIssueType = "range"
+	InvalidValueIssue       ParameterIssueType = "invalid"
+	ImmutableParameterIssue ParameterIssueType = "immutable"
+	StaticParameterIssue    ParameterIssueType = "static"
+)
+
+// ParameterIssue is an issue of an updated parameter, the static parameters are reported
+// as warnings because they are valid but require restarting the instances.
+type ParameterIssue struct {
+	Parameter string             `json:"parameter"`
+	Value     string             `json:"value"`
+	Type      ParameterIssueType `json:"type"`
+	Message   string             `json:"message"`
+}
+
+// IsWarning returns true if the issue does not prevent the parameter from being updated.
+func (i ParameterIssue) IsWarning() bool {
+	return i.Type == StaticParameterIssue
+}
+
+// defaultValidatedConfigFile is the config file name used to validate the parameters if not specified,
+// the config constraint validates every config file with the same schema unless the file is selected.
+const defaultValidatedConfigFile = "config"
+
+// ValidateParameters validates the updated parameters against the ConfigConstraint without a cluster,
+// it reports the unknown and immutable parameters, the values mismatching the type or out of the range
+// defined by the schema, the values refused by the CUE validation, and the static parameters.
+// The parameters whose values are nil are deleted from the config file and only checked to be known and mutable.
+func ValidateParameters(cc *appsv1alpha1.ConfigConstraintSpec, configFile string, params map[string]*string) ([]ParameterIssue, error) {
+	if cc.FormatterConfig == nil {
+		return nil, core.MakeError("the config constraint does not support reconfiguring, the formatter config is not defined")
+	}
+	if configFile == "" {
+		configFile = defaultValidatedConfigFile
+	}
+	schema, err := getParametersSchema(cc)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var issues []ParameterIssue
+	for _, key := range keys {
+		value := params[key]
+		issue := ParameterIssue{Parameter: key}
+		if value != nil {
+			issue.Value = *value
+		}
+		addIssue := func(issueType ParameterIssueType, format string, a ...interface{}) {
+			issue.Type = issueType
+			issue.Message = fmt.Sprintf(format, a...)
+			issues = append(issues, issue)
+		}
+
+		classification := ClassifyParameter(cc, key)
+		if classification == ImmutableParameter {
+			addIssue(ImmutableParameterIssue, "parameter is immutable and can not be modified")
+			continue
+		}
+		var prop *apiextv1.JSONSchemaProps
+		if schema != nil {
+			p, ok := schema.Properties[key]
+			if !ok {
+				addIssue(UnknownParameterIssue, "parameter is not defined by the config constraint")
+				continue
+			}
+			prop = &p
+		}
+		if value != nil {
+			if issueType, msg := validateSchemaValue(prop, *value); msg != "" {
+				addIssue(issueType, msg)
+				continue
+			}
+			if _, err = controllerutil.MergeAndValidateConfigs(*cc, map[string]string{configFile: ""}, []string{configFile}, []core.ParamPairs{{
+				Key:           configFile,
+				UpdatedParams: map[string]interface{}{key: value},
+			}}); err != nil {
+				addIssue(InvalidValueIssue, "value does not satisfy the config constraint: %s", err.Error())
+				continue
+			}
+		}
+		if classification == StaticParameter {
+			addIssue(StaticParameterIssue, "parameter is static, updating it restarts the instances")
+		}
+	}
+	return issues, nil
+}
+
+// GetParameterTypes returns the types of the parameters defined by the schema of the ConfigConstraint,
+// it returns nil if the config constraint does not define the configuration schema.
+func GetParameterTypes(cc *appsv1alpha1.ConfigConstraintSpec) (map[string]string, error) {
+	schema, err := getParametersSchema(cc)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	types := make(map[string]string, len(schema.Properties))
+	for name, prop := range schema.Properties {
+		types[name] = prop.Type
+	}
+	return types, nil
+}
+
+// getParametersSchema returns the OpenAPI schema of the parameters, it returns nil if the
+// config constraint does not define the configuration schema.
+func getParametersSchema(cc *appsv1alpha1.ConfigConstraintSpec) (*apiextv1.JSONSchemaProps, error) {
+	var err error
+	if cc.ConfigurationSchema == nil {
+		return nil, nil
+	}
+
+	schema := cc.ConfigurationSchema.DeepCopy()
+	if schema.Schema == nil {
+		schema.Schema, err = openapi.GenerateOpenAPISchema(schema.CUE, cc.CfgSchemaTopLevelName)
+		if err != nil {
+			return nil, err
+		}
+		if schema.Schema == nil {
+			return nil, nil
+		}
+	}
+	spec, ok := schema.Schema.Properties["spec"]
+	if !ok {
+		return nil, nil
+	}
+	return &spec, nil
+}
+
+// validateSchemaValue validates the value against the type, enum, range and pattern defined by the schema
+// of the parameter, it returns an empty message if the value is valid.
+func validateSchemaValue(prop *apiextv1.JSONSchemaProps, value string) (ParameterIssueType, string) {
+	if prop == nil {
+		return "", ""
+	}
+	var number float64
+	switch prop.Type {
+	case "integer":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return TypeMismatchIssue, fmt.Sprintf("value %q is not an integer", value)
+		}
+		number = float64(i)
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return TypeMismatchIssue, fmt.Sprintf("value %q is not a number", value)
+		}
+		number = f
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return TypeMismatchIssue, fmt.Sprintf("value %q is not a boolean", value)
+		}
+	}
+
+	if len(prop.Enum) > 0 {
+		var allowed []string
+		for _, e := range prop.Enum {
+			var v interface{}
+			if err := json.Unmarshal(e.Raw, &v); err != nil {
+				continue
+			}
+			allowed = append(allowed, fmt.Sprint(v))
+		}
+		sort.Strings(allowed)
+		matched := false
+		for _, v := range allowed {
+			if v == value {
+				matched = true
+				break
+			}
+			if f, err := strconv.ParseFloat(v, 64); err == nil && (prop.Type == "integer" || prop.Type == "number") && f == number {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return OutOfRangeIssue, fmt.Sprintf("value %q is not one of %v", value, allowed)
+		}
+	}
+
+	if prop.Type == "integer" || prop.Type == "number" {
+		if m := prop.Minimum; m != nil && (number < *m || (prop.ExclusiveMinimum && number == *m)) {
+			return OutOfRangeIssue, fmt.Sprintf("value %s is out of range, the minimum is %v", value, *m)
+		}
+		if m := prop.Maximum; m != nil && (number > *m || (prop.ExclusiveMaximum && number == *m)) {
+			return OutOfRangeIssue, fmt.Sprintf("value %s is out of range, the maximum is %v", value, *m)
+		}
+	}
+	if prop.Pattern != "" {
+		if re, err := regexp.Compile(prop.Pattern); err == nil && !re.MatchString(value) {
+			return InvalidValueIssue, fmt.Sprintf("value %q does not match the pattern %s", value, prop.Pattern)
+		}
+	}
+	return "", ""
+}
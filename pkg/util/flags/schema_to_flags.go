@@ -78,16 +78,7 @@ func BuildFlagsWithOpenAPISchema(cmd *cobra.Command,
 	if openAPIV3Schema == nil {
 		return fmt.Errorf("can not found openAPIV3Schema")
 	}
-	// Convert apiextensionsv1.JSONSchemaProps to spec.Schema
-	schemaData, err := json.Marshal(openAPIV3Schema)
-	if err != nil {
-		return err
-	}
-	schema := &spec.Schema{}
-	if err = json.Unmarshal(schemaData, schema); err != nil {
-		return err
-	}
-	if err = BuildFlagsBySchema(cmd, schema); err != nil {
+	if err = BuildFlagsByOpenAPISchema(cmd, openAPIV3Schema); err != nil {
 		return err
 	}
 	// Parse dynamic flags
@@ -102,3 +93,17 @@ func BuildFlagsWithOpenAPISchema(cmd *cobra.Command,
 	}
 	return cmd.ValidateRequiredFlags()
 }
+
+// BuildFlagsByOpenAPISchema builds the flags from openAPIV3Schema properties without parsing them.
+func BuildFlagsByOpenAPISchema(cmd *cobra.Command, openAPIV3Schema *apiextensionsv1.JSONSchemaProps) error {
+	// Convert apiextensionsv1.JSONSchemaProps to spec.Schema
+	schemaData, err := json.Marshal(openAPIV3Schema)
+	if err != nil {
+		return err
+	}
+	schema := &spec.Schema{}
+	if err = json.Unmarshal(schemaData, schema); err != nil {
+		return err
+	}
+	return BuildFlagsBySchema(cmd, schema)
+}
@@ -72,7 +72,6 @@ import (
 
 	appsv1alpha1 "github.com/apecloud/kubeblocks/apis/apps/v1alpha1"
 	"github.com/apecloud/kubeblocks/pkg/configuration/core"
-	"github.com/apecloud/kubeblocks/pkg/configuration/openapi"
 	cfgutil "github.com/apecloud/kubeblocks/pkg/configuration/util"
 	"github.com/apecloud/kubeblocks/pkg/constant"
 	viper "github.com/apecloud/kubeblocks/pkg/viperx"
@@ -589,11 +588,7 @@ func enableReconfiguring(component *appsv1alpha1.ClusterComponentDefinition) boo
 
 // IsSupportReconfigureParams checks whether all updated parameters belong to config template parameters.
 func IsSupportReconfigureParams(tpl appsv1alpha1.ComponentConfigSpec, values map[string]*string, cli dynamic.Interface) (bool, error) {
-	var (
-		err              error
-		configConstraint = appsv1alpha1.ConfigConstraint{}
-	)
-
+	configConstraint := appsv1alpha1.ConfigConstraint{}
 	if err := GetResourceObjectFromGVR(types.ConfigConstraintGVR(), client.ObjectKey{
 		Namespace: "",
 		Name:      tpl.ConfigConstraintRef,
@@ -601,28 +596,28 @@ func IsSupportReconfigureParams(tpl appsv1alpha1.ComponentConfigSpec, values map
 		return false, err
 	}
 
-	if configConstraint.Spec.ConfigurationSchema == nil {
-		return true, nil
+	unknownParameters, err := GetUnknownParameters(&configConstraint.Spec, sets.KeySet(values))
+	if err != nil {
+		return false, err
 	}
+	return len(unknownParameters) == 0, nil
+}
 
-	schema := configConstraint.Spec.ConfigurationSchema.DeepCopy()
-	if schema.Schema == nil {
-		schema.Schema, err = openapi.GenerateOpenAPISchema(schema.CUE, configConstraint.Spec.CfgSchemaTopLevelName)
-		if err != nil {
-			return false, err
-		}
-		if schema.Schema == nil {
-			return true, nil
-		}
+// GetUnknownParameters returns the parameters which are not defined by the configuration schema of the config constraint.
+func GetUnknownParameters(cc *appsv1alpha1.ConfigConstraintSpec, parameters sets.Set[string]) ([]string, error) {
+	schema, err := getParametersSchema(cc)
+	if err != nil || schema == nil {
+		return nil, err
 	}
 
-	schemaSpec := schema.Schema.Properties["spec"]
-	for key := range values {
-		if _, ok := schemaSpec.Properties[key]; !ok {
-			return false, nil
+	var unknownParameters []string
+	for key := range parameters {
+		if _, ok := schema.Properties[key]; !ok {
+			unknownParameters = append(unknownParameters, key)
 		}
 	}
-	return true, nil
+	sort.Strings(unknownParameters)
+	return unknownParameters, nil
 }
 
 func ValidateParametersModified(tpl *appsv1alpha1.ComponentConfigSpec, parameters sets.Set[string], cli dynamic.Interface) (err error) {
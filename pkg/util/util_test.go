@@ -195,6 +195,16 @@ var _ = Describe("util", func() {
 		Expect(len(GVRToString(types.ClusterGVR())) > 0).Should(BeTrue())
 	})
 
+	It("GetParameterTypes", func() {
+		cc := testapps.NewCustomizedObj("resources/mysql-config-constraint.yaml", &appsv1alpha1.ConfigConstraint{})
+		paramTypes, err := GetParameterTypes(&cc.Spec)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(paramTypes).Should(HaveKeyWithValue("automatic_sp_privileges", "string"))
+
+		cc.Spec.ConfigurationSchema = nil
+		Expect(GetParameterTypes(&cc.Spec)).Should(BeNil())
+	})
+
 	It("IsSupportReconfigureParams", func() {
 		const (
 			ccName = "mysql_cc"
@@ -42,6 +42,8 @@ const (
 	kubeblocksAppComponent = "apps"
 	// dataprotectionAppComponent the value of app.kubernetes.io/component label for DataProtection deployment
 	dataprotectionAppComponent = "dataprotection"
+	// kubeblocksToolsImageEnv the env of the KubeBlocks deployment which specifies the tools image
+	kubeblocksToolsImageEnv = "KUBEBLOCKS_TOOLS_IMAGE"
 )
 
 type Version struct {
@@ -91,6 +93,26 @@ func getKubeBlocksVersion(client kubernetes.Interface) (string, error) {
 	return v, nil
 }
 
+// GetKubeBlocksToolsImage gets the tools image used by the installed KubeBlocks, which contains
+// kubectl and the other tools needed by the jobs, so it matches the version of KubeBlocks.
+func GetKubeBlocksToolsImage(client kubernetes.Interface) (string, error) {
+	deploy, err := GetKubeBlocksDeploy(client)
+	if err != nil {
+		return "", err
+	}
+	if deploy == nil {
+		return "", fmt.Errorf("KubeBlocks is not installed, failed to get its tools image")
+	}
+	for _, c := range deploy.Spec.Template.Spec.Containers {
+		for _, env := range c.Env {
+			if env.Name == kubeblocksToolsImageEnv && env.Value != "" {
+				return env.Value, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("the tools image is not found in the KubeBlocks deployment %s/%s", deploy.Namespace, deploy.Name)
+}
+
 // GetK8sVersion gets k8s server version
 func GetK8sVersion(discoveryClient discovery.DiscoveryInterface) (string, error) {
 	if discoveryClient == nil {
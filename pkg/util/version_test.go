@@ -23,6 +23,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/apecloud/kbcli/pkg/testing"
@@ -78,6 +79,21 @@ var _ = Describe("version util", func() {
 		Expect(err).Should(Succeed())
 	})
 
+	It("GetKubeBlocksToolsImage", func() {
+		_, err := GetKubeBlocksToolsImage(testing.FakeClientSet())
+		Expect(err).Should(MatchError(ContainSubstring("not installed")))
+
+		deploy := testing.FakeKBDeploy(kbVersion)
+		deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "manager"}}
+		_, err = GetKubeBlocksToolsImage(testing.FakeClientSet(deploy))
+		Expect(err).Should(MatchError(ContainSubstring("tools image is not found")))
+
+		deploy.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "KUBEBLOCKS_TOOLS_IMAGE", Value: "apecloud/kubeblocks-tools:" + kbVersion}}
+		image, err := GetKubeBlocksToolsImage(testing.FakeClientSet(deploy))
+		Expect(err).Should(Succeed())
+		Expect(image).Should(Equal("apecloud/kubeblocks-tools:" + kbVersion))
+	})
+
 	It("GetK8sVersion when client is nil", func() {
 		v, err := GetK8sVersion(nil)
 		Expect(v).Should(BeEmpty())